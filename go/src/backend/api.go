@@ -12,6 +12,15 @@ const (
     AuthUserRequest
     CardSearchRequest
     CardDetailRequest
+    CreateShareLinkRequest
+    RevokeShareLinkRequest
+    ShareLinksRequest
+    CreateCardListRequest
+    AddCardListEntryRequest
+    CardChangesRequest
+    LegalityChangesRequest
+    PricePreferenceRequest
+    SetPricePreferenceRequest
 )
 
 const (
@@ -20,20 +29,49 @@ const (
     AuthUserResponse
     CardSearchResponse
     CardDetailResponse
+    ShareLinkResponse
+    ShareLinkRevokedResponse
+    ShareLinksResponse
+    CardListResponse
+    CardListEntryResponse
+    CardChangesResponse
+    LegalityChangesResponse
+    // Pushed without a request, whenever an import changes the legality of
+    // a card in the user's decks or collections
+    LegalityChangesNotification
+    PricePreferenceResponse
 )
 
 var requestTypes  = [...]RequestType{
     ApiTypesRequest,
     AuthUserRequest,
     CardSearchRequest,
-    CardDetailRequest}
+    CardDetailRequest,
+    CreateShareLinkRequest,
+    RevokeShareLinkRequest,
+    ShareLinksRequest,
+    CreateCardListRequest,
+    AddCardListEntryRequest,
+    CardChangesRequest,
+    LegalityChangesRequest,
+    PricePreferenceRequest,
+    SetPricePreferenceRequest}
 
 var responseTypes = [...]ResponseType{
     ApiTypesResponse,
     ErrorResponse,
     AuthUserResponse,
     CardSearchResponse,
-    CardDetailResponse}
+    CardDetailResponse,
+    ShareLinkResponse,
+    ShareLinkRevokedResponse,
+    ShareLinksResponse,
+    CardListResponse,
+    CardListEntryResponse,
+    CardChangesResponse,
+    LegalityChangesResponse,
+    LegalityChangesNotification,
+    PricePreferenceResponse}
 
 type RequestMessage struct {
     Type RequestType `json:"type"`
@@ -6,13 +6,13 @@ import "fmt"
 import "io/ioutil"
 import "net/http"
 import "net/url"
-import "log"
+import "log/slog"
+import "logging"
 import "database/sql"
-import _ "github.com/go-sql-driver/mysql"
+import "dbconn"
 import "golang.org/x/crypto/bcrypt"
 
 const (
-    AUTHORIZATION_SERVER = "http://hydra:4445"
     AUTHORIZATION_REQUEST_ENDPOINT_BASE = "/oauth2/auth/requests"
     LOGIN_ENDPOINT = "/login"
     LOGIN_ACCEPT_ENDPOINT = "/login/accept"
@@ -24,6 +24,10 @@ const (
     TOKEN_INTROSPECTION_ENDPOINT = "/oauth2/introspect"
 )
 
+// Logins, consents, logouts and token checks log as their own component, so
+// that they can be turned up without the rest of the backend
+var authLogger = logging.For("auth")
+
 type LoginResult struct {
     Subject string `json:"subject,omitempty"`
     Remember bool `json:"remember,omitempty"`
@@ -151,54 +155,56 @@ func (loginResult LoginResult) String() string {
             loginResult.ErrorDescription)
 }
 
-func checkLoginChallenge(challenge string) (HydraLoginRequest, error) {
+func checkLoginChallenge(logger *slog.Logger, challenge string) (HydraLoginRequest, error) {
     //challenge = strings.Trim(challenge, "\"")
-    log.Printf("Received login challenge %s", challenge)
     // Construct the challenge message to the authorization backend
-    log.Printf("Received login challenge %s", challenge)
     params := url.Values{}
     params.Set("login_challenge", challenge)
     // Send the request to the authorization backend
-    requestUrl := AUTHORIZATION_SERVER + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
+    requestUrl := backendConfig.Auth.AdminURL + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
             LOGIN_ENDPOINT + "?" + params.Encode()
-    log.Printf("Sending login challenge request %s", requestUrl)
+    logger.Debug("Sending login challenge request", "url", logging.RedactURL(requestUrl))
 
     resp, err := http.Get(requestUrl)
     if err != nil {
-        log.Printf("Error sending login request: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error sending login request", "err", err)
         return HydraLoginRequest{}, err
     }
     defer resp.Body.Close()
 
     respBody, err := ioutil.ReadAll(resp.Body)
     if err != nil {
-        log.Printf("Error reading login request response: %s", err)
+        logger.Error("Error reading login request response", "err", err)
         return HydraLoginRequest{}, err
     }
 
     var loginRequest HydraLoginRequest
     err = json.Unmarshal(respBody, &loginRequest)
     if err != nil {
-        log.Printf("Error unmarshalling login request response: %s", err)
+        logger.Error("Error unmarshalling login request response", "err", err)
         return HydraLoginRequest{}, err
     }
 
-    log.Printf("Received login challenge response: %s", loginRequest)
+    logger.Debug("Received login challenge response", "skip", loginRequest.Skip,
+        "subject", loginRequest.Subject, "requested_scope", loginRequest.RequestedScope)
 
     return loginRequest, nil
 }
 
 func checkUserLogin(
+        logger *slog.Logger,
         username string,
         password string,
         loginChallenge string) (bool, string, error) {
     // Get the user record from the DB, if it exists
-    userDB, err := sql.Open("mysql", dbConnStr(LOGIN_DB_USER, LOGIN_DB_PW, USER_DB))
+    userDB, err := dbconn.Open(backendConfig.UserDB)
 	if err != nil {
-        log.Printf("Error connecting to users db: %s", err)
+        logger.Error("Error connecting to users db", "err", err)
         return false, "", err
 	}
 	defer userDB.Close()
+	defer trackDBPool(USER_DB_POOL, userDB)()
 
     res := userDB.QueryRow(`SELECT pw_hash, first_name, last_name
             FROM user_info
@@ -212,15 +218,16 @@ func checkUserLogin(
     var authResponse string
     if err == sql.ErrNoRows {
         // If there's no user with the given username, reject the login request
-        log.Printf("No user found for username %s", username)
-        authSuccessful, authResponse, err = completeLoginRequestWithAuthServer(false,
+        logger.Info("Login failed, no user found", "user_name", username)
+        recordAuthFailure(AUTH_FAILURE_UNKNOWN_USER)
+        authSuccessful, authResponse, err = completeLoginRequestWithAuthServer(logger, false,
             username, loginChallenge)
         if err != nil {
-            log.Printf("Error completing login flow: %s", err)
+            logger.Error("Error completing login flow", "err", err)
             return false, "", err
         }
     } else if err != nil {
-        log.Printf("Error fetching user record from db: %s", err)
+        logger.Error("Error fetching user record from db", "err", err)
         return false, "", err
     } else {
         // If we're here, we have a valid user row from the DB, check the given password
@@ -228,18 +235,21 @@ func checkUserLogin(
         err = bcrypt.CompareHashAndPassword(pwHash, []byte(password))
         if err != nil {
             // Password validation failed, let the login endpoint know
-            authSuccessful, authResponse, err = completeLoginRequestWithAuthServer(false,
+            logger.Info("Login failed, wrong password", "user_name", username)
+            recordAuthFailure(AUTH_FAILURE_BAD_PASSWORD)
+            authSuccessful, authResponse, err = completeLoginRequestWithAuthServer(logger, false,
                 username, loginChallenge)
             if err != nil {
-                log.Printf("Error completing login flow: %s", err)
+                logger.Error("Error completing login flow", "err", err)
                 return false, "", err
             }
         } else {
             // Password validation succeeded, let the login endpoint know
-            authSuccessful, authResponse, err = completeLoginRequestWithAuthServer(true,
+            logger.Info("Login succeeded", "user_name", username)
+            authSuccessful, authResponse, err = completeLoginRequestWithAuthServer(logger, true,
                 username, loginChallenge)
             if err != nil {
-                log.Printf("Error completing login flow: %s", err)
+                logger.Error("Error completing login flow", "err", err)
                 return false, "", err
             }
         }
@@ -249,6 +259,7 @@ func checkUserLogin(
 }
 
 func completeLoginRequestWithAuthServer(
+        logger *slog.Logger,
         loginSuccessful bool,
         username string,
         loginChallenge string) (bool, string, error) {
@@ -260,13 +271,13 @@ func completeLoginRequestWithAuthServer(
     var requestUrl string
     var body LoginResult
     if loginSuccessful {
-        requestUrl = AUTHORIZATION_SERVER + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
+        requestUrl = backendConfig.Auth.AdminURL + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
                 LOGIN_ACCEPT_ENDPOINT + "?" + params.Encode()
         body.Subject = username
         body.Remember = true
         body.RememberFor = 120
     } else {
-        requestUrl = AUTHORIZATION_SERVER + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
+        requestUrl = backendConfig.Auth.AdminURL + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
                 LOGIN_REJECT_ENDPOINT + "?" + params.Encode()
         body.Error = "access_denied"
         body.ErrorDescription = "The Username or Password is incorrect"
@@ -274,15 +285,16 @@ func completeLoginRequestWithAuthServer(
 
     bodyJson, err := json.Marshal(body)
     if err != nil {
-        log.Printf("Error marshalling login JSON body: %s", err)
+        logger.Error("Error marshalling login JSON body", "err", err)
         return false, "", err
     }
-    log.Printf("Sending auth request to server at endpoint %s with body %s",
-        requestUrl, string(bodyJson))
+    logger.Debug("Sending login result to auth server", "url", logging.RedactURL(requestUrl),
+        "subject", body.Subject, "accepted", loginSuccessful)
 
     req, err := http.NewRequest(http.MethodPut, requestUrl, bytes.NewReader(bodyJson))
     if err != nil {
-        log.Printf("Error creating login http request: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error creating login http request", "err", err)
         return false, "", err
     }
     req.Header.Set("Content-Type", "application/json")
@@ -290,23 +302,25 @@ func completeLoginRequestWithAuthServer(
     client := &http.Client{}
     resp, err := client.Do(req)
     if err != nil {
-        log.Printf("Error sending login request to auth server: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error sending login request to auth server", "err", err)
         return false, "", err
     }
     defer resp.Body.Close()
 
     response, err := ioutil.ReadAll(resp.Body)
     if err != nil {
-        log.Printf("Error reading response body: %s", err)
+        logger.Error("Error reading response body", "err", err)
         return false, "", err
     }
+    logger.Debug("Auth server responded", "status", resp.StatusCode)
 
     switch resp.StatusCode {
     case http.StatusOK:
         var completedRequest HydraCompletedRequest
         err = json.Unmarshal(response, &completedRequest)
         if err != nil {
-            log.Printf("Error unmarshalling completedRequest: %s", err)
+            logger.Error("Error unmarshalling completedRequest", "err", err)
             return false, "", err
         }
         return true, completedRequest.RedirectTo, nil
@@ -320,7 +334,7 @@ func completeLoginRequestWithAuthServer(
         var genericError HydraGenericError
         err = json.Unmarshal(response, &genericError)
         if err != nil {
-            log.Printf("Error unmarshalling genericError: %s", err)
+            logger.Error("Error unmarshalling genericError", "err", err)
             return false, "", err
         }
         return false, genericError.ErrorMsg, nil
@@ -328,18 +342,20 @@ func completeLoginRequestWithAuthServer(
 }
 
 func completeConsentRequestWithAuthServer(
+        logger *slog.Logger,
         consentSuccessful bool,
         consentRequest HydraConsentRequest) (bool, string, error) {
 
     // Get the user record from the DB so we can populate extra user info
     // in the consent acceptance (this will later be returned from requests to the
     // openid-connect userinfo endpoint)
-    userDB, err := sql.Open("mysql", dbConnStr(LOGIN_DB_USER, LOGIN_DB_PW, USER_DB))
+    userDB, err := dbconn.Open(backendConfig.UserDB)
 	if err != nil {
-        log.Printf("Error connecting to users db: %s", err)
+        logger.Error("Error connecting to users db", "err", err)
         return false, "", err
 	}
 	defer userDB.Close()
+	defer trackDBPool(USER_DB_POOL, userDB)()
 
     res := userDB.QueryRow(`SELECT first_name, last_name, email
             FROM user_info
@@ -349,7 +365,7 @@ func completeConsentRequestWithAuthServer(
     idToken := IDTokenSessionInfo{Username: consentRequest.Subject}
     err = res.Scan(&idToken.FirstName, &idToken.LastName, &idToken.Email)
     if err != nil {
-        log.Printf("Error retrieving user info record from db: %s", err)
+        logger.Error("Error retrieving user info record from db", "err", err)
         return false, "", err
     }
 
@@ -359,9 +375,10 @@ func completeConsentRequestWithAuthServer(
     // TODO: Implement the consent reject path
     params := url.Values{}
     params.Set("consent_challenge", consentRequest.Challenge)
-    requestUrl := AUTHORIZATION_SERVER + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
+    requestUrl := backendConfig.Auth.AdminURL + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
             CONSENT_ACCEPT_ENDPOINT + "?" + params.Encode()
-    log.Printf("Granting scopes: %v", consentRequest.RequestedScope)
+    logger.Info("Granting scopes", "subject", consentRequest.Subject,
+        "scopes", consentRequest.RequestedScope)
     body := ConsentResult{
         GrantScope: consentRequest.RequestedScope,
         Remember: true,
@@ -370,16 +387,16 @@ func completeConsentRequestWithAuthServer(
 
     bodyJson, err := json.Marshal(body)
     if err != nil {
-        log.Printf("Error marshalling consent JSON body: %s", err)
+        logger.Error("Error marshalling consent JSON body", "err", err)
         return false, "", err
     }
 
-    log.Printf("Sending consent request to server at endpoint %s with body %s",
-            requestUrl, string(bodyJson))
+    logger.Debug("Sending consent to auth server", "url", logging.RedactURL(requestUrl))
 
     req, err := http.NewRequest(http.MethodPut, requestUrl, bytes.NewReader(bodyJson))
     if err != nil {
-        log.Printf("Error creating consent http request: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error creating consent http request", "err", err)
         return false, "", err
     }
     req.Header.Set("Content-Type", "application/json")
@@ -387,25 +404,26 @@ func completeConsentRequestWithAuthServer(
     client := &http.Client{}
     resp, err := client.Do(req)
     if err != nil {
-        log.Printf("Error sending consent request to auth server: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error sending consent request to auth server", "err", err)
         return false, "", err
     }
     defer resp.Body.Close()
 
     respBody, err := ioutil.ReadAll(resp.Body)
     if err != nil {
-        log.Printf("Error reading response body: %s", err)
+        logger.Error("Error reading response body", "err", err)
         return false, "", err
     }
 
-    log.Printf("Auth server response: %s", string(respBody))
+    logger.Debug("Auth server responded", "status", resp.StatusCode)
 
     switch resp.StatusCode {
     case http.StatusOK:
         var completedRequest HydraCompletedRequest
         err = json.Unmarshal(respBody, &completedRequest)
         if err != nil {
-            log.Printf("Error unmarshalling completedRequest: %s", err)
+            logger.Error("Error unmarshalling completedRequest", "err", err)
             return false, "", err
         }
         return true, completedRequest.RedirectTo, nil
@@ -417,27 +435,29 @@ func completeConsentRequestWithAuthServer(
         var genericError HydraGenericError
         err = json.Unmarshal(respBody, &genericError)
         if err != nil {
-            log.Printf("Error unmarshalling genericError: %s", err)
+            logger.Error("Error unmarshalling genericError", "err", err)
             return false, "", err
         }
         return false, genericError.ErrorMsg, nil
     }
 }
 
-func completeLogoutRequestWithAuthServer(logoutChallenge string) (bool, string, error) {
+func completeLogoutRequestWithAuthServer(
+        logger *slog.Logger,
+        logoutChallenge string) (bool, string, error) {
     // For now, no reason to implement the logout reject path, I can't think
     // of how that would be useful
     params := url.Values{}
     params.Set("logout_challenge", logoutChallenge)
-    requestUrl := AUTHORIZATION_SERVER + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
+    requestUrl := backendConfig.Auth.AdminURL + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
             LOGOUT_ACCEPT_ENDPOINT + "?" + params.Encode()
 
-    log.Printf("Sending logout request to server at endpoint %s",
-            requestUrl)
+    logger.Debug("Sending logout to auth server", "url", logging.RedactURL(requestUrl))
 
     req, err := http.NewRequest(http.MethodPut, requestUrl, http.NoBody)
     if err != nil {
-        log.Printf("Error creating logout http request: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error creating logout http request", "err", err)
         return false, "", err
     }
     req.Header.Set("Content-Type", "application/json")
@@ -445,25 +465,26 @@ func completeLogoutRequestWithAuthServer(logoutChallenge string) (bool, string,
     client := &http.Client{}
     resp, err := client.Do(req)
     if err != nil {
-        log.Printf("Error sending logout request to auth server: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error sending logout request to auth server", "err", err)
         return false, "", err
     }
     defer resp.Body.Close()
 
     respBody, err := ioutil.ReadAll(resp.Body)
     if err != nil {
-        log.Printf("Error reading response body: %s", err)
+        logger.Error("Error reading response body", "err", err)
         return false, "", err
     }
 
-    log.Printf("Auth server response: %s", string(respBody))
+    logger.Debug("Auth server responded", "status", resp.StatusCode)
 
     switch resp.StatusCode {
     case http.StatusOK:
         var completedRequest HydraCompletedRequest
         err = json.Unmarshal(respBody, &completedRequest)
         if err != nil {
-            log.Printf("Error unmarshalling completedRequest: %s", err)
+            logger.Error("Error unmarshalling completedRequest", "err", err)
             return false, "", err
         }
         return true, completedRequest.RedirectTo, nil
@@ -475,84 +496,93 @@ func completeLogoutRequestWithAuthServer(logoutChallenge string) (bool, string,
         var genericError HydraGenericError
         err = json.Unmarshal(respBody, &genericError)
         if err != nil {
-            log.Printf("Error unmarshalling genericError: %s", err)
+            logger.Error("Error unmarshalling genericError", "err", err)
             return false, "", err
         }
         return false, genericError.ErrorMsg, nil
     }
 }
 
-func checkConsentChallenge(consentChallenge string) (HydraConsentRequest, error) {
+func checkConsentChallenge(
+        logger *slog.Logger,
+        consentChallenge string) (HydraConsentRequest, error) {
     params := url.Values{}
     params.Set("consent_challenge", consentChallenge)
 
     // First, we need to interrogate the authorization server for the details
     // of the consent request
-    requestUrl := AUTHORIZATION_SERVER + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
+    requestUrl := backendConfig.Auth.AdminURL + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
             CONSENT_ENDPOINT + "?" + params.Encode()
-    log.Printf("Sending consent challenge request %s", requestUrl)
+    logger.Debug("Sending consent challenge request", "url", logging.RedactURL(requestUrl))
 
     resp, err := http.Get(requestUrl)
     if err != nil {
-        log.Printf("Error sending consent request: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error sending consent request", "err", err)
         return HydraConsentRequest{}, err
     }
     defer resp.Body.Close()
 
     respBody, err := ioutil.ReadAll(resp.Body)
     if err != nil {
-        log.Printf("Error reading consent request response: %s", err)
+        logger.Error("Error reading consent request response", "err", err)
         return HydraConsentRequest{}, err
     }
 
     var consentRequest HydraConsentRequest
     err = json.Unmarshal(respBody, &consentRequest)
     if err != nil {
-        log.Printf("Error unmarshalling consent request response: %s", err)
+        logger.Error("Error unmarshalling consent request response", "err", err)
         return HydraConsentRequest{}, err
     }
 
-    log.Printf("Received consent consent response: %s", consentRequest)
+    logger.Debug("Received consent challenge response", "skip", consentRequest.Skip,
+        "subject", consentRequest.Subject, "requested_scope", consentRequest.RequestedScope)
 
     return consentRequest, nil
 }
 
-func checkLogoutChallenge(logoutChallenge string) (HydraLogoutRequest, error) {
+func checkLogoutChallenge(
+        logger *slog.Logger,
+        logoutChallenge string) (HydraLogoutRequest, error) {
     params := url.Values{}
     params.Set("logout_challenge", logoutChallenge)
 
     // First, we need to interrogate the authorization server for the details
     // of the logout request
-    requestUrl := AUTHORIZATION_SERVER + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
+    requestUrl := backendConfig.Auth.AdminURL + AUTHORIZATION_REQUEST_ENDPOINT_BASE +
             LOGOUT_ENDPOINT + "?" + params.Encode()
-    log.Printf("Sending logout challenge request %s", requestUrl)
+    logger.Debug("Sending logout challenge request", "url", logging.RedactURL(requestUrl))
 
     resp, err := http.Get(requestUrl)
     if err != nil {
-        log.Printf("Error sending logout request: %s", err)
+        err = logging.RedactURLError(err)
+        logger.Error("Error sending logout request", "err", err)
         return HydraLogoutRequest{}, err
     }
     defer resp.Body.Close()
 
     respBody, err := ioutil.ReadAll(resp.Body)
     if err != nil {
-        log.Printf("Error reading logout request response: %s", err)
+        logger.Error("Error reading logout request response", "err", err)
         return HydraLogoutRequest{}, err
     }
 
     var logoutRequest HydraLogoutRequest
     err = json.Unmarshal(respBody, &logoutRequest)
     if err != nil {
-        log.Printf("Error unmarshalling logout request response: %s", err)
+        logger.Error("Error unmarshalling logout request response", "err", err)
         return HydraLogoutRequest{}, err
     }
 
-    log.Printf("Received logout consent response: %s", logoutRequest)
+    logger.Debug("Received logout challenge response", "subject", logoutRequest.Subject,
+        "rp_initiated", logoutRequest.RPInitiated)
 
     return logoutRequest, nil
 }
 
 func authorizeToken(
+        logger *slog.Logger,
         subject string,
         token string,
         done <-chan interface{},
@@ -560,13 +590,13 @@ func authorizeToken(
 
     requestBody := url.Values{}
     requestBody.Set("token", token)
-    requestUrl := AUTHORIZATION_SERVER + TOKEN_INTROSPECTION_ENDPOINT
+    requestUrl := backendConfig.Auth.AdminURL + TOKEN_INTROSPECTION_ENDPOINT
 
-    log.Printf("Sending token introspection with body %v", requestBody);
+    logger.Debug("Sending token introspection request", "subject", subject)
 
     resp, err := http.PostForm(requestUrl, requestBody)
     if err != nil {
-        log.Printf("Error sending token introspection request: %s", err)
+        logger.Error("Error sending token introspection request", "err", err)
         return false
     }
 
@@ -574,26 +604,30 @@ func authorizeToken(
 
     respBody, err := ioutil.ReadAll(resp.Body)
     if err != nil {
-        log.Printf("Error reading response body: %s", err)
+        logger.Error("Error reading response body", "err", err)
         return false
     }
 
-    log.Printf("Auth server response: %s", string(respBody))
+    logger.Debug("Auth server responded", "status", resp.StatusCode)
 
     switch resp.StatusCode {
     case http.StatusOK:
         var tokenIntrospection HydraOauth2TokenIntrospection
         err = json.Unmarshal(respBody, &tokenIntrospection)
         if err != nil {
-            log.Printf("Error unmarshalling token introspection: %s", err)
+            logger.Error("Error unmarshalling token introspection", "err", err)
             return false
         }
 
         authResult := AuthResult{}
         if tokenIntrospection.Active && tokenIntrospection.Subject == subject {
             authResult.AuthSuccessful = true
+            logger.Info("Socket authorized", "subject", subject)
         } else {
             authResult.AuthSuccessful = false
+            logger.Info("Token rejected", "subject", subject,
+                "active", tokenIntrospection.Active,
+                "token_subject", tokenIntrospection.Subject)
         }
 
         select {
@@ -610,9 +644,11 @@ func authorizeToken(
         var genericError HydraGenericError
         err = json.Unmarshal(respBody, &genericError)
         if err != nil {
-            log.Printf("Error unmarshalling genericError: %s", err)
+            logger.Error("Error unmarshalling genericError", "err", err)
             return false
         }
+        logger.Warn("Token introspection failed", "status", resp.StatusCode,
+            "error", genericError.ErrorMsg, "error_description", genericError.ErrorDesc)
     }
 
     return false
@@ -627,9 +663,9 @@ func sendHttpAuthErrorMsg(resp http.ResponseWriter, errorMsg string) {
     resp.WriteHeader(http.StatusForbidden)
 }
 
-func sendHttpRedirect(resp http.ResponseWriter, redirectTo string) {
+func sendHttpRedirect(logger *slog.Logger, resp http.ResponseWriter, redirectTo string) {
     resp.Header().Set("Location", redirectTo)
-    log.Printf("Sending redirect to %s", redirectTo)
+    logger.Debug("Sending redirect", "url", logging.RedactURL(redirectTo))
     resp.WriteHeader(http.StatusFound)
 }
 
@@ -646,6 +682,7 @@ func enableCORS(resp *http.ResponseWriter, req *http.Request) bool {
 
 func HandleLoginChallenge(resp http.ResponseWriter, req *http.Request) {
     defer req.Body.Close()
+    logger := httpRequestLogger(authLogger, req)
 
     preflight := enableCORS(&resp, req)
     if preflight {
@@ -654,7 +691,7 @@ func HandleLoginChallenge(resp http.ResponseWriter, req *http.Request) {
 
     request, err := ioutil.ReadAll(req.Body)
     if err != nil {
-        log.Printf("Error reading login challenge request: %s", err)
+        logger.Error("Error reading login challenge request", "err", err)
         sendHttpError(resp)
         return
     }
@@ -662,14 +699,14 @@ func HandleLoginChallenge(resp http.ResponseWriter, req *http.Request) {
     var challenge Challenge
     err = json.Unmarshal(request, &challenge)
     if err != nil {
-        log.Printf("Error unmarshalling login challenge request: %s", err)
+        logger.Error("Error unmarshalling login challenge request", "err", err)
         sendHttpError(resp)
         return
     }
 
-    loginRequest, err := checkLoginChallenge(challenge.Challenge)
+    loginRequest, err := checkLoginChallenge(logger, challenge.Challenge)
     if err != nil {
-        log.Print(err)
+        logger.Error("Error checking login challenge", "err", err)
         sendHttpError(resp)
         return
     }
@@ -680,17 +717,17 @@ func HandleLoginChallenge(resp http.ResponseWriter, req *http.Request) {
     if loginRequest.Skip {
         // The user has already successfully authenticated, so complete the
         // authentication with the backend
-        authSuccessful, authResponse, err := completeLoginRequestWithAuthServer(true,
+        authSuccessful, authResponse, err := completeLoginRequestWithAuthServer(logger, true,
             loginRequest.Subject,
             challenge.Challenge)
         if err != nil {
-            log.Printf("Error completing skipped authentication: %s", err)
+            logger.Error("Error completing skipped authentication", "err", err)
             sendHttpError(resp)
             return
         }
 
         if authSuccessful {
-            sendHttpRedirect(resp, authResponse)
+            sendHttpRedirect(logger, resp, authResponse)
         } else {
             sendHttpAuthErrorMsg(resp, authResponse)
         }
@@ -701,19 +738,20 @@ func HandleLoginChallenge(resp http.ResponseWriter, req *http.Request) {
         respBody := FrontendLoginDisplayParams{DisplayLoginUI: true}
         respBodyJson, err := json.Marshal(respBody)
         if err != nil {
-            log.Printf("Error marshalling login display params json: %s", err)
+            logger.Error("Error marshalling login display params json", "err", err)
             sendHttpError(resp)
             return
         }
         _, err = resp.Write(respBodyJson)
         if err != nil {
-            log.Printf("Error writing login display params response: %s", err)
+            logger.Error("Error writing login display params response", "err", err)
         }
     }
 }
 
 func HandleLoginCredentials(resp http.ResponseWriter, req *http.Request) {
     defer req.Body.Close()
+    logger := httpRequestLogger(authLogger, req)
 
     preflight := enableCORS(&resp, req)
     if preflight {
@@ -722,7 +760,7 @@ func HandleLoginCredentials(resp http.ResponseWriter, req *http.Request) {
 
     request, err := ioutil.ReadAll(req.Body)
     if err != nil {
-        log.Printf("Error reading login credentials: %s", err)
+        logger.Error("Error reading login credentials", "err", err)
         sendHttpError(resp)
         return
     }
@@ -730,21 +768,21 @@ func HandleLoginCredentials(resp http.ResponseWriter, req *http.Request) {
     var loginCredentials LoginCredentials
     err = json.Unmarshal(request, &loginCredentials)
     if err != nil {
-        log.Printf("Error unmarshalling login credentials: %s", err)
+        logger.Error("Error unmarshalling login credentials", "err", err)
         sendHttpError(resp)
         return
     }
 
-    authSuccessful, authResponse, err := checkUserLogin(loginCredentials.Username,
+    authSuccessful, authResponse, err := checkUserLogin(logger, loginCredentials.Username,
         loginCredentials.Password, loginCredentials.LoginChallenge)
     if err != nil {
-        log.Print(err)
+        logger.Error("Error checking login", "err", err)
         sendHttpError(resp)
         return
     }
 
     if authSuccessful {
-        sendHttpRedirect(resp, authResponse)
+        sendHttpRedirect(logger, resp, authResponse)
     } else {
         sendHttpAuthErrorMsg(resp, authResponse)
     }
@@ -752,10 +790,11 @@ func HandleLoginCredentials(resp http.ResponseWriter, req *http.Request) {
 
 func HandleConsentChallenge(resp http.ResponseWriter, req *http.Request) {
     defer req.Body.Close()
+    logger := httpRequestLogger(authLogger, req)
 
     request, err := ioutil.ReadAll(req.Body)
     if err != nil {
-        log.Printf("Error reading consent challenge request: %s", err)
+        logger.Error("Error reading consent challenge request", "err", err)
         sendHttpError(resp)
         return
     }
@@ -763,14 +802,14 @@ func HandleConsentChallenge(resp http.ResponseWriter, req *http.Request) {
     var challenge Challenge
     err = json.Unmarshal(request, &challenge)
     if err != nil {
-        log.Printf("Error unmarshalling consent challenge request: %s", err)
+        logger.Error("Error unmarshalling consent challenge request", "err", err)
         sendHttpError(resp)
         return
     }
 
-    consentRequest, err := checkConsentChallenge(challenge.Challenge)
+    consentRequest, err := checkConsentChallenge(logger, challenge.Challenge)
     if err != nil {
-        log.Print(err)
+        logger.Error("Error checking consent challenge", "err", err)
         sendHttpError(resp)
         return
     }
@@ -778,16 +817,16 @@ func HandleConsentChallenge(resp http.ResponseWriter, req *http.Request) {
     // For now, we blindly accept all consent requests, since we're controlling
     // both the app and the authorization service, we assume we want to grant
     // access to everything.  Potentially implement this more fully in the future
-    consentSuccessful, consentResponse, err := completeConsentRequestWithAuthServer(true,
+    consentSuccessful, consentResponse, err := completeConsentRequestWithAuthServer(logger, true,
         consentRequest)
     if err != nil {
-        log.Printf("Error completing consent: %s", err)
+        logger.Error("Error completing consent", "err", err)
         sendHttpError(resp)
         return
     }
 
     if consentSuccessful {
-        sendHttpRedirect(resp, consentResponse)
+        sendHttpRedirect(logger, resp, consentResponse)
     } else {
         sendHttpAuthErrorMsg(resp, consentResponse)
     }
@@ -795,10 +834,11 @@ func HandleConsentChallenge(resp http.ResponseWriter, req *http.Request) {
 
 func HandleLogoutChallenge(resp http.ResponseWriter, req *http.Request) {
     defer req.Body.Close()
+    logger := httpRequestLogger(authLogger, req)
 
     request, err := ioutil.ReadAll(req.Body)
     if err != nil {
-        log.Printf("Error reading logout challenge request: %s", err)
+        logger.Error("Error reading logout challenge request", "err", err)
         sendHttpError(resp)
         return
     }
@@ -806,30 +846,31 @@ func HandleLogoutChallenge(resp http.ResponseWriter, req *http.Request) {
     var challenge Challenge
     err = json.Unmarshal(request, &challenge)
     if err != nil {
-        log.Printf("Error unmarshalling logout challenge request: %s", err)
+        logger.Error("Error unmarshalling logout challenge request", "err", err)
         sendHttpError(resp)
         return
     }
 
-    logoutRequest, err := checkLogoutChallenge(challenge.Challenge)
-    log.Printf("Logging out session %s", logoutRequest.SID)
+    logoutRequest, err := checkLogoutChallenge(logger, challenge.Challenge)
     if err != nil {
-        log.Print(err)
+        logger.Error("Error checking logout challenge", "err", err)
         sendHttpError(resp)
         return
     }
+    logger.Info("Logging out session", "session_id", logoutRequest.SID,
+        "subject", logoutRequest.Subject)
 
     // Just accept the logout request, nothing else to do here
-    logoutSuccessful, logoutResponse, err := completeLogoutRequestWithAuthServer(
+    logoutSuccessful, logoutResponse, err := completeLogoutRequestWithAuthServer(logger,
         challenge.Challenge)
     if err != nil {
-        log.Printf("Error completing logout: %s", err)
+        logger.Error("Error completing logout", "err", err)
         sendHttpError(resp)
         return
     }
 
     if logoutSuccessful {
-        sendHttpRedirect(resp, logoutResponse)
+        sendHttpRedirect(logger, resp, logoutResponse)
     } else {
         sendHttpAuthErrorMsg(resp, logoutResponse)
     }
@@ -0,0 +1,72 @@
+package backend
+
+import "context"
+import "database/sql"
+import "encoding/json"
+import "log/slog"
+import "time"
+
+// CardChange is one update to a card made by an import.  Changes is the
+// card's field level diff as recorded at the time, a list of
+// {path, kind, old, new} objects, e.g. a ban shows up as a change to
+// "Legalities[modern]" from "Legal" to "Banned".
+type CardChange struct {
+    MTGJSONVersion string `json:"mtgjson_version,omitempty"`
+    OldHash string `json:"old_hash"`
+    NewHash string `json:"new_hash"`
+    ChangedAt time.Time `json:"changed_at"`
+    Changes json.RawMessage `json:"changes"`
+}
+
+// cardChanges sends the change timeline for the card with uuid, oldest
+// change first
+func cardChanges(logger *slog.Logger,
+        db *sql.DB,
+        uuid string,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+
+    dbConn, err := db.Conn(context.Background())
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer dbConn.Close()
+
+    res, err := dbConn.QueryContext(
+        context.Background(),
+        `SELECT mtgjson_version, old_hash, new_hash, changed_at, changes
+        FROM card_changes
+        WHERE uuid = ?
+        ORDER BY changed_at ASC, card_change_id ASC`,
+        uuid)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer res.Close()
+
+    changes := make([]CardChange, 0)
+    for res.Next() {
+        var change CardChange
+        var version sql.NullString
+        var changesJSON string
+        err = res.Scan(&version, &change.OldHash, &change.NewHash, &change.ChangedAt, &changesJSON)
+        if err != nil {
+            sendError(logger, done, respChan, err)
+            return
+        }
+        change.MTGJSONVersion = version.String
+        change.Changes = json.RawMessage(changesJSON)
+        changes = append(changes, change)
+    }
+    if err = res.Err(); err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: CardChangesResponse, Value: changes}:
+    }
+}
@@ -0,0 +1,204 @@
+package backend
+
+import "database/sql"
+import "dbconn"
+import "fmt"
+import "log/slog"
+
+const MAX_LIST_NAME_LENGTH = 200
+
+// The kinds of list a user can have, as in card_lists.list_type
+var cardListTypes = map[string]bool{
+    "deck": true,
+    "collection": true,
+    "trade": true,
+}
+
+// NewCardList is the value of a CreateCardListRequest
+type NewCardList struct {
+    Name string `json:"name"`
+    Type string `json:"type"`
+}
+
+// CardList is the value of a CardListResponse
+type CardList struct {
+    ListId int `json:"list_id"`
+    Name string `json:"name"`
+    Type string `json:"type"`
+}
+
+// NewCardListEntry is the value of an AddCardListEntryRequest.  Quantity
+// defaults to 1 if it isn't given.
+type NewCardListEntry struct {
+    ListId int `json:"list_id"`
+    CardUUID string `json:"card_uuid"`
+    Quantity int `json:"quantity"`
+    IsFoil bool `json:"is_foil"`
+}
+
+// CardListEntry is the value of a CardListEntryResponse
+type CardListEntry struct {
+    EntryId int `json:"entry_id"`
+    ListId int `json:"list_id"`
+    CardUUID string `json:"card_uuid"`
+    Quantity int `json:"quantity"`
+    IsFoil bool `json:"is_foil"`
+}
+
+func (list *NewCardList) validate() error {
+    if !cardListTypes[list.Type] {
+        return fmt.Errorf("Unknown list type %q, expected deck, collection or trade", list.Type)
+    }
+    if list.Name == "" || len(list.Name) > MAX_LIST_NAME_LENGTH {
+        return fmt.Errorf("List names must be between 1 and %d characters", MAX_LIST_NAME_LENGTH)
+    }
+    return nil
+}
+
+// listOwnedBy returns whether listId exists and belongs to userId.  Other
+// users' lists are treated the same as lists that don't exist, so that
+// nobody can find out which list IDs are in use.
+func listOwnedBy(userDB *sql.DB, listId int, userId int) (bool, error) {
+    var listOwner int
+    err := userDB.QueryRow(`SELECT user_id
+            FROM card_lists
+            WHERE list_id = ?`,
+            listId).Scan(&listOwner)
+    if err == sql.ErrNoRows {
+        return false, nil
+    } else if err != nil {
+        return false, err
+    }
+
+    return listOwner == userId, nil
+}
+
+func createCardList(logger *slog.Logger,
+        subject string,
+        request NewCardList,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    err := request.validate()
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    userId, err := getUserId(userDB, subject)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    res, err := userDB.Exec(`INSERT INTO card_lists
+            (user_id, list_type, list_name)
+            VALUES
+            (?, ?, ?)`,
+            userId, request.Type, request.Name)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    listId, err := res.LastInsertId()
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    list := CardList{ListId: int(listId), Name: request.Name, Type: request.Type}
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: CardListResponse, Value: list}:
+    }
+}
+
+func addCardListEntry(logger *slog.Logger,
+        subject string,
+        cardDB *sql.DB,
+        request NewCardListEntry,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    if request.Quantity == 0 {
+        request.Quantity = 1
+    }
+    if request.Quantity < 0 {
+        sendError(logger, done, respChan, fmt.Errorf("Quantity must be at least 1"))
+        return
+    }
+
+    // Only cards that are still in the source can be added, so that lists
+    // don't pick up entries nothing can be shown for
+    var cardCount int
+    err := cardDB.QueryRow(`SELECT COUNT(*)
+            FROM all_cards
+            WHERE uuid = ?
+            AND removed_at IS NULL`,
+            request.CardUUID).Scan(&cardCount)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    if cardCount == 0 {
+        sendError(logger, done, respChan, fmt.Errorf("No card %s found", request.CardUUID))
+        return
+    }
+
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    userId, err := getUserId(userDB, subject)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    owned, err := listOwnedBy(userDB, request.ListId, userId)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    if !owned {
+        sendError(logger, done, respChan, fmt.Errorf("No list %d found for user %s",
+            request.ListId, subject))
+        return
+    }
+
+    res, err := userDB.Exec(`INSERT INTO card_list_entries
+            (list_id, card_uuid, quantity, is_foil)
+            VALUES
+            (?, ?, ?, ?)`,
+            request.ListId, request.CardUUID, request.Quantity, request.IsFoil)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    entryId, err := res.LastInsertId()
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    entry := CardListEntry{
+        EntryId: int(entryId),
+        ListId: request.ListId,
+        CardUUID: request.CardUUID,
+        Quantity: request.Quantity,
+        IsFoil: request.IsFoil}
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: CardListEntryResponse, Value: entry}:
+    }
+}
@@ -0,0 +1,266 @@
+package backend
+
+import "database/sql"
+import "dbconn"
+import "log/slog"
+import "sort"
+import "sync"
+import "time"
+
+const (
+    // How far back a LegalityChangesRequest looks if it doesn't say
+    DEFAULT_LEGALITY_CHANGE_DAYS = 30
+    // How often each authorized socket checks for new legality changes to
+    // push, since they're written by the importer rather than the backend
+    LEGALITY_CHANGE_POLL_INTERVAL = 5 * time.Minute
+)
+
+// The user db pool that every socket's legality change watcher looks its
+// user's cards up through, opened by the first one to poll.  The watchers
+// run for as long as their sockets are open, so a pool each would mean a new
+// pool per socket on every poll.
+var watcherUserDB = struct {
+    sync.Mutex
+    db *sql.DB
+}{}
+
+// LegalityChangesFilter is the value of a LegalityChangesRequest
+type LegalityChangesFilter struct {
+    Days int `json:"days"`
+}
+
+// LegalityChange is a card in one of the user's decks or collections
+// becoming legal, banned, restricted etc. in a format.  An empty legality
+// means the card wasn't listed for the format at all.
+type LegalityChange struct {
+    UUID string `json:"uuid"`
+    Name string `json:"name"`
+    SetName string `json:"setName"`
+    GameFormat string `json:"gameFormat"`
+    OldLegality string `json:"oldLegality"`
+    NewLegality string `json:"newLegality"`
+    MTGJSONVersion string `json:"mtgjsonVersion,omitempty"`
+    ChangedAt time.Time `json:"changedAt"`
+}
+
+// userListCardUUIDs returns every card uuid in the decks and collections of
+// the user with userId
+func userListCardUUIDs(userDB *sql.DB, userId int) (map[string]bool, error) {
+    res, err := userDB.Query(`SELECT DISTINCT card_list_entries.card_uuid
+        FROM
+        card_list_entries INNER JOIN card_lists
+        ON card_list_entries.list_id = card_lists.list_id
+        WHERE card_lists.user_id = ?
+        AND card_lists.list_type IN ('deck', 'collection')`,
+        userId)
+    if err != nil {
+        return nil, err
+    }
+    defer res.Close()
+
+    uuids := make(map[string]bool)
+    for res.Next() {
+        var uuid string
+        err = res.Scan(&uuid)
+        if err != nil {
+            return nil, err
+        }
+        uuids[uuid] = true
+    }
+    if err = res.Err(); err != nil {
+        return nil, err
+    }
+
+    return uuids, nil
+}
+
+// fetchLegalityChanges returns the legality changes to cards in uuids made
+// after afterId and since since, sorted by format and then card name, along
+// with the newest legality_change_id it saw.  Changes are filtered here
+// rather than in the query, since a collection can easily hold more cards
+// than fit in an IN clause.
+func fetchLegalityChanges(cardDB *sql.DB,
+        uuids map[string]bool,
+        afterId int64,
+        since time.Time) ([]LegalityChange, int64, error) {
+    res, err := cardDB.Query(`SELECT
+        legality_changes.legality_change_id, legality_changes.uuid,
+        all_cards.name, sets.name, legality_changes.game_format,
+        legality_changes.old_legality, legality_changes.new_legality,
+        legality_changes.mtgjson_version, legality_changes.changed_at
+        FROM
+        legality_changes
+        INNER JOIN all_cards ON legality_changes.card_id = all_cards.card_id
+        INNER JOIN sets ON all_cards.set_id = sets.set_id
+        WHERE legality_changes.legality_change_id > ?
+        AND legality_changes.changed_at >= ?`,
+        afterId, since)
+    if err != nil {
+        return nil, afterId, err
+    }
+    defer res.Close()
+
+    lastId := afterId
+    changes := make([]LegalityChange, 0)
+    for res.Next() {
+        var changeId int64
+        var change LegalityChange
+        var oldLegality sql.NullString
+        var newLegality sql.NullString
+        var version sql.NullString
+        err = res.Scan(&changeId, &change.UUID, &change.Name, &change.SetName,
+            &change.GameFormat, &oldLegality, &newLegality, &version, &change.ChangedAt)
+        if err != nil {
+            return nil, afterId, err
+        }
+
+        if changeId > lastId {
+            lastId = changeId
+        }
+        if !uuids[change.UUID] {
+            continue
+        }
+
+        change.OldLegality = oldLegality.String
+        change.NewLegality = newLegality.String
+        change.MTGJSONVersion = version.String
+        changes = append(changes, change)
+    }
+    if err = res.Err(); err != nil {
+        return nil, afterId, err
+    }
+
+    sort.Slice(changes, func(i, j int) bool {
+        if changes[i].GameFormat != changes[j].GameFormat {
+            return changes[i].GameFormat < changes[j].GameFormat
+        }
+        if changes[i].Name != changes[j].Name {
+            return changes[i].Name < changes[j].Name
+        }
+        return changes[i].UUID < changes[j].UUID
+    })
+
+    return changes, lastId, nil
+}
+
+// sharedWatcherUserDB returns the user db pool shared by the legality change
+// watchers, opening it if it isn't already.  It stays open for as long as
+// the backend runs.
+func sharedWatcherUserDB() (*sql.DB, error) {
+    watcherUserDB.Lock()
+    defer watcherUserDB.Unlock()
+
+    if watcherUserDB.db == nil {
+        userDB, err := dbconn.Open(backendConfig.UserDB)
+        if err != nil {
+            return nil, err
+        }
+        trackDBPool(USER_DB_POOL, userDB)
+        watcherUserDB.db = userDB
+    }
+    return watcherUserDB.db, nil
+}
+
+// fetchUserLegalityChanges looks up the cards of the user with subject, and
+// then the legality changes affecting them
+func fetchUserLegalityChanges(userDB *sql.DB,
+        subject string,
+        cardDB *sql.DB,
+        afterId int64,
+        since time.Time) ([]LegalityChange, int64, error) {
+    userId, err := getUserId(userDB, subject)
+    if err != nil {
+        return nil, afterId, err
+    }
+
+    uuids, err := userListCardUUIDs(userDB, userId)
+    if err != nil {
+        return nil, afterId, err
+    }
+
+    return fetchLegalityChanges(cardDB, uuids, afterId, since)
+}
+
+func legalityChanges(logger *slog.Logger,
+        subject string,
+        cardDB *sql.DB,
+        filter LegalityChangesFilter,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    days := filter.Days
+    if days <= 0 {
+        days = DEFAULT_LEGALITY_CHANGE_DAYS
+    }
+    since := time.Now().UTC().AddDate(0, 0, -days)
+
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    changes, _, err := fetchUserLegalityChanges(userDB, subject, cardDB, 0, since)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: LegalityChangesResponse, Value: changes}:
+    }
+}
+
+// watchLegalityChanges pushes a LegalityChangesNotification to the socket of
+// the user with subject whenever an import changes the legality of one of
+// their cards, until done is closed.  Only changes made after the socket
+// was authorized are pushed; older ones are there to be requested.
+func watchLegalityChanges(logger *slog.Logger,
+        subject string,
+        cardDB *sql.DB,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    var maxId sql.NullInt64
+    err := cardDB.QueryRow(`SELECT MAX(legality_change_id) FROM legality_changes`).Scan(&maxId)
+    if err != nil {
+        logger.Error("Not watching legality changes", "subject", subject, "err", err)
+        return
+    }
+    lastId := maxId.Int64
+
+    ticker := time.NewTicker(LEGALITY_CHANGE_POLL_INTERVAL)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-done:
+            return
+        case <-ticker.C:
+        }
+
+        userDB, err := sharedWatcherUserDB()
+        if err != nil {
+            logger.Warn("Error opening the user db to fetch legality changes",
+                "subject", subject, "err", err)
+            continue
+        }
+        changes, newLastId, err := fetchUserLegalityChanges(userDB, subject, cardDB, lastId, time.Time{})
+        if err != nil {
+            // Try again next time round, from the same place
+            logger.Warn("Error fetching legality changes", "subject", subject, "err", err)
+            continue
+        }
+        lastId = newLastId
+        if len(changes) == 0 {
+            continue
+        }
+
+        select {
+        case <-done:
+            return
+        case respChan <- ResponseMessage{Type: LegalityChangesNotification, Value: changes}:
+        }
+    }
+}
@@ -0,0 +1,128 @@
+package backend
+
+import "carddb"
+import "context"
+import "database/sql"
+import "dbconn"
+import "fmt"
+import "mtgcards"
+import "testing"
+import "time"
+
+// importLegalitySet imports a set of cardCount cards, all with legality in
+// modern, so that importing it again with a different legality records a
+// change for every card
+func importLegalitySet(t *testing.T, cardDB *sql.DB, cardCount int, legality string) {
+    set := mtgcards.MTGSet{
+        Code: "LEG",
+        Name: "Set LEG",
+        ReleaseDate: "2020-06-01",
+        Type: "expansion",
+        BaseSetSize: cardCount,
+        TotalSetSize: cardCount}
+    for i := 0; i < cardCount; i++ {
+        card := mtgcards.MTGCard{Legalities: map[string]string{"modern": legality}}
+        card.UUID = fmt.Sprintf("LEG-card-%d", i)
+        card.Name = fmt.Sprintf("Card %d", i)
+        card.Number = fmt.Sprint(i + 1)
+        card.Layout = "normal"
+        card.Types = []string{"Creature"}
+        set.Cards = append(set.Cards, card)
+    }
+
+    setChan := make(chan mtgcards.MTGSet, 1)
+    setChan <- set
+    close(setChan)
+    _, err := carddb.ImportSetsToDB(context.Background(), cardDB, setChan,
+        carddb.ImportOptions{Workers: 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+}
+
+func addTestListEntry(t *testing.T, userDB *sql.DB, userName string, listType string, uuid string) {
+    userId, err := getUserId(userDB, userName)
+    if err != nil {
+        t.Fatal(err)
+    }
+    res, err := userDB.Exec(`INSERT INTO card_lists
+            (user_id, list_type, list_name)
+            VALUES
+            (?, ?, ?)`,
+            userId, listType, listType)
+    if err != nil {
+        t.Fatal(err)
+    }
+    listId, err := res.LastInsertId()
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, err = userDB.Exec(`INSERT INTO card_list_entries
+            (list_id, card_uuid, quantity, is_foil)
+            VALUES
+            (?, ?, 1, 0)`,
+            listId, uuid)
+    if err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestFetchLegalityChangesForUsersCards(t *testing.T) {
+    cardDB, cleanup := setupTestDBs(t)
+    defer cleanup()
+
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer userDB.Close()
+
+    // Only alice's deck and collection count, not her trades or bob's deck
+    addTestListEntry(t, userDB, "alice", "deck", "LEG-card-0")
+    addTestListEntry(t, userDB, "alice", "collection", "LEG-card-1")
+    addTestListEntry(t, userDB, "alice", "trade", "LEG-card-2")
+    addTestListEntry(t, userDB, "bob", "deck", "LEG-card-3")
+
+    importLegalitySet(t, cardDB, 4, "Legal")
+    importLegalitySet(t, cardDB, 4, "Banned")
+
+    changes, lastId, err := fetchUserLegalityChanges(userDB, "alice", cardDB, 0, time.Time{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(changes) != 2 || changes[0].UUID != "LEG-card-0" || changes[1].UUID != "LEG-card-1" {
+        t.Fatalf("Expected changes to alice's deck and collection cards, got %+v", changes)
+    }
+    if changes[0].OldLegality != "Legal" || changes[0].NewLegality != "Banned" {
+        t.Errorf("Expected Legal to Banned, got %+v", changes[0])
+    }
+
+    // The last id covers the changes that were filtered out too, so they
+    // aren't looked at again
+    var maxId int64
+    err = cardDB.QueryRow(`SELECT MAX(legality_change_id) FROM legality_changes`).Scan(&maxId)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if lastId != maxId {
+        t.Errorf("Expected the last id to be %d, got %d", maxId, lastId)
+    }
+
+    changes, nextLastId, err := fetchUserLegalityChanges(userDB, "alice", cardDB, lastId, time.Time{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(changes) != 0 || nextLastId != lastId {
+        t.Errorf("Expected nothing new after %d, got %+v up to %d", lastId, changes, nextLastId)
+    }
+
+    importLegalitySet(t, cardDB, 4, "Legal")
+    changes, nextLastId, err = fetchUserLegalityChanges(userDB, "alice", cardDB, lastId, time.Time{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(changes) != 2 || changes[0].NewLegality != "Legal" || nextLastId <= lastId {
+        t.Errorf("Expected only the newer changes after %d, got %+v up to %d",
+            lastId, changes, nextLastId)
+    }
+}
@@ -0,0 +1,131 @@
+package backend
+
+import "database/sql"
+import "log/slog"
+import "metrics"
+import "sync"
+import "time"
+
+// Where the backend's Prometheus metrics are served.  nginx only proxies
+// /backend/, so they aren't reachable from outside.
+const METRICS_PATH = "/metrics"
+
+// The names db pools are tracked under
+const (
+    CARD_DB_POOL = "card"
+    USER_DB_POOL = "user"
+)
+
+// Why an attempt to authenticate failed, for authFailures
+const (
+    AUTH_FAILURE_UNKNOWN_USER = "unknown_user"
+    AUTH_FAILURE_BAD_PASSWORD = "bad_password"
+    AUTH_FAILURE_INVALID_TOKEN = "invalid_token"
+    AUTH_FAILURE_UNAUTHORIZED_REQUEST = "unauthorized_request"
+)
+
+var websocketConnections = metrics.NewGauge("mtg_backend_websocket_connections",
+    "Websocket connections currently open")
+var websocketConnectionsTotal = metrics.NewCounter("mtg_backend_websocket_connections_total",
+    "Websocket connections accepted")
+
+var requestsTotal = metrics.NewCounterVec("mtg_backend_requests_total",
+    "API requests handled, by request type", "type")
+var requestDuration = metrics.NewHistogramVec("mtg_backend_request_duration_seconds",
+    "How long API requests took to handle, by request type", metrics.DefaultBuckets, "type")
+
+var authFailures = metrics.NewCounterVec("mtg_backend_auth_failures_total",
+    "Failed logins, token checks and requests on unauthorized sockets, by reason", "reason")
+
+var dbConnections = metrics.NewGaugeVec("mtg_backend_db_connections",
+    "Connections held by the open db pools, by db and state", "db", "state")
+var dbPools = metrics.NewGaugeVec("mtg_backend_db_pools",
+    "db pools currently open, by db", "db")
+var dbWaits = metrics.NewGaugeVec("mtg_backend_db_waits",
+    "Times the open db pools have had to wait for a connection, by db", "db")
+var dbWaitSeconds = metrics.NewGaugeVec("mtg_backend_db_wait_seconds",
+    "Time the open db pools have spent waiting for a connection, by db", "db")
+
+// Each websocket connection has its own card db pool, so the pool stats are
+// summed over every pool that's open when the metrics are scraped
+var openDBPools = struct {
+    sync.Mutex
+    pools map[*sql.DB]string
+}{pools: make(map[*sql.DB]string)}
+
+func init() {
+    metrics.OnScrape(collectDBPoolStats)
+}
+
+// trackDBPool includes db's pool stats in the metrics, under name, until the
+// returned function is called
+func trackDBPool(name string, db *sql.DB) func() {
+    openDBPools.Lock()
+    defer openDBPools.Unlock()
+    openDBPools.pools[db] = name
+
+    return func() {
+        openDBPools.Lock()
+        defer openDBPools.Unlock()
+        delete(openDBPools.pools, db)
+    }
+}
+
+func collectDBPoolStats() {
+    totals := make(map[string]*sql.DBStats)
+    pools := make(map[string]int)
+
+    openDBPools.Lock()
+    for db, name := range openDBPools.pools {
+        stats := db.Stats()
+        total, ok := totals[name]
+        if !ok {
+            total = &sql.DBStats{}
+            totals[name] = total
+        }
+        total.OpenConnections += stats.OpenConnections
+        total.InUse += stats.InUse
+        total.Idle += stats.Idle
+        total.WaitCount += stats.WaitCount
+        total.WaitDuration += stats.WaitDuration
+        pools[name] += 1
+    }
+    openDBPools.Unlock()
+
+    // Pools that have all closed since the last scrape go back to zero,
+    // rather than keeping the last value they had
+    for _, name := range []string{CARD_DB_POOL, USER_DB_POOL} {
+        total, ok := totals[name]
+        if !ok {
+            total = &sql.DBStats{}
+        }
+        dbConnections.With(name, "open").Set(float64(total.OpenConnections))
+        dbConnections.With(name, "in_use").Set(float64(total.InUse))
+        dbConnections.With(name, "idle").Set(float64(total.Idle))
+        dbPools.With(name).Set(float64(pools[name]))
+        dbWaits.With(name).Set(float64(total.WaitCount))
+        dbWaitSeconds.With(name).Set(total.WaitDuration.Seconds())
+    }
+}
+
+func recordAuthFailure(reason string) {
+    authFailures.With(reason).Inc()
+}
+
+// handleRequest runs handler in its own goroutine, with a logger for the
+// request, recording the request and how long it took once it's done
+func handleRequest(connLogger *slog.Logger, requestType RequestType, handler func(*slog.Logger)) {
+    logger := requestLogger(connLogger, requestType)
+    start := time.Now()
+    go func() {
+        defer recordRequest(requestType, start)
+        logger.Debug("Handling request")
+        handler(logger)
+        logger.Debug("Handled request", "duration", time.Since(start))
+    }()
+}
+
+func recordRequest(requestType RequestType, start time.Time) {
+    requestsTotal.With(requestType.String()).Inc()
+    requestDuration.With(requestType.String()).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,124 @@
+package backend
+
+import "database/sql"
+import "dbconn"
+import "fmt"
+import "log/slog"
+import "mtgcards"
+
+// The vendor and currency valuations use for users who haven't picked their
+// own, which are the prices the importer has always stored
+const (
+    DEFAULT_PRICE_CURRENCY = "USD"
+    DEFAULT_PRICE_VENDOR = mtgcards.VENDOR_TCGPLAYER
+)
+
+// PricePreference is the vendor, and the currency of its prices, used to
+// value a user's cards.  It's the value of a SetPricePreferenceRequest and
+// a PricePreferenceResponse.
+type PricePreference struct {
+    Currency string `json:"currency"`
+    Vendor string `json:"vendor"`
+}
+
+// validate checks that the vendor is one prices are imported from and that
+// it sells in the currency
+func (preference *PricePreference) validate() error {
+    currency, ok := mtgcards.VendorCurrencies[preference.Vendor]
+    if !ok {
+        return fmt.Errorf("Unknown vendor %q", preference.Vendor)
+    }
+    if preference.Currency != currency {
+        return fmt.Errorf("Vendor %s has prices in %s, not %q",
+            preference.Vendor, currency, preference.Currency)
+    }
+    return nil
+}
+
+func getPricePreference(userDB *sql.DB, userId int) (PricePreference, error) {
+    preference := PricePreference{
+        Currency: DEFAULT_PRICE_CURRENCY,
+        Vendor: DEFAULT_PRICE_VENDOR}
+
+    err := userDB.QueryRow(`SELECT currency, vendor
+        FROM user_price_preferences
+        WHERE user_id = ?`,
+        userId).Scan(&preference.Currency, &preference.Vendor)
+    if err != nil && err != sql.ErrNoRows {
+        return preference, err
+    }
+
+    return preference, nil
+}
+
+func pricePreference(logger *slog.Logger,
+        subject string,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    userId, err := getUserId(userDB, subject)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    preference, err := getPricePreference(userDB, userId)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: PricePreferenceResponse, Value: preference}:
+    }
+}
+
+func setPricePreference(logger *slog.Logger,
+        subject string,
+        preference PricePreference,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    err := preference.validate()
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    userId, err := getUserId(userDB, subject)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    _, err = userDB.Exec(`INSERT INTO user_price_preferences
+            (user_id, currency, vendor)
+            VALUES
+            (?, ?, ?)
+            ` + dbconn.OnDuplicateUpdate(userDB, "user_id", "currency", "vendor"),
+            userId, preference.Currency, preference.Vendor)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: PricePreferenceResponse, Value: preference}:
+    }
+}
@@ -0,0 +1,38 @@
+package backend
+
+import "log/slog"
+import "mtgcards"
+import "testing"
+
+func TestSetPricePreferenceReplacesPrevious(t *testing.T) {
+    _, cleanup := setupTestDBs(t)
+    defer cleanup()
+
+    preferences := []PricePreference{
+        {Currency: "EUR", Vendor: mtgcards.VENDOR_CARDMARKET},
+        {Currency: "USD", Vendor: mtgcards.VENDOR_CARDKINGDOM}}
+    for _, preference := range preferences {
+        resp := callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+            setPricePreference(logger, "alice", preference, done, respChan)
+        })
+        if resp.Type != PricePreferenceResponse {
+            t.Fatalf("Expected the preference to be set, got %v: %v", resp.Type, resp.Value)
+        }
+    }
+
+    resp := callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        pricePreference(logger, "alice", done, respChan)
+    })
+    if resp.Value != preferences[1] {
+        t.Errorf("Expected the last preference %v, got %v", preferences[1], resp.Value)
+    }
+
+    // Other users still get the default
+    resp = callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        pricePreference(logger, "bob", done, respChan)
+    })
+    defaultPreference := PricePreference{Currency: DEFAULT_PRICE_CURRENCY, Vendor: DEFAULT_PRICE_VENDOR}
+    if resp.Value != defaultPreference {
+        t.Errorf("Expected the default preference for bob, got %v", resp.Value)
+    }
+}
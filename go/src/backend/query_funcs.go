@@ -2,14 +2,17 @@ package backend
 
 import "context"
 import "fmt"
-import "log"
+import "log/slog"
 import "database/sql"
 import "strings"
 
 import "mtgcards"
 
-func sendError(done <-chan interface{}, respChan chan<- ResponseMessage, err error) {
-    log.Print(err)
+func sendError(logger *slog.Logger,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage,
+        err error) {
+    logger.Error("Error handling request", "err", err)
     select {
     case <-done:
     case respChan <- ResponseMessage{Type: ErrorResponse, Value: err}:
@@ -23,14 +26,15 @@ type CardSearchResult struct {
     SetKeyruneCode string `json:"setKeyruneCode"`
 }
 
-func cardSearch(db *sql.DB,
+func cardSearch(logger *slog.Logger,
+        db *sql.DB,
         request string,
         done <-chan interface{},
         respChan chan<- ResponseMessage) {
 
     dbConn, err := db.Conn(context.Background())
     if err != nil {
-        sendError(done, respChan, err)
+        sendError(logger, done, respChan, err)
         return
     }
     defer dbConn.Close()
@@ -46,11 +50,12 @@ func cardSearch(db *sql.DB,
         all_cards.name, all_cards.uuid, sets.name, sets.keyrune_code
         FROM
         all_cards INNER JOIN sets ON all_cards.set_id = sets.set_id
-        WHERE all_cards.name RLIKE ? OR all_cards.flavor_name RLIKE ?
+        WHERE (all_cards.name REGEXP ? OR all_cards.flavor_name REGEXP ?)
+        AND all_cards.removed_at IS NULL
         ORDER BY all_cards.name ASC`,
         processedName, processedName)
     if err != nil {
-        sendError(done, respChan, err)
+        sendError(logger, done, respChan, err)
         return
     }
     defer res.Close()
@@ -64,7 +69,7 @@ func cardSearch(db *sql.DB,
         var setKeyruneCode string
         err = res.Scan(&cardName, &cardUUID, &setName, &setKeyruneCode)
         if err != nil {
-            sendError(done, respChan, err)
+            sendError(logger, done, respChan, err)
             return
         }
         cards = append(cards,
@@ -75,7 +80,7 @@ func cardSearch(db *sql.DB,
                 SetKeyruneCode: setKeyruneCode})
     }
     if err = res.Err(); err != nil {
-        sendError(done, respChan, err)
+        sendError(logger, done, respChan, err)
         return
     }
 
@@ -91,14 +96,15 @@ type CardDetail struct {
     SetId int `json:"set_id"`
 }
 
-func cardDetail(db *sql.DB,
+func cardDetail(logger *slog.Logger,
+        db *sql.DB,
         uuid string,
         done <-chan interface{},
         respChan chan<- ResponseMessage) {
 
     dbConn, err := db.Conn(context.Background())
     if err != nil {
-        log.Print(err)
+        logger.Error("Error connecting to card db", "err", err)
         return
     }
     defer dbConn.Close()
@@ -235,8 +241,8 @@ func cardDetail(db *sql.DB,
         &card.Toughness,
         &card.Watermark)
     if err != nil {
-        log.Printf("Error parsing basic card info: %s", err)
-        sendError(done, respChan, err)
+        logger.Error("Error parsing basic card info", "uuid", uuid, "err", err)
+        sendError(logger, done, respChan, err)
         return
     }
 
@@ -286,8 +292,8 @@ func cardDetail(db *sql.DB,
         WHERE card_id = ?`,
         card.CardId)
     if err != nil {
-        log.Printf("Error getting card printings: %s", err)
-        sendError(done, respChan, err)
+        logger.Error("Error getting card printings", "uuid", uuid, "err", err)
+        sendError(logger, done, respChan, err)
         return
     }
     card.Printings = make([]string, 0)
@@ -295,7 +301,7 @@ func cardDetail(db *sql.DB,
         var setCode string
         err = printings.Scan(&setCode)
         if err != nil {
-            sendError(done, respChan, err)
+            sendError(logger, done, respChan, err)
             printings.Close()
             return
         }
@@ -303,7 +309,7 @@ func cardDetail(db *sql.DB,
     }
     printings.Close()
     if err = printings.Err(); err != nil {
-        sendError(done, respChan, err)
+        sendError(logger, done, respChan, err)
         return
     }
 
@@ -315,8 +321,8 @@ func cardDetail(db *sql.DB,
         WHERE card_id = ?`,
         card.CardId)
     if err != nil {
-        log.Printf("Error getting card variations: %s", err)
-        sendError(done, respChan, err)
+        logger.Error("Error getting card variations", "uuid", uuid, "err", err)
+        sendError(logger, done, respChan, err)
         return
     }
     card.Variations = make([]string, 0)
@@ -324,7 +330,7 @@ func cardDetail(db *sql.DB,
         var variationUUID string
         err = variations.Scan(&variationUUID)
         if err != nil {
-            sendError(done, respChan, err)
+            sendError(logger, done, respChan, err)
             variations.Close()
             return
         }
@@ -332,7 +338,7 @@ func cardDetail(db *sql.DB,
     }
     variations.Close()
     if err = variations.Err(); err != nil {
-        sendError(done, respChan, err)
+        sendError(logger, done, respChan, err)
         return
     }
 
@@ -348,7 +354,7 @@ func cardDetail(db *sql.DB,
         WHERE legalities.card_id = ?`,
         card.CardId)
     if err != nil {
-        sendError(done, respChan, err)
+        sendError(logger, done, respChan, err)
         return
     }
     card.Legalities = make(map[string]string)
@@ -357,7 +363,7 @@ func cardDetail(db *sql.DB,
         var legalityOption string
         err = legalities.Scan(&gameFormat, &legalityOption)
         if err != nil {
-            sendError(done, respChan, err)
+            sendError(logger, done, respChan, err)
             legalities.Close()
             return
         }
@@ -365,7 +371,7 @@ func cardDetail(db *sql.DB,
     }
     legalities.Close()
     if err = legalities.Err(); err != nil {
-        sendError(done, respChan, err)
+        sendError(logger, done, respChan, err)
         return
     }
 
@@ -379,8 +385,8 @@ func cardDetail(db *sql.DB,
         WHERE leadership_skills.card_id = ?`,
         card.CardId)
     if err != nil {
-        log.Printf("Error getting leadership skills: %s", err)
-        sendError(done, respChan, err)
+        logger.Error("Error getting leadership skills", "uuid", uuid, "err", err)
+        sendError(logger, done, respChan, err)
         return
     }
     card.LeadershipSkills = make(map[string]bool)
@@ -389,7 +395,7 @@ func cardDetail(db *sql.DB,
         var leaderLegal bool
         err = leadershipSkills.Scan(&leadershipFormat, &leaderLegal)
         if err != nil {
-            sendError(done, respChan, err)
+            sendError(logger, done, respChan, err)
             leadershipSkills.Close()
             return
         }
@@ -397,7 +403,7 @@ func cardDetail(db *sql.DB,
     }
     leadershipSkills.Close()
     if err = leadershipSkills.Err(); err != nil {
-        sendError(done, respChan, err)
+        sendError(logger, done, respChan, err)
         return
     }
 
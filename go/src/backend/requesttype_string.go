@@ -12,15 +12,25 @@ func _() {
 	_ = x[AuthUserRequest-1]
 	_ = x[CardSearchRequest-2]
 	_ = x[CardDetailRequest-3]
+	_ = x[CreateShareLinkRequest-4]
+	_ = x[RevokeShareLinkRequest-5]
+	_ = x[ShareLinksRequest-6]
+	_ = x[CreateCardListRequest-7]
+	_ = x[AddCardListEntryRequest-8]
+	_ = x[CardChangesRequest-9]
+	_ = x[LegalityChangesRequest-10]
+	_ = x[PricePreferenceRequest-11]
+	_ = x[SetPricePreferenceRequest-12]
 }
 
-const _RequestType_name = "ApiTypesRequestAuthUserRequestCardSearchRequestCardDetailRequest"
+const _RequestType_name = "ApiTypesRequestAuthUserRequestCardSearchRequestCardDetailRequestCreateShareLinkRequestRevokeShareLinkRequestShareLinksRequestCreateCardListRequestAddCardListEntryRequestCardChangesRequestLegalityChangesRequestPricePreferenceRequestSetPricePreferenceRequest"
 
-var _RequestType_index = [...]uint8{0, 15, 30, 47, 64}
+var _RequestType_index = [...]uint16{0, 15, 30, 47, 64, 86, 108, 125, 146, 169, 187, 209, 231, 256}
 
 func (i RequestType) String() string {
-	if i < 0 || i >= RequestType(len(_RequestType_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_RequestType_index)-1 {
 		return "RequestType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _RequestType_name[_RequestType_index[i]:_RequestType_index[i+1]]
+	return _RequestType_name[_RequestType_index[idx]:_RequestType_index[idx+1]]
 }
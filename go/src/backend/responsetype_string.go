@@ -13,15 +13,25 @@ func _() {
 	_ = x[AuthUserResponse-2]
 	_ = x[CardSearchResponse-3]
 	_ = x[CardDetailResponse-4]
+	_ = x[ShareLinkResponse-5]
+	_ = x[ShareLinkRevokedResponse-6]
+	_ = x[ShareLinksResponse-7]
+	_ = x[CardListResponse-8]
+	_ = x[CardListEntryResponse-9]
+	_ = x[CardChangesResponse-10]
+	_ = x[LegalityChangesResponse-11]
+	_ = x[LegalityChangesNotification-12]
+	_ = x[PricePreferenceResponse-13]
 }
 
-const _ResponseType_name = "ApiTypesResponseErrorResponseAuthUserResponseCardSearchResponseCardDetailResponse"
+const _ResponseType_name = "ApiTypesResponseErrorResponseAuthUserResponseCardSearchResponseCardDetailResponseShareLinkResponseShareLinkRevokedResponseShareLinksResponseCardListResponseCardListEntryResponseCardChangesResponseLegalityChangesResponseLegalityChangesNotificationPricePreferenceResponse"
 
-var _ResponseType_index = [...]uint8{0, 16, 29, 45, 63, 81}
+var _ResponseType_index = [...]uint16{0, 16, 29, 45, 63, 81, 98, 122, 140, 156, 177, 196, 219, 246, 269}
 
 func (i ResponseType) String() string {
-	if i < 0 || i >= ResponseType(len(_ResponseType_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_ResponseType_index)-1 {
 		return "ResponseType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _ResponseType_name[_ResponseType_index[i]:_ResponseType_index[i+1]]
+	return _ResponseType_name[_ResponseType_index[idx]:_ResponseType_index[idx+1]]
 }
@@ -0,0 +1,439 @@
+package backend
+
+import "crypto/rand"
+import "database/sql"
+import "dbconn"
+import "encoding/base64"
+import "encoding/json"
+import "fmt"
+import "html/template"
+import "log/slog"
+import "net/http"
+import "strings"
+import "time"
+
+const (
+    SHARE_JSON_PATH = "/backend/share/json/"
+    SHARE_VIEW_PATH = "/backend/share/view/"
+    SHARE_TOKEN_BYTES = 32
+    CARD_IMAGE_URL_TEMPLATE = "/static_content/card_face_images/%s.png"
+)
+
+type ShareLinkRequest struct {
+    ListId int `json:"list_id"`
+}
+
+type ShareLinkRevocation struct {
+    Token string `json:"token"`
+}
+
+type ShareLink struct {
+    ListId int `json:"list_id"`
+    Token string `json:"token"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type SharedList struct {
+    Name string `json:"name"`
+    Type string `json:"type"`
+    Cards []SharedListCard `json:"cards"`
+}
+
+type SharedListCard struct {
+    Name string `json:"name"`
+    UUID string `json:"uuid"`
+    SetName string `json:"setName"`
+    SetKeyruneCode string `json:"setKeyruneCode"`
+    Quantity int `json:"quantity"`
+    IsFoil bool `json:"isFoil"`
+    ImageURL string `json:"imageUrl,omitempty"`
+}
+
+var sharedListPageTemplate = template.Must(template.New("shared_list").Parse(
+`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<link rel="stylesheet" href="/keyrune/css/keyrune.min.css">
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td { padding: 0.25em 0.75em; vertical-align: middle; }
+img { height: 120px; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>Shared {{.Type}}, {{len .Cards}} entries</p>
+<table>
+{{range .Cards}}<tr>
+<td>{{.Quantity}}x</td>
+<td><i class="ss ss-{{.SetKeyruneCode}}" title="{{.SetName}}"></i></td>
+<td>{{.Name}}{{if .IsFoil}} (foil){{end}}</td>
+<td>{{.SetName}}</td>
+<td>{{if .ImageURL}}<img src="{{.ImageURL}}" alt="{{.Name}}" loading="lazy">{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func generateShareToken() (string, error) {
+    tokenBytes := make([]byte, SHARE_TOKEN_BYTES)
+    _, err := rand.Read(tokenBytes)
+    if err != nil {
+        return "", err
+    }
+
+    return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
+
+func isValidShareToken(token string) bool {
+    // Reject anything that couldn't have come from generateShareToken before
+    // we bother the database with it
+    tokenBytes, err := base64.RawURLEncoding.DecodeString(token)
+    if err != nil {
+        return false
+    }
+    return len(tokenBytes) == SHARE_TOKEN_BYTES
+}
+
+func getUserId(userDB *sql.DB, subject string) (int, error) {
+    res := userDB.QueryRow(`SELECT user_id
+            FROM user_info
+            WHERE user_name = ?`,
+            subject)
+
+    var userId int
+    err := res.Scan(&userId)
+    if err != nil {
+        return 0, err
+    }
+
+    return userId, nil
+}
+
+func createShareLink(logger *slog.Logger,
+        subject string,
+        request ShareLinkRequest,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    userId, err := getUserId(userDB, subject)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    // Only the owner of a list is allowed to share it
+    owned, err := listOwnedBy(userDB, request.ListId, userId)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    if !owned {
+        sendError(logger, done, respChan, fmt.Errorf("No list %d found for user %s",
+            request.ListId, subject))
+        return
+    }
+
+    token, err := generateShareToken()
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    shareLink := ShareLink{
+        ListId: request.ListId,
+        Token: token,
+        CreatedAt: time.Now().UTC()}
+    _, err = userDB.Exec(`INSERT INTO share_tokens
+            (share_token, list_id, created_at)
+            VALUES
+            (?, ?, ?)`,
+            shareLink.Token, shareLink.ListId, shareLink.CreatedAt)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: ShareLinkResponse, Value: shareLink}:
+    }
+}
+
+func revokeShareLink(logger *slog.Logger,
+        subject string,
+        revocation ShareLinkRevocation,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    userId, err := getUserId(userDB, subject)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    // Tokens are kept around after revocation rather than deleted, so that
+    // a revoked token can never be handed out again
+    res, err := userDB.Exec(`UPDATE share_tokens
+            SET revoked_at = ?
+            WHERE share_token = ?
+            AND revoked_at IS NULL
+            AND list_id IN (SELECT list_id FROM card_lists WHERE user_id = ?)`,
+            time.Now().UTC(), revocation.Token, userId)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    if rowsAffected == 0 {
+        sendError(logger, done, respChan, fmt.Errorf("No active share link found for user %s",
+            subject))
+        return
+    }
+
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: ShareLinkRevokedResponse, Value: revocation}:
+    }
+}
+
+func shareLinks(logger *slog.Logger,
+        subject string,
+        request ShareLinkRequest,
+        done <-chan interface{},
+        respChan chan<- ResponseMessage) {
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    userId, err := getUserId(userDB, subject)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    res, err := userDB.Query(`SELECT
+            share_tokens.share_token, share_tokens.created_at
+            FROM
+            share_tokens INNER JOIN card_lists
+            ON share_tokens.list_id = card_lists.list_id
+            WHERE share_tokens.list_id = ?
+            AND card_lists.user_id = ?
+            AND share_tokens.revoked_at IS NULL
+            ORDER BY share_tokens.created_at ASC`,
+            request.ListId, userId)
+    if err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+    defer res.Close()
+
+    links := make([]ShareLink, 0)
+    for res.Next() {
+        link := ShareLink{ListId: request.ListId}
+        err = res.Scan(&link.Token, &link.CreatedAt)
+        if err != nil {
+            sendError(logger, done, respChan, err)
+            return
+        }
+        links = append(links, link)
+    }
+    if err = res.Err(); err != nil {
+        sendError(logger, done, respChan, err)
+        return
+    }
+
+    select {
+    case <-done:
+    case respChan <- ResponseMessage{Type: ShareLinksResponse, Value: links}:
+    }
+}
+
+func fetchSharedList(logger *slog.Logger,
+        userDB *sql.DB,
+        cardDB *sql.DB,
+        token string) (SharedList, bool, error) {
+    var list SharedList
+    var listId int
+
+    res := userDB.QueryRow(`SELECT
+            card_lists.list_id, card_lists.list_name, card_lists.list_type
+            FROM
+            share_tokens INNER JOIN card_lists
+            ON share_tokens.list_id = card_lists.list_id
+            WHERE share_tokens.share_token = ?
+            AND share_tokens.revoked_at IS NULL`,
+            token)
+    err := res.Scan(&listId, &list.Name, &list.Type)
+    if err == sql.ErrNoRows {
+        return list, false, nil
+    } else if err != nil {
+        return list, false, err
+    }
+
+    entries, err := userDB.Query(`SELECT card_uuid, quantity, is_foil
+            FROM card_list_entries
+            WHERE list_id = ?
+            ORDER BY list_entry_id ASC`,
+            listId)
+    if err != nil {
+        return list, false, err
+    }
+    defer entries.Close()
+
+    list.Cards = make([]SharedListCard, 0)
+    for entries.Next() {
+        var card SharedListCard
+        err = entries.Scan(&card.UUID, &card.Quantity, &card.IsFoil)
+        if err != nil {
+            return list, false, err
+        }
+        list.Cards = append(list.Cards, card)
+    }
+    if err = entries.Err(); err != nil {
+        return list, false, err
+    }
+
+    // Fill in the card details from the card db
+    for idx := range list.Cards {
+        card := &list.Cards[idx]
+
+        var imageCachedLocally bool
+        cardInfo := cardDB.QueryRow(`SELECT
+                all_cards.name, sets.name, sets.keyrune_code, all_cards.image_cached_locally
+                FROM
+                all_cards INNER JOIN sets ON all_cards.set_id = sets.set_id
+                WHERE all_cards.uuid = ?`,
+                card.UUID)
+        err = cardInfo.Scan(&card.Name, &card.SetName, &card.SetKeyruneCode,
+            &imageCachedLocally)
+        if err == sql.ErrNoRows {
+            logger.Warn("Shared list entry references unknown card", "uuid", card.UUID)
+            continue
+        } else if err != nil {
+            return list, false, err
+        }
+
+        card.SetKeyruneCode = strings.ToLower(card.SetKeyruneCode)
+        if imageCachedLocally {
+            card.ImageURL = fmt.Sprintf(CARD_IMAGE_URL_TEMPLATE, card.UUID)
+        }
+    }
+
+    return list, true, nil
+}
+
+func loadSharedList(logger *slog.Logger,
+        resp http.ResponseWriter,
+        req *http.Request,
+        pathPrefix string) (SharedList, bool) {
+    token := strings.TrimPrefix(req.URL.Path, pathPrefix)
+    if !isValidShareToken(token) {
+        http.NotFound(resp, req)
+        return SharedList{}, false
+    }
+
+    userDB, err := dbconn.Open(backendConfig.UserDB)
+    if err != nil {
+        logger.Error("Error connecting to users db", "err", err)
+        sendHttpError(resp)
+        return SharedList{}, false
+    }
+    defer userDB.Close()
+    defer trackDBPool(USER_DB_POOL, userDB)()
+
+    cardDB, err := dbconn.Open(backendConfig.CardDB)
+    if err != nil {
+        logger.Error("Error connecting to card db", "err", err)
+        sendHttpError(resp)
+        return SharedList{}, false
+    }
+    defer cardDB.Close()
+    defer trackDBPool(CARD_DB_POOL, cardDB)()
+
+    list, found, err := fetchSharedList(logger, userDB, cardDB, token)
+    if err != nil {
+        logger.Error("Error fetching shared list", "err", err)
+        sendHttpError(resp)
+        return SharedList{}, false
+    }
+    if !found {
+        http.NotFound(resp, req)
+        return SharedList{}, false
+    }
+
+    return list, true
+}
+
+func HandleSharedListJson(resp http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodGet {
+        resp.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    logger := httpRequestLogger(logger, req)
+    list, ok := loadSharedList(logger, resp, req, SHARE_JSON_PATH)
+    if !ok {
+        return
+    }
+
+    listJson, err := json.Marshal(list)
+    if err != nil {
+        logger.Error("Error marshalling shared list json", "err", err)
+        sendHttpError(resp)
+        return
+    }
+
+    resp.Header().Set("Content-Type", "application/json")
+    resp.Header().Set("Access-Control-Allow-Origin", "*")
+    _, err = resp.Write(listJson)
+    if err != nil {
+        logger.Warn("Error writing shared list response", "err", err)
+    }
+}
+
+func HandleSharedListPage(resp http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodGet {
+        resp.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    logger := httpRequestLogger(logger, req)
+    list, ok := loadSharedList(logger, resp, req, SHARE_VIEW_PATH)
+    if !ok {
+        return
+    }
+
+    resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+    err := sharedListPageTemplate.Execute(resp, list)
+    if err != nil {
+        logger.Warn("Error rendering shared list page", "err", err)
+    }
+}
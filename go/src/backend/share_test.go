@@ -0,0 +1,286 @@
+package backend
+
+import "carddb"
+import "config"
+import "context"
+import "database/sql"
+import "dbconn"
+import "io/ioutil"
+import "log/slog"
+import "migrations"
+import "mtgcards"
+import "net/http"
+import "net/http/httptest"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+
+const TEST_CARD_UUID = "AAA-card-1"
+
+func openTestDB(t *testing.T, dbConfig config.DatabaseConfig, schema *migrations.Schema) *sql.DB {
+    db, err := dbconn.Open(dbConfig)
+    if err != nil {
+        t.Fatal(err)
+    }
+    err = migrations.Migrate(db, dbConfig.Driver, schema, schema.LatestVersion())
+    if err != nil {
+        db.Close()
+        t.Fatal(err)
+    }
+    return db
+}
+
+// setupTestDBs points backendConfig at fresh SQLite user and card dbs,
+// with users alice and bob and a single card to put in lists
+func setupTestDBs(t *testing.T) (*sql.DB, func()) {
+    dir, err := ioutil.TempDir("", "backend")
+    if err != nil {
+        t.Fatal(err)
+    }
+    backendConfig = &config.Config{
+        UserDB: config.DatabaseConfig{
+            Driver: config.SQLITE_DRIVER,
+            Path: filepath.Join(dir, "users.db")},
+        CardDB: config.DatabaseConfig{
+            Driver: config.SQLITE_DRIVER,
+            Path: filepath.Join(dir, "cards.db")}}
+
+    userDB := openTestDB(t, backendConfig.UserDB, &migrations.UsersDBSchema)
+    defer userDB.Close()
+    for _, name := range []string{"alice", "bob"} {
+        _, err = userDB.Exec(`INSERT INTO user_info
+                (pw_hash, user_name, email, first_name, last_name)
+                VALUES
+                ('', ?, ?, ?, '')`,
+                name, name + "@example.com", name)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    cardDB := openTestDB(t, backendConfig.CardDB, &migrations.CardDBSchema)
+    card := mtgcards.MTGCard{}
+    card.UUID = TEST_CARD_UUID
+    card.Name = "Llanowar Elves"
+    card.Number = "1"
+    card.Layout = "normal"
+    card.Types = []string{"Creature"}
+    set := mtgcards.MTGSet{
+        Code: "AAA",
+        Name: "Set AAA",
+        ReleaseDate: "2020-06-01",
+        Type: "expansion",
+        BaseSetSize: 1,
+        TotalSetSize: 1,
+        Cards: []mtgcards.MTGCard{card}}
+    setChan := make(chan mtgcards.MTGSet, 1)
+    setChan <- set
+    close(setChan)
+    _, err = carddb.ImportSetsToDB(context.Background(), cardDB, setChan,
+        carddb.ImportOptions{Workers: 1})
+    if err != nil {
+        cardDB.Close()
+        t.Fatal(err)
+    }
+
+    cleanup := func() {
+        cardDB.Close()
+        os.RemoveAll(dir)
+        backendConfig = nil
+    }
+    return cardDB, cleanup
+}
+
+// callHandler runs one of the websocket request handlers and returns what
+// it sent back
+func callHandler(t *testing.T,
+        handler func(*slog.Logger, <-chan interface{}, chan<- ResponseMessage)) ResponseMessage {
+    done := make(chan interface{})
+    defer close(done)
+    respChan := make(chan ResponseMessage, 1)
+
+    handler(logger, done, respChan)
+    select {
+    case resp := <-respChan:
+        return resp
+    default:
+        t.Fatal("Handler didn't send a response")
+        return ResponseMessage{}
+    }
+}
+
+func fetchShared(token string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(http.MethodGet, SHARE_JSON_PATH + token, nil)
+    resp := httptest.NewRecorder()
+    HandleSharedListJson(resp, req)
+    return resp
+}
+
+func TestShareLinkLifecycle(t *testing.T) {
+    cardDB, cleanup := setupTestDBs(t)
+    defer cleanup()
+
+    resp := callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        createCardList(logger, "alice", NewCardList{Name: "Elves", Type: "deck"}, done, respChan)
+    })
+    if resp.Type != CardListResponse {
+        t.Fatalf("Expected a list to be created, got %v: %v", resp.Type, resp.Value)
+    }
+    list := resp.Value.(CardList)
+
+    resp = callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        addCardListEntry(logger, "alice", cardDB,
+            NewCardListEntry{ListId: list.ListId, CardUUID: TEST_CARD_UUID}, done, respChan)
+    })
+    if resp.Type != CardListEntryResponse {
+        t.Fatalf("Expected an entry to be added, got %v: %v", resp.Type, resp.Value)
+    }
+    if entry := resp.Value.(CardListEntry); entry.Quantity != 1 {
+        t.Errorf("Expected the quantity to default to 1, got %d", entry.Quantity)
+    }
+
+    resp = callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        createShareLink(logger, "alice", ShareLinkRequest{ListId: list.ListId}, done, respChan)
+    })
+    if resp.Type != ShareLinkResponse {
+        t.Fatalf("Expected a share link, got %v: %v", resp.Type, resp.Value)
+    }
+    link := resp.Value.(ShareLink)
+
+    shared := fetchShared(link.Token)
+    if shared.Code != http.StatusOK {
+        t.Fatalf("Expected the shared list to be found, got status %d", shared.Code)
+    }
+    if !strings.Contains(shared.Body.String(), "Llanowar Elves") {
+        t.Errorf("Expected the shared list to include its card, got %s", shared.Body.String())
+    }
+
+    resp = callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        revokeShareLink(logger, "alice", ShareLinkRevocation{Token: link.Token}, done, respChan)
+    })
+    if resp.Type != ShareLinkRevokedResponse {
+        t.Fatalf("Expected the link to be revoked, got %v: %v", resp.Type, resp.Value)
+    }
+
+    shared = fetchShared(link.Token)
+    if shared.Code != http.StatusNotFound {
+        t.Errorf("Expected a revoked link to be not found, got status %d", shared.Code)
+    }
+}
+
+func TestCardListOwnerCheck(t *testing.T) {
+    cardDB, cleanup := setupTestDBs(t)
+    defer cleanup()
+
+    resp := callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        createCardList(logger, "alice", NewCardList{Name: "Trades", Type: "trade"}, done, respChan)
+    })
+    if resp.Type != CardListResponse {
+        t.Fatalf("Expected a list to be created, got %v: %v", resp.Type, resp.Value)
+    }
+    list := resp.Value.(CardList)
+
+    resp = callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        createShareLink(logger, "alice", ShareLinkRequest{ListId: list.ListId}, done, respChan)
+    })
+    if resp.Type != ShareLinkResponse {
+        t.Fatalf("Expected a share link, got %v: %v", resp.Type, resp.Value)
+    }
+    link := resp.Value.(ShareLink)
+
+    // Nothing bob asks for is allowed to touch alice's list
+    resp = callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        addCardListEntry(logger, "bob", cardDB,
+            NewCardListEntry{ListId: list.ListId, CardUUID: TEST_CARD_UUID}, done, respChan)
+    })
+    if resp.Type != ErrorResponse {
+        t.Errorf("Expected bob adding to alice's list to fail, got %v", resp.Type)
+    }
+
+    resp = callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        createShareLink(logger, "bob", ShareLinkRequest{ListId: list.ListId}, done, respChan)
+    })
+    if resp.Type != ErrorResponse {
+        t.Errorf("Expected bob sharing alice's list to fail, got %v", resp.Type)
+    }
+
+    resp = callHandler(t, func(logger *slog.Logger, done <-chan interface{}, respChan chan<- ResponseMessage) {
+        revokeShareLink(logger, "bob", ShareLinkRevocation{Token: link.Token}, done, respChan)
+    })
+    if resp.Type != ErrorResponse {
+        t.Errorf("Expected bob revoking alice's link to fail, got %v", resp.Type)
+    }
+
+    if shared := fetchShared(link.Token); shared.Code != http.StatusOK {
+        t.Errorf("Expected alice's link to still work, got status %d", shared.Code)
+    }
+}
+
+func sharedListRequest(method string, token string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, SHARE_JSON_PATH + token, nil)
+    resp := httptest.NewRecorder()
+    HandleSharedListJson(resp, req)
+    return resp
+}
+
+func TestShareTokens(t *testing.T) {
+    token, err := generateShareToken()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !isValidShareToken(token) {
+        t.Errorf("Expected generated token %q to be valid", token)
+    }
+
+    other, err := generateShareToken()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if other == token {
+        t.Error("Expected every generated token to be different")
+    }
+
+    for _, invalid := range []string{"", "abc", token[1:], token + "AA", token[:10] + "+" + token[11:]} {
+        if isValidShareToken(invalid) {
+            t.Errorf("Expected %q not to be a valid token", invalid)
+        }
+    }
+}
+
+func TestSharedListRejectsBadRequests(t *testing.T) {
+    // None of these get as far as the database
+    for _, token := range []string{"", "abc", strings.Repeat("A", 20)} {
+        resp := sharedListRequest(http.MethodGet, token)
+        if resp.Code != http.StatusNotFound {
+            t.Errorf("Expected status 404 for token %q, got %d", token, resp.Code)
+        }
+    }
+
+    resp := sharedListRequest(http.MethodPost, strings.Repeat("A", 43))
+    if resp.Code != http.StatusMethodNotAllowed {
+        t.Errorf("Expected status 405 for a POST, got %d", resp.Code)
+    }
+}
+
+func TestNewCardListValidation(t *testing.T) {
+    for _, test := range []struct {
+        list NewCardList
+        valid bool
+    }{
+        {NewCardList{Name: "Elves", Type: "deck"}, true},
+        {NewCardList{Name: "Binder", Type: "collection"}, true},
+        {NewCardList{Name: "Trades", Type: "trade"}, true},
+        {NewCardList{Name: strings.Repeat("a", MAX_LIST_NAME_LENGTH), Type: "deck"}, true},
+        {NewCardList{Name: "", Type: "deck"}, false},
+        {NewCardList{Name: strings.Repeat("a", MAX_LIST_NAME_LENGTH + 1), Type: "deck"}, false},
+        {NewCardList{Name: "Elves", Type: "wishlist"}, false},
+        {NewCardList{Name: "Elves", Type: "Deck"}, false}} {
+        err := test.list.validate()
+        if test.valid && err != nil {
+            t.Errorf("Expected %+v to be valid, got %s", test.list, err)
+        } else if !test.valid && err == nil {
+            t.Errorf("Expected %+v to be invalid", test.list)
+        }
+    }
+}
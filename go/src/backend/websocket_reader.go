@@ -3,72 +3,99 @@ package backend
 import "net/http"
 import "io/ioutil"
 import "encoding/json"
-import "fmt"
-import "log"
-import "database/sql"
-import _ "github.com/go-sql-driver/mysql"
+import "log/slog"
+import "logging"
+import "dbconn"
+import "sync/atomic"
+import "time"
 import "github.com/gorilla/websocket"
 
-const (
-    DB_HOST = "card_db:3306"
-    CARD_DB = "mtg_cards"
-    USER_DB = "users"
-    APP_DB_USER = "app_user"
-    APP_DB_PW = "app_db_password"
-    LOGIN_DB_USER = "login_user"
-    LOGIN_DB_PW = "login_user_password"
-)
-
-func checkOrigin(req *http.Request) bool {
-    origin := req.Header["Origin"]
-    log.Printf("Websocket connection from origin: %s\n", origin)
+import "config"
 
-    return true
+var backendConfig *config.Config
+
+var logger = logging.For("backend")
+
+// Every websocket connection and every request gets its own ID, which is
+// added to everything logged while handling it
+var lastConnectionId uint64
+var lastRequestId uint64
+
+// Configure must be called before any of the handlers are registered
+func Configure(newConfig *config.Config) {
+    backendConfig = newConfig
 }
 
-func dbConnStr(user string, pw string, db string) string {
-    return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true",
-            user, pw, DB_HOST, db)
+// httpRequestLogger returns logger with a new request ID, and where the
+// request came from, added.  The path isn't added, since a shared list's
+// path has its share token in it.
+func httpRequestLogger(logger *slog.Logger, req *http.Request) *slog.Logger {
+    return logger.With("request_id", atomic.AddUint64(&lastRequestId, 1),
+        "remote_addr", req.RemoteAddr)
+}
+
+// requestLogger returns connLogger with a new request ID, and the type of
+// the websocket request, added
+func requestLogger(connLogger *slog.Logger, requestType RequestType) *slog.Logger {
+    return connLogger.With("request_id", atomic.AddUint64(&lastRequestId, 1),
+        "request_type", requestType.String())
+}
+
+func checkOrigin(logger *slog.Logger, req *http.Request) bool {
+    origin := req.Header["Origin"]
+    logger.Debug("Websocket connection", "origin", origin)
+
+    return true
 }
 
 func HandleApi(resp http.ResponseWriter, req *http.Request) {
-    log.Printf("Accepted connection from %s...\n", req.RemoteAddr)
+    connId := atomic.AddUint64(&lastConnectionId, 1)
+    connLogger := logger.With("conn_id", connId, "remote_addr", req.RemoteAddr)
+    connLogger.Info("Accepted connection")
 
-    upgrader := websocket.Upgrader{CheckOrigin: checkOrigin}
+    upgrader := websocket.Upgrader{CheckOrigin: func(req *http.Request) bool {
+        return checkOrigin(connLogger, req)
+    }}
     conn, err := upgrader.Upgrade(resp, req, nil)
     if err != nil {
-        log.Print(err)
+        connLogger.Warn("Error upgrading connection to websocket", "err", err)
         return
     }
     defer conn.Close()
 
-    log.Printf("Successfully upgraded accepted connection from %s to websocket\n",
-        req.RemoteAddr)
+    connLogger.Info("Upgraded connection to websocket")
+    defer connLogger.Info("Websocket closed")
+    websocketConnectionsTotal.Inc()
+    websocketConnections.Inc()
+    defer websocketConnections.Dec()
 
     // Connect to the mariadb database
-    cardDB, err := sql.Open("mysql", dbConnStr(APP_DB_USER, APP_DB_PW, CARD_DB))
+    cardDB, err := dbconn.Open(backendConfig.CardDB)
 	if err != nil {
-		log.Print(err)
+		connLogger.Error("Error connecting to card db", "err", err)
         return
 	}
 
 	defer cardDB.Close()
+	defer trackDBPool(CARD_DB_POOL, cardDB)()
+
 	cardDB.SetMaxIdleConns(10)
 
     doneChan := make(chan interface{})
 
     // Start a goroutine to handle writing responses back to the websocket
     respChan := make(chan ResponseMessage)
-    go websocketResponder(conn, doneChan, respChan)
+    go websocketResponder(connLogger, conn, doneChan, respChan)
 
     // We wait for the client to authorize this socket by sending their access token
     // Before this socket is authorized, we only respond to a limited subset of requests
     socketAuthorized := false
+    socketSubject := ""
 
     done := false
     for !done {
         if messageType, reader, err := conn.NextReader(); err != nil {
-            log.Print(err)
+            connLogger.Debug("Error reading from websocket", "err", err)
             close(doneChan)
             conn.Close()
             done = true
@@ -80,13 +107,13 @@ func HandleApi(resp http.ResponseWriter, req *http.Request) {
             case websocket.TextMessage:
                 rawMessage, err := ioutil.ReadAll(reader)
                 if err != nil {
-                    log.Print(err)
+                    connLogger.Warn("Error reading message", "err", err)
                     continue
                 }
                 var message RequestMessage
                 err = json.Unmarshal([]byte(rawMessage), &message)
                 if err != nil {
-                    log.Print(err)
+                    connLogger.Warn("Error unmarshalling message", "err", err)
                     continue
                 }
 
@@ -95,18 +122,34 @@ func HandleApi(resp http.ResponseWriter, req *http.Request) {
                 if !socketAuthorized {
                     switch message.Type {
                     case ApiTypesRequest:
-                        go apiTypes(doneChan, respChan)
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            apiTypes(doneChan, respChan)
+                        })
                     case AuthUserRequest:
                         var authRequest AuthRequest
                         err = json.Unmarshal([]byte(message.Value), &authRequest)
                         if err != nil {
-                            log.Print(err)
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
                             continue
                         }
-                        socketAuthorized = authorizeToken(authRequest.Subject,
-                                authRequest.AuthToken, doneChan, respChan)
+                        // Handled in line, since the requests after it depend
+                        // on whether it succeeds
+                        authStart := time.Now()
+                        socketAuthorized = authorizeToken(
+                                requestLogger(authLogger.With("conn_id", connId), message.Type),
+                                authRequest.Subject, authRequest.AuthToken, doneChan, respChan)
+                        recordRequest(message.Type, authStart)
+                        if !socketAuthorized {
+                            recordAuthFailure(AUTH_FAILURE_INVALID_TOKEN)
+                        } else {
+                            socketSubject = authRequest.Subject
+                            go watchLegalityChanges(connLogger, socketSubject, cardDB, doneChan, respChan)
+                        }
                     default:
-                        log.Printf("Attempt to call API %d on unauthorized socket", message.Type)
+                        connLogger.Warn("Attempt to call API on unauthorized socket",
+                            "request_type", message.Type.String())
+                        recordAuthFailure(AUTH_FAILURE_UNAUTHORIZED_REQUEST)
                     }
                 } else {
                     // Don't bother handling API types or auth messages here,
@@ -117,23 +160,121 @@ func HandleApi(resp http.ResponseWriter, req *http.Request) {
                         var searchName string
                         err = json.Unmarshal([]byte(message.Value), &searchName)
                         if err != nil {
-                            log.Print(err)
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
                             continue
                         }
-                        go cardSearch(cardDB, searchName, doneChan, respChan)
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            cardSearch(logger, cardDB, searchName, doneChan, respChan)
+                        })
                     case CardDetailRequest:
                         var cardUUID string
                         err = json.Unmarshal([]byte(message.Value), &cardUUID)
                         if err != nil {
-                            log.Print(err)
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
+                            continue
+                        }
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            cardDetail(logger, cardDB, cardUUID, doneChan, respChan)
+                        })
+                    case CreateShareLinkRequest:
+                        var shareRequest ShareLinkRequest
+                        err = json.Unmarshal([]byte(message.Value), &shareRequest)
+                        if err != nil {
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
+                            continue
+                        }
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            createShareLink(logger, socketSubject, shareRequest, doneChan, respChan)
+                        })
+                    case RevokeShareLinkRequest:
+                        var revocation ShareLinkRevocation
+                        err = json.Unmarshal([]byte(message.Value), &revocation)
+                        if err != nil {
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
+                            continue
+                        }
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            revokeShareLink(logger, socketSubject, revocation, doneChan, respChan)
+                        })
+                    case ShareLinksRequest:
+                        var shareRequest ShareLinkRequest
+                        err = json.Unmarshal([]byte(message.Value), &shareRequest)
+                        if err != nil {
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
+                            continue
+                        }
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            shareLinks(logger, socketSubject, shareRequest, doneChan, respChan)
+                        })
+                    case CreateCardListRequest:
+                        var newList NewCardList
+                        err = json.Unmarshal([]byte(message.Value), &newList)
+                        if err != nil {
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
+                            continue
+                        }
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            createCardList(logger, socketSubject, newList, doneChan, respChan)
+                        })
+                    case AddCardListEntryRequest:
+                        var newEntry NewCardListEntry
+                        err = json.Unmarshal([]byte(message.Value), &newEntry)
+                        if err != nil {
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
+                            continue
+                        }
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            addCardListEntry(logger, socketSubject, cardDB, newEntry, doneChan, respChan)
+                        })
+                    case CardChangesRequest:
+                        var cardUUID string
+                        err = json.Unmarshal([]byte(message.Value), &cardUUID)
+                        if err != nil {
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
+                            continue
+                        }
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            cardChanges(logger, cardDB, cardUUID, doneChan, respChan)
+                        })
+                    case LegalityChangesRequest:
+                        var changesFilter LegalityChangesFilter
+                        err = json.Unmarshal([]byte(message.Value), &changesFilter)
+                        if err != nil {
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
+                            continue
+                        }
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            legalityChanges(logger, socketSubject, cardDB, changesFilter, doneChan, respChan)
+                        })
+                    case PricePreferenceRequest:
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            pricePreference(logger, socketSubject, doneChan, respChan)
+                        })
+                    case SetPricePreferenceRequest:
+                        var preference PricePreference
+                        err = json.Unmarshal([]byte(message.Value), &preference)
+                        if err != nil {
+                            connLogger.Warn("Error unmarshalling request", "request_type",
+                                message.Type.String(), "err", err)
                             continue
                         }
-                        go cardDetail(cardDB, cardUUID, doneChan, respChan)
+                        handleRequest(connLogger, message.Type, func(logger *slog.Logger) {
+                            setPricePreference(logger, socketSubject, preference, doneChan, respChan)
+                        })
                     }
                 }
 
             default:
-                log.Printf("Received an unexpected message type: %d", messageType)
+                connLogger.Warn("Received an unexpected message type", "message_type", messageType)
             }
         }
     }
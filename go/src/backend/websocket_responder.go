@@ -1,9 +1,10 @@
 package backend
 
-import "log"
+import "log/slog"
 import "github.com/gorilla/websocket"
 
-func websocketResponder(conn *websocket.Conn,
+func websocketResponder(logger *slog.Logger,
+        conn *websocket.Conn,
         done <-chan interface{},
         responses <-chan ResponseMessage) {
     processing := true
@@ -15,7 +16,8 @@ func websocketResponder(conn *websocket.Conn,
         case response := <-responses:
             err := conn.WriteJSON(response)
             if err != nil {
-                log.Print(err)
+                logger.Warn("Error writing response", "response_type", response.Type.String(),
+                    "err", err)
             }
         }
     }
@@ -1,14 +1,22 @@
 package main
 
+import "config"
 import "database/sql"
-import _ "github.com/go-sql-driver/mysql"
+import "dbconn"
+import "flag"
 import "fmt"
 import "io"
-import "log"
+import "logging"
 import "net/http"
 import "os"
+import "path/filepath"
 import "time"
 
+var logger = logging.For("card-image-downloader")
+
+// How many records go by between progress lines, at debug level
+const PROGRESS_INTERVAL = 100
+
 type CardImageRecord struct {
     ScryfallId string
     ImageCachedLocally bool
@@ -16,11 +24,22 @@ type CardImageRecord struct {
 }
 
 func main() {
+    configPath := flag.String("config", "", "Path to the JSON config file")
+    flag.Parse()
+
+    downloaderConfig, err := config.Load(*configPath)
+    if err != nil {
+        logging.Fatal(logger, "Error loading config", "err", err)
+    }
+    err = downloaderConfig.ConfigureLogging()
+    if err != nil {
+        logging.Fatal(logger, "Error configuring logging", "err", err)
+    }
+
 	// Connect to the mariadb database
-	cardDB, err := sql.Open("mysql",
-        "app_user:app_db_password@tcp(172.18.0.3)/mtg_cards?parseTime=true")
+	cardDB, err := dbconn.Open(downloaderConfig.CardDB)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal(logger, "Error connecting to card db", "err", err)
         return
 	}
 	defer cardDB.Close()
@@ -34,7 +53,7 @@ func main() {
         image_cached_locally
         FROM all_cards`)
     if err != nil {
-        log.Fatal(err)
+        logging.Fatal(logger, "Error preparing card query", "err", err)
     }
     defer cardInfoQuery.Close()
 
@@ -44,7 +63,7 @@ func main() {
         image_cached_locally
         FROM all_tokens`)
     if err != nil {
-        log.Fatal(err)
+        logging.Fatal(logger, "Error preparing token query", "err", err)
     }
     defer tokenInfoQuery.Close()
 
@@ -52,7 +71,7 @@ func main() {
         SET image_cached_locally = 1
         WHERE uuid = ?`)
     if err != nil {
-        log.Fatal(err)
+        logging.Fatal(logger, "Error preparing card update", "err", err)
     }
     defer cardUpdateQuery.Close()
 
@@ -60,14 +79,14 @@ func main() {
         SET image_cached_locally = 1
         WHERE uuid = ?`)
     if err != nil {
-        log.Fatal(err)
+        logging.Fatal(logger, "Error preparing token update", "err", err)
     }
     defer tokenUpdateQuery.Close()
 
     // First, get all of the cards and tokens we're downloading images for
     allCards, err := cardInfoQuery.Query()
     if err != nil {
-        log.Fatal(err)
+        logging.Fatal(logger, "Error fetching cards", "err", err)
     }
 
     for allCards.Next() {
@@ -76,7 +95,7 @@ func main() {
         var imageCachedLocally bool
         err = allCards.Scan(&uuid, &scryfallId, &imageCachedLocally)
         if err != nil {
-            log.Print(err)
+            logger.Error("Error reading card", "err", err)
             continue
         }
         scryfallImageRecords[uuid] = CardImageRecord{
@@ -85,13 +104,13 @@ func main() {
             IsToken: false}
     }
     if err = allCards.Err(); err != nil {
-        log.Print(err)
+        logger.Error("Error reading cards", "err", err)
     }
     allCards.Close()
 
     allTokens, err := tokenInfoQuery.Query()
     if err != nil {
-        log.Fatal(err)
+        logging.Fatal(logger, "Error fetching tokens", "err", err)
     }
 
     for allTokens.Next() {
@@ -100,7 +119,7 @@ func main() {
         var imageCachedLocally bool
         err = allTokens.Scan(&uuid, &scryfallId, &imageCachedLocally)
         if err != nil {
-            log.Print(err)
+            logger.Error("Error reading token", "err", err)
             continue
         }
         scryfallImageRecords[uuid] = CardImageRecord{
@@ -109,19 +128,21 @@ func main() {
             IsToken: true}
     }
     if err = allTokens.Err(); err != nil {
-        log.Print(err)
+        logger.Error("Error reading tokens", "err", err)
     }
     allTokens.Close()
 
     totalRecords := len(scryfallImageRecords)
-    log.Printf("Card/Token records received: %d\n", totalRecords)
+    logger.Info("Card/token records received", "records", totalRecords)
 
     totalStart := time.Now()
 
     currentRecord := 0
     // Try and download all of the card images we can get from scryfall
     for uuid, cardImageRecord := range scryfallImageRecords {
-        fmt.Printf("Processing record %d of %d\r", currentRecord, totalRecords)
+        if currentRecord % PROGRESS_INTERVAL == 0 {
+            logger.Debug("Processing records", "done", currentRecord, "total", totalRecords)
+        }
         currentRecord += 1
         // Skip cards whose images we've already downloaded
         if cardImageRecord.ImageCachedLocally {
@@ -133,20 +154,22 @@ func main() {
             cardImageRecord.ScryfallId)
         resp, err := http.Get(scryfallImageUrl)
         if err != nil {
-            log.Print(err)
+            logger.Warn("Error downloading image", "uuid", uuid, "url", scryfallImageUrl, "err", err)
             continue
         }
 
         if resp.StatusCode != http.StatusOK {
-            log.Print(resp.Status)
+            logger.Warn("Error downloading image", "uuid", uuid, "url", scryfallImageUrl,
+                "status", resp.Status)
             resp.Body.Close()
             continue
         }
 
-        localFileName := fmt.Sprintf("../../../web_content/card_face_images/%s.png", uuid)
+        localFileName := filepath.Join(downloaderConfig.ImageDownloader.OutputDir,
+            uuid + ".png")
         localFile, err := os.Create(localFileName)
         if err != nil {
-            log.Print(err)
+            logger.Error("Error creating image file", "path", localFileName, "err", err)
             resp.Body.Close()
             continue
         }
@@ -154,7 +177,7 @@ func main() {
         // Copy the file from the http response to the local file
         _, err = io.Copy(localFile, resp.Body)
         if err != nil {
-            log.Print(err)
+            logger.Warn("Error saving image", "uuid", uuid, "path", localFileName, "err", err)
             resp.Body.Close()
             localFile.Close()
             continue
@@ -173,7 +196,7 @@ func main() {
 
         _, err = updateQuery.Exec(uuid)
         if err != nil {
-            log.Print(err)
+            logger.Error("Error recording downloaded image", "uuid", uuid, "err", err)
             continue
         }
 
@@ -184,7 +207,7 @@ func main() {
         if duration.Milliseconds() < 100 {
             waitDuration, err := time.ParseDuration(fmt.Sprintf("%dms", 100 - duration.Milliseconds()))
             if err != nil {
-                log.Print(err)
+                logger.Error("Error working out the rate limit wait", "err", err)
                 continue
             }
             time.Sleep(waitDuration)
@@ -192,5 +215,5 @@ func main() {
     }
 
     totalDuration := time.Since(totalStart)
-    fmt.Printf("It took %v to download %d images\n", totalDuration, currentRecord)
+    logger.Info("Finished downloading images", "duration", totalDuration, "records", currentRecord)
 }
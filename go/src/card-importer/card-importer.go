@@ -1,106 +1,210 @@
 package main
 
+import "config"
+import "context"
 import "database/sql"
+import "dbconn"
+import "flag"
 import "fmt"
-import _ "github.com/go-sql-driver/mysql"
-import influx "github.com/influxdata/influxdb1-client/v2"
-import "log"
+import "log/slog"
+import "logging"
+import "migrations"
 import "mtgcards"
 import "carddb"
 import "os"
 import "os/signal"
+import "pricestore"
+import "sort"
 import "syscall"
 import "time"
 
-const (
-    cardDBUrl = "card_db"
-    cardPricesDBUrl = "card_prices_db"
-    appUsername = "app_user"
-    appPassword = "app_db_password"
-)
+var logger = logging.For("card-importer")
 
 func main() {
-    // Create a ticker that will signal the program to check for updates
-    // every 12 hours
-    tickDuration, err := time.ParseDuration("12h")
+    configPath := flag.String("config", "", "Path to the JSON config file")
+    dryRun := flag.Bool("dry-run", false,
+        "Compare the latest MTGJSON build against the db and write a report, without changing anything")
+    reportPath := flag.String("report", "dry-run-report.json",
+        "Where to write the dry run report, as HTML if it ends in .html, otherwise JSON")
+    filterFlags := registerImportFilterFlags()
+    flag.Parse()
+
+    importerConfig, err := config.Load(*configPath)
     if err != nil {
-        log.Fatal(err)
+        logging.Fatal(logger, "Error loading config", "err", err)
+    }
+    err = importerConfig.ConfigureLogging()
+    if err != nil {
+        logging.Fatal(logger, "Error configuring logging", "err", err)
+    }
+    // The control server's net/http logs through the standard logger
+    logging.RedirectStdLog(logger)
+    dataSource, err := mtgcards.NewDataSource(importerConfig.MTGJSON.Source,
+        importerConfig.MTGJSON.BaseURL, importerConfig.MTGJSON.DataDir)
+    if err != nil {
+        logging.Fatal(logger, "Error setting up the MTGJSON source", "err", err)
+    }
+    mtgcards.ConfigureDownloads(dataSource, importerConfig.MTGJSON.SchemaVersion)
+    // The config's String masks the db passwords
+    logger.Info("Loaded config", "config", importerConfig.String())
+
+    // Refuse to start against a schema that hasn't been migrated, rather
+    // than failing part way through an import
+    err = checkCardDBSchema(importerConfig)
+    if err != nil {
+        logging.Fatal(logger, "Card db schema check failed", "err", err)
+    }
+
+    // Register a signal handler for SIGINT and SIGTERM, so that we can exit
+    // cleanly in case an update is currently in progress.  The update stops
+    // taking on new work and finishes what it's in the middle of before we
+    // exit.  A second signal exits straight away.
+    ctx, cancel := context.WithCancel(context.Background())
+    closeRequest := make(chan os.Signal, 2)
+    signal.Notify(closeRequest, syscall.SIGINT, syscall.SIGTERM)
+    go func() {
+        <-closeRequest
+        logger.Info("Close request received, stopping any update in progress")
+        cancel()
+
+        <-closeRequest
+        logger.Warn("Second close request received, exiting immediately")
+        os.Exit(1)
+    }()
+
+    // A dry run is a one off, rather than a long running importer.  It
+    // compares with the filter given on the command line, if there is one,
+    // but doesn't save it.
+    if *dryRun {
+        var dryRunFilter *carddb.ImportFilter
+        if filter, given := filterFlags.filter(); given {
+            dryRunFilter = &filter
+        }
+        err = RunDryRun(ctx, importerConfig, dryRunFilter, *reportPath)
+        if err != nil {
+            logging.Fatal(logger, "Dry run failed", "err", err)
+        }
+        return
+    }
+
+    // A filter given on the command line replaces the saved one, and is what
+    // every update uses from then on, scheduled or not
+    err = maybeSaveImportFilter(importerConfig, filterFlags)
+    if err != nil {
+        logging.Fatal(logger, "Error saving the import filter", "err", err)
     }
-    updateTicker := time.NewTicker(tickDuration)
 
     // Register a signal handler for SIGUSR1, so that updates can be requested
     // outside of the normal update period
     updateRequest := make(chan os.Signal)
     signal.Notify(updateRequest, syscall.SIGUSR1)
 
-    // Register a signal handler for SIGINT, so that we can exit cleanly
-    // in case an update is currently in progress (will wait for the update
-    // to finish before we exit)
-    closeRequest := make(chan os.Signal)
-    signal.Notify(closeRequest, syscall.SIGINT)
+    // Updates can also be requested (and watched, and cancelled) through the
+    // control server, if it's enabled
+    controller := newRunController(importerConfig.Importer.RunHistory)
 
-    log.Printf("Card importer started with PID %d...\n", os.Getpid())
+    // Cards, prices and images are each updated on their own schedule (every
+    // 12 hours by default)
+    scheduler, err := NewScheduler(importerConfig)
+    if err != nil {
+        logging.Fatal(logger, "Error setting up the scheduler", "err", err)
+    }
+    go scheduler.Run(ctx, controller)
+
+    if importerConfig.Importer.ControlListenAddress != "" {
+        go NewControlServer(controller, scheduler).ListenAndServe(importerConfig.Importer.ControlListenAddress)
+    }
+
+    logger.Info("Card importer started", "pid", os.Getpid())
     for {
+        // Check this first, so that we exit as soon as possible if there's
+        // a request, even if an update is also due
+        if ctx.Err() != nil {
+            logger.Info("Exiting")
+            return
+        }
+
         select {
-        // Handle this one first, if it's available, so that we exit
-        // as soon as possible if there's a request
-        case <-closeRequest:
-            log.Printf("Close request received, exiting...\n")
-            os.Exit(0)
-
-        // Run the update checker either when the ticker goes off,
-        // or on-demand, in response to SIGUSR1
-        case <-updateTicker.C:
-            log.Printf("Timer tick, checking for updates...\n")
-            CheckForAndMaybeRunUpdate()
+        case <-ctx.Done():
+
+        // Run the update checker whenever an update is queued, either by the
+        // scheduler, or on-demand, in response to SIGUSR1 or the control
+        // server
         case <-updateRequest:
-            log.Printf("Manual update request, checking for updates...\n")
-            CheckForAndMaybeRunUpdate()
+            logger.Info("Manual update request, queueing an update")
+            if !controller.queue(NewUpdateRun(TRIGGER_SIGNAL, JOB_ALL, RunOptions{})) {
+                logger.Info("An update is already queued, ignoring the request")
+            }
+        case <-controller.queued:
+            // Queued runs may have been cancelled while they were waiting
+            for ctx.Err() == nil {
+                run := controller.takePending()
+                if run == nil {
+                    break
+                }
+                run.logger.Info("Running update, checking for updates")
+                controller.run(ctx, importerConfig, run)
+            }
         }
     }
 }
 
-func CheckForAndMaybeRunUpdate() {
+func checkCardDBSchema(importerConfig *config.Config) error {
+    cardDB, err := dbconn.Open(importerConfig.CardDB)
+    if err != nil {
+        return err
+    }
+    defer cardDB.Close()
+
+    return migrations.CheckSchema(cardDB, &migrations.CardDBSchema)
+}
+
+// CheckForAndMaybeRunUpdate brings the cards, prices and images up to date,
+// as far as run's options allow, recording what it did against run.  If ctx
+// is cancelled part way through, the stage in progress is wound down, the
+// later stages are skipped, and the run is recorded as interrupted.
+func CheckForAndMaybeRunUpdate(ctx context.Context, importerConfig *config.Config, run *UpdateRun) {
+    run.Progress.StartStage("checking for updates")
+
 	// Connect to the mariadb database
-    cardDBConnStr := fmt.Sprintf("%s:%s@tcp(%s)/mtg_cards?parseTime=true",
-        appUsername, appPassword, cardDBUrl)
-	cardDB, err := sql.Open("mysql", cardDBConnStr)
+	cardDB, err := dbconn.Open(importerConfig.CardDB)
 	if err != nil {
-		log.Print(err)
+		run.logError(err)
         return
 	}
 	defer cardDB.Close()
-	cardDB.SetMaxIdleConns(1000)
-
-    // Connect to the influxdb database
-    cardPricesDBAddr := fmt.Sprintf("http://%s:8086", cardPricesDBUrl)
-    influxClientConfig := influx.HTTPConfig{
-        Addr: cardPricesDBAddr,
-        Username: appUsername,
-        Password: appPassword}
-
-    pricesAndStatsDB, err := influx.NewHTTPClient(influxClientConfig)
+	// Each import worker holds a connection for the set it's working on, and
+	// the option tables hold one more for the whole import
+	workers := importerConfig.Importer.ImportWorkers
+	cardDB.SetMaxOpenConns(workers + 2)
+	cardDB.SetMaxIdleConns(workers + 2)
+
+    // Connect to the price store (influxdb, unless configured otherwise)
+    pricesAndStatsDB, err := pricestore.Open(importerConfig)
     if err != nil {
-        log.Print(err)
+        run.logError(err)
         return
     }
     defer pricesAndStatsDB.Close()
 
     // First, get the latest version data to see if we even need to bother downloading
     // any additional data
-    onlineVersion, err := mtgcards.DownloadVersion()
+    onlineVersion, err := mtgcards.DownloadVersion(ctx)
     if err != nil {
-        log.Print(err)
+        run.logError(err)
         return
     }
     dbVersion, err := carddb.GetDbLastUpdate(cardDB)
     if err != nil {
-        log.Print(err)
+        run.logError(err)
         return
     }
 
-    log.Printf("Online version:\n%s", onlineVersion)
-    log.Printf("DB version:\n%s", dbVersion)
+    run.logger.Info("Checked versions",
+        "online_build_date", onlineVersion.BuildDate,
+        "online_prices_date", onlineVersion.PricesDate,
+        "db_build_date", dbVersion.BuildDate,
+        "db_prices_date", dbVersion.PricesDate)
 
     cardsUpdated := false
     pricesUpdated := false
@@ -109,140 +213,328 @@ func CheckForAndMaybeRunUpdate() {
     pricesUpdateDuration := time.Duration(0)
     imagesUpdateDuration := time.Duration(0)
 
+    filterChanged, err := carddb.ImportFilterChangedSinceLastRun(cardDB)
+    if err != nil {
+        run.logError(err)
+        return
+    }
+
     // Update cards if necessary
-    if onlineVersion.BuildDate.After(dbVersion.BuildDate) {
-        cardsUpdateDuration, err = UpdateCards(cardDB, pricesAndStatsDB)
+    cardsOutOfDate := onlineVersion.BuildDate.After(dbVersion.BuildDate)
+    if !run.UpdatesCards() {
+        run.logger.Info("Not updating cards in this update")
+    } else if cardsOutOfDate || filterChanged || run.Options.ForceCards {
+        if !cardsOutOfDate && run.Options.ForceCards {
+            run.logger.Info("Card update forced, updating cards again")
+        } else if !cardsOutOfDate {
+            run.logger.Info("Import filter has changed, updating cards again")
+        }
+        cardsUpdateDuration, err = UpdateCards(ctx, importerConfig, cardDB, pricesAndStatsDB, onlineVersion, run)
         if err != nil {
-            log.Print(err)
+            run.logError(err)
         } else {
             cardsUpdated = true
         }
     } else {
-        log.Printf("Already have latest version of cards, skipping update...\n")
+        run.logger.Info("Already have latest version of cards, skipping update")
     }
 
     // Update prices if necessary
-    if onlineVersion.PricesDate.After(dbVersion.PricesDate) {
-        pricesUpdateDuration, err = UpdatePrices(pricesAndStatsDB, onlineVersion.PricesDate)
+    if ctx.Err() != nil {
+        run.logger.Warn("Update interrupted while updating cards, skipping prices and images")
+    } else if !run.UpdatesPrices() {
+        run.logger.Info("Not updating prices in this update")
+    } else if onlineVersion.PricesDate.After(dbVersion.PricesDate) || run.Options.ForcePrices {
+        pricesUpdateDuration, err = UpdatePrices(ctx, pricesAndStatsDB, onlineVersion.PricesDate, run)
         if err != nil {
-            log.Print(err)
+            run.logError(err)
         } else {
             pricesUpdated = true
         }
     } else {
-        log.Printf("Already have latest version of prices, skipping update...\n")
+        run.logger.Info("Already have latest version of prices, skipping update")
     }
 
-    // Update the update times in the db, but only if something actually got updated
+    // Update the update times in the db, but only the ones for what actually
+    // got updated, since cards and prices can be updated separately
     if cardsUpdated || pricesUpdated {
-        log.Printf("Updating last update time in DB\n")
-        err = carddb.UpdateDbLastUpdate(cardDB, onlineVersion)
+        run.logger.Debug("Updating last update time in DB")
+        newVersion := dbVersion
+        if cardsUpdated {
+            newVersion.BuildDate = onlineVersion.BuildDate
+            newVersion.VersionMajor = onlineVersion.VersionMajor
+            newVersion.VersionMinor = onlineVersion.VersionMinor
+            newVersion.VersionPatch = onlineVersion.VersionPatch
+        }
+        if pricesUpdated {
+            newVersion.PricesDate = onlineVersion.PricesDate
+        }
+        err = carddb.UpdateDbLastUpdate(cardDB, newVersion)
         if err != nil {
-            log.Print(err)
+            run.logError(err)
         }
     }
 
     // Check to see if we're missing any card images, and if so, try and get them
-    imagesUpdated, imagesUpdateDuration, err = UpdateImages(cardDB, pricesAndStatsDB)
-    if err != nil {
-        log.Print(err)
+    if ctx.Err() != nil {
+        run.logger.Warn("Update interrupted, skipping images")
+    } else if !run.UpdatesImages() {
+        run.logger.Info("Not updating images in this update")
+    } else {
+        imagesUpdated, imagesUpdateDuration, err = UpdateImages(ctx, cardDB, pricesAndStatsDB,
+            importerConfig.Importer.CardImageDir, run)
+        if err != nil {
+            run.logError(err)
+        }
+    }
+
+    downloadStats := mtgcards.TakeDownloadStats()
+    run.logger.Info("Download stats",
+        "downloads", downloadStats.Downloads,
+        "not_modified", downloadStats.NotModified,
+        "retries", downloadStats.Retries,
+        "verification_failures", downloadStats.VerificationFailures,
+        "bytes", downloadStats.Bytes)
+    recordDownloadMetrics(downloadStats)
+    if cardsUpdated {
+        recordStageDuration(STAGE_CARDS, cardsUpdateDuration)
+    }
+    if pricesUpdated {
+        recordStageDuration(STAGE_PRICES, pricesUpdateDuration)
+    }
+    if imagesUpdated {
+        recordStageDuration(STAGE_IMAGES, imagesUpdateDuration)
     }
 
     // Add the update run stats to the db
-    log.Printf("Adding update run stats to db...\n")
+    run.Progress.StartStage("recording stats")
+    run.logger.Debug("Adding update run stats to db")
     err = carddb.AddSingleUpdateStatsToDb(pricesAndStatsDB,
         cardsUpdated,
         pricesUpdated,
         imagesUpdated,
         cardsUpdateDuration,
         pricesUpdateDuration,
-        imagesUpdateDuration)
+        imagesUpdateDuration,
+        downloadStats,
+        ctx.Err() != nil)
     if err != nil {
-        log.Print(err)
+        run.logError(err)
     }
 }
 
-func UpdateCards(cardDB *sql.DB, priceAndStatsDB influx.Client) (time.Duration, error) {
+func UpdateCards(ctx context.Context,
+        importerConfig *config.Config,
+        cardDB *sql.DB, priceAndStatsDB pricestore.PriceStore,
+        version mtgcards.MTGJSONVersion,
+        updateRun *UpdateRun) (time.Duration, error) {
     updateDuration := time.Duration(0)
     updateStart := time.Now()
 
-    log.Printf("Cards are out of date, updating...\n")
+    updateRun.logger.Info("Cards are out of date, updating")
 
-    log.Printf("Downloading new cards...\n")
-    allSets, err := mtgcards.DownloadAllPrintings(false, false)
+    // Record the run, so that if it doesn't finish, the next one can pick up
+    // where it left off
+    run, err := carddb.StartImportRun(cardDB, version)
     if err != nil {
         return updateDuration, err
     }
+    if run.ResumedSets() > 0 {
+        updateRun.logger.Info("Resuming import",
+            "version", run.Version(), "sets_already_imported", run.ResumedSets())
+    }
+    runStatus := carddb.IMPORT_RUN_FAILED
+    defer func() {
+        if runStatus != carddb.IMPORT_RUN_COMPLETED && ctx.Err() != nil {
+            runStatus = carddb.IMPORT_RUN_INTERRUPTED
+        }
+        err := run.Finish(cardDB, runStatus)
+        if err != nil {
+            updateRun.logger.Error("Error recording the import run", "err", err)
+        }
+    }()
 
-    log.Printf("Updating cards in db...\n")
-    stats, err := carddb.ImportSetsToDB(cardDB, allSets)
+    filter, err := carddb.GetImportFilter(cardDB)
     if err != nil {
         return updateDuration, err
     }
-    log.Printf("Finished DB Update\n")
-    log.Printf("Total sets in update: %d\n", stats.TotalSets())
-    log.Printf("Total existing sets in the database: %d\n",
-        stats.TotalExistingSets())
-    log.Printf("Total new sets: %d\n", stats.TotalNewSets())
-    log.Printf("Existing sets skipped due to hash match: %d\n",
-        stats.ExistingSetsSkipped())
-    log.Printf("Existing sets updated due to hash mismatch: %d\n",
-        stats.ExistingSetsUpdated())
-    log.Printf("Total cards in update: %d\n", stats.TotalCards())
-    log.Printf("Total new cards: %d\n", stats.TotalNewCards())
-    log.Printf("Total new cards in new sets: %d\n", stats.TotalNewCardsInNewSets())
-    log.Printf("Total new cards in existing sets: %d\n",
-        stats.TotalNewCardsInExistingSets())
-    log.Printf("Total existing cards: %d\n", stats.TotalExistingCards())
-    log.Printf("Existing cards skipped due to hash match: %d\n",
-        stats.ExistingCardsSkipped())
-    log.Printf("Existing cards updated due to hash mismatch: %d\n",
-        stats.ExistingCardsUpdated())
-    log.Printf("Total tokens in update: %d\n", stats.TotalTokens())
-    log.Printf("Total new tokens: %d\n", stats.TotalNewTokens())
-    log.Printf("Total new tokens in new sets: %d\n", stats.TotalNewTokensInNewSets())
-    log.Printf("Total new tokens in existing sets: %d\n",
-        stats.TotalNewTokensInExistingSets())
-    log.Printf("Total existing tokens: %d\n", stats.TotalExistingTokens())
-    log.Printf("Existing tokens skipped due to hash match: %d\n",
-        stats.ExistingTokensSkipped())
-    log.Printf("Existing tokens updated due to hash mismatch: %d\n",
-        stats.ExistingTokensUpdated())
-
-    log.Printf("Adding card update stats to the db...\n")
+    updateRun.logger.Info("Importing", "filter", filter.String())
+
+    // Sets are decoded one at a time and handed to the importer as they're
+    // read, rather than decoding the whole file up front
+    readCtx, stopReading := context.WithCancel(ctx)
+    sets := make(chan mtgcards.MTGSet)
+    readErr := make(chan error, 1)
+    // Set by the goroutine running sendSets, and only read once it's sent on
+    // readErr
+    var downloadFailures []setDownloadFailure
+
+    // Only a full update sees everything in the source, so only a full
+    // update can tell what's been removed from it.  Either can tell what's
+    // come back.
+    seen := carddb.NewSourceContents()
+    fullRead := importerConfig.Importer.UpdateMode != config.INCREMENTAL_UPDATE_MODE ||
+        updateRun.Options.FullUpdate || updateRun.Options.ForceCards
+    if !fullRead {
+        updateRun.logger.Info("Checking for changed sets")
+        changedSets, err := findChangedSets(ctx, cardDB, &filter)
+        if err != nil {
+            stopReading()
+            return updateDuration, err
+        }
+        updateRun.logger.Info("Downloading changed sets", "sets", len(changedSets))
+        go func() {
+            downloadFailures = sendSets(readCtx, changedSets, sets)
+            readErr <- nil
+        }()
+    } else {
+        updateRun.logger.Info("Downloading new cards")
+        setReader, err := mtgcards.OpenAllPrintings(ctx, false, false)
+        if err != nil {
+            stopReading()
+            return updateDuration, err
+        }
+        defer setReader.Close()
+
+        go func() {
+            readErr <- setReader.SendAll(readCtx, sets)
+        }()
+    }
+
+    updateRun.logger.Info("Updating cards in db")
+    stats, importErr := carddb.ImportSetsToDB(ctx, cardDB, sets, carddb.ImportOptions{
+        Workers: importerConfig.Importer.ImportWorkers,
+        Progress: updateRun.Progress,
+        Run: run,
+        Seen: seen,
+        BulkInsert: importerConfig.Importer.BulkInsertNewSets,
+        Filter: &filter})
+    stopReading()
+    // Wait for the reader to stop before it gets closed
+    if sendErr := <-readErr; sendErr != nil && importErr == nil {
+        importErr = sendErr
+    }
+    if stats == nil {
+        return updateDuration, importErr
+    }
+    for _, failure := range downloadFailures {
+        stats.AddSetFailure(failure.code, failure.err)
+    }
+    updateRun.setCardStats(stats)
+    recordCardMetrics(stats)
+
+    // Even if the import stopped early, record how far it got.  Sets that
+    // failed aren't checkpointed, so leaving the run incomplete, and the build
+    // date where it was, means the next run tries just those sets again.
+    setFailures := stats.SetFailures()
+    if importErr == nil && len(setFailures) > 0 {
+        runStatus = carddb.IMPORT_RUN_PARTIAL
+        importErr = fmt.Errorf("%d sets failed to import", len(setFailures))
+    } else if importErr == nil {
+        runStatus = carddb.IMPORT_RUN_COMPLETED
+    }
+    if importErr != nil {
+        updateRun.logger.Warn("DB Update didn't complete", "err", importErr)
+    } else {
+        updateRun.logger.Info("Finished DB Update")
+    }
+    updateRun.logger.Info("Card update stats", statsArgs(stats.Fields())...)
+    for _, failure := range setFailures {
+        updateRun.logger.Warn("Set failed to import", "set", failure.SetCode, "err", failure.Error)
+    }
+
+    updateRun.logger.Debug("Adding card update stats to the db")
     err = stats.AddToDb(priceAndStatsDB)
-    if err != nil {
+    if err != nil && importErr == nil {
         return updateDuration, err
     }
+
+    // Only a complete read of the source says what's been removed from it.
+    // The import itself still succeeded if this fails, so it doesn't fail
+    // the update.
+    if importErr == nil && fullRead {
+        updateRun.Progress.StartStage("reconciling removals")
+        err = ReconcileRemovals(updateRun.logger, cardDB, importerConfig.UserDB, priceAndStatsDB, seen)
+        if err != nil {
+            updateRun.logError(err)
+        }
+    } else if importErr == nil {
+        // Otherwise a set that's back in the source would be seen as changed,
+        // and imported again, on every incremental update
+        restoreStats, err := carddb.RestoreSeen(cardDB, seen)
+        if err != nil {
+            updateRun.logError(err)
+        } else {
+            updateRun.logger.Info("Restored sets, cards and tokens back in the source",
+                "sets_restored", restoreStats.SetsRestored(),
+                "cards_restored", restoreStats.CardsRestored(),
+                "tokens_restored", restoreStats.TokensRestored())
+        }
+    }
     updateDuration = time.Since(updateStart)
 
-    return updateDuration, nil
+    return updateDuration, importErr
+}
+
+// ReconcileRemovals marks everything that's no longer in the source as
+// removed, moving card list entries over to replacement cards where there
+// are any
+func ReconcileRemovals(logger *slog.Logger,
+        cardDB *sql.DB,
+        usersDBConfig config.DatabaseConfig,
+        priceAndStatsDB pricestore.PriceStore,
+        seen *carddb.SourceContents) error {
+    logger.Info("Checking for cards and sets removed from the source")
+    usersDB, err := dbconn.Open(usersDBConfig)
+    if err != nil {
+        return err
+    }
+    defer usersDB.Close()
+
+    stats, err := carddb.ReconcileRemovals(cardDB, usersDB, seen)
+    logger.Info("Removal stats",
+        "sets_removed", stats.SetsRemoved(),
+        "sets_restored", stats.SetsRestored(),
+        "cards_removed", stats.CardsRemoved(),
+        "cards_restored", stats.CardsRestored(),
+        "cards_replaced", stats.CardsReplaced(),
+        "tokens_removed", stats.TokensRemoved(),
+        "tokens_restored", stats.TokensRestored(),
+        "tokens_replaced", stats.TokensReplaced(),
+        "list_entries_remapped", stats.ListEntriesRemapped())
+    if err != nil {
+        return err
+    }
+
+    logger.Debug("Adding removal stats to the db")
+    return stats.AddToDb(priceAndStatsDB)
 }
 
-func UpdatePrices(priceAndStatsDB influx.Client, pricesDate time.Time) (time.Duration, error) {
+func UpdatePrices(ctx context.Context,
+        priceAndStatsDB pricestore.PriceStore,
+        pricesDate time.Time,
+        run *UpdateRun) (time.Duration, error) {
     updateDuration := time.Duration(0)
     updateStart := time.Now()
-    log.Printf("Prices are out of date, updating...\n")
+    run.logger.Info("Prices are out of date, updating")
+    run.Progress.StartStage("updating prices")
 
-    log.Printf("Downloading new prices...\n")
-    allPrices, err := mtgcards.DownloadAllPrices(false)
+    run.logger.Info("Downloading new prices")
+    allPrices, err := mtgcards.DownloadAllPrices(ctx, false)
     if err != nil {
         return updateDuration, err
     }
 
-    log.Printf("Updating prices in db...\n")
-    stats, err := carddb.ImportPricesToDb(priceAndStatsDB, pricesDate, allPrices)
+    run.logger.Info("Updating prices in db")
+    stats, err := carddb.ImportPricesToDb(ctx, priceAndStatsDB, pricesDate, allPrices)
     if err != nil {
         return updateDuration, err
     }
-    log.Printf("New price records added for %d cards\n", stats.TotalCardRecords())
-    log.Printf("Total new price records added: %d\n", stats.TotalPriceRecords())
-    log.Printf("New MTGO price records added: %d\n", stats.MTGOPriceRecords())
-    log.Printf("New MTGO foil price records added: %d\n", stats.MTGOFoilPriceRecords())
-    log.Printf("New paper price records added: %d\n", stats.PaperPriceRecords())
-    log.Printf("New paper foil price records added: %d\n", stats.PaperFoilPriceRecords())
+    run.setPriceStats(&stats)
+    recordPriceMetrics(&stats)
+    run.logger.Info("Price update stats", statsArgs(stats.Fields())...)
 
-    log.Printf("Adding price update stats to the db...\n")
+    run.logger.Debug("Adding price update stats to the db")
     err = stats.AddToDb(priceAndStatsDB)
     if err != nil {
         return updateDuration, err
@@ -253,15 +545,19 @@ func UpdatePrices(priceAndStatsDB influx.Client, pricesDate time.Time) (time.Dur
     return updateDuration, nil
 }
 
-func UpdateImages(cardDB *sql.DB, pricesAndStatsDB influx.Client) (bool, time.Duration, error) {
-    log.Printf("Checking for and downloading any missing card images...\n")
+func UpdateImages(ctx context.Context,
+        cardDB *sql.DB,
+        pricesAndStatsDB pricestore.PriceStore,
+        imageDir string,
+        run *UpdateRun) (bool, time.Duration, error) {
+    run.logger.Info("Checking for and downloading any missing card images")
+    run.Progress.StartStage("updating images")
     updateStartTime := time.Now()
-    updateStats, err := carddb.UpdateCardImages(cardDB)
+    updateStats, err := carddb.UpdateCardImages(ctx, cardDB, imageDir)
     updateDuration := time.Since(updateStartTime)
-    log.Printf("Cards needing images: %d\n", updateStats.CardsNeedingImages())
-    log.Printf("Tokens needing images: %d\n", updateStats.TokensNeedingImages())
-    log.Printf("Images downloaded: %d\n", updateStats.ImagesDownloaded())
-    log.Printf("Images that failed to download: %d\n", updateStats.ImagesFailedToDownload())
+    run.setImageStats(&updateStats)
+    recordImageMetrics(&updateStats)
+    run.logger.Info("Image update stats", statsArgs(updateStats.Fields())...)
     if err != nil {
         return false, time.Duration(0), err
     } else {
@@ -279,3 +575,19 @@ func UpdateImages(cardDB *sql.DB, pricesAndStatsDB influx.Client) (bool, time.Du
         }
     }
 }
+
+// statsArgs turns stats fields into key value pairs for logging, in key
+// order, so that they come out the same way every time
+func statsArgs(fields map[string]interface{}) []interface{} {
+    keys := make([]string, 0, len(fields))
+    for key := range fields {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    args := make([]interface{}, 0, len(fields) * 2)
+    for _, key := range keys {
+        args = append(args, key, fields[key])
+    }
+    return args
+}
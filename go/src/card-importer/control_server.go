@@ -0,0 +1,200 @@
+package main
+
+import "encoding/json"
+import "io"
+import "metrics"
+import "net/http"
+import "strconv"
+
+const (
+    CONTROL_STATUS_PATH = "/status"
+    CONTROL_RUNS_PATH = "/runs"
+    CONTROL_CANCEL_PATH = "/runs/cancel"
+    CONTROL_METRICS_PATH = "/metrics"
+)
+
+// The phase reported when there's no run in progress
+const PHASE_IDLE = "idle"
+
+// ImporterStatus is what the importer is doing right now
+type ImporterStatus struct {
+    Phase string `json:"phase"`
+    Progress *ProgressSummary `json:"progress,omitempty"`
+    Run *RunSummary `json:"run,omitempty"`
+    Queued []RunSummary `json:"queued"`
+    Schedule []ScheduledJobSummary `json:"schedule"`
+}
+
+// ProgressSummary is how far through its current phase the run in progress
+// is.  The set counts only move while cards are being imported, and the
+// number of sets read is only the total once sets_total_known is true.
+type ProgressSummary struct {
+    SetsRead int `json:"sets_read"`
+    SetsDone int `json:"sets_done"`
+    SetsFailed int `json:"sets_failed"`
+    SetsTotalKnown bool `json:"sets_total_known"`
+    CardsDone int `json:"cards_done"`
+    CardsPerSecond float64 `json:"cards_per_second"`
+    ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// ControlServer serves the importer's status and run history, and lets runs
+// be started and cancelled:
+//
+//    GET  /status          the current phase and progress, the queue, and
+//                          when each scheduled job next runs
+//    GET  /runs?limit=N    the last N finished runs, newest first
+//    POST /runs            queue a run of everything, with RunOptions as the
+//                          (optional) body
+//    POST /runs/cancel     cancel the run in progress and any queued runs
+//    GET  /metrics         Prometheus metrics
+type ControlServer struct {
+    controller *runController
+    scheduler *Scheduler
+}
+
+func NewControlServer(controller *runController, scheduler *Scheduler) *ControlServer {
+    return &ControlServer{controller: controller, scheduler: scheduler}
+}
+
+func (server *ControlServer) Handler() http.Handler {
+    mux := http.NewServeMux()
+    mux.HandleFunc(CONTROL_STATUS_PATH, server.handleStatus)
+    mux.HandleFunc(CONTROL_RUNS_PATH, server.handleRuns)
+    mux.HandleFunc(CONTROL_CANCEL_PATH, server.handleCancel)
+    mux.Handle(CONTROL_METRICS_PATH, metrics.Handler())
+    return mux
+}
+
+// ListenAndServe serves the control API on address until the listener
+// fails.  Run it in its own goroutine.
+func (server *ControlServer) ListenAndServe(address string) {
+    logger.Info("Starting control server", "address", address)
+    err := http.ListenAndServe(address, server.Handler())
+    if err != nil {
+        logger.Error("Control server stopped", "err", err)
+    }
+}
+
+func (server *ControlServer) handleStatus(resp http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodGet {
+        resp.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    current, pending, _ := server.controller.runs()
+    status := ImporterStatus{
+        Phase: PHASE_IDLE,
+        Queued: make([]RunSummary, 0, len(pending)),
+        Schedule: server.scheduler.Summary()}
+    if current != nil {
+        snapshot := current.Progress.Snapshot()
+        summary := current.Summary()
+        status.Phase = snapshot.Stage
+        status.Run = &summary
+        status.Progress = &ProgressSummary{
+            SetsRead: snapshot.SetsRead,
+            SetsDone: snapshot.SetsDone,
+            SetsFailed: snapshot.SetsFailed,
+            SetsTotalKnown: snapshot.SetsTotalKnown,
+            CardsDone: snapshot.CardsDone,
+            CardsPerSecond: snapshot.CardsPerSecond,
+            ElapsedSeconds: snapshot.Elapsed.Seconds()}
+    }
+    for _, run := range pending {
+        status.Queued = append(status.Queued, run.Summary())
+    }
+
+    writeJSON(resp, http.StatusOK, status)
+}
+
+func (server *ControlServer) handleRuns(resp http.ResponseWriter, req *http.Request) {
+    switch req.Method {
+    case http.MethodGet:
+        server.listRuns(resp, req)
+    case http.MethodPost:
+        server.startRun(resp, req)
+    default:
+        resp.WriteHeader(http.StatusMethodNotAllowed)
+    }
+}
+
+func (server *ControlServer) listRuns(resp http.ResponseWriter, req *http.Request) {
+    _, _, history := server.controller.runs()
+
+    limit := len(history)
+    if limitParam := req.URL.Query().Get("limit"); limitParam != "" {
+        parsed, err := strconv.Atoi(limitParam)
+        if err != nil || parsed < 0 {
+            writeError(resp, http.StatusBadRequest, "limit must be a non-negative integer")
+            return
+        }
+        if parsed < limit {
+            limit = parsed
+        }
+    }
+
+    summaries := make([]RunSummary, 0, limit)
+    for i := len(history) - 1; i >= len(history) - limit; i-- {
+        summaries = append(summaries, history[i].Summary())
+    }
+    writeJSON(resp, http.StatusOK, summaries)
+}
+
+func (server *ControlServer) startRun(resp http.ResponseWriter, req *http.Request) {
+    var options RunOptions
+    decoder := json.NewDecoder(io.LimitReader(req.Body, 4096))
+    decoder.DisallowUnknownFields()
+    err := decoder.Decode(&options)
+    if err != nil && err != io.EOF {
+        writeError(resp, http.StatusBadRequest, "Invalid run options: " + err.Error())
+        return
+    }
+    err = options.Validate()
+    if err != nil {
+        writeError(resp, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    run := NewUpdateRun(TRIGGER_API, JOB_ALL, options)
+    if !server.controller.queue(run) {
+        writeError(resp, http.StatusConflict, "An update of everything is already queued")
+        return
+    }
+    run.logger.Info("Update requested through the control server",
+        "remote_addr", req.RemoteAddr)
+    writeJSON(resp, http.StatusAccepted, run.Summary())
+}
+
+func (server *ControlServer) handleCancel(resp http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodPost {
+        resp.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    if !server.controller.cancel() {
+        writeError(resp, http.StatusConflict, "No run to cancel")
+        return
+    }
+    resp.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(resp http.ResponseWriter, status int, value interface{}) {
+    valueJson, err := json.Marshal(value)
+    if err != nil {
+        logger.Error("Error marshalling control server response", "err", err)
+        resp.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+
+    resp.Header().Set("Content-Type", "application/json")
+    resp.WriteHeader(status)
+    _, err = resp.Write(valueJson)
+    if err != nil {
+        logger.Warn("Error writing control server response", "err", err)
+    }
+}
+
+func writeError(resp http.ResponseWriter, status int, message string) {
+    writeJSON(resp, status, map[string]string{"error": message})
+}
@@ -0,0 +1,115 @@
+package main
+
+import "encoding/json"
+import "net/http"
+import "net/http/httptest"
+import "strings"
+import "testing"
+
+func controlRequest(server *ControlServer, method string, path string, body string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, path, strings.NewReader(body))
+    resp := httptest.NewRecorder()
+    server.Handler().ServeHTTP(resp, req)
+    return resp
+}
+
+func TestControlServerListRunsLimit(t *testing.T) {
+    controller := newRunController(10)
+    for _, job := range []string{JOB_CARDS, JOB_PRICES, JOB_IMAGES} {
+        controller.queue(NewUpdateRun(TRIGGER_SCHEDULE, job, RunOptions{}))
+    }
+    controller.cancel()
+    server := NewControlServer(controller, &Scheduler{})
+
+    for query, expectedJobs := range map[string][]string{
+        "": {JOB_IMAGES, JOB_PRICES, JOB_CARDS},
+        "?limit=2": {JOB_IMAGES, JOB_PRICES},
+        "?limit=0": {},
+        "?limit=100": {JOB_IMAGES, JOB_PRICES, JOB_CARDS}} {
+        resp := controlRequest(server, http.MethodGet, CONTROL_RUNS_PATH + query, "")
+        if resp.Code != http.StatusOK {
+            t.Errorf("Expected status 200 for %q, got %d", query, resp.Code)
+            continue
+        }
+        var summaries []RunSummary
+        if err := json.Unmarshal(resp.Body.Bytes(), &summaries); err != nil {
+            t.Fatal(err)
+        }
+        if len(summaries) != len(expectedJobs) {
+            t.Errorf("Expected %d runs for %q, got %d", len(expectedJobs), query, len(summaries))
+            continue
+        }
+        for idx, summary := range summaries {
+            if summary.Job != expectedJobs[idx] {
+                t.Errorf("Expected run %d for %q to be %s, got %s", idx, query,
+                    expectedJobs[idx], summary.Job)
+            }
+        }
+    }
+
+    for _, query := range []string{"?limit=-1", "?limit=lots"} {
+        resp := controlRequest(server, http.MethodGet, CONTROL_RUNS_PATH + query, "")
+        if resp.Code != http.StatusBadRequest {
+            t.Errorf("Expected status 400 for %q, got %d", query, resp.Code)
+        }
+    }
+}
+
+func TestControlServerStartRun(t *testing.T) {
+    server := NewControlServer(newRunController(10), &Scheduler{})
+
+    for _, body := range []string{`{"force_card": true}`, `{"images_only": true, "force_cards": true}`,
+            `{"images_only": true, "full_update": true}`} {
+        resp := controlRequest(server, http.MethodPost, CONTROL_RUNS_PATH, body)
+        if resp.Code != http.StatusBadRequest {
+            t.Errorf("Expected status 400 for %s, got %d", body, resp.Code)
+        }
+    }
+
+    resp := controlRequest(server, http.MethodPost, CONTROL_RUNS_PATH, `{"force_cards": true}`)
+    if resp.Code != http.StatusAccepted {
+        t.Fatalf("Expected status 202, got %d: %s", resp.Code, resp.Body.String())
+    }
+    var summary RunSummary
+    if err := json.Unmarshal(resp.Body.Bytes(), &summary); err != nil {
+        t.Fatal(err)
+    }
+    if summary.Status != RUN_QUEUED || !summary.Options.ForceCards || summary.Trigger != TRIGGER_API {
+        t.Errorf("Unexpected summary of the queued run: %+v", summary)
+    }
+
+    resp = controlRequest(server, http.MethodPost, CONTROL_RUNS_PATH, "")
+    if resp.Code != http.StatusConflict {
+        t.Errorf("Expected status 409 while a run is queued, got %d", resp.Code)
+    }
+
+    resp = controlRequest(server, http.MethodGet, CONTROL_STATUS_PATH, "")
+    var status ImporterStatus
+    if err := json.Unmarshal(resp.Body.Bytes(), &status); err != nil {
+        t.Fatal(err)
+    }
+    if status.Phase != PHASE_IDLE || len(status.Queued) != 1 {
+        t.Errorf("Expected an idle importer with 1 queued run, got %+v", status)
+    }
+}
+
+func TestControlServerCancel(t *testing.T) {
+    controller := newRunController(10)
+    server := NewControlServer(controller, &Scheduler{})
+
+    resp := controlRequest(server, http.MethodGet, CONTROL_CANCEL_PATH, "")
+    if resp.Code != http.StatusMethodNotAllowed {
+        t.Errorf("Expected status 405 for a GET, got %d", resp.Code)
+    }
+
+    resp = controlRequest(server, http.MethodPost, CONTROL_CANCEL_PATH, "")
+    if resp.Code != http.StatusConflict {
+        t.Errorf("Expected status 409 with nothing to cancel, got %d", resp.Code)
+    }
+
+    controller.queue(NewUpdateRun(TRIGGER_API, JOB_ALL, RunOptions{}))
+    resp = controlRequest(server, http.MethodPost, CONTROL_CANCEL_PATH, "")
+    if resp.Code != http.StatusNoContent {
+        t.Errorf("Expected status 204, got %d", resp.Code)
+    }
+}
@@ -0,0 +1,95 @@
+package main
+
+import "carddb"
+import "config"
+import "context"
+import "dbconn"
+import "mtgcards"
+import "os"
+import "strings"
+
+// RunDryRun compares the latest MTGJSON build against the card db, and
+// writes a report of what an import would change to reportPath, without
+// writing anything to the db itself.  The sets compared are the ones filter
+// lets through, or the saved import filter's if it's nil.
+func RunDryRun(ctx context.Context,
+        importerConfig *config.Config,
+        filter *carddb.ImportFilter,
+        reportPath string) error {
+    cardDB, err := dbconn.Open(importerConfig.CardDB)
+    if err != nil {
+        return err
+    }
+    defer cardDB.Close()
+    workers := importerConfig.Importer.ImportWorkers
+    cardDB.SetMaxOpenConns(workers + 2)
+    cardDB.SetMaxIdleConns(workers + 2)
+
+    onlineVersion, err := mtgcards.DownloadVersion(ctx)
+    if err != nil {
+        return err
+    }
+    logger.Info("Checked version", "online_build_date", onlineVersion.BuildDate)
+
+    if filter == nil {
+        savedFilter, err := carddb.GetImportFilter(cardDB)
+        if err != nil {
+            return err
+        }
+        filter = &savedFilter
+    }
+    logger.Info("Comparing", "filter", filter.String())
+
+    logger.Info("Downloading new cards")
+    setReader, err := mtgcards.OpenAllPrintings(ctx, false, false)
+    if err != nil {
+        return err
+    }
+    defer setReader.Close()
+
+    logger.Info("Comparing cards against the db")
+    report := carddb.NewDryRunReport(onlineVersion)
+    readCtx, stopReading := context.WithCancel(ctx)
+    sets := make(chan mtgcards.MTGSet)
+    readErr := make(chan error, 1)
+    go func() {
+        readErr <- setReader.SendAll(readCtx, sets)
+    }()
+
+    _, compareErr := carddb.ImportSetsToDB(ctx, cardDB, sets, carddb.ImportOptions{
+        Workers: workers,
+        DryRun: report,
+        Filter: filter})
+    stopReading()
+    // Wait for the reader to stop before it gets closed
+    if sendErr := <-readErr; sendErr != nil && compareErr == nil {
+        compareErr = sendErr
+    }
+    if compareErr != nil {
+        logger.Warn("Comparison stopped early, the report will be incomplete", "err", compareErr)
+    }
+
+    logger.Info("Dry run stats",
+        "new_sets", len(report.NewSets),
+        "changed_sets", len(report.ChangedSets),
+        "new_cards", len(report.NewCards),
+        "changed_cards", len(report.ChangedCards))
+
+    reportFile, err := os.Create(reportPath)
+    if err != nil {
+        return err
+    }
+    defer reportFile.Close()
+
+    if strings.HasSuffix(strings.ToLower(reportPath), ".html") {
+        err = report.WriteHTML(reportFile)
+    } else {
+        err = report.WriteJSON(reportFile)
+    }
+    if err != nil {
+        return err
+    }
+    logger.Info("Wrote dry run report", "file", reportPath)
+
+    return compareErr
+}
@@ -0,0 +1,85 @@
+package main
+
+import "carddb"
+import "config"
+import "dbconn"
+import "flag"
+import "strings"
+
+type importFilterFlags struct {
+    includeSets *string
+    excludeSets *string
+    includeSetTypes *string
+    excludeSetTypes *string
+    excludeOnlineOnly *bool
+    releasedAfter *string
+    releasedBefore *string
+    clear *bool
+}
+
+func registerImportFilterFlags() *importFilterFlags {
+    return &importFilterFlags{
+        includeSets: flag.String("include-sets", "",
+            "Comma separated set codes, only these sets are imported"),
+        excludeSets: flag.String("exclude-sets", "",
+            "Comma separated set codes that aren't imported"),
+        includeSetTypes: flag.String("include-set-types", "",
+            "Comma separated set types (e.g. expansion,core), only sets of these types are imported"),
+        excludeSetTypes: flag.String("exclude-set-types", "",
+            "Comma separated set types (e.g. memorabilia,token) that aren't imported"),
+        excludeOnlineOnly: flag.Bool("exclude-online-only", false,
+            "Don't import sets that are only available online"),
+        releasedAfter: flag.String("released-after", "",
+            "Only import sets released on or after this date (YYYY-MM-DD)"),
+        releasedBefore: flag.String("released-before", "",
+            "Only import sets released on or before this date (YYYY-MM-DD)"),
+        clear: flag.Bool("clear-filter", false,
+            "Remove the saved import filter, so that every set is imported again")}
+}
+
+func splitList(list string) []string {
+    var values []string
+    for _, value := range strings.Split(list, ",") {
+        value = strings.TrimSpace(value)
+        if value != "" {
+            values = append(values, value)
+        }
+    }
+    return values
+}
+
+// filter returns the filter given on the command line, and whether one was
+// given at all
+func (flags *importFilterFlags) filter() (carddb.ImportFilter, bool) {
+    filter := carddb.ImportFilter{
+        IncludeSetCodes: splitList(*flags.includeSets),
+        ExcludeSetCodes: splitList(*flags.excludeSets),
+        IncludeSetTypes: splitList(*flags.includeSetTypes),
+        ExcludeSetTypes: splitList(*flags.excludeSetTypes),
+        ExcludeOnlineOnly: *flags.excludeOnlineOnly,
+        ReleasedAfter: *flags.releasedAfter,
+        ReleasedBefore: *flags.releasedBefore}
+    return filter, *flags.clear || !filter.IsEmpty()
+}
+
+// maybeSaveImportFilter saves the filter given on the command line, if
+// there was one, so that later updates use it too
+func maybeSaveImportFilter(importerConfig *config.Config, flags *importFilterFlags) error {
+    filter, given := flags.filter()
+    if !given {
+        return nil
+    }
+
+    cardDB, err := dbconn.Open(importerConfig.CardDB)
+    if err != nil {
+        return err
+    }
+    defer cardDB.Close()
+
+    err = carddb.SaveImportFilter(cardDB, filter)
+    if err != nil {
+        return err
+    }
+    logger.Info("Saved import filter, importing it from now on", "filter", filter.String())
+    return nil
+}
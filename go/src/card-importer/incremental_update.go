@@ -0,0 +1,63 @@
+package main
+
+import "carddb"
+import "context"
+import "database/sql"
+import "fmt"
+import "mtgcards"
+
+// findChangedSets compares MTGJSON's set list against the sets in the db,
+// and returns the codes of the sets that need importing again.  Sets the
+// filter excludes aren't downloaded at all.
+func findChangedSets(ctx context.Context,
+        cardDB *sql.DB,
+        filter *carddb.ImportFilter) ([]string, error) {
+    setList, err := mtgcards.DownloadSetList(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    filtered := make([]mtgcards.MTGSet, 0, len(setList))
+    for idx := range setList {
+        if filter.Matches(&setList[idx]) {
+            filtered = append(filtered, setList[idx])
+        }
+    }
+
+    return carddb.ChangedSets(cardDB, filtered)
+}
+
+// setDownloadFailure is a set sendSets couldn't download
+type setDownloadFailure struct {
+    code string
+    err error
+}
+
+// sendSets downloads each of the sets with codes from its own file and sends
+// it on sets, closing sets once it's done.  A set that can't be downloaded
+// doesn't stop the others, it's returned so that it can be recorded as a set
+// failure.  It stops early if ctx is cancelled.
+func sendSets(ctx context.Context, codes []string, sets chan<- mtgcards.MTGSet) []setDownloadFailure {
+    defer close(sets)
+
+    failures := make([]setDownloadFailure, 0)
+    for _, code := range codes {
+        set, err := mtgcards.DownloadSet(ctx, code)
+        if ctx.Err() != nil {
+            return failures
+        }
+        if err != nil {
+            failures = append(failures, setDownloadFailure{
+                code: code,
+                err: fmt.Errorf("Unable to download set %s: %s", code, err)})
+            continue
+        }
+
+        select {
+        case <-ctx.Done():
+            return failures
+        case sets <- set:
+        }
+    }
+    return failures
+}
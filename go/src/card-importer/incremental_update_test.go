@@ -0,0 +1,26 @@
+package main
+
+import "context"
+import "mtgcards"
+import "testing"
+
+func TestSendSetsCarriesOnPastFailedDownloads(t *testing.T) {
+    mtgcards.ConfigureDownloads(mtgcards.NewMemoryDataSource(map[string][]byte{
+        "AAA.json": []byte(`{"meta": {}, "data": {"code": "AAA", "cards": []}}`),
+        "CON_.json": []byte(`{"meta": {}, "data": {"code": "CON", "cards": []}}`),
+    }), mtgcards.MTGJSON_V5)
+
+    sets := make(chan mtgcards.MTGSet, 3)
+    failures := sendSets(context.Background(), []string{"AAA", "BBB", "CON"}, sets)
+
+    sent := make([]string, 0)
+    for set := range sets {
+        sent = append(sent, set.Code)
+    }
+    if len(sent) != 2 || sent[0] != "AAA" || sent[1] != "CON" {
+        t.Errorf("Expected AAA and CON to be sent, got %v", sent)
+    }
+    if len(failures) != 1 || failures[0].code != "BBB" {
+        t.Errorf("Expected only BBB to fail, got %v", failures)
+    }
+}
@@ -0,0 +1,97 @@
+package main
+
+import "carddb"
+import "metrics"
+import "mtgcards"
+import "time"
+
+// Histogram buckets (in seconds) for runs and stages, which take anything
+// from seconds for an unchanged check up to hours for a fresh import
+var runDurationBuckets = []float64{1, 10, 30, 60, 300, 600, 1800, 3600, 7200, 14400}
+
+// The stages of a run, as recorded by stageDuration
+const (
+    STAGE_CARDS = "cards"
+    STAGE_PRICES = "prices"
+    STAGE_IMAGES = "images"
+)
+
+var runsTotal = metrics.NewCounterVec("mtg_importer_runs_total",
+    "Update runs, by job and how they finished", "job", "status")
+var runDuration = metrics.NewHistogramVec("mtg_importer_run_duration_seconds",
+    "How long update runs took, by job", runDurationBuckets, "job")
+var lastRunFinished = metrics.NewGaugeVec("mtg_importer_last_run_finished_timestamp_seconds",
+    "When the last run of each job finished, as a Unix timestamp", "job")
+var stageDuration = metrics.NewHistogramVec("mtg_importer_stage_duration_seconds",
+    "How long each stage of an update took, for stages that updated something",
+    runDurationBuckets, "stage")
+
+var setsProcessed = metrics.NewCounterVec("mtg_importer_sets_total",
+    "Sets seen by card updates, by what happened to them", "result")
+var cardsProcessed = metrics.NewCounterVec("mtg_importer_cards_total",
+    "Cards seen by card updates, by what happened to them", "result")
+var tokensProcessed = metrics.NewCounterVec("mtg_importer_tokens_total",
+    "Tokens seen by card updates, by what happened to them", "result")
+
+var priceRecords = metrics.NewCounterVec("mtg_importer_price_records_total",
+    "Price records added, by kind", "kind")
+
+var downloads = metrics.NewCounterVec("mtg_importer_downloads_total",
+    "MTGJSON file fetches, by result", "result")
+var downloadBytes = metrics.NewCounter("mtg_importer_download_bytes_total",
+    "Bytes downloaded from MTGJSON")
+
+var imagesDownloaded = metrics.NewCounter("mtg_importer_images_downloaded_total",
+    "Card and token images downloaded")
+var imageFailures = metrics.NewCounter("mtg_importer_image_failures_total",
+    "Card and token images that failed to download")
+
+func recordRunMetrics(run *UpdateRun, duration time.Duration, status string) {
+    runsTotal.With(run.Job, status).Inc()
+    runDuration.With(run.Job).Observe(duration.Seconds())
+    lastRunFinished.With(run.Job).Set(float64(time.Now().Unix()))
+}
+
+func recordStageDuration(stage string, duration time.Duration) {
+    stageDuration.With(stage).Observe(duration.Seconds())
+}
+
+func recordCardMetrics(stats *carddb.CardUpdateStats) {
+    setsProcessed.With("new").Add(float64(stats.TotalNewSets()))
+    setsProcessed.With("updated").Add(float64(stats.ExistingSetsUpdated()))
+    setsProcessed.With("unchanged").Add(float64(stats.ExistingSetsSkipped()))
+    setsProcessed.With("failed").Add(float64(stats.FailedSets()))
+    setsProcessed.With("filtered").Add(float64(stats.FilteredSetsSkipped()))
+    setsProcessed.With("checkpointed").Add(float64(stats.CheckpointedSetsSkipped()))
+
+    cardsProcessed.With("new").Add(float64(stats.TotalNewCards()))
+    cardsProcessed.With("updated").Add(float64(stats.ExistingCardsUpdated()))
+    cardsProcessed.With("unchanged").Add(float64(stats.ExistingCardsSkipped()))
+    cardsProcessed.With("failed").Add(float64(stats.FailedCards()))
+
+    tokensProcessed.With("new").Add(float64(stats.TotalNewTokens()))
+    tokensProcessed.With("updated").Add(float64(stats.ExistingTokensUpdated()))
+    tokensProcessed.With("unchanged").Add(float64(stats.ExistingTokensSkipped()))
+    tokensProcessed.With("failed").Add(float64(stats.FailedTokens()))
+}
+
+func recordPriceMetrics(stats *carddb.PricesUpdateStats) {
+    priceRecords.With("mtgo").Add(float64(stats.MTGOPriceRecords()))
+    priceRecords.With("mtgo_foil").Add(float64(stats.MTGOFoilPriceRecords()))
+    priceRecords.With("paper").Add(float64(stats.PaperPriceRecords()))
+    priceRecords.With("paper_foil").Add(float64(stats.PaperFoilPriceRecords()))
+    priceRecords.With("buylist").Add(float64(stats.BuylistPriceRecords()))
+}
+
+func recordImageMetrics(stats *carddb.ImageUpdateStats) {
+    imagesDownloaded.Add(float64(stats.ImagesDownloaded()))
+    imageFailures.Add(float64(stats.ImagesFailedToDownload()))
+}
+
+func recordDownloadMetrics(stats mtgcards.DownloadStats) {
+    downloads.With("downloaded").Add(float64(stats.Downloads))
+    downloads.With("not_modified").Add(float64(stats.NotModified))
+    downloads.With("retried").Add(float64(stats.Retries))
+    downloads.With("verification_failed").Add(float64(stats.VerificationFailures))
+    downloadBytes.Add(float64(stats.Bytes))
+}
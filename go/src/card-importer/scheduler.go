@@ -0,0 +1,202 @@
+package main
+
+import "carddb"
+import "config"
+import "context"
+import "cron"
+import "database/sql"
+import "dbconn"
+import "math/rand"
+import "sync"
+import "time"
+
+// A job the scheduler runs, and when it next runs
+type scheduledJob struct {
+    job string
+    expr string
+    schedule cron.Schedule
+    nextRun time.Time
+    // The job's last run, so that the next isn't started while it's still
+    // going
+    lastRun *UpdateRun
+    skipped int
+}
+
+// ScheduledJobSummary is a scheduled job as reported by the control server
+type ScheduledJobSummary struct {
+    Job string `json:"job"`
+    Schedule string `json:"schedule"`
+    NextRunAt time.Time `json:"next_run_at"`
+    Skipped int `json:"skipped"`
+}
+
+// Scheduler queues the card, price and image updates on their own cron
+// schedules.  When a job's time comes round, its next run is worked out and
+// saved to the card db, so that a restart carries on with the same
+// schedule, and runs anything that came due while the importer was down.
+type Scheduler struct {
+    cardDBConfig config.DatabaseConfig
+    jitter time.Duration
+
+    mutex sync.Mutex
+    jobs []*scheduledJob
+}
+
+// NewScheduler builds the schedule from importerConfig, picking up the next
+// run times saved by the last importer to run
+func NewScheduler(importerConfig *config.Config) (*Scheduler, error) {
+    scheduler := &Scheduler{
+        cardDBConfig: importerConfig.CardDB,
+        jitter: importerConfig.Importer.ScheduleJitterDuration()}
+
+    cardDB, err := dbconn.Open(importerConfig.CardDB)
+    if err != nil {
+        return nil, err
+    }
+    defer cardDB.Close()
+
+    saved, err := carddb.GetScheduledJobs(cardDB)
+    if err != nil {
+        return nil, err
+    }
+
+    now := time.Now()
+    for _, job := range []struct {
+        name string
+        expr string
+    }{
+        {JOB_CARDS, importerConfig.Importer.CardsSchedule},
+        {JOB_PRICES, importerConfig.Importer.PricesSchedule},
+        {JOB_IMAGES, importerConfig.Importer.ImagesSchedule}} {
+        expr := importerConfig.Importer.EffectiveSchedule(job.expr)
+        schedule, err := cron.Parse(expr)
+        if err != nil {
+            return nil, err
+        }
+
+        scheduled := &scheduledJob{
+            job: job.name,
+            expr: expr,
+            schedule: schedule}
+        // A saved time from a different schedule doesn't mean anything any
+        // more
+        if savedJob, ok := saved[job.name]; ok && savedJob.Schedule == expr {
+            scheduled.nextRun = savedJob.NextRunAt
+        } else {
+            scheduled.nextRun = scheduler.nextRun(scheduled, now)
+            scheduler.save(cardDB, scheduled)
+        }
+        logger.Info("Scheduled update", "job", scheduled.job,
+            "next_run", scheduled.nextRun.Local().Format(time.RFC1123), "schedule", expr)
+        scheduler.jobs = append(scheduler.jobs, scheduled)
+    }
+
+    return scheduler, nil
+}
+
+// Run queues each job on controller whenever it's due, until ctx is
+// cancelled.  Run it in its own goroutine.
+func (scheduler *Scheduler) Run(ctx context.Context, controller *runController) {
+    for {
+        timer := time.NewTimer(time.Until(scheduler.earliestRun()))
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return
+        case <-timer.C:
+            scheduler.queueDueJobs(controller)
+        }
+    }
+}
+
+func (scheduler *Scheduler) earliestRun() time.Time {
+    scheduler.mutex.Lock()
+    defer scheduler.mutex.Unlock()
+
+    earliest := scheduler.jobs[0].nextRun
+    for _, job := range scheduler.jobs[1:] {
+        if job.nextRun.Before(earliest) {
+            earliest = job.nextRun
+        }
+    }
+    return earliest
+}
+
+func (scheduler *Scheduler) queueDueJobs(controller *runController) {
+    scheduler.mutex.Lock()
+    defer scheduler.mutex.Unlock()
+
+    cardDB, err := dbconn.Open(scheduler.cardDBConfig)
+    if err != nil {
+        logger.Error("Error opening the card db to save the schedule", "err", err)
+    } else {
+        defer cardDB.Close()
+    }
+
+    now := time.Now()
+    for _, job := range scheduler.jobs {
+        if job.nextRun.After(now) {
+            continue
+        }
+
+        if job.lastRun != nil && !job.lastRun.Finished() {
+            logger.Warn("Scheduled update is due, but the last one is still going, skipping it",
+                "job", job.job)
+            job.skipped += 1
+        } else {
+            run := NewUpdateRun(TRIGGER_SCHEDULE, job.job, RunOptions{})
+            if controller.queue(run) {
+                logger.Info("Scheduled update is due, queued it", "job", job.job)
+                job.lastRun = run
+            } else {
+                logger.Warn("Scheduled update is due, but one is already queued, skipping it",
+                    "job", job.job)
+                job.skipped += 1
+            }
+        }
+
+        // However late this run is, the next one comes from the schedule,
+        // rather than catching up on every run that was missed
+        job.nextRun = scheduler.nextRun(job, now)
+        if cardDB != nil {
+            scheduler.save(cardDB, job)
+        }
+        logger.Info("Next update", "job", job.job, "next_run", job.nextRun.Local().Format(time.RFC1123))
+    }
+}
+
+// nextRun returns when job next runs after after, including the jitter
+func (scheduler *Scheduler) nextRun(job *scheduledJob, after time.Time) time.Time {
+    next := job.schedule.Next(after)
+    if scheduler.jitter > 0 {
+        next = next.Add(time.Duration(rand.Int63n(int64(scheduler.jitter))))
+    }
+    return next
+}
+
+// A failure to save only matters if the importer restarts before the next
+// run, so it's logged rather than stopping the schedule
+func (scheduler *Scheduler) save(cardDB *sql.DB, job *scheduledJob) {
+    err := carddb.SaveScheduledJob(cardDB, carddb.ScheduledJob{
+        Job: job.job,
+        Schedule: job.expr,
+        NextRunAt: job.nextRun})
+    if err != nil {
+        logger.Error("Error saving the next update time", "job", job.job, "err", err)
+    }
+}
+
+func (scheduler *Scheduler) Summary() []ScheduledJobSummary {
+    scheduler.mutex.Lock()
+    defer scheduler.mutex.Unlock()
+
+    summaries := make([]ScheduledJobSummary, 0, len(scheduler.jobs))
+    for _, job := range scheduler.jobs {
+        summaries = append(summaries, ScheduledJobSummary{
+            Job: job.job,
+            Schedule: job.expr,
+            NextRunAt: job.nextRun,
+            Skipped: job.skipped})
+    }
+    return summaries
+}
@@ -0,0 +1,338 @@
+package main
+
+import "carddb"
+import "config"
+import "context"
+import "fmt"
+import "log/slog"
+import "sync"
+import "time"
+
+// What started a run
+const (
+    TRIGGER_SCHEDULE = "schedule"
+    TRIGGER_SIGNAL = "signal"
+    TRIGGER_API = "api"
+)
+
+// What a run updates.  Scheduled runs each do one job; runs asked for
+// through SIGUSR1 or the control server do everything.
+const (
+    JOB_ALL = "all"
+    JOB_CARDS = "cards"
+    JOB_PRICES = "prices"
+    JOB_IMAGES = "images"
+)
+
+// The states a run can be in.  A cancelled run was stopped through the
+// control API, an interrupted one by the importer shutting down.
+const (
+    RUN_QUEUED = "queued"
+    RUN_RUNNING = "running"
+    RUN_FINISHED = "finished"
+    RUN_CANCELLED = "cancelled"
+    RUN_INTERRUPTED = "interrupted"
+)
+
+// RunOptions change what a run updates.  By default, cards and prices are
+// only updated when MTGJSON has a newer build than the db has, and missing
+// images are always checked for.  FullUpdate reads all of AllPrintings even
+// in incremental update mode, which is the only way changes to the cards in
+// an otherwise unchanged set are picked up; forcing cards implies it.
+type RunOptions struct {
+    ForceCards bool `json:"force_cards"`
+    ForcePrices bool `json:"force_prices"`
+    FullUpdate bool `json:"full_update"`
+    ImagesOnly bool `json:"images_only"`
+}
+
+func (options *RunOptions) Validate() error {
+    if options.ImagesOnly && (options.ForceCards || options.ForcePrices || options.FullUpdate) {
+        return fmt.Errorf("images_only can't be combined with force_cards, force_prices or full_update")
+    }
+    return nil
+}
+
+// UpdateRun is a single run of CheckForAndMaybeRunUpdate.  Its progress and
+// the stats of the stages it's finished can be read while it's still going.
+type UpdateRun struct {
+    Trigger string
+    Job string
+    Options RunOptions
+    Progress *carddb.ImportProgress
+    // Logs everything about the run with its job and trigger
+    logger *slog.Logger
+
+    mutex sync.RWMutex
+    status string
+    cancelled bool
+    startedAt time.Time
+    finishedAt time.Time
+    cardStats map[string]interface{}
+    priceStats map[string]interface{}
+    imageStats map[string]interface{}
+    errors []string
+}
+
+// RunSummary is a copy of an UpdateRun at a single point in time, as
+// reported by the control server.  Stats are keyed by the names they're
+// stored under in the stats db.
+type RunSummary struct {
+    Trigger string `json:"trigger"`
+    Job string `json:"job"`
+    Options RunOptions `json:"options"`
+    Status string `json:"status"`
+    StartedAt *time.Time `json:"started_at,omitempty"`
+    FinishedAt *time.Time `json:"finished_at,omitempty"`
+    CardStats map[string]interface{} `json:"card_stats,omitempty"`
+    PriceStats map[string]interface{} `json:"price_stats,omitempty"`
+    ImageStats map[string]interface{} `json:"image_stats,omitempty"`
+    Errors []string `json:"errors,omitempty"`
+}
+
+func NewUpdateRun(trigger string, job string, options RunOptions) *UpdateRun {
+    return &UpdateRun{
+        Trigger: trigger,
+        Job: job,
+        Options: options,
+        Progress: carddb.NewImportProgress(),
+        logger: logger.With("job", job, "trigger", trigger),
+        status: RUN_QUEUED}
+}
+
+func (run *UpdateRun) UpdatesCards() bool {
+    return (run.Job == JOB_ALL || run.Job == JOB_CARDS) && !run.Options.ImagesOnly
+}
+
+func (run *UpdateRun) UpdatesPrices() bool {
+    return (run.Job == JOB_ALL || run.Job == JOB_PRICES) && !run.Options.ImagesOnly
+}
+
+func (run *UpdateRun) UpdatesImages() bool {
+    return run.Job == JOB_ALL || run.Job == JOB_IMAGES
+}
+
+// Finished returns whether the run has stopped, one way or another
+func (run *UpdateRun) Finished() bool {
+    run.mutex.RLock()
+    defer run.mutex.RUnlock()
+    return run.status != RUN_QUEUED && run.status != RUN_RUNNING
+}
+
+func (run *UpdateRun) start() {
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    run.status = RUN_RUNNING
+    run.startedAt = time.Now()
+}
+
+func (run *UpdateRun) finish(status string) {
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    run.status = status
+    run.finishedAt = time.Now()
+}
+
+func (run *UpdateRun) markCancelled() {
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    run.cancelled = true
+}
+
+func (run *UpdateRun) Cancelled() bool {
+    run.mutex.RLock()
+    defer run.mutex.RUnlock()
+    return run.cancelled
+}
+
+func (run *UpdateRun) setCardStats(stats *carddb.CardUpdateStats) {
+    fields := stats.Fields()
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    run.cardStats = fields
+}
+
+func (run *UpdateRun) setPriceStats(stats *carddb.PricesUpdateStats) {
+    fields := stats.Fields()
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    run.priceStats = fields
+}
+
+func (run *UpdateRun) setImageStats(stats *carddb.ImageUpdateStats) {
+    fields := stats.Fields()
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    run.imageStats = fields
+}
+
+// logError logs err, and records it against the run so that it shows up in
+// the run history
+func (run *UpdateRun) logError(err error) {
+    run.logger.Error("Update error", "err", err)
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    run.errors = append(run.errors, err.Error())
+}
+
+func (run *UpdateRun) Summary() RunSummary {
+    run.mutex.RLock()
+    defer run.mutex.RUnlock()
+
+    summary := RunSummary{
+        Trigger: run.Trigger,
+        Job: run.Job,
+        Options: run.Options,
+        Status: run.status,
+        CardStats: run.cardStats,
+        PriceStats: run.priceStats,
+        ImageStats: run.imageStats,
+        Errors: append([]string(nil), run.errors...)}
+    if !run.startedAt.IsZero() {
+        startedAt := run.startedAt
+        summary.StartedAt = &startedAt
+    }
+    if !run.finishedAt.IsZero() {
+        finishedAt := run.finishedAt
+        summary.FinishedAt = &finishedAt
+    }
+    return summary
+}
+
+// runController runs updates one at a time, in the order they're asked for,
+// whether they come from the scheduler, SIGUSR1 or the control API, and
+// keeps the last few that finished.  Only one run of each job waits at a
+// time; asking for another while one is already waiting is refused rather
+// than queued.
+type runController struct {
+    // Signalled whenever a run is queued, to wake up the main loop
+    queued chan interface{}
+
+    mutex sync.Mutex
+    pending []*UpdateRun
+    current *UpdateRun
+    cancelCurrent context.CancelFunc
+    history []*UpdateRun
+    historySize int
+}
+
+func newRunController(historySize int) *runController {
+    return &runController{
+        queued: make(chan interface{}, 1),
+        historySize: historySize}
+}
+
+// queue adds run to be started once the runs ahead of it are done.
+// Returns false if a run of the same job is already waiting.
+func (controller *runController) queue(run *UpdateRun) bool {
+    controller.mutex.Lock()
+    defer controller.mutex.Unlock()
+    for _, pending := range controller.pending {
+        if pending.Job == run.Job {
+            return false
+        }
+    }
+    controller.pending = append(controller.pending, run)
+
+    select {
+    case controller.queued <- nil:
+    default:
+    }
+    return true
+}
+
+// takePending returns the run that's been waiting longest, if there is
+// one, and moves it from the queue to current.  Doing both under the one
+// lock means there's no moment when cancel can't find it.
+func (controller *runController) takePending() *UpdateRun {
+    controller.mutex.Lock()
+    defer controller.mutex.Unlock()
+    if len(controller.pending) == 0 {
+        return nil
+    }
+    run := controller.pending[0]
+    controller.pending = controller.pending[1:]
+    controller.current = run
+    return run
+}
+
+// run runs an update taken by takePending and blocks until it's done.
+// Cancelling ctx interrupts it, as does cancel.
+func (controller *runController) run(ctx context.Context,
+        importerConfig *config.Config,
+        run *UpdateRun) {
+    runCtx, cancelRun := context.WithCancel(ctx)
+    defer cancelRun()
+
+    controller.mutex.Lock()
+    controller.cancelCurrent = cancelRun
+    controller.mutex.Unlock()
+    // A cancel since takePending could only mark the run
+    if run.Cancelled() {
+        cancelRun()
+    }
+
+    run.start()
+    runStart := time.Now()
+    CheckForAndMaybeRunUpdate(runCtx, importerConfig, run)
+
+    status := RUN_FINISHED
+    if run.Cancelled() {
+        status = RUN_CANCELLED
+    } else if ctx.Err() != nil {
+        status = RUN_INTERRUPTED
+    }
+    run.finish(status)
+    recordRunMetrics(run, time.Since(runStart), status)
+    run.logger.Info("Update done", "status", status,
+        "duration", time.Since(runStart))
+
+    controller.mutex.Lock()
+    defer controller.mutex.Unlock()
+    controller.current = nil
+    controller.cancelCurrent = nil
+    controller.addToHistory(run)
+}
+
+// cancel stops the run in progress and drops the ones waiting, if there are
+// any.  The run in progress winds down the same way it does when the
+// importer is shut down.  Returns false if there was nothing to cancel.
+func (controller *runController) cancel() bool {
+    controller.mutex.Lock()
+    defer controller.mutex.Unlock()
+
+    cancelled := false
+    for _, pending := range controller.pending {
+        pending.markCancelled()
+        pending.finish(RUN_CANCELLED)
+        controller.addToHistory(pending)
+        cancelled = true
+    }
+    controller.pending = nil
+    if controller.current != nil && !controller.current.Cancelled() {
+        controller.current.logger.Info("Cancelling the update in progress")
+        controller.current.markCancelled()
+        if controller.cancelCurrent != nil {
+            controller.cancelCurrent()
+        }
+        cancelled = true
+    }
+    return cancelled
+}
+
+// Must be called with the mutex held
+func (controller *runController) addToHistory(run *UpdateRun) {
+    controller.history = append(controller.history, run)
+    if len(controller.history) > controller.historySize {
+        controller.history = controller.history[len(controller.history) - controller.historySize:]
+    }
+}
+
+// runs returns the current, pending and finished runs
+func (controller *runController) runs() (*UpdateRun, []*UpdateRun, []*UpdateRun) {
+    controller.mutex.Lock()
+    defer controller.mutex.Unlock()
+    return controller.current,
+        append([]*UpdateRun(nil), controller.pending...),
+        append([]*UpdateRun(nil), controller.history...)
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestRunControllerQueuesOneRunPerJob(t *testing.T) {
+    controller := newRunController(10)
+
+    if !controller.queue(NewUpdateRun(TRIGGER_SCHEDULE, JOB_CARDS, RunOptions{})) {
+        t.Fatal("Expected the first cards run to be queued")
+    }
+    if controller.queue(NewUpdateRun(TRIGGER_API, JOB_CARDS, RunOptions{})) {
+        t.Error("Expected a second cards run to be refused while the first is waiting")
+    }
+    if !controller.queue(NewUpdateRun(TRIGGER_SCHEDULE, JOB_PRICES, RunOptions{})) {
+        t.Error("Expected a prices run to be queued alongside the cards run")
+    }
+
+    // Once the waiting run has started, another of the same job can wait
+    run := controller.takePending()
+    if run == nil || run.Job != JOB_CARDS {
+        t.Fatalf("Expected the cards run to be taken first, got %v", run)
+    }
+    if !controller.queue(NewUpdateRun(TRIGGER_API, JOB_CARDS, RunOptions{})) {
+        t.Error("Expected a cards run to be queued once the last one started")
+    }
+
+    current, pending, _ := controller.runs()
+    if current != run {
+        t.Error("Expected the taken run to be current")
+    }
+    if len(pending) != 2 || pending[0].Job != JOB_PRICES || pending[1].Job != JOB_CARDS {
+        t.Errorf("Expected prices then cards to be waiting, got %d runs", len(pending))
+    }
+}
+
+func TestRunControllerCancel(t *testing.T) {
+    controller := newRunController(10)
+    if controller.cancel() {
+        t.Error("Expected nothing to cancel")
+    }
+
+    controller.queue(NewUpdateRun(TRIGGER_SCHEDULE, JOB_CARDS, RunOptions{}))
+    controller.queue(NewUpdateRun(TRIGGER_SCHEDULE, JOB_PRICES, RunOptions{}))
+
+    // Cancelling between a run being taken and it starting still has to
+    // reach it
+    current := controller.takePending()
+    if !controller.cancel() {
+        t.Fatal("Expected the runs to be cancelled")
+    }
+    if !current.Cancelled() {
+        t.Error("Expected the taken run to be cancelled")
+    }
+
+    _, pending, history := controller.runs()
+    if len(pending) != 0 {
+        t.Errorf("Expected the queue to be emptied, %d runs are waiting", len(pending))
+    }
+    if len(history) != 1 || history[0].Job != JOB_PRICES || history[0].Summary().Status != RUN_CANCELLED {
+        t.Errorf("Expected the waiting prices run to be recorded as cancelled, got %d runs", len(history))
+    }
+
+    if controller.cancel() {
+        t.Error("Expected an already cancelled run not to be cancelled again")
+    }
+}
+
+func TestRunControllerTrimsHistory(t *testing.T) {
+    controller := newRunController(2)
+    for _, job := range []string{JOB_CARDS, JOB_PRICES, JOB_IMAGES} {
+        controller.queue(NewUpdateRun(TRIGGER_SCHEDULE, job, RunOptions{}))
+    }
+    controller.cancel()
+
+    _, _, history := controller.runs()
+    if len(history) != 2 || history[0].Job != JOB_PRICES || history[1].Job != JOB_IMAGES {
+        t.Errorf("Expected only the last 2 runs to be kept, got %d", len(history))
+    }
+}
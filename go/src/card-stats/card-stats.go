@@ -1,14 +1,36 @@
 package main
 
-import "log"
+import "config"
+import "context"
+import "flag"
+import "logging"
 import "mtgcards"
 
+var logger = logging.For("card-stats")
+
 func main() {
-	allSets, err := mtgcards.DownloadAllPrintings(true, true)
+	configPath := flag.String("config", "", "Path to the JSON config file")
+	flag.Parse()
+
+	statsConfig, err := config.Load(*configPath)
+	if err != nil {
+		logging.Fatal(logger, "Error loading config", "err", err)
+	}
+	err = statsConfig.ConfigureLogging()
+	if err != nil {
+		logging.Fatal(logger, "Error configuring logging", "err", err)
+	}
+	dataSource, err := mtgcards.NewDataSource(statsConfig.MTGJSON.Source,
+		statsConfig.MTGJSON.BaseURL, statsConfig.MTGJSON.DataDir)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal(logger, "Error setting up the MTGJSON source", "err", err)
+	}
+	mtgcards.ConfigureDownloads(dataSource, statsConfig.MTGJSON.SchemaVersion)
+
+	allSets, err := mtgcards.DownloadAllPrintings(context.Background(), true, false)
+	if err != nil {
+		logging.Fatal(logger, "Error downloading AllPrintings", "err", err)
 	}
 
 	mtgcards.DevelopmentStats(allSets)
 }
-
@@ -0,0 +1,386 @@
+package carddb
+
+import "database/sql"
+import "fmt"
+import "mtgcards"
+import "strings"
+
+// The most placeholders put in a single bulk INSERT.  SQLite builds before
+// 3.32 refuse statements with more than 999, and MariaDB's limit is far
+// higher, so stay under SQLite's.
+const MAX_BULK_INSERT_PLACEHOLDERS = 999
+
+// The columns of all_cards set on insert, in the order cardInsertValues
+// returns them
+var allCardsInsertColumns = []string{
+    "uuid", "card_hash", "artist", "ascii_name", "border_color", "card_number", "card_power",
+    "card_type", "color_identity", "color_indicator", "colors", "converted_mana_cost",
+    "duel_deck", "edhrec_rank", "face_converted_mana_cost", "flavor_name", "flavor_text",
+    "frame_version", "hand", "has_foil", "has_non_foil", "is_alternative",
+    "is_arena", "is_buy_a_box", "is_date_stamped", "is_full_art", "is_mtgo", "is_online_only",
+    "is_oversized", "is_paper", "is_promo", "is_reprint", "is_reserved", "is_starter",
+    "is_story_spotlight", "is_textless", "is_timeshifted", "layout", "life", "loyalty",
+    "mana_cost", "mcm_id", "mcm_meta_id", "mtg_arena_id", "mtgo_foil_id", "mtgo_id",
+    "mtgstocks_id", "multiverse_id", "name", "original_text", "original_type", "rarity",
+    "scryfall_id", "scryfall_illustration_id", "scryfall_oracle_id", "set_id",
+    "side", "tcgplayer_product_id", "text", "toughness", "watermark"}
+
+// multiRowInsertSQL returns an INSERT of rows rows into columns of table
+func multiRowInsertSQL(table string, columns []string, rows int) string {
+    row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+    rowList := make([]string, rows)
+    for i := range rowList {
+        rowList[i] = row
+    }
+
+    return fmt.Sprintf("INSERT INTO %s\n(%s)\nVALUES\n%s",
+        table, strings.Join(columns, ", "), strings.Join(rowList, ",\n"))
+}
+
+// bulkInserter batches up rows for a table, and writes them as multi-row
+// INSERTs once there are enough of them to fill one, or when flushed
+type bulkInserter struct {
+    tx *sql.Tx
+    table string
+    columns []string
+    rowsPerInsert int
+    rows int
+    values []interface{}
+}
+
+func newBulkInserter(tx *sql.Tx, table string, columns ...string) *bulkInserter {
+    rowsPerInsert := MAX_BULK_INSERT_PLACEHOLDERS / len(columns)
+    return &bulkInserter{
+        tx: tx,
+        table: table,
+        columns: columns,
+        rowsPerInsert: rowsPerInsert,
+        values: make([]interface{}, 0, rowsPerInsert * len(columns))}
+}
+
+func (inserter *bulkInserter) add(values ...interface{}) error {
+    if len(values) != len(inserter.columns) {
+        return fmt.Errorf("Expected %d values for %s, got %d",
+            len(inserter.columns), inserter.table, len(values))
+    }
+
+    inserter.values = append(inserter.values, values...)
+    inserter.rows += 1
+    if inserter.rows == inserter.rowsPerInsert {
+        return inserter.flush()
+    }
+    return nil
+}
+
+func (inserter *bulkInserter) flush() error {
+    if inserter.rows == 0 {
+        return nil
+    }
+
+    _, err := inserter.tx.Exec(
+        multiRowInsertSQL(inserter.table, inserter.columns, inserter.rows),
+        inserter.values...)
+    if err != nil {
+        return fmt.Errorf("Bulk insert into %s failed: %s", inserter.table, err)
+    }
+
+    inserter.rows = 0
+    inserter.values = inserter.values[:0]
+    return nil
+}
+
+// cardDataInserters has a bulkInserter for each of the tables holding the
+// card data that doesn't live in all_cards
+type cardDataInserters struct {
+    altLangData *bulkInserter
+    baseTypes *bulkInserter
+    frameEffects *bulkInserter
+    leadershipSkills *bulkInserter
+    legalities *bulkInserter
+    otherFaces *bulkInserter
+    printings *bulkInserter
+    purchaseURLs *bulkInserter
+    rulings *bulkInserter
+    subtypes *bulkInserter
+    supertypes *bulkInserter
+    variations *bulkInserter
+}
+
+func newCardDataInserters(tx *sql.Tx) *cardDataInserters {
+    return &cardDataInserters{
+        altLangData: newBulkInserter(tx, "alternate_language_data",
+            "card_id", "flavor_text", "language", "multiverse_id", "name", "text", "card_type"),
+        baseTypes: newBulkInserter(tx, "card_base_types", "card_id", "base_type_option_id"),
+        frameEffects: newBulkInserter(tx, "frame_effects", "card_id", "frame_effect_option_id"),
+        leadershipSkills: newBulkInserter(tx, "leadership_skills",
+            "card_id", "leadership_format_id", "leader_legal"),
+        legalities: newBulkInserter(tx, "legalities",
+            "card_id", "game_format_id", "legality_option_id"),
+        otherFaces: newBulkInserter(tx, "other_faces", "card_id", "other_face_uuid"),
+        printings: newBulkInserter(tx, "card_printings", "card_id", "set_code"),
+        purchaseURLs: newBulkInserter(tx, "purchase_urls",
+            "card_id", "purchase_site_id", "purchase_url"),
+        rulings: newBulkInserter(tx, "rulings", "card_id", "ruling_date", "ruling_text"),
+        subtypes: newBulkInserter(tx, "card_subtypes", "card_id", "subtype_option_id"),
+        supertypes: newBulkInserter(tx, "card_supertypes", "card_id", "supertype_option_id"),
+        variations: newBulkInserter(tx, "variations", "card_id", "variation_uuid")}
+}
+
+func (inserters *cardDataInserters) flush() error {
+    for _, inserter := range []*bulkInserter{
+            inserters.altLangData,
+            inserters.baseTypes,
+            inserters.frameEffects,
+            inserters.leadershipSkills,
+            inserters.legalities,
+            inserters.otherFaces,
+            inserters.printings,
+            inserters.purchaseURLs,
+            inserters.rulings,
+            inserters.subtypes,
+            inserters.supertypes,
+            inserters.variations} {
+        err := inserter.flush()
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// add queues the same rows InsertOtherCardDataToDB inserts for the card
+func (inserters *cardDataInserters) add(cardId int64, card *mtgcards.MTGCard) error {
+    for _, altLangData := range card.AlternateLanguageData {
+        err := inserters.altLangData.add(cardId, altLangData.FlavorText, altLangData.Language,
+            altLangData.MultiverseId, altLangData.Name, altLangData.Text, altLangData.Type)
+        if err != nil {
+            return err
+        }
+    }
+
+    for baseType := range baseTypes(card) {
+        baseTypeOptionId, err := getBaseTypeOptionId(baseType)
+        if err != nil {
+            return err
+        }
+        err = inserters.baseTypes.add(cardId, baseTypeOptionId)
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, frameEffect := range card.FrameEffects {
+        frameEffectId, err := getFrameEffectId(frameEffect)
+        if err != nil {
+            return err
+        }
+        err = inserters.frameEffects.add(cardId, frameEffectId)
+        if err != nil {
+            return err
+        }
+    }
+
+    for leadershipFormat, leaderValid := range card.LeadershipSkills {
+        leadershipFormatId, err := getLeadershipFormatId(leadershipFormat)
+        if err != nil {
+            return err
+        }
+        err = inserters.leadershipSkills.add(cardId, leadershipFormatId, leaderValid)
+        if err != nil {
+            return err
+        }
+    }
+
+    for format, legality := range card.Legalities {
+        gameFormatId, err := getGameFormatId(format)
+        if err != nil {
+            return err
+        }
+        legalityOptionId, err := getLegalityOptionId(legality)
+        if err != nil {
+            return err
+        }
+        err = inserters.legalities.add(cardId, gameFormatId, legalityOptionId)
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, otherFaceId := range card.OtherFaceIds {
+        err := inserters.otherFaces.add(cardId, otherFaceId)
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, setCode := range card.Printings {
+        err := inserters.printings.add(cardId, setCode)
+        if err != nil {
+            return err
+        }
+    }
+
+    for site, url := range card.PurchaseURLs {
+        purchaseSiteId, err := getPurchaseSiteId(site)
+        if err != nil {
+            return err
+        }
+        err = inserters.purchaseURLs.add(cardId, purchaseSiteId, url)
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, ruling := range card.Rulings {
+        err := inserters.rulings.add(cardId, ruling.Date, ruling.Text)
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, subtype := range card.Subtypes {
+        subtypeId, err := getSubtypeOptionId(subtype)
+        if err != nil {
+            return err
+        }
+        err = inserters.subtypes.add(cardId, subtypeId)
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, supertype := range card.Supertypes {
+        supertypeId, err := getSupertypeOptionId(supertype)
+        if err != nil {
+            return err
+        }
+        err = inserters.supertypes.add(cardId, supertypeId)
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, variation := range card.Variations {
+        err := inserters.variations.add(cardId, variation)
+        if err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// bulkInsertNewSetToDb inserts a set that isn't in the db yet, along with
+// all of its cards and tokens, in tx.  Cards and everything that hangs off
+// them go in as multi-row INSERTs, a table at a time, rather than a row at a
+// time.  tx is committed if everything was inserted, and rolled back
+// otherwise, so unlike the row at a time path a single bad card fails the
+// whole set, which is then retried in full by the next import.
+//
+// It returns how many cards and new tokens were inserted.
+func bulkInsertNewSetToDb(
+        tx *sql.Tx,
+        insertQueries *DBInsertQueries,
+        set *mtgcards.MTGSet) (int, int, error) {
+    setId, cards, tokens, err := bulkInsertSet(tx, insertQueries, set)
+    if err != nil {
+        tx.Rollback()
+        return 0, 0, err
+    }
+
+    err = tx.Commit()
+    if err != nil {
+        return 0, 0, err
+    }
+
+    logger.Info("Bulk inserted set", "set", set.Code, "set_id", setId, "cards", cards,
+        "tokens", tokens)
+    return cards, tokens, nil
+}
+
+func bulkInsertSet(
+        tx *sql.Tx,
+        insertQueries *DBInsertQueries,
+        set *mtgcards.MTGSet) (int64, int, int, error) {
+    setInsertQueries := insertQueries.ForTx(tx)
+    setId, err := InsertSetToDB(set, setInsertQueries)
+    if err != nil {
+        return 0, 0, 0, err
+    }
+
+    cardInserter := newBulkInserter(tx, "all_cards", allCardsInsertColumns...)
+    for idx := range set.Cards {
+        err = cardInserter.add(cardInsertValues(&set.Cards[idx], setId)...)
+        if err != nil {
+            return 0, 0, 0, err
+        }
+    }
+    err = cardInserter.flush()
+    if err != nil {
+        return 0, 0, 0, err
+    }
+
+    // A multi-row INSERT only gives back one id, and the rest aren't
+    // guaranteed to follow on from it, so look them all up instead
+    cardIds, err := cardIdsInSet(tx, setId)
+    if err != nil {
+        return 0, 0, 0, err
+    }
+
+    dataInserters := newCardDataInserters(tx)
+    for idx := range set.Cards {
+        card := &set.Cards[idx]
+        cardId, ok := cardIds[card.UUID]
+        if !ok {
+            return 0, 0, 0, fmt.Errorf("Card %s wasn't inserted", card.UUID)
+        }
+        err = dataInserters.add(cardId, card)
+        if err != nil {
+            return 0, 0, 0, err
+        }
+    }
+    err = dataInserters.flush()
+    if err != nil {
+        return 0, 0, 0, err
+    }
+
+    // Tokens can already be in the db from another set, which the multi-row
+    // INSERTs can't skip over, and there are few enough of them that they
+    // can go in a row at a time
+    tokens := 0
+    for idx := range set.Tokens {
+        inserted, err := InsertTokenToDB(&set.Tokens[idx], setId, setInsertQueries)
+        if err != nil {
+            return 0, 0, 0, err
+        }
+        if inserted {
+            tokens += 1
+        }
+    }
+
+    return setId, len(set.Cards), tokens, nil
+}
+
+func cardIdsInSet(tx *sql.Tx, setId int64) (map[string]int64, error) {
+    res, err := tx.Query(`SELECT card_id, uuid FROM all_cards WHERE set_id = ?`, setId)
+    if err != nil {
+        return nil, err
+    }
+    defer res.Close()
+
+    cardIds := make(map[string]int64)
+    for res.Next() {
+        var cardId int64
+        var uuid string
+        err = res.Scan(&cardId, &uuid)
+        if err != nil {
+            return nil, err
+        }
+        cardIds[uuid] = cardId
+    }
+    if err = res.Err(); err != nil {
+        return nil, err
+    }
+
+    return cardIds, nil
+}
@@ -0,0 +1,156 @@
+package carddb
+
+import "config"
+import "context"
+import "database/sql"
+import "dbconn"
+import "fmt"
+import "io/ioutil"
+import "migrations"
+import "mtgcards"
+import "os"
+import "path/filepath"
+import "testing"
+
+func openTestCardDB(tb testing.TB) (*sql.DB, func()) {
+    dir, err := ioutil.TempDir("", "carddb")
+    if err != nil {
+        tb.Fatal(err)
+    }
+    dbConfig := config.DatabaseConfig{
+        Driver: config.SQLITE_DRIVER,
+        Path: filepath.Join(dir, "cards.db")}
+
+    db, err := dbconn.Open(dbConfig)
+    if err != nil {
+        os.RemoveAll(dir)
+        tb.Fatal(err)
+    }
+    cleanup := func() {
+        db.Close()
+        os.RemoveAll(dir)
+    }
+
+    err = migrations.Migrate(db, dbConfig.Driver, &migrations.CardDBSchema,
+        migrations.CardDBSchema.LatestVersion())
+    if err != nil {
+        cleanup()
+        tb.Fatal(err)
+    }
+    return db, cleanup
+}
+
+func testSet(code string, cardCount int) mtgcards.MTGSet {
+    set := mtgcards.MTGSet{
+        Code: code,
+        Name: "Set " + code,
+        ReleaseDate: "2020-06-01",
+        Type: "expansion",
+        BaseSetSize: cardCount,
+        TotalSetSize: cardCount}
+
+    for i := 0; i < cardCount; i++ {
+        card := mtgcards.MTGCard{
+            Legalities: map[string]string{"modern": "Legal", "legacy": "Legal"},
+            Printings: []string{code},
+            PurchaseURLs: map[string]string{"tcgplayer": "https://example.com"},
+            Rulings: []mtgcards.MTGCardRuling{{Date: "2020-06-01", Text: "A ruling"}}}
+        card.UUID = fmt.Sprintf("%s-card-%d", code, i)
+        card.Name = fmt.Sprintf("Card %d", i)
+        card.Number = fmt.Sprint(i + 1)
+        card.Layout = "normal"
+        card.Subtypes = []string{"Elf"}
+        card.Supertypes = []string{"Legendary"}
+        card.Types = []string{"Creature"}
+        set.Cards = append(set.Cards, card)
+    }
+    return set
+}
+
+func importTestSets(tb testing.TB, db *sql.DB, bulkInsert bool, sets ...mtgcards.MTGSet) *CardUpdateStats {
+    setChan := make(chan mtgcards.MTGSet, len(sets))
+    for _, set := range sets {
+        setChan <- set
+    }
+    close(setChan)
+
+    stats, err := ImportSetsToDB(context.Background(), db, setChan, ImportOptions{
+        Workers: 1,
+        BulkInsert: bulkInsert})
+    if err != nil {
+        tb.Fatal(err)
+    }
+    if stats.FailedSets() > 0 || stats.FailedCards() > 0 {
+        tb.Fatalf("Import failed: %v", stats.SetFailures())
+    }
+    return stats
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+    var count int
+    err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return count
+}
+
+func TestBulkInsertMatchesRowAtATimeInsert(t *testing.T) {
+    rowDB, rowCleanup := openTestCardDB(t)
+    defer rowCleanup()
+    bulkDB, bulkCleanup := openTestCardDB(t)
+    defer bulkCleanup()
+
+    // Enough cards that every table takes more than one INSERT
+    importTestSets(t, rowDB, false, testSet("AAA", 400))
+    stats := importTestSets(t, bulkDB, true, testSet("AAA", 400))
+    if stats.TotalNewCardsInNewSets() != 400 {
+        t.Errorf("Expected 400 new cards, got %d", stats.TotalNewCardsInNewSets())
+    }
+
+    for _, table := range []string{"all_cards", "card_base_types", "card_printings",
+            "card_subtypes", "card_supertypes", "legalities", "purchase_urls", "rulings"} {
+        rowCount := countRows(t, rowDB, table)
+        bulkCount := countRows(t, bulkDB, table)
+        if rowCount != bulkCount || rowCount == 0 {
+            t.Errorf("%s has %d rows after a bulk insert, expected %d", table, bulkCount, rowCount)
+        }
+    }
+
+    // The bulk inserted cards have to read back the same as they went in,
+    // or the next import would see them all as changed
+    var getQueries DBGetQueries
+    defer getQueries.Cleanup()
+    if err := getQueries.Prepare(bulkDB); err != nil {
+        t.Fatal(err)
+    }
+    set := testSet("AAA", 1)
+    set.Canonicalize()
+    exists, cardHash, _, err := GetCardHashAndIdFromDB(set.Cards[0].UUID, &getQueries)
+    if err != nil || !exists || cardHash != set.Cards[0].Hash() {
+        t.Errorf("Unexpected bulk inserted card hash %s (exists %v, err %v), expected %s",
+            cardHash, exists, err, set.Cards[0].Hash())
+    }
+}
+
+func benchmarkNewSetInsert(b *testing.B, bulkInsert bool) {
+    db, cleanup := openTestCardDB(b)
+    defer cleanup()
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        b.StopTimer()
+        set := testSet(fmt.Sprintf("S%d", i), 250)
+        b.StartTimer()
+
+        importTestSets(b, db, bulkInsert, set)
+    }
+}
+
+func BenchmarkNewSetRowAtATime(b *testing.B) {
+    benchmarkNewSetInsert(b, false)
+}
+
+func BenchmarkNewSetBulkInsert(b *testing.B) {
+    benchmarkNewSetInsert(b, true)
+}
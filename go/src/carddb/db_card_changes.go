@@ -0,0 +1,40 @@
+package carddb
+
+import "database/sql"
+import "encoding/json"
+import "mtgcards"
+import "time"
+
+// recordCardChange stores the field level changes made to a card by an
+// import in card_changes, so that the old values aren't lost when the card
+// is overwritten.  version is the MTGJSON version being imported, or empty
+// if the import isn't part of a run.
+func recordCardChange(
+        cardId int64,
+        card *mtgcards.MTGCard,
+        version string,
+        oldHash string,
+        newHash string,
+        changes mtgcards.ChangeList,
+        queries *DBInsertQueries) error {
+    changesJSON, err := json.Marshal(changes)
+    if err != nil {
+        return err
+    }
+
+    var mtgjsonVersion sql.NullString
+    if len(version) > 0 {
+        mtgjsonVersion.String = version
+        mtgjsonVersion.Valid = true
+    }
+
+    _, err = queries.InsertCardChangeQuery.Exec(
+        cardId,
+        card.UUID,
+        mtgjsonVersion,
+        oldHash,
+        newHash,
+        string(changesJSON),
+        time.Now().UTC())
+    return err
+}
@@ -0,0 +1,93 @@
+package carddb
+
+import "encoding/json"
+import "mtgcards"
+import "testing"
+
+func TestCardRoundTripsThroughDB(t *testing.T) {
+    db, cleanup := openTestCardDB(t)
+    defer cleanup()
+
+    // An empty set to put the card in
+    importTestSets(t, db, false, testSet("AAA", 0))
+    var setId int64
+    err := db.QueryRow(`SELECT set_id FROM sets WHERE code = ?`, "AAA").Scan(&setId)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    card := testSet("AAA", 1).Cards[0]
+    card.Artist = "An Artist"
+    card.ColorIdentity = []string{"G", "U"}
+    card.Colors = []string{"G"}
+    card.EDHRecRank = 1234
+    card.FlavorText = "Some flavor"
+    card.ManaCost = "{G}"
+    card.Rarity = "common"
+    card.Text = "{T}: Add {G}."
+    card.Power = "1"
+    card.Toughness = "1"
+
+    insertQueries := DBInsertQueries{}
+    if err = insertQueries.Prepare(db); err != nil {
+        t.Fatal(err)
+    }
+    defer insertQueries.Cleanup()
+    if err = InsertCardToDB(&card, setId, &insertQueries); err != nil {
+        t.Fatal(err)
+    }
+
+    getQueries := DBGetQueries{}
+    if err = getQueries.Prepare(db); err != nil {
+        t.Fatal(err)
+    }
+    defer getQueries.Cleanup()
+    found, _, cardId, err := GetCardHashAndIdFromDB(card.UUID, &getQueries)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !found {
+        t.Fatal("Expected the inserted card to be found")
+    }
+
+    stored, err := GetCardFromDB(cardId, &card, &getQueries)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if changes := stored.Diff(&card); len(changes) != 0 {
+        t.Errorf("Expected the stored card to match the inserted one, got %s", changes)
+    }
+
+    // Changing one legality is one change, to that format
+    changed := card
+    changed.Legalities = map[string]string{"modern": "Banned", "legacy": "Legal"}
+    changes := stored.Diff(&changed)
+    if len(changes) != 1 || changes[0].Path != "Legalities[modern]" {
+        t.Errorf("Expected a single change to Legalities[modern], got %s", changes)
+    }
+}
+
+func TestImportRecordsChangedLegality(t *testing.T) {
+    db, cleanup := openTestCardDB(t)
+    defer cleanup()
+
+    set := testSet("AAA", 1)
+    importTestSets(t, db, false, set)
+    set.Cards[0].Legalities = map[string]string{"modern": "Banned", "legacy": "Legal"}
+    importTestSets(t, db, false, set)
+
+    var changesJSON string
+    err := db.QueryRow(`SELECT changes FROM card_changes WHERE uuid = ?`,
+        set.Cards[0].UUID).Scan(&changesJSON)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var changes mtgcards.ChangeList
+    if err = json.Unmarshal([]byte(changesJSON), &changes); err != nil {
+        t.Fatal(err)
+    }
+    if len(changes) != 1 || changes[0].Path != "Legalities[modern]" ||
+            changes[0].Old != "Legal" || changes[0].New != "Banned" {
+        t.Errorf("Expected a single change to Legalities[modern], got %s", changes)
+    }
+}
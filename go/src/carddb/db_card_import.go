@@ -2,16 +2,77 @@ package carddb
 
 import "context"
 import "database/sql"
-import "log"
+import "fmt"
+import "logging"
 import "mtgcards"
 import "sync"
 
+var logger = logging.For("carddb")
+
+// ImportOptions controls how ImportSetsToDB runs
+type ImportOptions struct {
+    // How many sets are imported at once.  Each worker holds a set's cards in
+    // memory and a db connection open, so this bounds both no matter how
+    // large AllPrintings gets.
+    Workers int
+
+    // Where progress is reported while the import runs.  If nil, progress is
+    // still logged but isn't visible to the caller.
+    Progress *ImportProgress
+
+    // The run this import is part of.  Sets that an earlier run for the same
+    // version already finished are skipped, and each set is checkpointed
+    // once it's done.  If nil, every set is imported and nothing is recorded.
+    Run *ImportRun
+
+    // If set, nothing is written to the db.  Sets are compared against what's
+    // already there, and everything that would change is added to the report
+    // instead.
+    DryRun *DryRunReport
+
+    // If set, every set, card and token received is recorded here, including
+    // the ones that are skipped, so that ReconcileRemovals can tell what's
+    // gone from the source
+    Seen *SourceContents
+
+    // If set, sets that aren't in the db yet are inserted with multi-row
+    // INSERTs in a single transaction, rather than a card at a time.  Much
+    // quicker for a fresh import, but one bad card fails its whole set.
+    BulkInsert bool
+
+    // If set, only the sets that match it are imported (or compared, in a
+    // dry run).  Sets it excludes are still recorded in Seen, so that they
+    // don't look like they've been removed from the source.
+    Filter *ImportFilter
+}
+
+// ImportSetsToDB imports every set received on sets, until the channel is
+// closed.  A set that fails to import is recorded in the returned stats
+// rather than stopping the run.  If it returns an error, the caller is
+// responsible for stopping whatever is sending on sets.
+//
+// If ctx is cancelled, no new sets are started, but any set that's already
+// being imported is finished, so that no set is left half updated.  The
+// stats for the sets that did get imported are returned along with
+// ctx.Err().
 func ImportSetsToDB(
+        ctx context.Context,
         db *sql.DB,
-        sets map[string]mtgcards.MTGSet) (*CardUpdateStats, error) {
-	var setImportWg sync.WaitGroup
+        sets <-chan mtgcards.MTGSet,
+        options ImportOptions) (*CardUpdateStats, error) {
+	var workersWg sync.WaitGroup
 	var stats CardUpdateStats
 
+	if options.Workers < 1 {
+		return nil, fmt.Errorf("Need at least 1 import worker, got %d", options.Workers)
+	}
+
+	progress := options.Progress
+	if progress == nil {
+		progress = NewImportProgress()
+	}
+	progress.StartStage("importing cards")
+
 	// We defer the cleanup before calling the setup function, because the setup
 	// function might get partway through the initialization, and then error out,
 	// leaving some things to be cleaned up.  The cleanup function will only
@@ -49,23 +110,105 @@ func ImportSetsToDB(
         return nil, err
     }
 
-	for _, set := range sets {
-		setImportWg.Add(1)
-		go maybeInsertSetToDb(
-            db,
-            &getQueries,
-            &insertQueries,
-            &updateQueries,
-            &deleteQueries,
-            &stats,
-            &setImportWg,
-            set)
+	// Recorded against every card change, so the history shows which
+	// build a change came in with
+	version := ""
+	if options.Run != nil {
+		version = options.Run.Version()
 	}
 
-	setImportWg.Wait()
+	reportDone := make(chan interface{})
+	go progress.reportPeriodically(reportDone)
+	defer close(reportDone)
+
+	for i := 0; i < options.Workers; i++ {
+		workersWg.Add(1)
+		go func() {
+			defer workersWg.Done()
+			for {
+				// Check for cancellation first, since select doesn't
+				// prefer either case if a set is ready too
+				if ctx.Err() != nil {
+					return
+				}
+
+				var set mtgcards.MTGSet
+				var ok bool
+				select {
+				case <-ctx.Done():
+					return
+				case set, ok = <-sets:
+				}
+				if !ok {
+					progress.finishedReading()
+					return
+				}
+
+				progress.setRead()
+				if options.Seen != nil {
+					options.Seen.addSet(&set)
+				}
+				if options.Filter != nil && !options.Filter.Matches(&set) {
+					stats.AddToFilteredSetsSkipped(1)
+					progress.setDone(0, false)
+					continue
+				}
+				if options.Run != nil && options.Run.alreadyImported(set.Code) {
+					logger.Info("Set was already imported by an earlier run, skipping it",
+						"set", set.Code)
+					stats.AddToCheckpointedSetsSkipped(1)
+					progress.setDone(0, false)
+					continue
+				}
+
+				var cards int
+				var err error
+				if options.DryRun != nil {
+					cards, err = compareSetToDb(&getQueries, set, options.DryRun)
+				} else {
+					cards, err = maybeInsertSetToDb(
+						db,
+						&getQueries,
+						&insertQueries,
+						&updateQueries,
+						&deleteQueries,
+						&stats,
+						version,
+						options.BulkInsert,
+						set)
+				}
+				if err != nil {
+					logger.Error("Failed to import set", "set", set.Code, "err", err)
+					stats.AddSetFailure(set.Code, err)
+				} else if options.Run != nil && options.DryRun == nil {
+					// Not being able to checkpoint only means the set
+					// gets checked again if this run doesn't finish
+					checkpointErr := options.Run.checkpoint(db, set.Code)
+					if checkpointErr != nil {
+						logger.Warn("Failed to checkpoint set", "set", set.Code, "err", checkpointErr)
+					}
+				}
+				progress.setDone(cards, err != nil)
+			}
+		}()
+	}
+
+	workersWg.Wait()
+	progress.Report()
+	if ctx.Err() != nil {
+		logger.Warn("Import interrupted, the rest will be imported on the next run",
+			"sets_done", progress.Snapshot().SetsDone)
+		stats.MarkInterrupted()
+		return &stats, ctx.Err()
+	}
 	return &stats, nil
 }
 
+// maybeInsertSetToDb returns how many cards and tokens it went through, and
+// an error only if the set as a whole couldn't be imported.  Individual
+// cards and tokens that fail are counted in stats instead.  Every card that
+// gets updated has its changes recorded against version.  If bulkInsert is
+// set, a set that isn't in the db yet is inserted by bulkInsertNewSetToDb.
 func maybeInsertSetToDb(
         db *sql.DB,
         getQueries *DBGetQueries,
@@ -73,24 +216,31 @@ func maybeInsertSetToDb(
         updateQueries *DBUpdateQueries,
         deleteQueries *DBDeleteQueries,
         stats *CardUpdateStats,
-		wg *sync.WaitGroup,
-        set mtgcards.MTGSet) {
-	defer wg.Done()
+        version string,
+        bulkInsert bool,
+        set mtgcards.MTGSet) (int, error) {
+	// Deliberately not the import's context: once a set has been started it
+	// gets finished, since its hash is committed before its cards are, and a
+	// set cut off part way through would be skipped as up to date next time
 	ctx := context.Background()
+	setLogger := logger.With("set", set.Code)
+
+	err := registerSetOptions(&set)
+	if err != nil {
+		return 0, err
+	}
 
 	// Open a DB connection
 	conn, err := db.Conn(ctx)
 	if err != nil {
-		log.Print(err)
-		return
+		return 0, err
 	}
 	defer conn.Close()
 
 	// Transaction for inserting the set itself
 	setTx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
-		log.Print(err)
-		return
+		return 0, err
 	}
 
 	// Hash the set for later use
@@ -102,9 +252,8 @@ func maybeInsertSetToDb(
 	// First, check to see if this set is in the DB at all
 	setExists, setDbHash, setId, err := GetSetHashAndIdFromDB(set.Code, setGetQueries)
 	if err != nil {
-		log.Print(err)
 		setTx.Rollback()
-		return
+		return 0, err
 	}
 
 	stats.AddToTotalSets(1)
@@ -125,29 +274,31 @@ func maybeInsertSetToDb(
     totalExistingTokensHashSkipped := 0
     totalExistingTokensUpdated := 0
 
+    failedCards := 0
+    failedTokens := 0
+
 	if setExists {
-		log.Printf("Set %s already exists in the database\n", set.Code)
+		setLogger.Debug("Set already exists in the database")
 		// This set already exists in the db
 		// Check to see if the hash matcdbhes what's already in the db
 		if setDbHash == setHash {
 			// Hashes match, so we can skip updating this set in the db
-			log.Printf("Set %s in db matches hash %s, skipping update...\n", set.Code, setDbHash)
+			setLogger.Debug("Set hash matches the db, skipping update", "hash", setDbHash)
 			setTx.Commit()
 			stats.AddToExistingSetsSkipped(1)
 		} else {
 			// Hashes don't match, so we need to first update the set itself, and then
 			// look at each card in the set to see if it needs to be updated
-			log.Printf("Set %s hashes don't match (db: %s, json: %s), updating set...\n",
-				set.Code, setDbHash, setHash)
+			setLogger.Info("Set hash doesn't match the db, updating set", "db_hash", setDbHash,
+				"hash", setHash)
 
             setUpdateQueries := updateQueries.ForTx(setTx)
             setDeleteQueries := deleteQueries.ForTx(setTx)
             setInsertQueries := insertQueries.ForTx(setTx)
             err := UpdateSetInDB(setId, &set, setUpdateQueries, setDeleteQueries, setInsertQueries)
             if err != nil {
-                log.Print(err)
                 setTx.Rollback()
-                return
+                return 0, err
             }
 
 			setTx.Commit()
@@ -163,7 +314,9 @@ func maybeInsertSetToDb(
 				// Transaction for each card
 				cardTx, err := conn.BeginTx(ctx, nil)
 				if err != nil {
-					log.Print(err)
+					setLogger.Error("Error starting card transaction", "card", card.Name,
+						"uuid", card.UUID, "err", err)
+					failedCards += 1
 					continue
 				}
 
@@ -171,8 +324,10 @@ func maybeInsertSetToDb(
 
 				cardExists, cardDbHash, cardId, err := GetCardHashAndIdFromDB(card.UUID, cardGetQueries)
 				if err != nil {
-					log.Print(err)
+					setLogger.Error("Error fetching card hash", "card", card.Name,
+						"uuid", card.UUID, "err", err)
 					cardTx.Rollback()
+					failedCards += 1
 					continue
 				}
 
@@ -180,8 +335,10 @@ func maybeInsertSetToDb(
                     cardInsertQueries := insertQueries.ForTx(cardTx)
                     err := InsertCardToDB(card, setId, cardInsertQueries)
 					if err != nil {
-						log.Print(err)
+						setLogger.Error("Error inserting card", "card", card.Name,
+							"uuid", card.UUID, "err", err)
 						cardTx.Rollback()
+						failedCards += 1
 						continue
 					}
 
@@ -194,20 +351,32 @@ func maybeInsertSetToDb(
 					cardHash := card.Hash()
 					if cardHash == cardDbHash {
 						// Can skip
-						log.Printf("Card %s hash matches in db (%s), skipping", card.Name, cardHash)
+						setLogger.Debug("Card hash matches the db, skipping", "card", card.Name,
+							"uuid", card.UUID, "hash", cardHash)
                         cardTx.Commit()
                         totalCards += 1
                         totalExistingCards += 1
 						totalExistingCardsHashSkipped += 1
 					} else {
 						// Need to update card
-						log.Printf("Card %s hash doesn't match (db: %s, card: %s), updating",
-							card.Name, cardDbHash, cardHash)
+						setLogger.Debug("Card hash doesn't match the db, updating", "card", card.Name,
+							"uuid", card.UUID, "db_hash", cardDbHash, "hash", cardHash)
+
+                        // The card is about to be overwritten, so hang on to
+                        // what it was for its change history
+                        oldCard, err := GetCardFromDB(cardId, card, cardGetQueries)
+                        if err != nil {
+                            setLogger.Error("Error fetching card to update", "card", card.Name,
+                                "uuid", card.UUID, "err", err)
+                            cardTx.Rollback()
+                            failedCards += 1
+                            continue
+                        }
 
                         cardUpdateQueries := updateQueries.ForTx(cardTx)
                         cardDeleteQueries := deleteQueries.ForTx(cardTx)
                         cardInsertQueries := insertQueries.ForTx(cardTx)
-                        err := UpdateCardInDB(
+                        err = UpdateCardInDB(
                             cardId,
                             setId,
                             card,
@@ -215,8 +384,40 @@ func maybeInsertSetToDb(
                             cardDeleteQueries,
                             cardInsertQueries)
                         if err != nil {
-                            log.Print(err)
+                            setLogger.Error("Error updating card", "card", card.Name,
+                                "uuid", card.UUID, "err", err)
+                            cardTx.Rollback()
+                            failedCards += 1
+                            continue
+                        }
+
+                        err = recordCardChange(
+                            cardId,
+                            card,
+                            version,
+                            cardDbHash,
+                            cardHash,
+                            oldCard.Diff(card),
+                            cardInsertQueries)
+                        if err != nil {
+                            setLogger.Error("Error recording card change", "card", card.Name,
+                                "uuid", card.UUID, "err", err)
                             cardTx.Rollback()
+                            failedCards += 1
+                            continue
+                        }
+
+                        err = recordLegalityChanges(
+                            cardId,
+                            card,
+                            version,
+                            oldCard.Legalities,
+                            cardInsertQueries)
+                        if err != nil {
+                            setLogger.Error("Error recording legality changes", "card", card.Name,
+                                "uuid", card.UUID, "err", err)
+                            cardTx.Rollback()
+                            failedCards += 1
                             continue
                         }
                         cardTx.Commit()
@@ -237,7 +438,9 @@ func maybeInsertSetToDb(
 				// Transaction for each token
 				tokenTx, err := conn.BeginTx(ctx, nil)
 				if err != nil {
-					log.Print(err)
+					setLogger.Error("Error starting token transaction", "token", token.Name,
+						"uuid", token.UUID, "err", err)
+					failedTokens += 1
 					continue
 				}
 
@@ -247,8 +450,10 @@ func maybeInsertSetToDb(
                     token.UUID,
                     tokenGetQueries)
 				if err != nil {
-					log.Print(err)
+					setLogger.Error("Error fetching token hash", "token", token.Name,
+						"uuid", token.UUID, "err", err)
 					tokenTx.Rollback()
+					failedTokens += 1
 					continue
 				}
 
@@ -256,8 +461,10 @@ func maybeInsertSetToDb(
                     tokenInsertQueries := insertQueries.ForTx(tokenTx)
                     inserted, err := InsertTokenToDB(token, setId, tokenInsertQueries)
 					if err != nil {
-						log.Print(err)
+						setLogger.Error("Error inserting token", "token", token.Name,
+							"uuid", token.UUID, "err", err)
 						tokenTx.Rollback()
+						failedTokens += 1
 						continue
 					}
 
@@ -272,15 +479,16 @@ func maybeInsertSetToDb(
 					tokenHash := token.Hash()
 					if tokenHash == tokenDbHash {
 						// Can skip
-						log.Printf("Token %s hash matches in db (%s), skipping", token.Name, tokenHash)
+						setLogger.Debug("Token hash matches the db, skipping", "token", token.Name,
+							"uuid", token.UUID, "hash", tokenHash)
                         tokenTx.Commit()
                         totalTokens += 1
                         totalExistingTokens += 1
 						totalExistingTokensHashSkipped += 1
 					} else {
 						// Need to update token
-						log.Printf("Token %s hash doesn't match (db: %s, token: %s), updating",
-							token.Name, tokenDbHash, tokenHash)
+						setLogger.Debug("Token hash doesn't match the db, updating", "token", token.Name,
+							"uuid", token.UUID, "db_hash", tokenDbHash, "hash", tokenHash)
 
                         tokenUpdateQueries := updateQueries.ForTx(tokenTx)
                         tokenDeleteQueries := deleteQueries.ForTx(tokenTx)
@@ -293,8 +501,10 @@ func maybeInsertSetToDb(
                             tokenDeleteQueries,
                             tokenInsertQueries)
                         if err != nil {
-                            log.Print(err)
+                            setLogger.Error("Error updating token", "token", token.Name,
+                                "uuid", token.UUID, "err", err)
                             tokenTx.Rollback()
+                            failedTokens += 1
                             continue
                         }
                         tokenTx.Commit()
@@ -307,15 +517,30 @@ func maybeInsertSetToDb(
 
 		}
 		stats.AddToTotalExistingSets(1)
+	} else if bulkInsert {
+		// This set does not already exist in the db, so it all goes in at
+		// once
+		setLogger.Debug("Bulk inserting set")
+		cards, tokens, err := bulkInsertNewSetToDb(setTx, insertQueries, &set)
+		if err != nil {
+			return 0, err
+		}
+
+		stats.AddToTotalNewSets(1)
+		totalCards += cards
+		totalNewCards += cards
+		totalNewCardsInNewSets += cards
+		totalTokens += tokens
+		totalNewTokens += tokens
+		totalNewTokensInNewSets += tokens
 	} else {
 		// This set does not already exist in the db
 
         setInsertQueries := insertQueries.ForTx(setTx)
 		setId, err := InsertSetToDB(&set, setInsertQueries)
 		if err != nil {
-			log.Print(err)
 			setTx.Rollback()
-			return
+			return 0, err
 		}
 
 		setTx.Commit()
@@ -323,7 +548,7 @@ func maybeInsertSetToDb(
 
 		// Insert all of the cards in the set.  No need to check the full card hash, since we're bulk
 		// inserting the entire set
-		log.Printf("Processing cards in set %s\n", set.Code)
+		setLogger.Debug("Processing cards in set")
 		for idx := range set.Cards {
             // Need to access by index here to get a pointer to the card,
             // not a copy
@@ -332,7 +557,9 @@ func maybeInsertSetToDb(
 			// Transaction for each card
 			cardTx, err := conn.BeginTx(ctx, nil)
 			if err != nil {
-				log.Print(err)
+				setLogger.Error("Error starting card transaction", "card", card.Name,
+					"uuid", card.UUID, "err", err)
+				failedCards += 1
 				continue
 			}
 
@@ -340,8 +567,10 @@ func maybeInsertSetToDb(
 
 			err = InsertCardToDB(card, setId, cardInsertQueries)
 			if err != nil {
-				log.Print(err)
+				setLogger.Error("Error inserting card", "card", card.Name,
+					"uuid", card.UUID, "err", err)
 				cardTx.Rollback()
+				failedCards += 1
 				continue
 			}
 			cardTx.Commit()
@@ -353,7 +582,7 @@ func maybeInsertSetToDb(
 
 		// Insert all of the tokens in the set.  No need to check the full token hash, since we're bulk
 		// inserting the entire set
-		log.Printf("Processing tokens in set %s\n", set.Code)
+		setLogger.Debug("Processing tokens in set")
 		for idx := range set.Tokens {
             // Need to access by index here to get a pointer to the token,
             // not a copy
@@ -362,7 +591,9 @@ func maybeInsertSetToDb(
 			// Transaction for each token
 			tokenTx, err := conn.BeginTx(ctx, nil)
 			if err != nil {
-				log.Print(err)
+				setLogger.Error("Error starting token transaction", "token", token.Name,
+					"uuid", token.UUID, "err", err)
+				failedTokens += 1
 				continue
 			}
 
@@ -370,8 +601,10 @@ func maybeInsertSetToDb(
 
             inserted, err := InsertTokenToDB(token, setId, tokenInsertQueries)
 			if err != nil {
-				log.Print(err)
+				setLogger.Error("Error inserting token", "token", token.Name,
+					"uuid", token.UUID, "err", err)
 				tokenTx.Rollback()
+				failedTokens += 1
 				continue
 			}
 			tokenTx.Commit()
@@ -399,5 +632,66 @@ func maybeInsertSetToDb(
     stats.AddToTotalExistingTokens(totalExistingTokens)
     stats.AddToExistingTokensSkipped(totalExistingTokensHashSkipped)
     stats.AddToExistingTokensUpdated(totalExistingTokensUpdated)
-	log.Printf("Done processing set %s\n", set.Code)
+    stats.AddToFailedCards(failedCards)
+    stats.AddToFailedTokens(failedTokens)
+	setLogger.Debug("Done processing set")
+	return totalCards + totalTokens, nil
+}
+
+// compareSetToDb makes the same hash comparisons as maybeInsertSetToDb, but
+// only adds what it would have changed to report.  Like maybeInsertSetToDb,
+// it returns how many cards it went through.
+func compareSetToDb(
+        getQueries *DBGetQueries,
+        set mtgcards.MTGSet,
+        report *DryRunReport) (int, error) {
+	set.Canonicalize()
+	setHash := set.Hash()
+
+	setExists, setDbHash, _, err := GetSetHashAndIdFromDB(set.Code, getQueries)
+	if err != nil {
+		return 0, err
+	}
+
+	if !setExists {
+		report.addNewSet(&set)
+		for idx := range set.Cards {
+			report.addNewCard(set.Code, &set.Cards[idx])
+		}
+		return len(set.Cards), nil
+	}
+
+	if setDbHash == setHash {
+		return 0, nil
+	}
+	report.addChangedSet(&set)
+
+	for idx := range set.Cards {
+		// Need to access by index here to get a pointer to the card,
+		// not a copy
+		card := &set.Cards[idx]
+
+		cardExists, cardDbHash, cardId, err := GetCardHashAndIdFromDB(card.UUID, getQueries)
+		if err != nil {
+			return idx, err
+		}
+
+		if !cardExists {
+			report.addNewCard(set.Code, card)
+			continue
+		}
+
+		cardHash := card.Hash()
+		if cardHash == cardDbHash {
+			continue
+		}
+
+		oldCard, err := GetCardFromDB(cardId, card, getQueries)
+		if err != nil {
+			return idx, err
+		}
+		report.addChangedCard(set.Code, &oldCard, card, cardDbHash, cardHash)
+	}
+
+	return len(set.Cards), nil
 }
@@ -24,10 +24,198 @@ func GetCardHashAndIdFromDB(
 	}
 }
 
-func InsertCardToDB(
-        card *mtgcards.MTGCard,
-        setId int64,
-        queries *DBInsertQueries) error {
+// GetCardFromDB loads the card stored with cardId.  Only the values kept in
+// all_cards, legalities and rulings are loaded; everything else stored in a
+// table of its own (printings, subtypes etc.) is taken from base instead, so
+// that comparing the result against base only shows the differences in
+// those.
+func GetCardFromDB(
+        cardId int64,
+        base *mtgcards.MTGCard,
+        queries *DBGetQueries) (mtgcards.MTGCard, error) {
+    card := *base
+
+    var asciiName sql.NullString
+    var colorIdentity sql.NullString
+    var colorIndicator sql.NullString
+    var colors sql.NullString
+    var duelDeck sql.NullString
+    var edhrecRank sql.NullInt32
+    var flavorName sql.NullString
+    var flavorText sql.NullString
+    var hand sql.NullString
+    var life sql.NullString
+    var loyalty sql.NullString
+    var mtgArenaId sql.NullInt32
+    var mtgoFoilId sql.NullInt32
+    var mtgoId sql.NullInt32
+    var name sql.NullString
+    var scryfallIllustrationId sql.NullString
+    var side sql.NullString
+
+    res := queries.GetCardQuery.QueryRow(cardId)
+    err := res.Scan(
+        &card.UUID,
+        &card.Artist,
+        &asciiName,
+        &card.BorderColor,
+        &card.Number,
+        &card.Power,
+        &card.Type,
+        &colorIdentity,
+        &colorIndicator,
+        &colors,
+        &card.ConvertedManaCost,
+        &duelDeck,
+        &edhrecRank,
+        &card.FaceConvertedManaCost,
+        &flavorName,
+        &flavorText,
+        &card.FrameVersion,
+        &hand,
+        &card.HasFoil,
+        &card.HasNonFoil,
+        &card.IsAlternative,
+        &card.IsArena,
+        &card.IsBuyABox,
+        &card.IsDateStamped,
+        &card.IsFullArt,
+        &card.IsMTGO,
+        &card.IsOnlineOnly,
+        &card.IsOversized,
+        &card.IsPaper,
+        &card.IsPromo,
+        &card.IsReprint,
+        &card.IsReserved,
+        &card.IsStarter,
+        &card.IsStorySpotlight,
+        &card.IsTextless,
+        &card.IsTimeshifted,
+        &card.Layout,
+        &life,
+        &loyalty,
+        &card.ManaCost,
+        &card.MCMId,
+        &card.MCMMetaId,
+        &mtgArenaId,
+        &mtgoFoilId,
+        &mtgoId,
+        &card.MTGStocksId,
+        &card.MultiverseId,
+        &name,
+        &card.OriginalText,
+        &card.OriginalType,
+        &card.Rarity,
+        &card.ScryfallId,
+        &scryfallIllustrationId,
+        &card.ScryfallOracleId,
+        &side,
+        &card.TCGPlayerProductId,
+        &card.Text,
+        &card.Toughness,
+        &card.Watermark)
+    if err != nil {
+        return card, err
+    }
+
+    // Null values are the ones InsertCardToDB stores for empty ones
+    card.AsciiName = asciiName.String
+    card.ColorIdentity = splitNullList(colorIdentity)
+    card.ColorIndicator = splitNullList(colorIndicator)
+    card.Colors = splitNullList(colors)
+    card.DuelDeck = duelDeck.String
+    card.EDHRecRank = int(edhrecRank.Int32)
+    card.FlavorName = flavorName.String
+    card.FlavorText = flavorText.String
+    card.Hand = hand.String
+    card.Life = life.String
+    card.Loyalty = loyalty.String
+    card.MTGArenaId = int(mtgArenaId.Int32)
+    card.MTGOFoilId = int(mtgoFoilId.Int32)
+    card.MTGOId = int(mtgoId.Int32)
+    card.Name = name.String
+    card.ScryfallIllustrationId = scryfallIllustrationId.String
+    card.Side = side.String
+
+    card.Legalities, err = getCardLegalitiesFromDB(cardId, queries)
+    if err != nil {
+        return card, err
+    }
+
+    card.Rulings, err = getCardRulingsFromDB(cardId, queries)
+    if err != nil {
+        return card, err
+    }
+
+    return card, nil
+}
+
+func getCardLegalitiesFromDB(
+        cardId int64,
+        queries *DBGetQueries) (map[string]string, error) {
+    rows, err := queries.GetCardLegalitiesQuery.Query(cardId)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    legalities := make(map[string]string)
+    for rows.Next() {
+        var gameFormat string
+        var legalityOption string
+        err = rows.Scan(&gameFormat, &legalityOption)
+        if err != nil {
+            return nil, err
+        }
+        legalities[gameFormat] = legalityOption
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return legalities, nil
+}
+
+func getCardRulingsFromDB(
+        cardId int64,
+        queries *DBGetQueries) ([]mtgcards.MTGCardRuling, error) {
+    rows, err := queries.GetCardRulingsQuery.Query(cardId)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    rulings := make([]mtgcards.MTGCardRuling, 0)
+    for rows.Next() {
+        var ruling mtgcards.MTGCardRuling
+        err = rows.Scan(&ruling.Date, &ruling.Text)
+        if err != nil {
+            return nil, err
+        }
+        // Some drivers hand DATE columns back as a full timestamp, but
+        // MTGJSON only ever gives the date
+        if len(ruling.Date) > len("2006-01-02") {
+            ruling.Date = ruling.Date[:len("2006-01-02")]
+        }
+        rulings = append(rulings, ruling)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return rulings, nil
+}
+
+func splitNullList(list sql.NullString) []string {
+    if !list.Valid {
+        return nil
+    }
+    return strings.Split(list.String, ",")
+}
+
+// cardInsertValues returns the values for a card's row in all_cards, in the
+// order of allCardsInsertColumns
+func cardInsertValues(card *mtgcards.MTGCard, setId int64) []interface{} {
 	// Build all of the values that can be null
     var asciiName sql.NullString
     var colorIdentity sql.NullString
@@ -132,7 +320,7 @@ func InsertCardToDB(
 		side.Valid = true
 	}
 
-	res, err := queries.InsertCardQuery.Exec(
+	return []interface{}{
         card.UUID,
         card.Hash(),
         card.Artist,
@@ -193,8 +381,14 @@ func InsertCardToDB(
         card.TCGPlayerProductId,
 		card.Text,
 		card.Toughness,
-		card.Watermark)
+		card.Watermark}
+}
 
+func InsertCardToDB(
+        card *mtgcards.MTGCard,
+        setId int64,
+        queries *DBInsertQueries) error {
+	res, err := queries.InsertCardQuery.Exec(cardInsertValues(card, setId)...)
 	if err != nil {
 		return err
 	}
@@ -418,27 +612,7 @@ func InsertOtherCardDataToDB(
 		}
 	}
 
-	// Calculate the set of "base" types, which I'm defining as the set
-	// subtraction of card.Types - (card.Subtypes + card.Supertypes)
-	cardBaseTypes := make(map[string]bool)
-	for _, cardType := range card.Types {
-		var inSubtype, inSupertype bool
-		for _, subtype := range card.Subtypes {
-			if subtype == cardType {
-				inSubtype = true
-				break
-			}
-		}
-		for _, supertype := range card.Supertypes {
-			if supertype == cardType {
-				inSupertype = true
-				break
-			}
-		}
-		if !inSubtype && !inSupertype {
-			cardBaseTypes[cardType] = true
-		}
-	}
+	cardBaseTypes := baseTypes(card)
 	for baseType, _ := range cardBaseTypes {
         err := InsertBaseTypeToDB(cardId, baseType, queries)
 		if err != nil {
@@ -529,6 +703,31 @@ func InsertOtherCardDataToDB(
     return nil
 }
 
+// baseTypes returns the set of "base" types, which I'm defining as the set
+// subtraction of card.Types - (card.Subtypes + card.Supertypes)
+func baseTypes(card *mtgcards.MTGCard) map[string]bool {
+	cardBaseTypes := make(map[string]bool)
+	for _, cardType := range card.Types {
+		var inSubtype, inSupertype bool
+		for _, subtype := range card.Subtypes {
+			if subtype == cardType {
+				inSubtype = true
+				break
+			}
+		}
+		for _, supertype := range card.Supertypes {
+			if supertype == cardType {
+				inSupertype = true
+				break
+			}
+		}
+		if !inSubtype && !inSupertype {
+			cardBaseTypes[cardType] = true
+		}
+	}
+	return cardBaseTypes
+}
+
 func InsertSubtypeToDB(
         cardId int64,
         subtype string,
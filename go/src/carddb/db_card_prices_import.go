@@ -1,109 +1,81 @@
 package carddb
 
-import influx "github.com/influxdata/influxdb1-client/v2"
-import "fmt"
+import "context"
 import "mtgcards"
+import "pricestore"
 import "sort"
 import "time"
 
 const POINTS_PER_WRITE = 1000
 
+// How many price records go by between progress lines, at debug level
+const PRICE_PROGRESS_INTERVAL = 10000
+
+// ImportPricesToDb writes every price record newer than lastImportTime to
+// the price store.  If ctx is cancelled, the points batched so far are still
+// written before it returns ctx.Err().
+//
+// Each vendor's retail and buylist prices for each finish are a separate
+// series in the platform's measurement, tagged as in PriceSeriesTags.
+// Points imported before vendors were tracked only have the card tag, with
+// foil prices in separate mtgo_foil and paper_foil measurements.
 func ImportPricesToDb(
-        influxClient influx.Client,
+        ctx context.Context,
+        priceStore pricestore.PriceStore,
         lastImportTime time.Time,
         prices map[string]mtgcards.MTGCardPrices) (PricesUpdateStats, error) {
     importStats := PricesUpdateStats{}
 
     // Create the points to be pushed to the db
-    bpConfig := influx.BatchPointsConfig{Database: "mtg_cards"}
-    bp, err := influx.NewBatchPoints(bpConfig)
-    if err != nil {
-        return importStats, err
-    }
+    points := make([]pricestore.Point, 0, POINTS_PER_WRITE)
 
     totalRecords := len(prices)
     currentRecord := 0
 
     for card, cardPrices := range prices {
-        fmt.Printf("Processing price record %d of %d\r", currentRecord, totalRecords)
+        if currentRecord % PRICE_PROGRESS_INTERVAL == 0 {
+            logger.Debug("Processing price records", "done", currentRecord, "total", totalRecords)
+        }
 
-        // Batch up records to write to influx so that we don't overflow a single
-        // request, but also don't take too long
-        if len(bp.Points()) > POINTS_PER_WRITE {
-            err = influxClient.Write(bp)
+        if ctx.Err() != nil {
+            break
+        }
+
+        // Batch up records to write to the price store so that we don't
+        // overflow a single request, but also don't take too long
+        if len(points) > POINTS_PER_WRITE {
+            err := priceStore.WritePoints(points)
             if err != nil {
                 return importStats, err
             }
 
-            importStats.AddToTotalPriceRecords(len(bp.Points()))
+            importStats.AddToTotalPriceRecords(len(points))
 
-            bp, err = influx.NewBatchPoints(bpConfig)
-            if err != nil {
-                return importStats, err
-            }
+            points = points[:0]
         }
 
         newPriceRecordsAdded := false
 
-        // MTGO
-        newPriceRecords, err := maybeAddPoints(
-            bp,
-            card,
-            cardPrices.MTGO,
-            "mtgo",
-            lastImportTime)
-        if err != nil {
-            return importStats, err
-        }
-        if newPriceRecords > 0 {
-            newPriceRecordsAdded = true
-        }
-        importStats.AddToMTGOPriceRecords(newPriceRecords)
-
-        // MTGO Foil
-        newPriceRecords, err = maybeAddPoints(
-            bp,
-            card,
-            cardPrices.MTGOFoil,
-            "mtgo_foil",
-            lastImportTime)
-        if err != nil {
-            return importStats, err
-        }
-        if newPriceRecords > 0 {
-            newPriceRecordsAdded = true
-        }
-        importStats.AddToMTGOFoilPriceRecords(newPriceRecords)
-
-        // Paper
-        newPriceRecords, err = maybeAddPoints(
-            bp,
-            card,
-            cardPrices.Paper,
-            "paper",
-            lastImportTime)
-        if err != nil {
-            return importStats, err
-        }
-        if newPriceRecords > 0 {
-            newPriceRecordsAdded = true
-        }
-        importStats.AddToPaperPriceRecords(newPriceRecords)
-
-        // Paper Foil
-        newPriceRecords, err = maybeAddPoints(
-            bp,
-            card,
-            cardPrices.PaperFoil,
-            "paper_foil",
-            lastImportTime)
-        if err != nil {
-            return importStats, err
-        }
-        if newPriceRecords > 0 {
-            newPriceRecordsAdded = true
+        for vendorIdx := range cardPrices.Vendors {
+            vendorPrices := &cardPrices.Vendors[vendorIdx]
+            for _, series := range vendorPriceSeries(vendorPrices) {
+                var newPriceRecords int
+                var err error
+                points, newPriceRecords, err = maybeAddPoints(
+                    points,
+                    card,
+                    vendorPrices,
+                    series,
+                    lastImportTime)
+                if err != nil {
+                    return importStats, err
+                }
+                if newPriceRecords > 0 {
+                    newPriceRecordsAdded = true
+                }
+                addSeriesPriceRecords(&importStats, vendorPrices.Platform, series, newPriceRecords)
+            }
         }
-        importStats.AddToPaperFoilPriceRecords(newPriceRecords)
 
         if newPriceRecordsAdded {
             importStats.AddToTotalCardRecords(1)
@@ -113,48 +85,105 @@ func ImportPricesToDb(
     }
 
     // Flush any remaining points to the db
-    if len(bp.Points()) > 0 {
-        err = influxClient.Write(bp)
+    if len(points) > 0 {
+        err := priceStore.WritePoints(points)
         if err != nil {
             return importStats, err
         }
 
-        importStats.AddToTotalPriceRecords(len(bp.Points()))
+        importStats.AddToTotalPriceRecords(len(points))
     }
 
-    fmt.Printf("\n")
+    return importStats, ctx.Err()
+}
+
+// The price types and finishes each vendor's prices are split into, which
+// along with the vendor and its currency are the tags of each price series
+const (
+    PRICE_TYPE_RETAIL = "retail"
+    PRICE_TYPE_BUYLIST = "buylist"
+    FINISH_NORMAL = "normal"
+    FINISH_FOIL = "foil"
+)
+
+type priceSeries struct {
+    priceType string
+    finish string
+    records mtgcards.MTGCardPriceRecords
+}
 
-    return importStats, nil
+func vendorPriceSeries(vendorPrices *mtgcards.MTGCardVendorPrices) []priceSeries {
+    return []priceSeries{
+        {PRICE_TYPE_RETAIL, FINISH_NORMAL, vendorPrices.Retail.Normal},
+        {PRICE_TYPE_RETAIL, FINISH_FOIL, vendorPrices.Retail.Foil},
+        {PRICE_TYPE_BUYLIST, FINISH_NORMAL, vendorPrices.Buylist.Normal},
+        {PRICE_TYPE_BUYLIST, FINISH_FOIL, vendorPrices.Buylist.Foil}}
+}
+
+// PriceSeriesTags returns the tags a card's price series is stored under in
+// the price store.  The measurement is the platform ("mtgo" or "paper").
+func PriceSeriesTags(card string,
+        vendor string,
+        currency string,
+        priceType string,
+        finish string) map[string]string {
+    return map[string]string{
+        "card": card,
+        "vendor": vendor,
+        "currency": currency,
+        "price_type": priceType,
+        "finish": finish}
+}
+
+// The platform and finish counts are of retail prices from every vendor;
+// buylist prices are counted together
+func addSeriesPriceRecords(stats *PricesUpdateStats,
+        platform string,
+        series priceSeries,
+        newPriceRecords int) {
+    if series.priceType == PRICE_TYPE_BUYLIST {
+        stats.AddToBuylistPriceRecords(newPriceRecords)
+        return
+    }
+
+    foil := series.finish == FINISH_FOIL
+    switch {
+    case platform == mtgcards.PLATFORM_MTGO && !foil:
+        stats.AddToMTGOPriceRecords(newPriceRecords)
+    case platform == mtgcards.PLATFORM_MTGO && foil:
+        stats.AddToMTGOFoilPriceRecords(newPriceRecords)
+    case platform == mtgcards.PLATFORM_PAPER && !foil:
+        stats.AddToPaperPriceRecords(newPriceRecords)
+    case platform == mtgcards.PLATFORM_PAPER && foil:
+        stats.AddToPaperFoilPriceRecords(newPriceRecords)
+    }
 }
 
 func maybeAddPoints(
-        bp influx.BatchPoints,
+        points []pricestore.Point,
         card string,
-        priceRecords mtgcards.MTGCardPriceRecords,
-        measurementName string,
-        lastImportDate time.Time) (int, error) {
+        vendorPrices *mtgcards.MTGCardVendorPrices,
+        series priceSeries,
+        lastImportDate time.Time) ([]pricestore.Point, int, error) {
     // First, sort the price records
-    sort.Sort(priceRecords)
+    sort.Sort(series.records)
 
     pointsAdded := 0
-    for _, priceRecord := range priceRecords {
+    for _, priceRecord := range series.records {
         // Only import points that are after the last time we
         // imported data
         if priceRecord.Date.Before(lastImportDate) {
             continue
         }
 
-        point, err := influx.NewPoint(
-            measurementName,
-            map[string]string{"card": card},
-            map[string]interface{}{"price": priceRecord.Price},
-            priceRecord.Date)
-        if err != nil {
-            return 0, err
-        }
-        bp.AddPoint(point)
+        points = append(points, pricestore.Point{
+            Measurement: vendorPrices.Platform,
+            Tags: PriceSeriesTags(card, vendorPrices.Vendor, vendorPrices.Currency,
+                series.priceType, series.finish),
+            Fields: map[string]interface{}{"price": priceRecord.Price},
+            Time: priceRecord.Date})
         pointsAdded += 1
     }
 
-    return pointsAdded, nil
+    return points, pointsAdded, nil
 }
@@ -0,0 +1,180 @@
+package carddb
+
+import "encoding/json"
+import "html/template"
+import "io"
+import "mtgcards"
+import "sort"
+import "sync"
+import "time"
+
+// DryRunReport is everything an import would change, without it having
+// actually been changed.  Sets are compared concurrently, so it's safe to
+// add to from multiple goroutines.
+type DryRunReport struct {
+    mutex sync.Mutex
+
+    MTGJSONVersion string `json:"mtgjson_version"`
+    GeneratedAt time.Time `json:"generated_at"`
+
+    NewSets []DryRunSet `json:"new_sets"`
+    ChangedSets []DryRunSet `json:"changed_sets"`
+    NewCards []DryRunCard `json:"new_cards"`
+    ChangedCards []DryRunCardChange `json:"changed_cards"`
+}
+
+type DryRunSet struct {
+    Code string `json:"code"`
+    Name string `json:"name"`
+    Cards int `json:"cards"`
+}
+
+type DryRunCard struct {
+    SetCode string `json:"set_code"`
+    UUID string `json:"uuid"`
+    Name string `json:"name"`
+}
+
+type DryRunCardChange struct {
+    SetCode string `json:"set_code"`
+    UUID string `json:"uuid"`
+    Name string `json:"name"`
+    OldHash string `json:"old_hash"`
+    NewHash string `json:"new_hash"`
+    // Every changed field, as given by MTGCard.Diff.  Empty if the only
+    // changes are to values kept outside of all_cards, legalities and
+    // rulings.
+    Fields mtgcards.ChangeList `json:"fields"`
+}
+
+func NewDryRunReport(version mtgcards.MTGJSONVersion) *DryRunReport {
+    return &DryRunReport{
+        MTGJSONVersion: ImportRunVersion(version),
+        GeneratedAt: time.Now().UTC(),
+        NewSets: make([]DryRunSet, 0),
+        ChangedSets: make([]DryRunSet, 0),
+        NewCards: make([]DryRunCard, 0),
+        ChangedCards: make([]DryRunCardChange, 0)}
+}
+
+func (report *DryRunReport) addNewSet(set *mtgcards.MTGSet) {
+    report.mutex.Lock()
+    defer report.mutex.Unlock()
+    report.NewSets = append(report.NewSets,
+        DryRunSet{Code: set.Code, Name: set.Name, Cards: len(set.Cards)})
+}
+
+func (report *DryRunReport) addChangedSet(set *mtgcards.MTGSet) {
+    report.mutex.Lock()
+    defer report.mutex.Unlock()
+    report.ChangedSets = append(report.ChangedSets,
+        DryRunSet{Code: set.Code, Name: set.Name, Cards: len(set.Cards)})
+}
+
+func (report *DryRunReport) addNewCard(setCode string, card *mtgcards.MTGCard) {
+    report.mutex.Lock()
+    defer report.mutex.Unlock()
+    report.NewCards = append(report.NewCards,
+        DryRunCard{SetCode: setCode, UUID: card.UUID, Name: card.Name})
+}
+
+func (report *DryRunReport) addChangedCard(setCode string,
+        oldCard *mtgcards.MTGCard,
+        newCard *mtgcards.MTGCard,
+        oldHash string,
+        newHash string) {
+    fields := oldCard.Diff(newCard)
+
+    report.mutex.Lock()
+    defer report.mutex.Unlock()
+    report.ChangedCards = append(report.ChangedCards, DryRunCardChange{
+        SetCode: setCode,
+        UUID: newCard.UUID,
+        Name: newCard.Name,
+        OldHash: oldHash,
+        NewHash: newHash,
+        Fields: fields})
+}
+
+// sort puts everything in a stable order, since sets are compared in
+// whatever order the workers happen to finish them
+func (report *DryRunReport) sort() {
+    sort.Slice(report.NewSets, func(i, j int) bool {
+        return report.NewSets[i].Code < report.NewSets[j].Code
+    })
+    sort.Slice(report.ChangedSets, func(i, j int) bool {
+        return report.ChangedSets[i].Code < report.ChangedSets[j].Code
+    })
+    sort.Slice(report.NewCards, func(i, j int) bool {
+        if report.NewCards[i].SetCode != report.NewCards[j].SetCode {
+            return report.NewCards[i].SetCode < report.NewCards[j].SetCode
+        }
+        return report.NewCards[i].UUID < report.NewCards[j].UUID
+    })
+    sort.Slice(report.ChangedCards, func(i, j int) bool {
+        if report.ChangedCards[i].SetCode != report.ChangedCards[j].SetCode {
+            return report.ChangedCards[i].SetCode < report.ChangedCards[j].SetCode
+        }
+        return report.ChangedCards[i].UUID < report.ChangedCards[j].UUID
+    })
+}
+
+func (report *DryRunReport) WriteJSON(output io.Writer) error {
+    report.mutex.Lock()
+    defer report.mutex.Unlock()
+    report.sort()
+
+    encoder := json.NewEncoder(output)
+    encoder.SetIndent("", "    ")
+    return encoder.Encode(report)
+}
+
+func (report *DryRunReport) WriteHTML(output io.Writer) error {
+    report.mutex.Lock()
+    defer report.mutex.Unlock()
+    report.sort()
+
+    return dryRunReportTemplate.Execute(output, report)
+}
+
+var dryRunReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Dry run import of {{.MTGJSONVersion}}</title>
+<style>
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Dry run import of {{.MTGJSONVersion}}</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>New sets ({{len .NewSets}})</h2>
+<table>
+<tr><th>Code</th><th>Name</th><th>Cards</th></tr>
+{{range .NewSets}}<tr><td>{{.Code}}</td><td>{{.Name}}</td><td>{{.Cards}}</td></tr>
+{{end}}</table>
+
+<h2>Changed sets ({{len .ChangedSets}})</h2>
+<table>
+<tr><th>Code</th><th>Name</th><th>Cards</th></tr>
+{{range .ChangedSets}}<tr><td>{{.Code}}</td><td>{{.Name}}</td><td>{{.Cards}}</td></tr>
+{{end}}</table>
+
+<h2>New cards ({{len .NewCards}})</h2>
+<table>
+<tr><th>Set</th><th>Name</th><th>UUID</th></tr>
+{{range .NewCards}}<tr><td>{{.SetCode}}</td><td>{{.Name}}</td><td>{{.UUID}}</td></tr>
+{{end}}</table>
+
+<h2>Changed cards ({{len .ChangedCards}})</h2>
+<table>
+<tr><th>Set</th><th>Name</th><th>UUID</th><th>Changed fields (old | new)</th></tr>
+{{range .ChangedCards}}<tr><td>{{.SetCode}}</td><td>{{.Name}}</td><td>{{.UUID}}</td>
+<td>{{range .Fields}}{{.}}<br>{{else}}<i>Only fields stored outside all_cards, legalities and rulings</i>{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
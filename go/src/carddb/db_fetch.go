@@ -30,7 +30,8 @@ func NewDBFetchContext(db *sql.DB) (*DBFetchContext, error) {
         all_cards.name, sets.name, sets.keyrune_code
         FROM
         all_cards INNER JOIN sets ON all_cards.set_id = sets.set_id
-        WHERE all_cards.name RLIKE ?
+        WHERE all_cards.name REGEXP ?
+        AND all_cards.removed_at IS NULL
         ORDER BY all_cards.name ASC`)
     if err != nil {
         return nil, err
@@ -1,19 +1,27 @@
 package carddb
 
+import "context"
 import "database/sql"
 import "fmt"
 import "io"
-import "log"
 import "net/http"
 import "os"
+import "path/filepath"
 import "time"
 
+// How many images go by between progress lines, at debug level
+const IMAGE_PROGRESS_INTERVAL = 100
+
 const (
     ScryfallImageURLTemplate = "https://api.scryfall.com/cards/%s?format=image&version=png"
-    LocalDownloadLocation = "/var/card-importer/card-images/"
 )
 
-func UpdateCardImages(cardDB *sql.DB) (ImageUpdateStats, error) {
+// UpdateCardImages downloads the image for every card and token that
+// doesn't have one yet.  If ctx is cancelled, the download in progress is
+// abandoned (without marking the image as cached) and ctx.Err() is returned.
+func UpdateCardImages(ctx context.Context,
+        cardDB *sql.DB,
+        imageDir string) (ImageUpdateStats, error) {
     updateStats := ImageUpdateStats{}
 
     cardInfoQuery, err := cardDB.Prepare(`SELECT
@@ -60,7 +68,10 @@ func UpdateCardImages(cardDB *sql.DB) (ImageUpdateStats, error) {
     }
     if len(cardsMissingImages) > 0 {
         updateStats.AddToCardsNeedingImages(len(cardsMissingImages))
-        downloadNewImages(cardsMissingImages, cardUpdateQuery, &updateStats)
+        err = downloadNewImages(ctx, cardsMissingImages, imageDir, cardUpdateQuery, &updateStats)
+        if err != nil {
+            return updateStats, err
+        }
     }
 
     // Check to see if there are any tokens missing images
@@ -71,7 +82,10 @@ func UpdateCardImages(cardDB *sql.DB) (ImageUpdateStats, error) {
     }
     if len(tokensMissingImages) > 0 {
         updateStats.AddToTokensNeedingImages(len(tokensMissingImages))
-        downloadNewImages(tokensMissingImages, tokenUpdateQuery, &updateStats)
+        err = downloadNewImages(ctx, tokensMissingImages, imageDir, tokenUpdateQuery, &updateStats)
+        if err != nil {
+            return updateStats, err
+        }
     }
 
     return updateStats, nil
@@ -102,37 +116,52 @@ func checkForNewImages(infoQuery *sql.Stmt) (map[string]string, error) {
     return missingImages, nil
 }
 
-func downloadNewImages(missingImages map[string]string,
+func downloadNewImages(ctx context.Context,
+        missingImages map[string]string,
+        imageDir string,
         updateQuery *sql.Stmt,
-        updateStats *ImageUpdateStats) {
+        updateStats *ImageUpdateStats) error {
 
     totalRecords := len(missingImages)
     currentRecord := 0
     // Try and download all of the missing images we can get from scryfall
     for uuid, scryfallId := range missingImages {
-        fmt.Printf("Processing missing image record %d of %d\r", currentRecord, totalRecords)
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+
+        if currentRecord % IMAGE_PROGRESS_INTERVAL == 0 {
+            logger.Debug("Processing missing images", "done", currentRecord, "total", totalRecords)
+        }
         currentRecord += 1
 
         startTime := time.Now()
         scryfallImageUrl := fmt.Sprintf(ScryfallImageURLTemplate, scryfallId)
-        resp, err := http.Get(scryfallImageUrl)
+        request, err := http.NewRequestWithContext(ctx, http.MethodGet, scryfallImageUrl, nil)
+        if err != nil {
+            logger.Error("Error building image request", "uuid", uuid, "err", err)
+            updateStats.AddToImagesFailedToDownload(1)
+            continue
+        }
+        resp, err := http.DefaultClient.Do(request)
         if err != nil {
-            log.Print(err)
+            logger.Warn("Error downloading image", "uuid", uuid, "url", scryfallImageUrl, "err", err)
             updateStats.AddToImagesFailedToDownload(1)
             continue
         }
 
         if resp.StatusCode != http.StatusOK {
-            log.Print(resp.Status)
+            logger.Warn("Error downloading image", "uuid", uuid, "url", scryfallImageUrl,
+                "status", resp.Status)
             resp.Body.Close()
             updateStats.AddToImagesFailedToDownload(1)
             continue
         }
 
-        localFileName := fmt.Sprintf("%s%s.png", LocalDownloadLocation, uuid)
+        localFileName := filepath.Join(imageDir, uuid + ".png")
         localFile, err := os.Create(localFileName)
         if err != nil {
-            log.Print(err)
+            logger.Error("Error creating image file", "path", localFileName, "err", err)
             resp.Body.Close()
             updateStats.AddToImagesFailedToDownload(1)
             continue
@@ -141,9 +170,11 @@ func downloadNewImages(missingImages map[string]string,
         // Copy the file from the http response to the local file
         _, err = io.Copy(localFile, resp.Body)
         if err != nil {
-            log.Print(err)
+            logger.Warn("Error saving image", "uuid", uuid, "path", localFileName, "err", err)
             resp.Body.Close()
             localFile.Close()
+            // Don't leave a partial image behind
+            os.Remove(localFileName)
             updateStats.AddToImagesFailedToDownload(1)
             continue
         }
@@ -153,7 +184,7 @@ func downloadNewImages(missingImages map[string]string,
 
         _, err = updateQuery.Exec(uuid)
         if err != nil {
-            log.Print(err)
+            logger.Error("Error recording downloaded image", "uuid", uuid, "err", err)
             continue
             updateStats.AddToImagesFailedToDownload(1)
         }
@@ -165,7 +196,7 @@ func downloadNewImages(missingImages map[string]string,
         if duration.Milliseconds() < 100 {
             waitDuration, err := time.ParseDuration(fmt.Sprintf("%dms", 100 - duration.Milliseconds()))
             if err != nil {
-                log.Print(err)
+                logger.Error("Error working out the rate limit wait", "err", err)
                 continue
             }
             time.Sleep(waitDuration)
@@ -173,4 +204,6 @@ func downloadNewImages(missingImages map[string]string,
 
         updateStats.AddToImagesDownloaded(1)
     }
+
+    return nil
 }
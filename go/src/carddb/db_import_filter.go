@@ -0,0 +1,195 @@
+package carddb
+
+import "database/sql"
+import "encoding/json"
+import "fmt"
+import "mtgcards"
+import "strings"
+import "time"
+
+// The layout of ImportFilter.ReleasedAfter and ReleasedBefore, which is
+// also how MTGJSON writes set release dates
+const IMPORT_FILTER_DATE_FORMAT = "2006-01-02"
+
+// ImportFilter limits which sets get imported.  A set is imported if it
+// passes every part of the filter that's set; the zero value imports
+// everything.  Set codes and types are compared ignoring case, and the
+// release dates are inclusive.
+type ImportFilter struct {
+    IncludeSetCodes []string `json:"include_set_codes,omitempty"`
+    ExcludeSetCodes []string `json:"exclude_set_codes,omitempty"`
+    IncludeSetTypes []string `json:"include_set_types,omitempty"`
+    ExcludeSetTypes []string `json:"exclude_set_types,omitempty"`
+    ExcludeOnlineOnly bool `json:"exclude_online_only,omitempty"`
+    ReleasedAfter string `json:"released_after,omitempty"`
+    ReleasedBefore string `json:"released_before,omitempty"`
+}
+
+func (filter *ImportFilter) IsEmpty() bool {
+    return len(filter.IncludeSetCodes) == 0 &&
+        len(filter.ExcludeSetCodes) == 0 &&
+        len(filter.IncludeSetTypes) == 0 &&
+        len(filter.ExcludeSetTypes) == 0 &&
+        !filter.ExcludeOnlineOnly &&
+        filter.ReleasedAfter == "" &&
+        filter.ReleasedBefore == ""
+}
+
+func (filter *ImportFilter) Validate() error {
+    for _, date := range []string{filter.ReleasedAfter, filter.ReleasedBefore} {
+        if date == "" {
+            continue
+        }
+        if _, err := time.Parse(IMPORT_FILTER_DATE_FORMAT, date); err != nil {
+            return fmt.Errorf("Invalid release date %q in import filter, expected YYYY-MM-DD", date)
+        }
+    }
+    if filter.ReleasedAfter != "" && filter.ReleasedBefore != "" &&
+            filter.ReleasedAfter > filter.ReleasedBefore {
+        return fmt.Errorf("Import filter release dates are the wrong way round, %s is after %s",
+            filter.ReleasedAfter, filter.ReleasedBefore)
+    }
+    return nil
+}
+
+// Matches returns whether set passes the filter
+func (filter *ImportFilter) Matches(set *mtgcards.MTGSet) bool {
+    if len(filter.IncludeSetCodes) > 0 && !containsFold(filter.IncludeSetCodes, set.Code) {
+        return false
+    }
+    if containsFold(filter.ExcludeSetCodes, set.Code) {
+        return false
+    }
+    if len(filter.IncludeSetTypes) > 0 && !containsFold(filter.IncludeSetTypes, set.Type) {
+        return false
+    }
+    if containsFold(filter.ExcludeSetTypes, set.Type) {
+        return false
+    }
+    if filter.ExcludeOnlineOnly && set.IsOnlineOnly {
+        return false
+    }
+    // Dates in the same format compare correctly as strings
+    if filter.ReleasedAfter != "" && set.ReleaseDate < filter.ReleasedAfter {
+        return false
+    }
+    if filter.ReleasedBefore != "" && set.ReleaseDate > filter.ReleasedBefore {
+        return false
+    }
+    return true
+}
+
+func (filter ImportFilter) String() string {
+    if filter.IsEmpty() {
+        return "all sets"
+    }
+
+    var parts []string
+    if len(filter.IncludeSetCodes) > 0 {
+        parts = append(parts, "sets " + strings.Join(filter.IncludeSetCodes, ","))
+    }
+    if len(filter.ExcludeSetCodes) > 0 {
+        parts = append(parts, "not sets " + strings.Join(filter.ExcludeSetCodes, ","))
+    }
+    if len(filter.IncludeSetTypes) > 0 {
+        parts = append(parts, "set types " + strings.Join(filter.IncludeSetTypes, ","))
+    }
+    if len(filter.ExcludeSetTypes) > 0 {
+        parts = append(parts, "not set types " + strings.Join(filter.ExcludeSetTypes, ","))
+    }
+    if filter.ExcludeOnlineOnly {
+        parts = append(parts, "not online only")
+    }
+    if filter.ReleasedAfter != "" {
+        parts = append(parts, "released on or after " + filter.ReleasedAfter)
+    }
+    if filter.ReleasedBefore != "" {
+        parts = append(parts, "released on or before " + filter.ReleasedBefore)
+    }
+    return strings.Join(parts, ", ")
+}
+
+func containsFold(values []string, value string) bool {
+    for _, v := range values {
+        if strings.EqualFold(v, value) {
+            return true
+        }
+    }
+    return false
+}
+
+// GetImportFilter returns the most recently saved import filter, or the
+// empty filter if none has been saved
+func GetImportFilter(db *sql.DB) (ImportFilter, error) {
+    var filter ImportFilter
+    var filterJSON string
+    err := db.QueryRow(`SELECT filter
+        FROM import_filters
+        ORDER BY import_filter_id DESC
+        LIMIT 1`).Scan(&filterJSON)
+    if err == sql.ErrNoRows {
+        return filter, nil
+    } else if err != nil {
+        return filter, err
+    }
+
+    err = json.Unmarshal([]byte(filterJSON), &filter)
+    if err != nil {
+        return filter, fmt.Errorf("Unable to read saved import filter: %s", err)
+    }
+    return filter, nil
+}
+
+// SaveImportFilter makes filter the one every import uses from now on.
+// Saving the empty filter goes back to importing everything.  Earlier
+// filters are kept, so there's a record of what was imported when.
+func SaveImportFilter(db *sql.DB, filter ImportFilter) error {
+    err := filter.Validate()
+    if err != nil {
+        return err
+    }
+
+    filterJSON, err := json.Marshal(filter)
+    if err != nil {
+        return err
+    }
+
+    _, err = db.Exec(`INSERT INTO import_filters
+        (filter, created_at)
+        VALUES
+        (?, ?)`,
+        string(filterJSON), time.Now().UTC())
+    return err
+}
+
+// ImportFilterChangedSinceLastRun returns whether a filter has been saved
+// since the last import run that completed, in which case the cards need
+// importing again even if MTGJSON hasn't changed, to pick up any sets the
+// new filter lets in
+func ImportFilterChangedSinceLastRun(db *sql.DB) (bool, error) {
+    var filterSavedAt time.Time
+    err := db.QueryRow(`SELECT created_at
+        FROM import_filters
+        ORDER BY import_filter_id DESC
+        LIMIT 1`).Scan(&filterSavedAt)
+    if err == sql.ErrNoRows {
+        return false, nil
+    } else if err != nil {
+        return false, err
+    }
+
+    var runStartedAt time.Time
+    err = db.QueryRow(`SELECT started_at
+        FROM import_runs
+        WHERE status = ?
+        ORDER BY import_run_id DESC
+        LIMIT 1`,
+        IMPORT_RUN_COMPLETED).Scan(&runStartedAt)
+    if err == sql.ErrNoRows {
+        return true, nil
+    } else if err != nil {
+        return false, err
+    }
+
+    return filterSavedAt.After(runStartedAt), nil
+}
@@ -0,0 +1,122 @@
+package carddb
+
+import "mtgcards"
+import "testing"
+import "time"
+
+func TestImportFilterMatches(t *testing.T) {
+    set := mtgcards.MTGSet{
+        Code: "ABC",
+        Type: "expansion",
+        ReleaseDate: "2020-06-01"}
+    onlineSet := set
+    onlineSet.IsOnlineOnly = true
+
+    for _, test := range []struct {
+        name string
+        filter ImportFilter
+        set mtgcards.MTGSet
+        matches bool
+    }{
+        {"empty filter", ImportFilter{}, set, true},
+        {"included code", ImportFilter{IncludeSetCodes: []string{"abc"}}, set, true},
+        {"not included code", ImportFilter{IncludeSetCodes: []string{"XYZ"}}, set, false},
+        {"excluded code", ImportFilter{ExcludeSetCodes: []string{"aBc"}}, set, false},
+        {"not excluded code", ImportFilter{ExcludeSetCodes: []string{"XYZ"}}, set, true},
+        {"included type", ImportFilter{IncludeSetTypes: []string{"Expansion"}}, set, true},
+        {"not included type", ImportFilter{IncludeSetTypes: []string{"core"}}, set, false},
+        {"excluded type", ImportFilter{ExcludeSetTypes: []string{"EXPANSION"}}, set, false},
+        {"not excluded type", ImportFilter{ExcludeSetTypes: []string{"core"}}, set, true},
+        {"online only excluded", ImportFilter{ExcludeOnlineOnly: true}, onlineSet, false},
+        {"paper with online only excluded", ImportFilter{ExcludeOnlineOnly: true}, set, true},
+        {"online only allowed", ImportFilter{}, onlineSet, true},
+        {"released on the after date", ImportFilter{ReleasedAfter: "2020-06-01"}, set, true},
+        {"released before the after date", ImportFilter{ReleasedAfter: "2020-06-02"}, set, false},
+        {"released on the before date", ImportFilter{ReleasedBefore: "2020-06-01"}, set, true},
+        {"released after the before date", ImportFilter{ReleasedBefore: "2020-05-31"}, set, false},
+        {"released on both dates", ImportFilter{ReleasedAfter: "2020-06-01", ReleasedBefore: "2020-06-01"}, set, true},
+    } {
+        if matches := test.filter.Matches(&test.set); matches != test.matches {
+            t.Errorf("%s: expected %v, got %v", test.name, test.matches, matches)
+        }
+    }
+}
+
+func TestImportFilterValidate(t *testing.T) {
+    for _, test := range []struct {
+        filter ImportFilter
+        valid bool
+    }{
+        {ImportFilter{}, true},
+        {ImportFilter{ReleasedAfter: "2020-01-01", ReleasedBefore: "2020-12-31"}, true},
+        {ImportFilter{ReleasedAfter: "2020-06-01", ReleasedBefore: "2020-06-01"}, true},
+        {ImportFilter{ReleasedAfter: "2020-12-31", ReleasedBefore: "2020-01-01"}, false},
+        {ImportFilter{ReleasedAfter: "01/06/2020"}, false},
+        {ImportFilter{ReleasedBefore: "2020-13-01"}, false},
+    } {
+        err := test.filter.Validate()
+        if test.valid && err != nil {
+            t.Errorf("Expected %+v to be valid, got %s", test.filter, err)
+        } else if !test.valid && err == nil {
+            t.Errorf("Expected %+v to be invalid", test.filter)
+        }
+    }
+}
+
+func TestImportFilterChangedSinceLastRun(t *testing.T) {
+    db, cleanup := openTestCardDB(t)
+    defer cleanup()
+
+    checkChanged := func(expected bool, when string) {
+        changed, err := ImportFilterChangedSinceLastRun(db)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if changed != expected {
+            t.Errorf("Expected the filter changed to be %v %s", expected, when)
+        }
+    }
+    // The times are set explicitly, so that nothing depends on how finely
+    // the db stores them
+    saveFilter := func(filter ImportFilter, savedAt time.Time) {
+        if err := SaveImportFilter(db, filter); err != nil {
+            t.Fatal(err)
+        }
+        _, err := db.Exec(`UPDATE import_filters
+            SET created_at = ?
+            WHERE import_filter_id = (SELECT MAX(import_filter_id) FROM import_filters)`,
+            savedAt)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    runImport := func(status string, startedAt time.Time) {
+        run, err := StartImportRun(db, mtgcards.MTGJSONVersion{})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err = run.Finish(db, status); err != nil {
+            t.Fatal(err)
+        }
+        _, err = db.Exec(`UPDATE import_runs
+            SET started_at = ?
+            WHERE import_run_id = ?`,
+            startedAt, run.Id())
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+    checkChanged(false, "with no filter saved")
+
+    saveFilter(ImportFilter{IncludeSetCodes: []string{"ABC"}}, start)
+    checkChanged(true, "with no run since it was saved")
+
+    runImport(IMPORT_RUN_COMPLETED, start.Add(time.Hour))
+    checkChanged(false, "after a completed run")
+
+    saveFilter(ImportFilter{}, start.Add(2 * time.Hour))
+    runImport(IMPORT_RUN_FAILED, start.Add(3 * time.Hour))
+    checkChanged(true, "when only a failed run has started since it was saved")
+}
@@ -0,0 +1,129 @@
+package carddb
+
+import "sync"
+import "time"
+
+// How often ImportSetsToDB logs how far through the import it is
+const PROGRESS_REPORT_INTERVAL = 30 * time.Second
+
+// ImportProgress tracks how far through an import run is, so that it can be
+// reported while the run is still going.  Sets are imported while
+// AllPrintings is still being read, so the total number of sets isn't known
+// until the reader has finished; until then it's the number read so far.
+type ImportProgress struct {
+    mutex sync.RWMutex
+
+    stage string
+    stageStart time.Time
+
+    setsRead int
+    setsDone int
+    setsFailed int
+    readingFinished bool
+    cardsDone int
+}
+
+// ImportProgressSnapshot is a copy of an ImportProgress at a single point
+// in time
+type ImportProgressSnapshot struct {
+    Stage string
+    SetsRead int
+    SetsDone int
+    SetsFailed int
+    SetsTotalKnown bool
+    CardsDone int
+    CardsPerSecond float64
+    Elapsed time.Duration
+}
+
+func NewImportProgress() *ImportProgress {
+    progress := &ImportProgress{}
+    progress.StartStage("starting")
+    return progress
+}
+
+// StartStage moves on to the next stage of the run (e.g. "importing cards"),
+// resetting the counts and the rate
+func (progress *ImportProgress) StartStage(stage string) {
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    progress.stage = stage
+    progress.stageStart = time.Now()
+    progress.setsRead = 0
+    progress.setsDone = 0
+    progress.setsFailed = 0
+    progress.readingFinished = false
+    progress.cardsDone = 0
+}
+
+func (progress *ImportProgress) setRead() {
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    progress.setsRead += 1
+}
+
+func (progress *ImportProgress) finishedReading() {
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    progress.readingFinished = true
+}
+
+func (progress *ImportProgress) setDone(cards int, failed bool) {
+    progress.mutex.Lock()
+    defer progress.mutex.Unlock()
+    progress.setsDone += 1
+    progress.cardsDone += cards
+    if failed {
+        progress.setsFailed += 1
+    }
+}
+
+func (progress *ImportProgress) Snapshot() ImportProgressSnapshot {
+    progress.mutex.RLock()
+    defer progress.mutex.RUnlock()
+
+    elapsed := time.Since(progress.stageStart)
+    cardsPerSecond := 0.0
+    if elapsed > 0 {
+        cardsPerSecond = float64(progress.cardsDone) / elapsed.Seconds()
+    }
+
+    return ImportProgressSnapshot{
+        Stage: progress.stage,
+        SetsRead: progress.setsRead,
+        SetsDone: progress.setsDone,
+        SetsFailed: progress.setsFailed,
+        SetsTotalKnown: progress.readingFinished,
+        CardsDone: progress.cardsDone,
+        CardsPerSecond: cardsPerSecond,
+        Elapsed: elapsed}
+}
+
+func (progress *ImportProgress) Report() {
+    snapshot := progress.Snapshot()
+
+    logger.Info("Import progress",
+        "stage", snapshot.Stage,
+        "sets_done", snapshot.SetsDone,
+        "sets_read", snapshot.SetsRead,
+        "sets_total_known", snapshot.SetsTotalKnown,
+        "sets_failed", snapshot.SetsFailed,
+        "cards_done", snapshot.CardsDone,
+        "cards_per_second", snapshot.CardsPerSecond)
+}
+
+// reportPeriodically logs the progress every PROGRESS_REPORT_INTERVAL until
+// done is closed
+func (progress *ImportProgress) reportPeriodically(done <-chan interface{}) {
+    ticker := time.NewTicker(PROGRESS_REPORT_INTERVAL)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-done:
+            return
+        case <-ticker.C:
+            progress.Report()
+        }
+    }
+}
@@ -0,0 +1,148 @@
+package carddb
+
+import "database/sql"
+import "fmt"
+import "mtgcards"
+import "sync"
+import "time"
+
+const (
+    IMPORT_RUN_RUNNING = "running"
+    IMPORT_RUN_COMPLETED = "completed"
+    IMPORT_RUN_INTERRUPTED = "interrupted"
+    IMPORT_RUN_FAILED = "failed"
+    // A run that got through the whole source, but with sets that failed to
+    // import.  It isn't complete, so the next run for the same version
+    // resumes from its checkpoints and only tries the failed sets again.
+    IMPORT_RUN_PARTIAL = "partial"
+    // A run that was still marked as running when the next one started,
+    // i.e. the importer died without getting the chance to finish it
+    IMPORT_RUN_ABANDONED = "abandoned"
+)
+
+// ImportRun is a single attempt at importing the cards for an MTGJSON
+// version, recorded in the import_runs table.  Each set is checkpointed once
+// it's been fully committed, so that if the run doesn't complete, the next
+// run for the same version can skip straight past the sets that are done.
+type ImportRun struct {
+    id int64
+    version string
+
+    mutex sync.Mutex
+    // Sets checkpointed by earlier runs for the same version
+    previouslyCompleted map[string]bool
+}
+
+// ImportRunVersion is how a version is identified in import_runs, in the
+// same <major>.<minor>.<patch>+<date> format MTGJSON uses, but with the
+// cards' build date, since that's what decides whether an update is needed
+func ImportRunVersion(version mtgcards.MTGJSONVersion) string {
+    return fmt.Sprintf("%d.%d.%d+%s", version.VersionMajor, version.VersionMinor,
+        version.VersionPatch, version.BuildDate.Format("20060102"))
+}
+
+// StartImportRun records the start of a new run for version, picking up the
+// checkpoints from any earlier runs of the same version that didn't finish.
+// Once a run of the version has completed, the checkpoints from before it
+// are done with, so a forced run of the same version imports everything
+// again.
+func StartImportRun(db *sql.DB, version mtgcards.MTGJSONVersion) (*ImportRun, error) {
+    run := ImportRun{
+        version: ImportRunVersion(version),
+        previouslyCompleted: make(map[string]bool)}
+
+    // Only one importer runs at a time, so anything still marked as running
+    // can't actually be
+    _, err := db.Exec(`UPDATE import_runs
+        SET status = ?
+        WHERE status = ?`,
+        IMPORT_RUN_ABANDONED, IMPORT_RUN_RUNNING)
+    if err != nil {
+        return nil, err
+    }
+
+    rows, err := db.Query(`SELECT DISTINCT import_run_checkpoints.set_code
+        FROM import_run_checkpoints
+        JOIN import_runs ON import_runs.import_run_id = import_run_checkpoints.import_run_id
+        WHERE import_runs.mtgjson_version = ?
+        AND import_runs.import_run_id > (
+            SELECT COALESCE(MAX(completed_runs.import_run_id), 0)
+            FROM import_runs AS completed_runs
+            WHERE completed_runs.mtgjson_version = ?
+            AND completed_runs.status = ?)`,
+        run.version, run.version, IMPORT_RUN_COMPLETED)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var setCode string
+        err = rows.Scan(&setCode)
+        if err != nil {
+            return nil, err
+        }
+        run.previouslyCompleted[setCode] = true
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    res, err := db.Exec(`INSERT INTO import_runs
+        (mtgjson_version, status, started_at)
+        VALUES
+        (?, ?, ?)`,
+        run.version, IMPORT_RUN_RUNNING, time.Now().UTC())
+    if err != nil {
+        return nil, err
+    }
+    run.id, err = res.LastInsertId()
+    if err != nil {
+        return nil, err
+    }
+
+    return &run, nil
+}
+
+func (run *ImportRun) Id() int64 {
+    return run.id
+}
+
+func (run *ImportRun) Version() string {
+    return run.version
+}
+
+// ResumedSets is how many sets were already checkpointed by earlier runs
+func (run *ImportRun) ResumedSets() int {
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    return len(run.previouslyCompleted)
+}
+
+func (run *ImportRun) alreadyImported(setCode string) bool {
+    run.mutex.Lock()
+    defer run.mutex.Unlock()
+    return run.previouslyCompleted[setCode]
+}
+
+// checkpoint records that setCode has been fully committed.  Sets are
+// imported concurrently, so this is safe to call from multiple goroutines.
+func (run *ImportRun) checkpoint(db *sql.DB, setCode string) error {
+    _, err := db.Exec(`INSERT INTO import_run_checkpoints
+        (import_run_id, set_code, completed_at)
+        VALUES
+        (?, ?, ?)`,
+        run.id, setCode, time.Now().UTC())
+    return err
+}
+
+// Finish records how the run ended, which should be one of
+// IMPORT_RUN_COMPLETED, IMPORT_RUN_PARTIAL, IMPORT_RUN_INTERRUPTED or
+// IMPORT_RUN_FAILED
+func (run *ImportRun) Finish(db *sql.DB, status string) error {
+    _, err := db.Exec(`UPDATE import_runs
+        SET status = ?, finished_at = ?
+        WHERE import_run_id = ?`,
+        status, time.Now().UTC(), run.id)
+    return err
+}
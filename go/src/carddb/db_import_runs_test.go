@@ -0,0 +1,74 @@
+package carddb
+
+import "context"
+import "database/sql"
+import "mtgcards"
+import "testing"
+import "time"
+
+func importTestSetsInRun(t *testing.T, db *sql.DB, run *ImportRun, sets ...mtgcards.MTGSet) *CardUpdateStats {
+    setChan := make(chan mtgcards.MTGSet, len(sets))
+    for _, set := range sets {
+        setChan <- set
+    }
+    close(setChan)
+
+    stats, err := ImportSetsToDB(context.Background(), db, setChan, ImportOptions{
+        Workers: 1,
+        Run: run})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return stats
+}
+
+func TestImportRunResumesOnlyUnfinishedRuns(t *testing.T) {
+    db, cleanup := openTestCardDB(t)
+    defer cleanup()
+
+    version := mtgcards.MTGJSONVersion{
+        BuildDate: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+        VersionMajor: 5,
+        VersionMinor: 1}
+
+    // An interrupted run's checkpoints are picked up by the next run
+    run, err := StartImportRun(db, version)
+    if err != nil {
+        t.Fatal(err)
+    }
+    importTestSetsInRun(t, db, run, testSet("AAA", 3))
+    if err = run.Finish(db, IMPORT_RUN_INTERRUPTED); err != nil {
+        t.Fatal(err)
+    }
+
+    run, err = StartImportRun(db, version)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if run.ResumedSets() != 1 {
+        t.Errorf("Expected to resume 1 set, got %d", run.ResumedSets())
+    }
+    stats := importTestSetsInRun(t, db, run, testSet("AAA", 3), testSet("BBB", 3))
+    if stats.CheckpointedSetsSkipped() != 1 || stats.TotalNewSets() != 1 {
+        t.Errorf("Expected 1 set skipped and 1 new, got %d and %d",
+            stats.CheckpointedSetsSkipped(), stats.TotalNewSets())
+    }
+    if err = run.Finish(db, IMPORT_RUN_COMPLETED); err != nil {
+        t.Fatal(err)
+    }
+
+    // Once the version has been completely imported, forcing another run of
+    // it has to look at every set again
+    run, err = StartImportRun(db, version)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if run.ResumedSets() != 0 {
+        t.Errorf("Expected a forced run to resume nothing, got %d sets", run.ResumedSets())
+    }
+    stats = importTestSetsInRun(t, db, run, testSet("AAA", 3), testSet("BBB", 3))
+    if stats.CheckpointedSetsSkipped() != 0 || stats.TotalExistingSets() != 2 {
+        t.Errorf("Expected both sets to be processed again, %d were skipped and %d processed",
+            stats.CheckpointedSetsSkipped(), stats.TotalExistingSets())
+    }
+}
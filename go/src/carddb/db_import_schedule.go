@@ -0,0 +1,63 @@
+package carddb
+
+import "database/sql"
+import "time"
+
+// ScheduledJob is when one of the importer's scheduled jobs runs next, kept
+// in the db so that the schedule carries on where it left off when the
+// importer is restarted.  Schedule is the cron expression NextRunAt was
+// worked out from, so that a changed schedule can be told apart from one
+// that's just due.
+type ScheduledJob struct {
+    Job string
+    Schedule string
+    NextRunAt time.Time
+}
+
+// GetScheduledJobs returns every job's saved next run, keyed by job
+func GetScheduledJobs(db *sql.DB) (map[string]ScheduledJob, error) {
+    res, err := db.Query(`SELECT job, schedule, next_run_at FROM import_schedule`)
+    if err != nil {
+        return nil, err
+    }
+    defer res.Close()
+
+    jobs := make(map[string]ScheduledJob)
+    for res.Next() {
+        var job ScheduledJob
+        err = res.Scan(&job.Job, &job.Schedule, &job.NextRunAt)
+        if err != nil {
+            return nil, err
+        }
+        jobs[job.Job] = job
+    }
+    if err = res.Err(); err != nil {
+        return nil, err
+    }
+
+    return jobs, nil
+}
+
+// SaveScheduledJob records when job next runs, replacing what was saved for
+// it before
+func SaveScheduledJob(db *sql.DB, job ScheduledJob) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+
+    _, err = tx.Exec(`DELETE FROM import_schedule WHERE job = ?`, job.Job)
+    if err == nil {
+        _, err = tx.Exec(`INSERT INTO import_schedule
+            (job, schedule, next_run_at, updated_at)
+            VALUES
+            (?, ?, ?, ?)`,
+            job.Job, job.Schedule, job.NextRunAt.UTC(), time.Now().UTC())
+    }
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    return tx.Commit()
+}
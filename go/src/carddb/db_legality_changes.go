@@ -0,0 +1,93 @@
+package carddb
+
+import "database/sql"
+import "mtgcards"
+import "sort"
+import "time"
+
+// LegalityTransition is a card's legality in a game format changing between
+// imports, e.g. from "Legal" to "Banned".  A card that wasn't listed for the
+// format before or after has an empty legality on that side.
+type LegalityTransition struct {
+    GameFormat string
+    OldLegality string
+    NewLegality string
+}
+
+// legalityTransitions returns every format whose legality differs between
+// oldLegalities and newLegalities, sorted by format
+func legalityTransitions(
+        oldLegalities map[string]string,
+        newLegalities map[string]string) []LegalityTransition {
+    transitions := make([]LegalityTransition, 0)
+    for gameFormat, oldLegality := range oldLegalities {
+        if newLegality := newLegalities[gameFormat]; newLegality != oldLegality {
+            transitions = append(transitions, LegalityTransition{
+                GameFormat: gameFormat,
+                OldLegality: oldLegality,
+                NewLegality: newLegality})
+        }
+    }
+    for gameFormat, newLegality := range newLegalities {
+        if _, inOld := oldLegalities[gameFormat]; !inOld {
+            transitions = append(transitions, LegalityTransition{
+                GameFormat: gameFormat,
+                NewLegality: newLegality})
+        }
+    }
+
+    sort.Slice(transitions, func(i, j int) bool {
+        return transitions[i].GameFormat < transitions[j].GameFormat
+    })
+    return transitions
+}
+
+// recordLegalityChanges stores every change between oldLegalities and the
+// card's current legalities in legality_changes, for the per format change
+// feed.  version is the MTGJSON version being imported, or empty if the
+// import isn't part of a run.
+func recordLegalityChanges(
+        cardId int64,
+        card *mtgcards.MTGCard,
+        version string,
+        oldLegalities map[string]string,
+        queries *DBInsertQueries) error {
+    var mtgjsonVersion sql.NullString
+    if len(version) > 0 {
+        mtgjsonVersion.String = version
+        mtgjsonVersion.Valid = true
+    }
+    changedAt := time.Now().UTC()
+
+    for _, transition := range legalityTransitions(oldLegalities, card.Legalities) {
+        logger.Info("Card legality changed", "card", card.Name, "uuid", card.UUID,
+            "format", transition.GameFormat, "old", transition.OldLegality,
+            "new", transition.NewLegality)
+
+        var oldLegality sql.NullString
+        if len(transition.OldLegality) > 0 {
+            oldLegality.String = transition.OldLegality
+            oldLegality.Valid = true
+        }
+
+        var newLegality sql.NullString
+        if len(transition.NewLegality) > 0 {
+            newLegality.String = transition.NewLegality
+            newLegality.Valid = true
+        }
+
+        _, err := queries.InsertLegalityChangeQuery.Exec(
+            cardId,
+            card.UUID,
+            transition.GameFormat,
+            oldLegality,
+            newLegality,
+            mtgjsonVersion,
+            changedAt)
+        if err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
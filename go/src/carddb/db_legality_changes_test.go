@@ -0,0 +1,50 @@
+package carddb
+
+import "reflect"
+import "testing"
+
+func TestLegalityTransitions(t *testing.T) {
+    for _, test := range []struct {
+        name string
+        oldLegalities map[string]string
+        newLegalities map[string]string
+        expected []LegalityTransition
+    }{
+        {
+            name: "unchanged",
+            oldLegalities: map[string]string{"modern": "Legal"},
+            newLegalities: map[string]string{"modern": "Legal"},
+            expected: []LegalityTransition{}},
+        {
+            name: "format added",
+            oldLegalities: map[string]string{"modern": "Legal"},
+            newLegalities: map[string]string{"modern": "Legal", "pioneer": "Legal"},
+            expected: []LegalityTransition{
+                {GameFormat: "pioneer", NewLegality: "Legal"}}},
+        {
+            name: "format removed",
+            oldLegalities: map[string]string{"modern": "Legal", "pioneer": "Legal"},
+            newLegalities: map[string]string{"modern": "Legal"},
+            expected: []LegalityTransition{
+                {GameFormat: "pioneer", OldLegality: "Legal"}}},
+        {
+            name: "banned",
+            oldLegalities: map[string]string{"modern": "Legal", "legacy": "Legal"},
+            newLegalities: map[string]string{"modern": "Banned", "legacy": "Legal"},
+            expected: []LegalityTransition{
+                {GameFormat: "modern", OldLegality: "Legal", NewLegality: "Banned"}}},
+        {
+            name: "sorted by format",
+            oldLegalities: map[string]string{"vintage": "Legal", "legacy": "Legal"},
+            newLegalities: map[string]string{"vintage": "Restricted", "commander": "Legal"},
+            expected: []LegalityTransition{
+                {GameFormat: "commander", NewLegality: "Legal"},
+                {GameFormat: "legacy", OldLegality: "Legal"},
+                {GameFormat: "vintage", OldLegality: "Legal", NewLegality: "Restricted"}}},
+    } {
+        transitions := legalityTransitions(test.oldLegalities, test.newLegalities)
+        if !reflect.DeepEqual(transitions, test.expected) {
+            t.Errorf("%s: expected %v, got %v", test.name, test.expected, transitions)
+        }
+    }
+}
@@ -2,6 +2,7 @@ package carddb
 
 import "context"
 import "database/sql"
+import "mtgcards"
 import "sync"
 
 var gameFormatsCache = map[string]int64{}
@@ -359,3 +360,107 @@ func getOptionIdFromOption(optionValue string, optionsCache map[string]int64,
 
 	return optionId, nil
 }
+
+// registerSetOptions makes sure every option value used by a set is already
+// in the option tables (and caches) before any of the set's transactions are
+// opened.  New options are inserted on their own connection, and SQLite only
+// allows one writer at a time, so adding them from inside a card transaction
+// would leave the two connections waiting on each other.
+func registerSetOptions(set *mtgcards.MTGSet) error {
+	for lang, _ := range set.Translations {
+		_, err := getSetTranslationLanguageId(lang)
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx := range set.Cards {
+		card := &set.Cards[idx]
+
+		err := registerTypeOptions(card.Types, card.Subtypes, card.Supertypes)
+		if err != nil {
+			return err
+		}
+
+		for _, frameEffect := range card.FrameEffects {
+			_, err = getFrameEffectId(frameEffect)
+			if err != nil {
+				return err
+			}
+		}
+
+		for leadershipFormat, _ := range card.LeadershipSkills {
+			_, err = getLeadershipFormatId(leadershipFormat)
+			if err != nil {
+				return err
+			}
+		}
+
+		for format, legality := range card.Legalities {
+			_, err = getGameFormatId(format)
+			if err != nil {
+				return err
+			}
+			_, err = getLegalityOptionId(legality)
+			if err != nil {
+				return err
+			}
+		}
+
+		for site, _ := range card.PurchaseURLs {
+			_, err = getPurchaseSiteId(site)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for idx := range set.Tokens {
+		token := &set.Tokens[idx]
+
+		err := registerTypeOptions(token.Types, token.Subtypes, token.Supertypes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func registerTypeOptions(types []string, subtypes []string, supertypes []string) error {
+	for _, subtype := range subtypes {
+		_, err := getSubtypeOptionId(subtype)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, supertype := range supertypes {
+		_, err := getSupertypeOptionId(supertype)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Base types are whatever's left of the types once the sub and super
+	// types are taken out, the same as when the card itself is inserted
+	for _, cardType := range types {
+		if !containsString(subtypes, cardType) && !containsString(supertypes, cardType) {
+			_, err := getBaseTypeOptionId(cardType)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
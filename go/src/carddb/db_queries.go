@@ -1,11 +1,15 @@
 package carddb
 
 import "database/sql"
+import "dbconn"
 
 type DBGetQueries struct {
     GetSetHashQuery *sql.Stmt
     GetCardHashQuery *sql.Stmt
     GetTokenHashQuery *sql.Stmt
+    GetCardQuery *sql.Stmt
+    GetCardLegalitiesQuery *sql.Stmt
+    GetCardRulingsQuery *sql.Stmt
 }
 
 type DBInsertQueries struct {
@@ -29,6 +33,8 @@ type DBInsertQueries struct {
     InsertTokenSubtypeQuery *sql.Stmt
     InsertTokenSupertypeQuery *sql.Stmt
     InsertTokenReverseRelatedQuery *sql.Stmt
+    InsertCardChangeQuery *sql.Stmt
+    InsertLegalityChangeQuery *sql.Stmt
 }
 
 type DBUpdateQueries struct {
@@ -63,6 +69,9 @@ func (queries *DBGetQueries) ForTx(tx *sql.Tx) *DBGetQueries {
     txQueries.GetSetHashQuery = tx.Stmt(queries.GetSetHashQuery)
     txQueries.GetCardHashQuery = tx.Stmt(queries.GetCardHashQuery)
     txQueries.GetTokenHashQuery = tx.Stmt(queries.GetTokenHashQuery)
+    txQueries.GetCardQuery = tx.Stmt(queries.GetCardQuery)
+    txQueries.GetCardLegalitiesQuery = tx.Stmt(queries.GetCardLegalitiesQuery)
+    txQueries.GetCardRulingsQuery = tx.Stmt(queries.GetCardRulingsQuery)
 
     return &txQueries
 }
@@ -90,6 +99,8 @@ func (queries *DBInsertQueries) ForTx(tx *sql.Tx) *DBInsertQueries {
     txQueries.InsertTokenSubtypeQuery = tx.Stmt(queries.InsertTokenSubtypeQuery)
     txQueries.InsertTokenSupertypeQuery = tx.Stmt(queries.InsertTokenSupertypeQuery)
     txQueries.InsertTokenReverseRelatedQuery = tx.Stmt(queries.InsertTokenReverseRelatedQuery)
+    txQueries.InsertCardChangeQuery = tx.Stmt(queries.InsertCardChangeQuery)
+    txQueries.InsertLegalityChangeQuery = tx.Stmt(queries.InsertLegalityChangeQuery)
 
     return &txQueries
 }
@@ -152,6 +163,42 @@ func (queries *DBGetQueries) Prepare(db *sql.DB) error {
         return err
     }
 
+    queries.GetCardQuery, err = db.Prepare(`SELECT
+        uuid, artist, ascii_name, border_color, card_number, card_power,
+        card_type, color_identity, color_indicator, colors, converted_mana_cost,
+        duel_deck, edhrec_rank, face_converted_mana_cost, flavor_name, flavor_text,
+        frame_version, hand, has_foil, has_non_foil, is_alternative,
+        is_arena, is_buy_a_box, is_date_stamped, is_full_art, is_mtgo, is_online_only,
+        is_oversized, is_paper, is_promo, is_reprint, is_reserved, is_starter,
+        is_story_spotlight, is_textless, is_timeshifted, layout, life, loyalty,
+        mana_cost, mcm_id, mcm_meta_id, mtg_arena_id, mtgo_foil_id, mtgo_id,
+        mtgstocks_id, multiverse_id, name, original_text, original_type, rarity,
+        scryfall_id, scryfall_illustration_id, scryfall_oracle_id,
+        side, tcgplayer_product_id, text, toughness, watermark
+        FROM all_cards
+        WHERE card_id = ?`)
+    if err != nil {
+        return err
+    }
+
+    queries.GetCardLegalitiesQuery, err = db.Prepare(`SELECT
+        game_formats.game_format_name, legality_options.legality_option_name
+        FROM legalities
+        JOIN game_formats ON game_formats.game_format_id = legalities.game_format_id
+        JOIN legality_options ON legality_options.legality_option_id = legalities.legality_option_id
+        WHERE legalities.card_id = ?`)
+    if err != nil {
+        return err
+    }
+
+    queries.GetCardRulingsQuery, err = db.Prepare(`SELECT ruling_date, ruling_text
+        FROM rulings
+        WHERE card_id = ?
+        ORDER BY ruling_date, ruling_text`)
+    if err != nil {
+        return err
+    }
+
     return nil
 }
 
@@ -167,6 +214,18 @@ func (queries *DBGetQueries) Cleanup() {
     if queries.GetTokenHashQuery != nil {
         queries.GetTokenHashQuery.Close()
     }
+
+    if queries.GetCardQuery != nil {
+        queries.GetCardQuery.Close()
+    }
+
+    if queries.GetCardLegalitiesQuery != nil {
+        queries.GetCardLegalitiesQuery.Close()
+    }
+
+    if queries.GetCardRulingsQuery != nil {
+        queries.GetCardRulingsQuery.Close()
+    }
 }
 
 func (queries *DBInsertQueries) Prepare(db *sql.DB) error {
@@ -191,22 +250,8 @@ func (queries *DBInsertQueries) Prepare(db *sql.DB) error {
 		return err
 	}
 
-    queries.InsertCardQuery, err = db.Prepare(`INSERT INTO all_cards
-		(uuid, card_hash, artist, ascii_name, border_color, card_number, card_power,
-        card_type, color_identity, color_indicator, colors, converted_mana_cost,
-        duel_deck, edhrec_rank, face_converted_mana_cost, flavor_name, flavor_text,
-        frame_version, hand, has_foil, has_non_foil, is_alternative,
-        is_arena, is_buy_a_box, is_date_stamped, is_full_art, is_mtgo, is_online_only,
-        is_oversized, is_paper, is_promo, is_reprint, is_reserved, is_starter,
-        is_story_spotlight, is_textless, is_timeshifted, layout, life, loyalty,
-        mana_cost, mcm_id, mcm_meta_id, mtg_arena_id, mtgo_foil_id, mtgo_id,
-        mtgstocks_id, multiverse_id, name, original_text, original_type, rarity,
-        scryfall_id, scryfall_illustration_id, scryfall_oracle_id, set_id,
-        side, tcgplayer_product_id, text, toughness, watermark)
-		VALUES
-		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
-		?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
-        ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+    queries.InsertCardQuery, err = db.Prepare(
+        multiRowInsertSQL("all_cards", allCardsInsertColumns, 1))
 	if err != nil {
 		return err
 	}
@@ -317,7 +362,7 @@ func (queries *DBInsertQueries) Prepare(db *sql.DB) error {
         scryfall_oracle_id, set_id, side, text, toughness, watermark)
         VALUES
         (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-        ON DUPLICATE KEY UPDATE uuid=uuid`)
+        ` + dbconn.OnDuplicateIgnore(db, "uuid"))
     if err != nil {
         return err
     }
@@ -354,6 +399,22 @@ func (queries *DBInsertQueries) Prepare(db *sql.DB) error {
         return err
     }
 
+    queries.InsertCardChangeQuery, err = db.Prepare(`INSERT INTO card_changes
+        (card_id, uuid, mtgjson_version, old_hash, new_hash, changes, changed_at)
+        VALUES
+        (?, ?, ?, ?, ?, ?, ?)`)
+    if err != nil {
+        return err
+    }
+
+    queries.InsertLegalityChangeQuery, err = db.Prepare(`INSERT INTO legality_changes
+        (card_id, uuid, game_format, old_legality, new_legality, mtgjson_version, changed_at)
+        VALUES
+        (?, ?, ?, ?, ?, ?, ?)`)
+    if err != nil {
+        return err
+    }
+
     return nil
 }
 
@@ -437,6 +498,14 @@ func (queries *DBInsertQueries) Cleanup() {
     if queries.InsertTokenReverseRelatedQuery != nil {
         queries.InsertTokenReverseRelatedQuery.Close()
     }
+
+    if queries.InsertCardChangeQuery != nil {
+        queries.InsertCardChangeQuery.Close()
+    }
+
+    if queries.InsertLegalityChangeQuery != nil {
+        queries.InsertLegalityChangeQuery.Close()
+    }
 }
 
 func (queries *DBUpdateQueries) Prepare(db *sql.DB) error {
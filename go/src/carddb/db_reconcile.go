@@ -0,0 +1,356 @@
+package carddb
+
+import "database/sql"
+import "fmt"
+import "mtgcards"
+import "sync"
+import "time"
+
+// If reconciling would remove more than this fraction of the cards, tokens
+// or sets in the db, something is more likely wrong with the source than
+// MTGJSON having actually dropped that much, so nothing is removed
+const MAX_REMOVED_FRACTION = 0.1
+
+// SourceContents is every set code and card and token uuid seen in the
+// source during an import, whether or not the set actually needed importing
+type SourceContents struct {
+    mutex sync.Mutex
+    setCodes map[string]bool
+    cardUUIDs map[string]bool
+    tokenUUIDs map[string]bool
+}
+
+func NewSourceContents() *SourceContents {
+    return &SourceContents{
+        setCodes: make(map[string]bool),
+        cardUUIDs: make(map[string]bool),
+        tokenUUIDs: make(map[string]bool)}
+}
+
+func (contents *SourceContents) addSet(set *mtgcards.MTGSet) {
+    contents.mutex.Lock()
+    defer contents.mutex.Unlock()
+
+    contents.setCodes[set.Code] = true
+    for idx := range set.Cards {
+        contents.cardUUIDs[set.Cards[idx].UUID] = true
+    }
+    for idx := range set.Tokens {
+        contents.tokenUUIDs[set.Tokens[idx].UUID] = true
+    }
+}
+
+// The tables reconciliation works on, which all have a uuid, a scryfall_id
+// and the removal tombstone columns
+type reconcileTable struct {
+    name string
+    addRemoved func(*RemovalStats, int)
+    addRestored func(*RemovalStats, int)
+    addReplaced func(*RemovalStats, int)
+}
+
+var reconcileCardsTable = reconcileTable{
+    name: "all_cards",
+    addRemoved: (*RemovalStats).AddToCardsRemoved,
+    addRestored: (*RemovalStats).AddToCardsRestored,
+    addReplaced: (*RemovalStats).AddToCardsReplaced}
+
+var reconcileTokensTable = reconcileTable{
+    name: "all_tokens",
+    addRemoved: (*RemovalStats).AddToTokensRemoved,
+    addRestored: (*RemovalStats).AddToTokensRestored,
+    addReplaced: (*RemovalStats).AddToTokensReplaced}
+
+// ReconcileRemovals soft deletes every card, token and set in the db that
+// isn't in contents, by setting its removed_at tombstone.  Where a removed
+// card or token has a live replacement with the same scryfall_id (i.e. it's
+// been re-UUIDed), the replacement is recorded in replaced_by_uuid and any
+// card list entries in usersDB are moved over to it.  Anything previously
+// removed that's back in the source is restored.
+//
+// contents must cover the whole source, so this should only be called after
+// an import that read every set.  usersDB may be nil, in which case card
+// list entries are left alone.
+func ReconcileRemovals(cardDB *sql.DB,
+        usersDB *sql.DB,
+        contents *SourceContents) (RemovalStats, error) {
+    stats := RemovalStats{}
+    removedAt := time.Now().UTC()
+
+    contents.mutex.Lock()
+    defer contents.mutex.Unlock()
+
+    err := reconcileSets(cardDB, contents.setCodes, removedAt, &stats)
+    if err != nil {
+        return stats, err
+    }
+
+    err = reconcileUUIDs(cardDB, usersDB, reconcileCardsTable, contents.cardUUIDs,
+        removedAt, &stats)
+    if err != nil {
+        return stats, err
+    }
+
+    err = reconcileUUIDs(cardDB, usersDB, reconcileTokensTable, contents.tokenUUIDs,
+        removedAt, &stats)
+    if err != nil {
+        return stats, err
+    }
+
+    return stats, nil
+}
+
+// RestoreSeen clears the removed_at tombstone of every set, card and token
+// in contents that has one, without removing anything.  It's for imports
+// that only read part of the source (i.e. incremental updates), which can
+// tell what's back in the source, but not what's gone from it.
+func RestoreSeen(cardDB *sql.DB, contents *SourceContents) (RemovalStats, error) {
+    stats := RemovalStats{}
+
+    contents.mutex.Lock()
+    defer contents.mutex.Unlock()
+
+    err := restoreSeenRows(cardDB, "sets", "code", contents.setCodes,
+        (*RemovalStats).AddToSetsRestored, &stats)
+    if err != nil {
+        return stats, err
+    }
+
+    err = restoreSeenRows(cardDB, reconcileCardsTable.name, "uuid", contents.cardUUIDs,
+        reconcileCardsTable.addRestored, &stats)
+    if err != nil {
+        return stats, err
+    }
+
+    err = restoreSeenRows(cardDB, reconcileTokensTable.name, "uuid", contents.tokenUUIDs,
+        reconcileTokensTable.addRestored, &stats)
+    if err != nil {
+        return stats, err
+    }
+
+    return stats, nil
+}
+
+func restoreSeenRows(cardDB *sql.DB,
+        table string,
+        keyColumn string,
+        seen map[string]bool,
+        addRestored func(*RemovalStats, int),
+        stats *RemovalStats) error {
+    // The table and column names are constants, never from input
+    rows, err := cardDB.Query(fmt.Sprintf(`SELECT %s FROM %s WHERE removed_at IS NOT NULL`,
+        keyColumn, table))
+    if err != nil {
+        return err
+    }
+
+    toRestore := make([]string, 0)
+    for rows.Next() {
+        var key string
+        err = rows.Scan(&key)
+        if err != nil {
+            rows.Close()
+            return err
+        }
+        if seen[key] {
+            toRestore = append(toRestore, key)
+        }
+    }
+    rows.Close()
+    if err = rows.Err(); err != nil {
+        return err
+    }
+
+    // Sets don't have a replacement, only cards and tokens do
+    clearReplacement := ""
+    if table != "sets" {
+        clearReplacement = ", replaced_by_uuid = NULL"
+    }
+    for _, key := range toRestore {
+        logger.Info("Back in the source, restoring it", "table", table, keyColumn, key)
+        _, err = cardDB.Exec(fmt.Sprintf(`UPDATE %s
+            SET removed_at = NULL%s
+            WHERE %s = ?`, table, clearReplacement, keyColumn),
+            key)
+        if err != nil {
+            return err
+        }
+        addRestored(stats, 1)
+    }
+
+    return nil
+}
+
+func reconcileSets(cardDB *sql.DB,
+        seenCodes map[string]bool,
+        removedAt time.Time,
+        stats *RemovalStats) error {
+    rows, err := cardDB.Query(`SELECT code, removed_at IS NOT NULL
+        FROM sets`)
+    if err != nil {
+        return err
+    }
+
+    totalSets := 0
+    toRemove := make([]string, 0)
+    toRestore := make([]string, 0)
+    for rows.Next() {
+        var code string
+        var removed bool
+        err = rows.Scan(&code, &removed)
+        if err != nil {
+            rows.Close()
+            return err
+        }
+
+        totalSets += 1
+        if seenCodes[code] && removed {
+            toRestore = append(toRestore, code)
+        } else if !seenCodes[code] && !removed {
+            toRemove = append(toRemove, code)
+        }
+    }
+    rows.Close()
+    if err = rows.Err(); err != nil {
+        return err
+    }
+
+    err = checkRemovedFraction("sets", len(toRemove), totalSets)
+    if err != nil {
+        return err
+    }
+
+    for _, code := range toRemove {
+        logger.Info("Set is no longer in the source, marking it as removed", "set", code)
+        _, err = cardDB.Exec(`UPDATE sets SET removed_at = ? WHERE code = ?`,
+            removedAt, code)
+        if err != nil {
+            return err
+        }
+        stats.AddToSetsRemoved(1)
+    }
+
+    for _, code := range toRestore {
+        logger.Info("Set is back in the source, restoring it", "set", code)
+        _, err = cardDB.Exec(`UPDATE sets SET removed_at = NULL WHERE code = ?`, code)
+        if err != nil {
+            return err
+        }
+        stats.AddToSetsRestored(1)
+    }
+
+    return nil
+}
+
+func reconcileUUIDs(cardDB *sql.DB,
+        usersDB *sql.DB,
+        table reconcileTable,
+        seenUUIDs map[string]bool,
+        removedAt time.Time,
+        stats *RemovalStats) error {
+    // The table name comes from reconcileCardsTable or reconcileTokensTable,
+    // never from input
+    rows, err := cardDB.Query(fmt.Sprintf(`SELECT uuid, scryfall_id, removed_at IS NOT NULL
+        FROM %s`, table.name))
+    if err != nil {
+        return err
+    }
+
+    totalRows := 0
+    toRemove := make(map[string]string)
+    toRestore := make([]string, 0)
+    // Where to find each scryfall_id among the rows that are staying
+    liveByScryfallId := make(map[string]string)
+    for rows.Next() {
+        var uuid string
+        var scryfallId string
+        var removed bool
+        err = rows.Scan(&uuid, &scryfallId, &removed)
+        if err != nil {
+            rows.Close()
+            return err
+        }
+
+        totalRows += 1
+        if seenUUIDs[uuid] {
+            liveByScryfallId[scryfallId] = uuid
+            if removed {
+                toRestore = append(toRestore, uuid)
+            }
+        } else if !removed {
+            toRemove[uuid] = scryfallId
+        }
+    }
+    rows.Close()
+    if err = rows.Err(); err != nil {
+        return err
+    }
+
+    err = checkRemovedFraction(table.name, len(toRemove), totalRows)
+    if err != nil {
+        return err
+    }
+
+    for uuid, scryfallId := range toRemove {
+        var replacement sql.NullString
+        if replacementUUID, ok := liveByScryfallId[scryfallId]; ok {
+            replacement.String = replacementUUID
+            replacement.Valid = true
+        }
+
+        _, err = cardDB.Exec(fmt.Sprintf(`UPDATE %s
+            SET removed_at = ?, replaced_by_uuid = ?
+            WHERE uuid = ?`, table.name),
+            removedAt, replacement, uuid)
+        if err != nil {
+            return err
+        }
+        table.addRemoved(stats, 1)
+
+        if !replacement.Valid {
+            logger.Info("No longer in the source, marking it as removed", "table", table.name,
+                "uuid", uuid)
+            continue
+        }
+
+        logger.Info("Replaced in the source", "table", table.name, "uuid", uuid,
+            "replacement", replacement.String)
+        table.addReplaced(stats, 1)
+        if usersDB != nil {
+            res, err := usersDB.Exec(`UPDATE card_list_entries
+                SET card_uuid = ?
+                WHERE card_uuid = ?`,
+                replacement.String, uuid)
+            if err != nil {
+                return err
+            }
+            remapped, err := res.RowsAffected()
+            if err != nil {
+                return err
+            }
+            stats.AddToListEntriesRemapped(int(remapped))
+        }
+    }
+
+    for _, uuid := range toRestore {
+        logger.Info("Back in the source, restoring it", "table", table.name, "uuid", uuid)
+        _, err = cardDB.Exec(fmt.Sprintf(`UPDATE %s
+            SET removed_at = NULL, replaced_by_uuid = NULL
+            WHERE uuid = ?`, table.name),
+            uuid)
+        if err != nil {
+            return err
+        }
+        table.addRestored(stats, 1)
+    }
+
+    return nil
+}
+
+func checkRemovedFraction(what string, removed int, total int) error {
+    if total > 0 && float64(removed) / float64(total) > MAX_REMOVED_FRACTION {
+        return fmt.Errorf("Refusing to remove %d of %d %s, the source looks incomplete",
+            removed, total, what)
+    }
+    return nil
+}
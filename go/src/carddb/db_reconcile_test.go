@@ -0,0 +1,59 @@
+package carddb
+
+import "mtgcards"
+import "testing"
+import "time"
+
+func TestRestoreSeenStopsRemovedSetsLookingChanged(t *testing.T) {
+    db, cleanup := openTestCardDB(t)
+    defer cleanup()
+
+    set := testSet("AAA", 3)
+    importTestSets(t, db, false, set)
+
+    // As if a full update had found the set gone from the source
+    removedAt := time.Now().UTC()
+    if _, err := db.Exec(`UPDATE sets SET removed_at = ?`, removedAt); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := db.Exec(`UPDATE all_cards SET removed_at = ?`, removedAt); err != nil {
+        t.Fatal(err)
+    }
+
+    changed, err := ChangedSets(db, []mtgcards.MTGSet{set})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(changed) != 1 {
+        t.Fatalf("Expected the removed set to be changed, got %v", changed)
+    }
+
+    // An incremental update sees it again
+    contents := NewSourceContents()
+    contents.addSet(&set)
+    stats, err := RestoreSeen(db, contents)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if stats.SetsRestored() != 1 || stats.CardsRestored() != 3 {
+        t.Errorf("Expected 1 set and 3 cards restored, got %d and %d",
+            stats.SetsRestored(), stats.CardsRestored())
+    }
+
+    changed, err = ChangedSets(db, []mtgcards.MTGSet{set})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(changed) != 0 {
+        t.Errorf("Expected no changed sets once restored, got %v", changed)
+    }
+
+    var removedCards int
+    err = db.QueryRow(`SELECT COUNT(*) FROM all_cards WHERE removed_at IS NOT NULL`).Scan(&removedCards)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if removedCards != 0 {
+        t.Errorf("Expected every card to be restored, %d are still removed", removedCards)
+    }
+}
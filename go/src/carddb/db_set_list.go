@@ -0,0 +1,73 @@
+package carddb
+
+import "database/sql"
+import "mtgcards"
+import "sort"
+
+// The parts of a set's metadata that are in both the set list and the sets
+// table
+type setSummary struct {
+    name string
+    baseSize int
+    totalSetSize int
+    setType string
+    isPartialPreview bool
+    removed bool
+}
+
+func summarizeSet(set *mtgcards.MTGSet) setSummary {
+    return setSummary{
+        name: set.Name,
+        baseSize: set.BaseSetSize,
+        totalSetSize: set.TotalSetSize,
+        setType: set.Type,
+        isPartialPreview: set.IsPartialPreview}
+}
+
+// ChangedSets returns the codes of the sets in setList (as returned by
+// mtgcards.DownloadSetList) that need to be imported again: sets that
+// aren't in the db, or were removed from it, sets whose metadata differs
+// from the db's, and sets that are still being previewed, since their cards
+// change from day to day.
+//
+// The set list doesn't say anything about individual cards, so a change to
+// a card in an otherwise unchanged set (e.g. an errata) isn't picked up
+// here.  Those are only picked up by a full import, which the importer does
+// when a run is forced or asks for a full update.
+func ChangedSets(db *sql.DB, setList []mtgcards.MTGSet) ([]string, error) {
+    res, err := db.Query(`SELECT
+        code, name, base_size, total_set_size, set_type, is_partial_preview,
+        removed_at IS NOT NULL
+        FROM sets`)
+    if err != nil {
+        return nil, err
+    }
+    defer res.Close()
+
+    dbSets := make(map[string]setSummary)
+    for res.Next() {
+        var code string
+        var summary setSummary
+        err = res.Scan(&code, &summary.name, &summary.baseSize, &summary.totalSetSize,
+            &summary.setType, &summary.isPartialPreview, &summary.removed)
+        if err != nil {
+            return nil, err
+        }
+        dbSets[code] = summary
+    }
+    if err = res.Err(); err != nil {
+        return nil, err
+    }
+
+    changed := make([]string, 0)
+    for idx := range setList {
+        set := &setList[idx]
+        dbSummary, ok := dbSets[set.Code]
+        if !ok || dbSummary.removed || set.IsPartialPreview || dbSummary != summarizeSet(set) {
+            changed = append(changed, set.Code)
+        }
+    }
+    sort.Strings(changed)
+
+    return changed, nil
+}
@@ -1,6 +1,7 @@
 package carddb
 
-import influx "github.com/influxdata/influxdb1-client/v2"
+import "mtgcards"
+import "pricestore"
 import "sync"
 import "time"
 
@@ -11,36 +12,23 @@ type PricesUpdateStats struct {
     mtgoFoilPriceRecords int
     paperPriceRecords int
     paperFoilPriceRecords int
+    buylistPriceRecords int
 }
 
-func (stats *PricesUpdateStats) AddToDb(client influx.Client) error {
-    bpConfig := influx.BatchPointsConfig{Database: "mtg_cards"}
-    bp, err := influx.NewBatchPoints(bpConfig)
-    if err != nil {
-        return err
-    }
+func (stats *PricesUpdateStats) AddToDb(priceStore pricestore.PriceStore) error {
+    return writeStatsPoint(priceStore, "price_updates", stats.Fields())
+}
 
-    fields := map[string]interface{} {
+// Fields returns the stats keyed by the names they're stored under
+func (stats *PricesUpdateStats) Fields() map[string]interface{} {
+    return map[string]interface{} {
         "total_card_records": stats.totalCardRecords,
         "total_price_records": stats.totalPriceRecords,
         "mtgo_price_records": stats.mtgoPriceRecords,
         "mtgo_foil_price_records": stats.mtgoFoilPriceRecords,
         "paper_price_records": stats.paperPriceRecords,
-        "paper_foil_price_records": stats.paperFoilPriceRecords}
-
-    point, err := influx.NewPoint("price_updates",
-        nil,
-        fields,
-        time.Now())
-
-    bp.AddPoint(point)
-
-    err = client.Write(bp)
-    if err != nil {
-        return err
-    }
-
-    return nil
+        "paper_foil_price_records": stats.paperFoilPriceRecords,
+        "buylist_price_records": stats.buylistPriceRecords}
 }
 
 func (stats *PricesUpdateStats) AddToTotalCardRecords(delta int) {
@@ -67,6 +55,10 @@ func (stats *PricesUpdateStats) AddToPaperFoilPriceRecords(delta int) {
     stats.paperFoilPriceRecords += delta
 }
 
+func (stats *PricesUpdateStats) AddToBuylistPriceRecords(delta int) {
+    stats.buylistPriceRecords += delta
+}
+
 func (stats *PricesUpdateStats) TotalCardRecords() int {
     return stats.totalCardRecords
 }
@@ -91,6 +83,10 @@ func (stats *PricesUpdateStats) PaperFoilPriceRecords() int {
     return stats.paperFoilPriceRecords
 }
 
+func (stats *PricesUpdateStats) BuylistPriceRecords() int {
+    return stats.buylistPriceRecords
+}
+
 type CardUpdateStats struct {
 	mutex sync.RWMutex
 
@@ -130,19 +126,41 @@ type CardUpdateStats struct {
     totalExistingTokens int
     existingTokensSkipped int
     existingTokensUpdated int
+
+    // Stats about anything that failed to import.  A failed set is one that
+    // couldn't be imported at all, failed cards and tokens are counted
+    // separately since the rest of their set still gets imported.
+    failedSets int
+    failedCards int
+    failedTokens int
+    setFailures []SetFailure
+
+    // Sets skipped because an earlier, unfinished run already imported them
+    checkpointedSetsSkipped int
+
+    // Sets skipped because the import filter excludes them
+    filteredSetsSkipped int
+
+    // Whether the import was stopped before every set had been imported
+    interrupted bool
+}
+
+// SetFailure records why a set couldn't be imported
+type SetFailure struct {
+    SetCode string
+    Error string
 }
 
-func (stats *CardUpdateStats) AddToDb(client influx.Client) error {
+func (stats *CardUpdateStats) AddToDb(priceStore pricestore.PriceStore) error {
+    return writeStatsPoint(priceStore, "card_updates", stats.Fields())
+}
+
+// Fields returns the stats keyed by the names they're stored under
+func (stats *CardUpdateStats) Fields() map[string]interface{} {
     stats.mutex.RLock()
     defer stats.mutex.RUnlock()
 
-    bpConfig := influx.BatchPointsConfig{Database: "mtg_cards"}
-    bp, err := influx.NewBatchPoints(bpConfig)
-    if err != nil {
-        return err
-    }
-
-    fields := map[string]interface{} {
+    return map[string]interface{} {
         "total_sets": stats.totalSets,
         "total_new_sets": stats.totalNewSets,
         "total_existing_sets": stats.totalExistingSets,
@@ -161,21 +179,13 @@ func (stats *CardUpdateStats) AddToDb(client influx.Client) error {
         "total_new_tokens_in_existing_sets": stats.totalNewTokensInExistingSets,
         "total_existing_tokens": stats.totalExistingTokens,
         "existing_tokens_skipped": stats.existingTokensSkipped,
-        "existing_tokens_updated": stats.existingTokensUpdated}
-
-    point, err := influx.NewPoint("card_updates",
-        nil,
-        fields,
-        time.Now())
-
-    bp.AddPoint(point)
-
-    err = client.Write(bp)
-    if err != nil {
-        return err
-    }
-
-    return nil
+        "existing_tokens_updated": stats.existingTokensUpdated,
+        "failed_sets": stats.failedSets,
+        "failed_cards": stats.failedCards,
+        "failed_tokens": stats.failedTokens,
+        "checkpointed_sets_skipped": stats.checkpointedSetsSkipped,
+        "filtered_sets_skipped": stats.filteredSetsSkipped,
+        "interrupted": stats.interrupted}
 }
 
 func (stats *CardUpdateStats) AddToTotalSets(delta int) {
@@ -292,6 +302,44 @@ func (stats *CardUpdateStats) AddToExistingTokensUpdated(delta int) {
     stats.existingTokensUpdated += delta
 }
 
+func (stats *CardUpdateStats) AddSetFailure(setCode string, err error) {
+    stats.mutex.Lock()
+    defer stats.mutex.Unlock()
+    stats.failedSets += 1
+    stats.setFailures = append(stats.setFailures,
+        SetFailure{SetCode: setCode, Error: err.Error()})
+}
+
+func (stats *CardUpdateStats) AddToFailedCards(delta int) {
+    stats.mutex.Lock()
+    defer stats.mutex.Unlock()
+    stats.failedCards += delta
+}
+
+func (stats *CardUpdateStats) AddToFailedTokens(delta int) {
+    stats.mutex.Lock()
+    defer stats.mutex.Unlock()
+    stats.failedTokens += delta
+}
+
+func (stats *CardUpdateStats) AddToCheckpointedSetsSkipped(delta int) {
+    stats.mutex.Lock()
+    defer stats.mutex.Unlock()
+    stats.checkpointedSetsSkipped += delta
+}
+
+func (stats *CardUpdateStats) AddToFilteredSetsSkipped(delta int) {
+    stats.mutex.Lock()
+    defer stats.mutex.Unlock()
+    stats.filteredSetsSkipped += delta
+}
+
+func (stats *CardUpdateStats) MarkInterrupted() {
+    stats.mutex.Lock()
+    defer stats.mutex.Unlock()
+    stats.interrupted = true
+}
+
 func (stats *CardUpdateStats) TotalSets() int {
     stats.mutex.RLock()
     defer stats.mutex.RUnlock()
@@ -406,6 +454,50 @@ func (stats *CardUpdateStats) ExistingTokensUpdated() int {
     return stats.existingTokensUpdated
 }
 
+func (stats *CardUpdateStats) FailedSets() int {
+    stats.mutex.RLock()
+    defer stats.mutex.RUnlock()
+    return stats.failedSets
+}
+
+func (stats *CardUpdateStats) FailedCards() int {
+    stats.mutex.RLock()
+    defer stats.mutex.RUnlock()
+    return stats.failedCards
+}
+
+func (stats *CardUpdateStats) FailedTokens() int {
+    stats.mutex.RLock()
+    defer stats.mutex.RUnlock()
+    return stats.failedTokens
+}
+
+func (stats *CardUpdateStats) CheckpointedSetsSkipped() int {
+    stats.mutex.RLock()
+    defer stats.mutex.RUnlock()
+    return stats.checkpointedSetsSkipped
+}
+
+func (stats *CardUpdateStats) FilteredSetsSkipped() int {
+    stats.mutex.RLock()
+    defer stats.mutex.RUnlock()
+    return stats.filteredSetsSkipped
+}
+
+func (stats *CardUpdateStats) Interrupted() bool {
+    stats.mutex.RLock()
+    defer stats.mutex.RUnlock()
+    return stats.interrupted
+}
+
+// SetFailures returns a copy of every set failure, in the order they
+// happened
+func (stats *CardUpdateStats) SetFailures() []SetFailure {
+    stats.mutex.RLock()
+    defer stats.mutex.RUnlock()
+    return append([]SetFailure(nil), stats.setFailures...)
+}
+
 type ImageUpdateStats struct {
     cardsNeedingImages int
     tokensNeedingImages int
@@ -413,32 +505,17 @@ type ImageUpdateStats struct {
     imagesFailedToDownload int
 }
 
-func (stats *ImageUpdateStats) AddToDb(client influx.Client) error {
-    bpConfig := influx.BatchPointsConfig{Database: "mtg_cards"}
-    bp, err := influx.NewBatchPoints(bpConfig)
-    if err != nil {
-        return err
-    }
+func (stats *ImageUpdateStats) AddToDb(priceStore pricestore.PriceStore) error {
+    return writeStatsPoint(priceStore, "image_updates", stats.Fields())
+}
 
-    fields := map[string]interface{} {
+// Fields returns the stats keyed by the names they're stored under
+func (stats *ImageUpdateStats) Fields() map[string]interface{} {
+    return map[string]interface{} {
         "cards_needing_images": stats.cardsNeedingImages,
         "tokens_needing_images": stats.tokensNeedingImages,
         "images_downloaded": stats.imagesDownloaded,
         "images_failed_to_download": stats.imagesFailedToDownload}
-
-    point, err := influx.NewPoint("image_updates",
-        nil,
-        fields,
-        time.Now())
-
-    bp.AddPoint(point)
-
-    err = client.Write(bp)
-    if err != nil {
-        return err
-    }
-
-    return nil
 }
 
 func (stats *ImageUpdateStats) AddToCardsNeedingImages(delta int) {
@@ -473,21 +550,122 @@ func (stats *ImageUpdateStats) ImagesFailedToDownload() int {
     return stats.imagesFailedToDownload
 }
 
-func AddSingleUpdateStatsToDb(client influx.Client,
+type RemovalStats struct {
+    setsRemoved int
+    setsRestored int
+    cardsRemoved int
+    cardsRestored int
+    cardsReplaced int
+    tokensRemoved int
+    tokensRestored int
+    tokensReplaced int
+    listEntriesRemapped int
+}
+
+func (stats *RemovalStats) AddToDb(priceStore pricestore.PriceStore) error {
+    fields := map[string]interface{} {
+        "sets_removed": stats.setsRemoved,
+        "sets_restored": stats.setsRestored,
+        "cards_removed": stats.cardsRemoved,
+        "cards_restored": stats.cardsRestored,
+        "cards_replaced": stats.cardsReplaced,
+        "tokens_removed": stats.tokensRemoved,
+        "tokens_restored": stats.tokensRestored,
+        "tokens_replaced": stats.tokensReplaced,
+        "list_entries_remapped": stats.listEntriesRemapped}
+
+    return writeStatsPoint(priceStore, "removals", fields)
+}
+
+func (stats *RemovalStats) AddToSetsRemoved(delta int) {
+    stats.setsRemoved += delta
+}
+
+func (stats *RemovalStats) AddToSetsRestored(delta int) {
+    stats.setsRestored += delta
+}
+
+func (stats *RemovalStats) AddToCardsRemoved(delta int) {
+    stats.cardsRemoved += delta
+}
+
+func (stats *RemovalStats) AddToCardsRestored(delta int) {
+    stats.cardsRestored += delta
+}
+
+func (stats *RemovalStats) AddToCardsReplaced(delta int) {
+    stats.cardsReplaced += delta
+}
+
+func (stats *RemovalStats) AddToTokensRemoved(delta int) {
+    stats.tokensRemoved += delta
+}
+
+func (stats *RemovalStats) AddToTokensRestored(delta int) {
+    stats.tokensRestored += delta
+}
+
+func (stats *RemovalStats) AddToTokensReplaced(delta int) {
+    stats.tokensReplaced += delta
+}
+
+func (stats *RemovalStats) AddToListEntriesRemapped(delta int) {
+    stats.listEntriesRemapped += delta
+}
+
+func (stats *RemovalStats) SetsRemoved() int {
+    return stats.setsRemoved
+}
+
+func (stats *RemovalStats) SetsRestored() int {
+    return stats.setsRestored
+}
+
+func (stats *RemovalStats) CardsRemoved() int {
+    return stats.cardsRemoved
+}
+
+func (stats *RemovalStats) CardsRestored() int {
+    return stats.cardsRestored
+}
+
+func (stats *RemovalStats) CardsReplaced() int {
+    return stats.cardsReplaced
+}
+
+func (stats *RemovalStats) TokensRemoved() int {
+    return stats.tokensRemoved
+}
+
+func (stats *RemovalStats) TokensRestored() int {
+    return stats.tokensRestored
+}
+
+func (stats *RemovalStats) TokensReplaced() int {
+    return stats.tokensReplaced
+}
+
+func (stats *RemovalStats) ListEntriesRemapped() int {
+    return stats.listEntriesRemapped
+}
+
+func AddSingleUpdateStatsToDb(priceStore pricestore.PriceStore,
         cardsUpdated bool,
         pricesUpdated bool,
         imagesUpdated bool,
         cardUpdateDuration time.Duration,
         priceUpdateDuration time.Duration,
-        imageUpdateDuration time.Duration) error {
-
-    bpConfig := influx.BatchPointsConfig{Database: "mtg_cards"}
-    bp, err := influx.NewBatchPoints(bpConfig)
-    if err != nil {
-        return err
-    }
+        imageUpdateDuration time.Duration,
+        downloadStats mtgcards.DownloadStats,
+        interrupted bool) error {
 
     fields := map[string]interface{} {
+        "interrupted": interrupted,
+        "downloads": downloadStats.Downloads,
+        "downloads_not_modified": downloadStats.NotModified,
+        "download_retries": downloadStats.Retries,
+        "download_verification_failures": downloadStats.VerificationFailures,
+        "download_bytes": downloadStats.Bytes,
         "cards_updated": cardsUpdated,
         "prices_updated": pricesUpdated,
         "images_updated": imagesUpdated,
@@ -495,18 +673,16 @@ func AddSingleUpdateStatsToDb(client influx.Client,
         "price_update_duration": priceUpdateDuration.Seconds(),
         "image_update_duration": imageUpdateDuration.Seconds()}
 
-    point, err := influx.NewPoint("updates",
-        nil,
-        fields,
-        time.Now())
-
-    bp.AddPoint(point)
+    return writeStatsPoint(priceStore, "updates", fields)
+}
 
-    err = client.Write(bp)
-    if err != nil {
-        return err
-    }
+func writeStatsPoint(priceStore pricestore.PriceStore,
+        measurement string,
+        fields map[string]interface{}) error {
+    point := pricestore.Point{
+        Measurement: measurement,
+        Fields: fields,
+        Time: time.Now()}
 
-    return nil
+    return priceStore.WritePoints([]pricestore.Point{point})
 }
-
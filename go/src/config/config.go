@@ -0,0 +1,613 @@
+package config
+
+import "bytes"
+import "cron"
+import "encoding/json"
+import "fmt"
+import "io/ioutil"
+import "logging"
+import "net/url"
+import "os"
+import "reflect"
+import "strconv"
+import "strings"
+import "time"
+
+// Environment variable that can be used to point at a config file instead of
+// passing -config on the command line
+const CONFIG_PATH_ENV = "MTG_CONFIG"
+
+// Supported values for DatabaseConfig.Driver
+const (
+    MYSQL_DRIVER = "mysql"
+    SQLITE_DRIVER = "sqlite"
+)
+
+// Supported values for PricesDBConfig.Store
+const (
+    INFLUX_PRICE_STORE = "influx"
+    SQL_PRICE_STORE = "sql"
+)
+
+// Supported values for MTGJSONConfig.Source
+const (
+    MTGJSON_HTTP_SOURCE = "http"
+    MTGJSON_LOCAL_SOURCE = "local"
+)
+
+// Supported values for ImporterConfig.UpdateMode
+const (
+    FULL_UPDATE_MODE = "full"
+    INCREMENTAL_UPDATE_MODE = "incremental"
+)
+
+// Supported values for MTGJSONConfig.SchemaVersion
+const (
+    MTGJSON_SCHEMA_V4 = 4
+    MTGJSON_SCHEMA_V5 = 5
+)
+
+// The card and user databases can either live in MariaDB (the default), or
+// in a SQLite file for single-machine and test deployments.  Both can point
+// at the same SQLite file.
+type DatabaseConfig struct {
+    Driver string `json:"driver"`
+    Path string `json:"path"`
+    Host string `json:"host"`
+    Name string `json:"name"`
+    User string `json:"user"`
+    Password string `json:"password"`
+    PasswordFile string `json:"password_file"`
+}
+
+// Prices (and update stats) either go to InfluxDB 1.x (the default), or to
+// a table in the card database when store is "sql", in which case none of
+// the other settings are used
+type PricesDBConfig struct {
+    Store string `json:"store"`
+    Address string `json:"address"`
+    Database string `json:"database"`
+    User string `json:"user"`
+    Password string `json:"password"`
+    PasswordFile string `json:"password_file"`
+}
+
+type AuthConfig struct {
+    AdminURL string `json:"admin_url"`
+}
+
+// MTGJSON v5 moved its files (e.g. to https://mtgjson.com/api/v5/), so
+// base_url has to be changed along with schema_version.  v5 also gives every
+// card a new uuid, so a card db built from v4 files sees every card as new.
+//
+// With source "http" (the default) files are downloaded from base_url and
+// cached in data_dir.  With source "local", data_dir is a mirror of the
+// MTGJSON files that's read without touching the network, and base_url
+// isn't used.
+type MTGJSONConfig struct {
+    Source string `json:"source"`
+    BaseURL string `json:"base_url"`
+    DataDir string `json:"data_dir"`
+    SchemaVersion int `json:"schema_version"`
+}
+
+// Card data, prices and missing images are each updated on their own cron
+// schedule (cards_schedule, prices_schedule and images_schedule, e.g.
+// "0 3 * * *").  Any that aren't set run every update_interval, as all three
+// did before they could be scheduled separately.  Each scheduled run is put
+// off by a random delay of up to schedule_jitter, and a run that comes round
+// while the same job's last run is still going is skipped.
+//
+// In update_mode "full" (the default) every update reads all of
+// AllPrintings.  In "incremental", only the sets whose entries in SetList
+// have changed are downloaded, from their own files, and sets removed from
+// MTGJSON, or changes to cards in sets that haven't otherwise changed (e.g.
+// bans and errata), aren't noticed, so a full update still needs running now
+// and then, by starting a run with full_update through the control server.
+//
+// With bulk_insert_new_sets (the default), sets that aren't in the card db
+// yet are inserted with multi-row INSERTs in one transaction per set.
+//
+// If control_listen_address is set, the importer serves its status, its
+// last run_history runs, endpoints to start and cancel runs, and its
+// Prometheus metrics (on /metrics) there.  The control server has no
+// authentication, so it should only be reachable from inside the deployment.
+type ImporterConfig struct {
+    UpdateInterval string `json:"update_interval"`
+    CardsSchedule string `json:"cards_schedule"`
+    PricesSchedule string `json:"prices_schedule"`
+    ImagesSchedule string `json:"images_schedule"`
+    ScheduleJitter string `json:"schedule_jitter"`
+    UpdateMode string `json:"update_mode"`
+    CardImageDir string `json:"card_image_dir"`
+    ImportWorkers int `json:"import_workers"`
+    BulkInsertNewSets bool `json:"bulk_insert_new_sets"`
+    ControlListenAddress string `json:"control_listen_address"`
+    RunHistory int `json:"run_history"`
+}
+
+// Logs go to stderr, as format "text" (the default) or "json".  level (one
+// of "debug", "info", "warn" or "error") applies to every component that
+// isn't given its own level in components, e.g. {"carddb": "debug"}.  The
+// components are the packages and programs that log (carddb, mtgcards,
+// backend, auth, card-importer, ...).  From the environment, components is
+// given as e.g. MTG_LOGGING_COMPONENTS=carddb=debug,auth=warn.
+//
+// Passwords, tokens and other credentials are redacted by key wherever
+// they're logged.
+type LoggingConfig struct {
+    Level string `json:"level"`
+    Format string `json:"format"`
+    Components map[string]string `json:"components"`
+}
+
+type BackendConfig struct {
+    ListenAddress string `json:"listen_address"`
+}
+
+type ImageDownloaderConfig struct {
+    OutputDir string `json:"output_dir"`
+}
+
+type Config struct {
+    CardDB DatabaseConfig `json:"card_db" env:"MTG_CARD_DB"`
+    UserDB DatabaseConfig `json:"user_db" env:"MTG_USER_DB"`
+    PricesDB PricesDBConfig `json:"prices_db" env:"MTG_PRICES_DB"`
+    Auth AuthConfig `json:"auth" env:"MTG_AUTH"`
+    MTGJSON MTGJSONConfig `json:"mtgjson" env:"MTG_MTGJSON"`
+    Importer ImporterConfig `json:"importer" env:"MTG_IMPORTER"`
+    Backend BackendConfig `json:"backend" env:"MTG_BACKEND"`
+    ImageDownloader ImageDownloaderConfig `json:"image_downloader" env:"MTG_IMAGE_DOWNLOADER"`
+    Logging LoggingConfig `json:"logging" env:"MTG_LOGGING"`
+}
+
+// Defaults are the hosts and credentials of the docker-compose setup, so
+// that it keeps working without a config file.  They're the values that used
+// to be compiled in, except for card-image-downloader, which used to reach
+// the card db at 172.18.0.3 rather than card_db:3306.
+func Defaults() Config {
+    return Config{
+        CardDB: DatabaseConfig{
+            Driver: MYSQL_DRIVER,
+            Host: "card_db:3306",
+            Name: "mtg_cards",
+            User: "app_user",
+            Password: "app_db_password"},
+        UserDB: DatabaseConfig{
+            Driver: MYSQL_DRIVER,
+            Host: "card_db:3306",
+            Name: "users",
+            User: "login_user",
+            Password: "login_user_password"},
+        PricesDB: PricesDBConfig{
+            Store: INFLUX_PRICE_STORE,
+            Address: "http://card_prices_db:8086",
+            Database: "mtg_cards",
+            User: "app_user",
+            Password: "app_db_password"},
+        Auth: AuthConfig{
+            AdminURL: "http://hydra:4445"},
+        MTGJSON: MTGJSONConfig{
+            Source: MTGJSON_HTTP_SOURCE,
+            BaseURL: "https://www.mtgjson.com/files/",
+            DataDir: "/var/card-importer/card-data/",
+            SchemaVersion: MTGJSON_SCHEMA_V4},
+        Importer: ImporterConfig{
+            UpdateInterval: "12h",
+            ScheduleJitter: "0s",
+            UpdateMode: FULL_UPDATE_MODE,
+            CardImageDir: "/var/card-importer/card-images/",
+            ImportWorkers: 8,
+            BulkInsertNewSets: true,
+            RunHistory: 20},
+        Backend: BackendConfig{
+            ListenAddress: ":8085"},
+        ImageDownloader: ImageDownloaderConfig{
+            OutputDir: "../../../web_content/card_face_images/"},
+        Logging: LoggingConfig{
+            Level: "info",
+            Format: logging.FORMAT_TEXT}}
+}
+
+// Load builds the config by starting from the defaults, then applying the
+// given config file (if any), then any environment variable overrides, and
+// finally reading any secrets that were given as files.  The result is
+// validated before it's returned.
+func Load(path string) (*Config, error) {
+    config := Defaults()
+
+    if path == "" {
+        path = os.Getenv(CONFIG_PATH_ENV)
+    }
+
+    if path != "" {
+        err := config.loadFile(path)
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    err := applyEnvOverrides(reflect.ValueOf(&config).Elem(), "MTG")
+    if err != nil {
+        return nil, err
+    }
+
+    err = config.resolveSecrets()
+    if err != nil {
+        return nil, err
+    }
+
+    err = config.Validate()
+    if err != nil {
+        return nil, err
+    }
+
+    return &config, nil
+}
+
+func (config *Config) loadFile(path string) error {
+    contents, err := ioutil.ReadFile(path)
+    if err != nil {
+        return err
+    }
+
+    // Unknown keys are almost always typos, so treat them as errors rather
+    // than silently running with a default
+    decoder := json.NewDecoder(bytes.NewReader(contents))
+    decoder.DisallowUnknownFields()
+    err = decoder.Decode(config)
+    if err != nil {
+        return fmt.Errorf("Error parsing config file %s: %s", path, err)
+    }
+
+    return nil
+}
+
+// Every field can be overridden with an environment variable whose name is
+// built from the env tag of the containing section and the json name of the
+// field, e.g. card_db.password can be set with MTG_CARD_DB_PASSWORD
+func applyEnvOverrides(value reflect.Value, prefix string) error {
+    valueType := value.Type()
+
+    for i := 0; i < valueType.NumField(); i++ {
+        field := valueType.Field(i)
+        fieldValue := value.Field(i)
+
+        switch fieldValue.Kind() {
+        case reflect.Struct:
+            err := applyEnvOverrides(fieldValue, field.Tag.Get("env"))
+            if err != nil {
+                return err
+            }
+
+        case reflect.String:
+            envName := envName(prefix, field)
+            if envValue, ok := os.LookupEnv(envName); ok {
+                fieldValue.SetString(envValue)
+            }
+
+        case reflect.Int:
+            envName := envName(prefix, field)
+            if envValue, ok := os.LookupEnv(envName); ok {
+                intValue, err := strconv.Atoi(envValue)
+                if err != nil {
+                    return fmt.Errorf("Invalid value %q for %s: %s", envValue, envName, err)
+                }
+                fieldValue.SetInt(int64(intValue))
+            }
+
+        case reflect.Bool:
+            envName := envName(prefix, field)
+            if envValue, ok := os.LookupEnv(envName); ok {
+                boolValue, err := strconv.ParseBool(envValue)
+                if err != nil {
+                    return fmt.Errorf("Invalid value %q for %s: %s", envValue, envName, err)
+                }
+                fieldValue.SetBool(boolValue)
+            }
+
+        case reflect.Map:
+            envName := envName(prefix, field)
+            if envValue, ok := os.LookupEnv(envName); ok {
+                mapValue, err := parseEnvMap(envValue)
+                if err != nil {
+                    return fmt.Errorf("Invalid value %q for %s: %s", envValue, envName, err)
+                }
+                fieldValue.Set(reflect.ValueOf(mapValue))
+            }
+        }
+    }
+
+    return nil
+}
+
+// Maps are given as comma separated key=value pairs
+func parseEnvMap(envValue string) (map[string]string, error) {
+    mapValue := make(map[string]string)
+    for _, pair := range strings.Split(envValue, ",") {
+        if strings.TrimSpace(pair) == "" {
+            continue
+        }
+        key, value, found := strings.Cut(pair, "=")
+        if !found {
+            return nil, fmt.Errorf("%q isn't a key=value pair", pair)
+        }
+        mapValue[strings.TrimSpace(key)] = strings.TrimSpace(value)
+    }
+    return mapValue, nil
+}
+
+func envName(prefix string, field reflect.StructField) string {
+    jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+    return prefix + "_" + strings.ToUpper(jsonName)
+}
+
+func (config *Config) resolveSecrets() error {
+    err := readSecretFile(config.CardDB.PasswordFile, &config.CardDB.Password)
+    if err != nil {
+        return err
+    }
+
+    err = readSecretFile(config.UserDB.PasswordFile, &config.UserDB.Password)
+    if err != nil {
+        return err
+    }
+
+    err = readSecretFile(config.PricesDB.PasswordFile, &config.PricesDB.Password)
+    if err != nil {
+        return err
+    }
+
+    return nil
+}
+
+// Secret files (e.g. docker secrets) take precedence over any password
+// given directly, since they're the more deliberate of the two
+func readSecretFile(path string, secret *string) error {
+    if path == "" {
+        return nil
+    }
+
+    contents, err := ioutil.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("Error reading secret file %s: %s", path, err)
+    }
+
+    *secret = strings.TrimRight(string(contents), "\r\n")
+    return nil
+}
+
+type ValidationError struct {
+    Problems []string
+}
+
+func (err *ValidationError) Error() string {
+    return "Invalid config: " + strings.Join(err.Problems, "; ")
+}
+
+func (config *Config) Validate() error {
+    var problems []string
+
+    problems = append(problems, config.CardDB.validate("card_db")...)
+    problems = append(problems, config.UserDB.validate("user_db")...)
+    problems = append(problems, config.PricesDB.validate("prices_db")...)
+
+    problems = append(problems, validateURL("auth.admin_url", config.Auth.AdminURL)...)
+    switch config.MTGJSON.Source {
+    case MTGJSON_HTTP_SOURCE:
+        problems = append(problems, validateURL("mtgjson.base_url", config.MTGJSON.BaseURL)...)
+    case MTGJSON_LOCAL_SOURCE:
+    default:
+        problems = append(problems, fmt.Sprintf("mtgjson.source must be %q or %q, got %q",
+            MTGJSON_HTTP_SOURCE, MTGJSON_LOCAL_SOURCE, config.MTGJSON.Source))
+    }
+    if config.MTGJSON.DataDir == "" {
+        problems = append(problems, "mtgjson.data_dir must be set")
+    }
+    if config.MTGJSON.SchemaVersion != MTGJSON_SCHEMA_V4 &&
+            config.MTGJSON.SchemaVersion != MTGJSON_SCHEMA_V5 {
+        problems = append(problems, fmt.Sprintf("mtgjson.schema_version must be %d or %d, got %d",
+            MTGJSON_SCHEMA_V4, MTGJSON_SCHEMA_V5, config.MTGJSON.SchemaVersion))
+    }
+
+    updateInterval, err := time.ParseDuration(config.Importer.UpdateInterval)
+    if err != nil {
+        problems = append(problems, fmt.Sprintf("importer.update_interval: %s", err))
+    } else if updateInterval <= 0 {
+        problems = append(problems, "importer.update_interval must be positive")
+    }
+    for _, schedule := range []struct {
+        name string
+        expr string
+    }{
+        {"importer.cards_schedule", config.Importer.CardsSchedule},
+        {"importer.prices_schedule", config.Importer.PricesSchedule},
+        {"importer.images_schedule", config.Importer.ImagesSchedule}} {
+        if schedule.expr == "" {
+            continue
+        }
+        if _, err := cron.Parse(schedule.expr); err != nil {
+            problems = append(problems, fmt.Sprintf("%s: %s", schedule.name, err))
+        }
+    }
+    scheduleJitter, err := time.ParseDuration(config.Importer.ScheduleJitter)
+    if err != nil {
+        problems = append(problems, fmt.Sprintf("importer.schedule_jitter: %s", err))
+    } else if scheduleJitter < 0 {
+        problems = append(problems, "importer.schedule_jitter can't be negative")
+    }
+    if config.Importer.UpdateMode != FULL_UPDATE_MODE &&
+            config.Importer.UpdateMode != INCREMENTAL_UPDATE_MODE {
+        problems = append(problems, fmt.Sprintf("importer.update_mode must be %q or %q, got %q",
+            FULL_UPDATE_MODE, INCREMENTAL_UPDATE_MODE, config.Importer.UpdateMode))
+    }
+    if config.Importer.CardImageDir == "" {
+        problems = append(problems, "importer.card_image_dir must be set")
+    }
+    if config.Importer.ImportWorkers < 1 {
+        problems = append(problems, "importer.import_workers must be at least 1")
+    }
+    if config.Importer.RunHistory < 1 {
+        problems = append(problems, "importer.run_history must be at least 1")
+    }
+
+    if config.Backend.ListenAddress == "" {
+        problems = append(problems, "backend.listen_address must be set")
+    }
+
+    if config.ImageDownloader.OutputDir == "" {
+        problems = append(problems, "image_downloader.output_dir must be set")
+    }
+
+    if config.Logging.Format != logging.FORMAT_TEXT && config.Logging.Format != logging.FORMAT_JSON {
+        problems = append(problems, fmt.Sprintf("logging.format must be %q or %q, got %q",
+            logging.FORMAT_TEXT, logging.FORMAT_JSON, config.Logging.Format))
+    }
+    if _, err := logging.ParseLevel(config.Logging.Level); err != nil {
+        problems = append(problems, fmt.Sprintf("logging.level: %s", err))
+    }
+    for component, level := range config.Logging.Components {
+        if _, err := logging.ParseLevel(level); err != nil {
+            problems = append(problems, fmt.Sprintf("logging.components.%s: %s", component, err))
+        }
+    }
+
+    if len(problems) > 0 {
+        return &ValidationError{Problems: problems}
+    }
+
+    return nil
+}
+
+func (dbConfig *DatabaseConfig) validate(section string) []string {
+    var problems []string
+
+    switch dbConfig.Driver {
+    case MYSQL_DRIVER:
+    case SQLITE_DRIVER:
+        if dbConfig.Path == "" {
+            problems = append(problems, section + ".path must be set for sqlite")
+        }
+        // None of the server settings apply to a SQLite file
+        return problems
+    default:
+        problems = append(problems, fmt.Sprintf("%s.driver must be %q or %q, got %q",
+            section, MYSQL_DRIVER, SQLITE_DRIVER, dbConfig.Driver))
+        return problems
+    }
+
+    if dbConfig.Host == "" {
+        problems = append(problems, section + ".host must be set")
+    }
+    if dbConfig.Name == "" {
+        problems = append(problems, section + ".name must be set")
+    }
+    if dbConfig.User == "" {
+        problems = append(problems, section + ".user must be set")
+    }
+    if dbConfig.Password == "" {
+        problems = append(problems, section + ".password or password_file must be set")
+    }
+
+    return problems
+}
+
+func (pricesConfig *PricesDBConfig) validate(section string) []string {
+    switch pricesConfig.Store {
+    case INFLUX_PRICE_STORE:
+    case SQL_PRICE_STORE:
+        return nil
+    default:
+        return []string{fmt.Sprintf("%s.store must be %q or %q, got %q",
+            section, INFLUX_PRICE_STORE, SQL_PRICE_STORE, pricesConfig.Store)}
+    }
+
+    problems := validateURL(section + ".address", pricesConfig.Address)
+
+    if pricesConfig.Database == "" {
+        problems = append(problems, section + ".database must be set")
+    }
+    if pricesConfig.User == "" {
+        problems = append(problems, section + ".user must be set")
+    }
+    if pricesConfig.Password == "" {
+        problems = append(problems, section + ".password or password_file must be set")
+    }
+
+    return problems
+}
+
+func validateURL(name string, value string) []string {
+    parsed, err := url.Parse(value)
+    if err != nil {
+        return []string{fmt.Sprintf("%s: %s", name, err)}
+    }
+    if parsed.Scheme == "" || parsed.Host == "" {
+        return []string{fmt.Sprintf("%s must be an absolute URL, got %q", name, value)}
+    }
+    return nil
+}
+
+// DSN returns the connection string for this database, in the format the
+// configured driver expects
+func (dbConfig DatabaseConfig) DSN() string {
+    if dbConfig.Driver == SQLITE_DRIVER {
+        // SQLite only allows a single writer, so make writers wait their turn
+        // (and take the write lock up front) instead of failing with
+        // SQLITE_BUSY while another import transaction is open
+        return fmt.Sprintf("file:%s?_pragma=busy_timeout(60000)&_pragma=journal_mode(WAL)&_txlock=immediate",
+            dbConfig.Path)
+    }
+
+    return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true",
+        dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Name)
+}
+
+func (importerConfig ImporterConfig) UpdateIntervalDuration() time.Duration {
+    // Already checked by Validate, so this can't fail on a loaded config
+    updateInterval, _ := time.ParseDuration(importerConfig.UpdateInterval)
+    return updateInterval
+}
+
+// EffectiveSchedule returns schedule (one of the *_schedule settings), or
+// one that runs every update_interval if it isn't set
+func (importerConfig ImporterConfig) EffectiveSchedule(schedule string) string {
+    if schedule != "" {
+        return schedule
+    }
+    return "@every " + importerConfig.UpdateInterval
+}
+
+func (importerConfig ImporterConfig) ScheduleJitterDuration() time.Duration {
+    // Already checked by Validate, so this can't fail on a loaded config
+    scheduleJitter, _ := time.ParseDuration(importerConfig.ScheduleJitter)
+    return scheduleJitter
+}
+
+// ConfigureLogging applies the logging section, which has already been
+// validated
+func (config *Config) ConfigureLogging() error {
+    return logging.Configure(os.Stderr, config.Logging.Format, config.Logging.Level,
+        config.Logging.Components)
+}
+
+// String renders the config with all of the secrets masked, so it's safe
+// to log at startup
+func (config Config) String() string {
+    config.CardDB.Password = maskSecret(config.CardDB.Password)
+    config.UserDB.Password = maskSecret(config.UserDB.Password)
+    config.PricesDB.Password = maskSecret(config.PricesDB.Password)
+
+    configJson, err := json.MarshalIndent(config, "", "    ")
+    if err != nil {
+        return err.Error()
+    }
+    return string(configJson)
+}
+
+func maskSecret(secret string) string {
+    if secret == "" {
+        return ""
+    }
+    return "********"
+}
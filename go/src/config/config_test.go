@@ -0,0 +1,167 @@
+package config
+
+import "errors"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+
+func writeTestFile(t *testing.T, dir string, name string, contents string) string {
+    path := filepath.Join(dir, name)
+    err := ioutil.WriteFile(path, []byte(contents), 0600)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func testDir(t *testing.T) string {
+    dir, err := ioutil.TempDir("", "config")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.RemoveAll(dir) })
+    return dir
+}
+
+func TestLoadPrecedence(t *testing.T) {
+    dir := testDir(t)
+    path := writeTestFile(t, dir, "config.json", `{
+        "card_db": {"host": "file_host:3306", "name": "file_cards"},
+        "importer": {"import_workers": 2, "bulk_insert_new_sets": false},
+        "logging": {"level": "warn"}
+    }`)
+
+    // The environment beats the file, which beats the defaults
+    t.Setenv("MTG_CARD_DB_NAME", "env_cards")
+    t.Setenv("MTG_IMPORTER_IMPORT_WORKERS", "4")
+    t.Setenv("MTG_LOGGING_COMPONENTS", "carddb=debug, auth=error")
+
+    config, err := Load(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    defaults := Defaults()
+    if config.CardDB.User != defaults.CardDB.User {
+        t.Errorf("Expected the default card_db.user %q, got %q", defaults.CardDB.User, config.CardDB.User)
+    }
+    if config.CardDB.Host != "file_host:3306" {
+        t.Errorf("Expected card_db.host from the file, got %q", config.CardDB.Host)
+    }
+    if config.CardDB.Name != "env_cards" {
+        t.Errorf("Expected card_db.name from the environment, got %q", config.CardDB.Name)
+    }
+    if config.Importer.ImportWorkers != 4 {
+        t.Errorf("Expected importer.import_workers from the environment, got %d",
+            config.Importer.ImportWorkers)
+    }
+    if config.Importer.BulkInsertNewSets {
+        t.Error("Expected importer.bulk_insert_new_sets to be turned off by the file")
+    }
+    if config.Logging.Level != "warn" {
+        t.Errorf("Expected logging.level from the file, got %q", config.Logging.Level)
+    }
+    if len(config.Logging.Components) != 2 || config.Logging.Components["carddb"] != "debug" ||
+            config.Logging.Components["auth"] != "error" {
+        t.Errorf("Expected logging.components from the environment, got %v",
+            config.Logging.Components)
+    }
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+    t.Setenv("MTG_IMPORTER_IMPORT_WORKERS", "lots")
+
+    _, err := Load(writeTestFile(t, testDir(t), "config.json", "{}"))
+    if err == nil || !strings.Contains(err.Error(), "MTG_IMPORTER_IMPORT_WORKERS") {
+        t.Errorf("Expected an error about MTG_IMPORTER_IMPORT_WORKERS, got %v", err)
+    }
+}
+
+func TestLoadPasswordFiles(t *testing.T) {
+    dir := testDir(t)
+    cardPassword := writeTestFile(t, dir, "card_password", "card secret\n")
+    pricesPassword := writeTestFile(t, dir, "prices_password", "prices secret")
+    path := writeTestFile(t, dir, "config.json", `{
+        "card_db": {"password": "ignored", "password_file": "` + cardPassword + `"}
+    }`)
+    t.Setenv("MTG_PRICES_DB_PASSWORD_FILE", pricesPassword)
+
+    config, err := Load(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if config.CardDB.Password != "card secret" {
+        t.Errorf("Expected card_db.password from its file without the newline, got %q",
+            config.CardDB.Password)
+    }
+    if config.PricesDB.Password != "prices secret" {
+        t.Errorf("Expected prices_db.password from its file, got %q", config.PricesDB.Password)
+    }
+    if config.UserDB.Password != Defaults().UserDB.Password {
+        t.Errorf("Expected the default user_db.password, got %q", config.UserDB.Password)
+    }
+
+    path = writeTestFile(t, dir, "missing_secret.json", `{
+        "user_db": {"password_file": "` + filepath.Join(dir, "missing") + `"}
+    }`)
+    _, err = Load(path)
+    if err == nil || !strings.Contains(err.Error(), "secret file") {
+        t.Errorf("Expected an error reading the secret file, got %v", err)
+    }
+}
+
+func TestLoadUnknownFields(t *testing.T) {
+    path := writeTestFile(t, testDir(t), "config.json", `{
+        "card_db": {"hostname": "typo:3306"}
+    }`)
+
+    _, err := Load(path)
+    if err == nil || !strings.Contains(err.Error(), "hostname") {
+        t.Errorf("Expected an error about the unknown field, got %v", err)
+    }
+}
+
+func TestValidate(t *testing.T) {
+    config := Defaults()
+    if err := config.Validate(); err != nil {
+        t.Fatalf("Expected the defaults to be valid, got %s", err)
+    }
+
+    config.CardDB.Driver = SQLITE_DRIVER
+    config.UserDB.Password = ""
+    config.PricesDB.Store = "csv"
+    config.Auth.AdminURL = "hydra"
+    config.Importer.UpdateInterval = "-1h"
+    config.Importer.CardsSchedule = "every day"
+    config.Importer.UpdateMode = "partial"
+    config.Importer.ImportWorkers = 0
+    config.Logging.Components = map[string]string{"carddb": "loud"}
+
+    err := config.Validate()
+    var validationErr *ValidationError
+    if !errors.As(err, &validationErr) {
+        t.Fatalf("Expected a ValidationError, got %v", err)
+    }
+
+    expected := []string{
+        "card_db.path must be set for sqlite",
+        "user_db.password or password_file must be set",
+        "prices_db.store",
+        "auth.admin_url must be an absolute URL",
+        "importer.update_interval must be positive",
+        "importer.cards_schedule",
+        "importer.update_mode",
+        "importer.import_workers must be at least 1",
+        "logging.components.carddb"}
+    if len(validationErr.Problems) != len(expected) {
+        t.Errorf("Expected %d problems, got %d: %v", len(expected),
+            len(validationErr.Problems), validationErr.Problems)
+    }
+    for _, problem := range expected {
+        if !strings.Contains(err.Error(), problem) {
+            t.Errorf("Expected a problem with %s, got %s", problem, err)
+        }
+    }
+}
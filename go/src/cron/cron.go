@@ -0,0 +1,247 @@
+package cron
+
+import "fmt"
+import "strconv"
+import "strings"
+import "time"
+
+// How far ahead Next looks for a matching time before deciding there isn't
+// one, e.g. for the 30th of February
+const searchYears = 5
+
+// A Schedule works out when something next runs
+type Schedule interface {
+    // Next returns the first time the schedule fires that's strictly after
+    // after, in after's location
+    Next(after time.Time) time.Time
+}
+
+// The shorthands Parse accepts in place of the five fields
+var descriptors = map[string]string{
+    "@yearly": "0 0 1 1 *",
+    "@annually": "0 0 1 1 *",
+    "@monthly": "0 0 1 * *",
+    "@weekly": "0 0 * * 0",
+    "@daily": "0 0 * * *",
+    "@midnight": "0 0 * * *",
+    "@hourly": "0 * * * *",
+}
+
+// Parse reads a standard five field cron expression (minute, hour, day of
+// month, month, day of week), or one of the @yearly, @monthly, @weekly,
+// @daily and @hourly shorthands, or "@every <duration>" (e.g. "@every 12h")
+// for a fixed interval.
+//
+// Each field is "*", a value, a range ("1-5"), or a comma separated list of
+// them, and any but a single value can take a step ("*/15", "0-12/6").
+// Months and days of the week can be given by their three letter English
+// names, and Sunday is both 0 and 7.  As in cron, if both the day of the
+// month and the day of the week are restricted, a day matching either runs.
+func Parse(expr string) (Schedule, error) {
+    expr = strings.TrimSpace(expr)
+
+    if strings.HasPrefix(expr, "@every ") {
+        interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+        if err != nil {
+            return nil, fmt.Errorf("Invalid interval in %q: %s", expr, err)
+        }
+        if interval < time.Second {
+            return nil, fmt.Errorf("Interval in %q must be at least 1s", expr)
+        }
+        return everySchedule{interval}, nil
+    }
+    if fields, ok := descriptors[strings.ToLower(expr)]; ok {
+        expr = fields
+    } else if strings.HasPrefix(expr, "@") {
+        return nil, fmt.Errorf("Unknown schedule %q", expr)
+    }
+
+    fields := strings.Fields(expr)
+    if len(fields) != 5 {
+        return nil, fmt.Errorf("Cron expression %q should have 5 fields, has %d", expr, len(fields))
+    }
+
+    var schedule cronSchedule
+    var err error
+    if schedule.minutes, err = minuteField.parse(fields[0]); err != nil {
+        return nil, err
+    }
+    if schedule.hours, err = hourField.parse(fields[1]); err != nil {
+        return nil, err
+    }
+    if schedule.daysOfMonth, err = dayOfMonthField.parse(fields[2]); err != nil {
+        return nil, err
+    }
+    if schedule.months, err = monthField.parse(fields[3]); err != nil {
+        return nil, err
+    }
+    if schedule.daysOfWeek, err = dayOfWeekField.parse(fields[4]); err != nil {
+        return nil, err
+    }
+    // Sunday can be written as 7, but time.Weekday counts it as 0
+    if schedule.daysOfWeek.has(7) {
+        schedule.daysOfWeek |= 1
+    }
+    schedule.anyDayOfMonth = fields[2] == "*"
+    schedule.anyDayOfWeek = fields[4] == "*"
+
+    // Catch expressions that can never run, rather than finding out when
+    // they're scheduled
+    if schedule.Next(time.Now()).IsZero() {
+        return nil, fmt.Errorf("Cron expression %q never runs", expr)
+    }
+
+    return &schedule, nil
+}
+
+type everySchedule struct {
+    interval time.Duration
+}
+
+func (schedule everySchedule) Next(after time.Time) time.Time {
+    return after.Add(schedule.interval)
+}
+
+// A set of the values a field matches, as a bitmask
+type valueSet uint64
+
+func (set valueSet) has(value int) bool {
+    return set & (1 << uint(value)) != 0
+}
+
+type cronSchedule struct {
+    minutes valueSet
+    hours valueSet
+    daysOfMonth valueSet
+    months valueSet
+    daysOfWeek valueSet
+    anyDayOfMonth bool
+    anyDayOfWeek bool
+}
+
+func (schedule *cronSchedule) Next(after time.Time) time.Time {
+    t := after.Truncate(time.Minute).Add(time.Minute)
+    limit := t.AddDate(searchYears, 0, 0)
+
+    // Move on a month, day, hour or minute at a time, starting from the
+    // beginning of each, until everything matches
+    for t.Before(limit) {
+        if !schedule.months.has(int(t.Month())) {
+            t = time.Date(t.Year(), t.Month() + 1, 1, 0, 0, 0, 0, t.Location())
+            continue
+        }
+        if !schedule.dayMatches(t) {
+            t = time.Date(t.Year(), t.Month(), t.Day() + 1, 0, 0, 0, 0, t.Location())
+            continue
+        }
+        if !schedule.hours.has(t.Hour()) {
+            t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour() + 1, 0, 0, 0, t.Location())
+            continue
+        }
+        if !schedule.minutes.has(t.Minute()) {
+            t = t.Add(time.Minute)
+            continue
+        }
+        return t
+    }
+
+    return time.Time{}
+}
+
+func (schedule *cronSchedule) dayMatches(t time.Time) bool {
+    dayOfMonth := schedule.daysOfMonth.has(t.Day())
+    dayOfWeek := schedule.daysOfWeek.has(int(t.Weekday()))
+    if schedule.anyDayOfMonth || schedule.anyDayOfWeek {
+        return dayOfMonth && dayOfWeek
+    }
+    return dayOfMonth || dayOfWeek
+}
+
+type field struct {
+    name string
+    min int
+    max int
+    names []string
+}
+
+var minuteField = field{name: "minute", min: 0, max: 59}
+var hourField = field{name: "hour", min: 0, max: 23}
+var dayOfMonthField = field{name: "day of month", min: 1, max: 31}
+var monthField = field{name: "month", min: 1, max: 12,
+    names: []string{"jan", "feb", "mar", "apr", "may", "jun",
+        "jul", "aug", "sep", "oct", "nov", "dec"}}
+var dayOfWeekField = field{name: "day of week", min: 0, max: 7,
+    names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+
+func (f field) parse(expr string) (valueSet, error) {
+    var set valueSet
+    for _, part := range strings.Split(expr, ",") {
+        partSet, err := f.parsePart(part)
+        if err != nil {
+            return 0, err
+        }
+        set |= partSet
+    }
+    return set, nil
+}
+
+func (f field) parsePart(part string) (valueSet, error) {
+    rangePart := part
+    step := 1
+    hasStep := false
+    if slash := strings.Index(part, "/"); slash >= 0 {
+        rangePart = part[:slash]
+        stepValue, err := strconv.Atoi(part[slash + 1:])
+        if err != nil || stepValue < 1 {
+            return 0, fmt.Errorf("Invalid step in %s %q", f.name, part)
+        }
+        step = stepValue
+        hasStep = true
+    }
+
+    var start, end int
+    var err error
+    if rangePart == "*" {
+        start, end = f.min, f.max
+    } else if dash := strings.Index(rangePart, "-"); dash >= 0 {
+        if start, err = f.value(rangePart[:dash]); err != nil {
+            return 0, err
+        }
+        if end, err = f.value(rangePart[dash + 1:]); err != nil {
+            return 0, err
+        }
+        if start > end {
+            return 0, fmt.Errorf("Invalid %s range %q", f.name, rangePart)
+        }
+    } else {
+        if start, err = f.value(rangePart); err != nil {
+            return 0, err
+        }
+        // "5/10" means from 5 onwards, every 10
+        end = start
+        if hasStep {
+            end = f.max
+        }
+    }
+
+    var set valueSet
+    for value := start; value <= end; value += step {
+        set |= 1 << uint(value)
+    }
+    return set, nil
+}
+
+func (f field) value(expr string) (int, error) {
+    // Names count up from the field's minimum, so January is 1 but Sunday
+    // is 0
+    for idx, name := range f.names {
+        if strings.EqualFold(expr, name) {
+            return f.min + idx, nil
+        }
+    }
+    value, err := strconv.Atoi(expr)
+    if err != nil || value < f.min || value > f.max {
+        return 0, fmt.Errorf("Invalid %s %q, must be %d-%d", f.name, expr, f.min, f.max)
+    }
+    return value, nil
+}
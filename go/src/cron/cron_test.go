@@ -0,0 +1,62 @@
+package cron
+
+import "testing"
+import "time"
+
+func TestNext(t *testing.T) {
+    // A Wednesday
+    start := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.UTC)
+
+    tests := []struct {
+        expr string
+        expected time.Time
+    }{
+        {"* * * * *", time.Date(2024, time.January, 31, 10, 18, 0, 0, time.UTC)},
+        {"*/15 * * * *", time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC)},
+        {"0 */6 * * *", time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)},
+        {"30 2 * * *", time.Date(2024, time.February, 1, 2, 30, 0, 0, time.UTC)},
+        {"0 0 29 feb *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+        {"0 9 * * mon-fri", time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)},
+        {"0 9 * * 7", time.Date(2024, time.February, 4, 9, 0, 0, 0, time.UTC)},
+        // Either the 15th or a Saturday, whichever comes first
+        {"0 0 15 * sat", time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC)},
+        {"5,35 10-11 * * *", time.Date(2024, time.January, 31, 10, 35, 0, 0, time.UTC)},
+        {"@daily", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+        {"@monthly", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+        {"@every 90m", start.Add(90 * time.Minute)},
+    }
+
+    for _, test := range tests {
+        schedule, err := Parse(test.expr)
+        if err != nil {
+            t.Errorf("Unexpected error parsing %q: %s", test.expr, err)
+            continue
+        }
+        next := schedule.Next(start)
+        if !next.Equal(test.expected) {
+            t.Errorf("%q: expected next run at %s, got %s", test.expr, test.expected, next)
+        }
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    for _, expr := range []string{
+            "",
+            "* * * *",
+            "60 * * * *",
+            "* 24 * * *",
+            "* * 0 * *",
+            "* * * 13 *",
+            "* * * * 8",
+            "5-1 * * * *",
+            "*/0 * * * *",
+            "* * * foo *",
+            "0 0 30 feb *",
+            "@fortnightly",
+            "@every soon",
+            "@every 0s"} {
+        if _, err := Parse(expr); err == nil {
+            t.Errorf("Expected an error parsing %q", expr)
+        }
+    }
+}
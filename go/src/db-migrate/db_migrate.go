@@ -0,0 +1,71 @@
+package main
+
+import "config"
+import "dbconn"
+import "flag"
+import "fmt"
+import "logging"
+import "migrations"
+import "os"
+
+var logger = logging.For("db-migrate")
+
+func main() {
+    configPath := flag.String("config", "", "Path to the JSON config file")
+    database := flag.String("db", "cards", "Which database to migrate, cards or users")
+    targetVersion := flag.Int("to", -1, "Version to migrate to, defaults to the latest")
+    statusOnly := flag.Bool("status", false, "Only print the current and latest versions")
+    flag.Parse()
+
+    migrateConfig, err := config.Load(*configPath)
+    if err != nil {
+        logging.Fatal(logger, "Error loading config", "err", err)
+    }
+    err = migrateConfig.ConfigureLogging()
+    if err != nil {
+        logging.Fatal(logger, "Error configuring logging", "err", err)
+    }
+
+    var dbConfig config.DatabaseConfig
+    var schema *migrations.Schema
+    switch *database {
+    case "cards":
+        dbConfig = migrateConfig.CardDB
+        schema = &migrations.CardDBSchema
+    case "users":
+        dbConfig = migrateConfig.UserDB
+        schema = &migrations.UsersDBSchema
+    default:
+        fmt.Fprintf(os.Stderr, "Unknown database %q, expected cards or users\n", *database)
+        os.Exit(2)
+    }
+
+    db, err := dbconn.Open(dbConfig)
+    if err != nil {
+        logging.Fatal(logger, "Error connecting to db", "schema", schema.Name, "err", err)
+    }
+    defer db.Close()
+
+    currentVersion, err := migrations.CurrentVersion(db, schema)
+    if err != nil {
+        logging.Fatal(logger, "Error reading schema version", "schema", schema.Name, "err", err)
+    }
+    latestVersion := schema.LatestVersion()
+
+    if *statusOnly {
+        fmt.Printf("%s: current version %d, latest version %d\n",
+            schema.Name, currentVersion, latestVersion)
+        return
+    }
+
+    if *targetVersion < 0 {
+        *targetVersion = latestVersion
+    }
+
+    logger.Info("Migrating", "schema", schema.Name, "from", currentVersion, "to", *targetVersion)
+    err = migrations.Migrate(db, dbConfig.Driver, schema, *targetVersion)
+    if err != nil {
+        logging.Fatal(logger, "Error migrating", "schema", schema.Name, "err", err)
+    }
+    logger.Info("Done", "schema", schema.Name)
+}
@@ -0,0 +1,64 @@
+package dbconn
+
+import "config"
+import "database/sql"
+import "errors"
+import "github.com/go-sql-driver/mysql"
+import sqlite "modernc.org/sqlite"
+import "strings"
+
+// MariaDB's ER_NO_SUCH_TABLE
+const mysqlNoSuchTable = 1146
+
+// Open connects to the card or user database described by dbConfig, using
+// whichever driver it's configured for
+func Open(dbConfig config.DatabaseConfig) (*sql.DB, error) {
+    return sql.Open(dbConfig.Driver, dbConfig.DSN())
+}
+
+// IsSQLite reports whether db was opened with the SQLite driver, for the
+// handful of statements that can't be written portably
+func IsSQLite(db *sql.DB) bool {
+    _, isSQLite := db.Driver().(*sqlite.Driver)
+    return isSQLite
+}
+
+// IsMissingTableError reports whether err is what either driver returns for
+// a query against a table that doesn't exist
+func IsMissingTableError(err error) bool {
+    var mysqlErr *mysql.MySQLError
+    if errors.As(err, &mysqlErr) {
+        return mysqlErr.Number == mysqlNoSuchTable
+    }
+    // modernc's errors only carry SQLite's generic SQLITE_ERROR code, so the
+    // message is all there is to go on
+    return err != nil && strings.Contains(err.Error(), "no such table")
+}
+
+// OnDuplicateIgnore returns the clause to put on the end of an INSERT so that
+// a row clashing with an existing one on the unique column is silently
+// skipped (affecting 0 rows), in the dialect of db
+func OnDuplicateIgnore(db *sql.DB, uniqueColumn string) string {
+    if IsSQLite(db) {
+        return "ON CONFLICT(" + uniqueColumn + ") DO NOTHING"
+    }
+    return "ON DUPLICATE KEY UPDATE " + uniqueColumn + "=" + uniqueColumn
+}
+
+// OnDuplicateUpdate returns the clause to put on the end of an INSERT so that
+// a row clashing with an existing one on conflictColumns (which must be a
+// unique index) overwrites updateColumns instead, in the dialect of db
+func OnDuplicateUpdate(db *sql.DB, conflictColumns string, updateColumns ...string) string {
+    assignments := make([]string, 0, len(updateColumns))
+    if IsSQLite(db) {
+        for _, column := range updateColumns {
+            assignments = append(assignments, column + "=excluded." + column)
+        }
+        return "ON CONFLICT(" + conflictColumns + ") DO UPDATE SET " +
+            strings.Join(assignments, ", ")
+    }
+    for _, column := range updateColumns {
+        assignments = append(assignments, column + "=VALUES(" + column + ")")
+    }
+    return "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
@@ -0,0 +1,154 @@
+package dbconn
+
+import "config"
+import "database/sql"
+import "fmt"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+
+func openTestDB(t *testing.T) *sql.DB {
+    dir, err := ioutil.TempDir("", "dbconn")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.RemoveAll(dir) })
+
+    db, err := Open(config.DatabaseConfig{
+        Driver: config.SQLITE_DRIVER,
+        Path: filepath.Join(dir, "test.db")})
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { db.Close() })
+
+    _, err = db.Exec(`CREATE TABLE cards (
+        name VARCHAR(100) NOT NULL PRIMARY KEY,
+        card_type VARCHAR(100) NOT NULL,
+        price INTEGER NOT NULL)`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return db
+}
+
+func TestOnDuplicateIgnore(t *testing.T) {
+    db := openTestDB(t)
+    insert := `INSERT INTO cards (name, card_type, price) VALUES (?, ?, ?) ` +
+        OnDuplicateIgnore(db, "name")
+
+    if _, err := db.Exec(insert, "Llanowar Elves", "Creature", 1); err != nil {
+        t.Fatal(err)
+    }
+    res, err := db.Exec(insert, "Llanowar Elves", "Instant", 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rowsAffected, _ := res.RowsAffected(); rowsAffected != 0 {
+        t.Errorf("Expected a duplicate to affect no rows, got %d", rowsAffected)
+    }
+
+    var cardType string
+    var price int
+    err = db.QueryRow(`SELECT card_type, price FROM cards WHERE name = ?`,
+        "Llanowar Elves").Scan(&cardType, &price)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cardType != "Creature" || price != 1 {
+        t.Errorf("Expected the original row to be kept, got %s and %d", cardType, price)
+    }
+}
+
+func TestOnDuplicateUpdate(t *testing.T) {
+    db := openTestDB(t)
+    insert := `INSERT INTO cards (name, card_type, price) VALUES (?, ?, ?) ` +
+        OnDuplicateUpdate(db, "name", "card_type", "price")
+
+    if _, err := db.Exec(insert, "Llanowar Elves", "Creature", 1); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := db.Exec(insert, "Llanowar Elves", "Creature - Elf", 2); err != nil {
+        t.Fatal(err)
+    }
+
+    var rowCount, price int
+    var cardType string
+    err := db.QueryRow(`SELECT COUNT(*), MAX(card_type), MAX(price) FROM cards`).Scan(
+        &rowCount, &cardType, &price)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rowCount != 1 || cardType != "Creature - Elf" || price != 2 {
+        t.Errorf("Expected 1 updated row, got %d rows with %s and %d", rowCount, cardType, price)
+    }
+}
+
+func TestMySQLDuplicateClauses(t *testing.T) {
+    // Opening doesn't connect, so this needs no server
+    db, err := sql.Open(config.MYSQL_DRIVER, "user:password@tcp(localhost:3306)/test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer db.Close()
+
+    if clause := OnDuplicateIgnore(db, "name"); clause != "ON DUPLICATE KEY UPDATE name=name" {
+        t.Errorf("Unexpected MySQL ignore clause %q", clause)
+    }
+    clause := OnDuplicateUpdate(db, "name", "card_type", "price")
+    if clause != "ON DUPLICATE KEY UPDATE card_type=VALUES(card_type), price=VALUES(price)" {
+        t.Errorf("Unexpected MySQL update clause %q", clause)
+    }
+}
+
+func TestSQLiteRegexpIsCaseInsensitive(t *testing.T) {
+    db := openTestDB(t)
+    for _, name := range []string{"Llanowar Elves", "Elvish Mystic", "Birds of Paradise"} {
+        _, err := db.Exec(`INSERT INTO cards (name, card_type, price) VALUES (?, 'Creature', 1)`, name)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    var matches int
+    err := db.QueryRow(`SELECT COUNT(*) FROM cards WHERE name REGEXP ?`, "^(llanowar|ELVISH) ").Scan(&matches)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if matches != 2 {
+        t.Errorf("Expected 2 matches ignoring case, got %d", matches)
+    }
+
+    err = db.QueryRow(`SELECT COUNT(*) FROM cards WHERE name REGEXP ?`, "(unclosed").Scan(&matches)
+    if err == nil {
+        t.Error("Expected an invalid pattern to fail the query")
+    }
+}
+
+func TestRegexpCacheIsBounded(t *testing.T) {
+    first, err := compileRegexp("elves")
+    if err != nil {
+        t.Fatal(err)
+    }
+    second, err := compileRegexp("elves")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if first != second {
+        t.Error("Expected a repeated pattern to come from the cache")
+    }
+
+    for i := 0; i < MAX_CACHED_REGEXPS * 2; i++ {
+        if _, err := compileRegexp(fmt.Sprintf("pattern %d", i)); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    regexpCacheMutex.Lock()
+    cached := len(regexpCache)
+    regexpCacheMutex.Unlock()
+    if cached > MAX_CACHED_REGEXPS {
+        t.Errorf("Expected at most %d cached patterns, got %d", MAX_CACHED_REGEXPS, cached)
+    }
+}
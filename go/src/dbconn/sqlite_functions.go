@@ -0,0 +1,73 @@
+package dbconn
+
+import "database/sql/driver"
+import "fmt"
+import "regexp"
+import sqlite "modernc.org/sqlite"
+import "sync"
+
+// Searches come from users, so don't let the cache grow forever
+const MAX_CACHED_REGEXPS = 100
+
+var regexpCache = map[string]*regexp.Regexp{}
+var regexpCacheMutex sync.Mutex
+
+// SQLite parses `x REGEXP y` but leaves the regexp() function up to the
+// application, so register one that behaves like MariaDB's case-insensitive
+// REGEXP on the columns we search
+func init() {
+    err := sqlite.RegisterDeterministicScalarFunction("regexp", 2, sqliteRegexp)
+    if err != nil {
+        panic(err)
+    }
+}
+
+func sqliteRegexp(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+    if args[0] == nil || args[1] == nil {
+        return nil, nil
+    }
+
+    pattern, ok := args[0].(string)
+    if !ok {
+        return nil, fmt.Errorf("REGEXP pattern must be text, got %T", args[0])
+    }
+
+    var value string
+    switch typedValue := args[1].(type) {
+    case string:
+        value = typedValue
+    case []byte:
+        value = string(typedValue)
+    default:
+        value = fmt.Sprint(typedValue)
+    }
+
+    compiled, err := compileRegexp(pattern)
+    if err != nil {
+        return nil, err
+    }
+
+    return compiled.MatchString(value), nil
+}
+
+// Searches run the same pattern against every row, so hang on to the
+// compiled versions
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+    regexpCacheMutex.Lock()
+    defer regexpCacheMutex.Unlock()
+
+    compiled, exists := regexpCache[pattern]
+    if !exists {
+        var err error
+        compiled, err = regexp.Compile("(?i)" + pattern)
+        if err != nil {
+            return nil, err
+        }
+        if len(regexpCache) >= MAX_CACHED_REGEXPS {
+            regexpCache = map[string]*regexp.Regexp{}
+        }
+        regexpCache[pattern] = compiled
+    }
+
+    return compiled, nil
+}
@@ -0,0 +1,267 @@
+package logging
+
+import "context"
+import "fmt"
+import "io"
+import "log"
+import "log/slog"
+import "net/url"
+import "os"
+import "strings"
+import "sync"
+import "sync/atomic"
+
+// Supported output formats for Configure
+const (
+    FORMAT_TEXT = "text"
+    FORMAT_JSON = "json"
+)
+
+// What a redacted value is replaced with
+const REDACTED = "[REDACTED]"
+
+// Keys (or the parts of keys, between underscores and dots) whose values are
+// credentials, and are never written out.  "token" doesn't match "tokens", so
+// counts of card tokens are left alone.  Hydra's login, consent and logout
+// challenges and verifiers are as good as credentials while they're live.
+var sensitiveKeyParts = map[string]bool{
+    "password": true,
+    "passwd": true,
+    "pw": true,
+    "secret": true,
+    "token": true,
+    "authorization": true,
+    "cookie": true,
+    "credentials": true,
+    "creds": true,
+    "challenge": true,
+    "verifier": true,
+}
+
+// The handler every component logs through, swapped out by Configure.  It's
+// held in a struct since atomic.Value needs every store to be the same type.
+type outputHandler struct {
+    handler slog.Handler
+}
+
+var output atomic.Value
+
+// Each component's level, so that loggers made before Configure is called
+// (e.g. in package vars) still pick up the configured levels
+var levels = struct {
+    sync.Mutex
+    defaultLevel slog.Level
+    components map[string]*slog.LevelVar
+    configured map[string]slog.Level
+}{components: make(map[string]*slog.LevelVar)}
+
+func init() {
+    output.Store(outputHandler{newHandler(os.Stderr, FORMAT_TEXT)})
+}
+
+// ParseLevel parses one of "debug", "info", "warn" or "error"
+func ParseLevel(level string) (slog.Level, error) {
+    var parsed slog.Level
+    err := parsed.UnmarshalText([]byte(level))
+    if err != nil {
+        return slog.LevelInfo, fmt.Errorf("Unknown log level %q", level)
+    }
+    return parsed, nil
+}
+
+// Configure sets where logs go and at what levels.  defaultLevel applies to
+// every component that isn't given its own level in components.  Until it's
+// called, everything at info and above goes to stderr as text.
+func Configure(out io.Writer, format string, defaultLevel string, components map[string]string) error {
+    if format != FORMAT_TEXT && format != FORMAT_JSON {
+        return fmt.Errorf("Unknown log format %q", format)
+    }
+    parsedDefault, err := ParseLevel(defaultLevel)
+    if err != nil {
+        return err
+    }
+    configured := make(map[string]slog.Level, len(components))
+    for component, level := range components {
+        parsed, err := ParseLevel(level)
+        if err != nil {
+            return fmt.Errorf("%s: %s", component, err)
+        }
+        configured[component] = parsed
+    }
+
+    levels.Lock()
+    levels.defaultLevel = parsedDefault
+    levels.configured = configured
+    for component, levelVar := range levels.components {
+        levelVar.Set(levelFor(component))
+    }
+    levels.Unlock()
+
+    output.Store(outputHandler{newHandler(out, format)})
+    return nil
+}
+
+// levelFor must be called with levels locked
+func levelFor(component string) slog.Level {
+    if level, ok := levels.configured[component]; ok {
+        return level
+    }
+    return levels.defaultLevel
+}
+
+func newHandler(out io.Writer, format string) slog.Handler {
+    // Levels are checked per component, so the output takes everything
+    options := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redact}
+    if format == FORMAT_JSON {
+        return slog.NewJSONHandler(out, options)
+    }
+    return slog.NewTextHandler(out, options)
+}
+
+// For returns the logger for component (e.g. "carddb"), which adds the
+// component to everything it logs, and only logs at or above the
+// component's configured level
+func For(component string) *slog.Logger {
+    levels.Lock()
+    levelVar, ok := levels.components[component]
+    if !ok {
+        levelVar = new(slog.LevelVar)
+        levelVar.Set(levelFor(component))
+        levels.components[component] = levelVar
+    }
+    levels.Unlock()
+
+    return slog.New(&componentHandler{
+        level: levelVar,
+        ops: []func(slog.Handler) slog.Handler{
+            withAttrs([]slog.Attr{slog.String("component", component)})}})
+}
+
+// Fatal logs msg at error level and exits, for the places that used to call
+// log.Fatal
+func Fatal(logger *slog.Logger, msg string, args ...interface{}) {
+    logger.Error(msg, args...)
+    os.Exit(1)
+}
+
+// RedirectStdLog sends anything still written through the standard log
+// package (e.g. by net/http) to logger, at info level
+func RedirectStdLog(logger *slog.Logger) {
+    log.SetFlags(0)
+    log.SetOutput(&stdLogWriter{logger: logger})
+}
+
+type stdLogWriter struct {
+    logger *slog.Logger
+}
+
+func (writer *stdLogWriter) Write(message []byte) (int, error) {
+    writer.logger.Info(strings.TrimRight(string(message), "\n"))
+    return len(message), nil
+}
+
+// componentHandler checks the component's level, then hands the record on
+// to whatever the output is when it's logged.  The attrs and groups added
+// with With and WithGroup are kept as ops to replay onto the output, since
+// it can change after the logger is made.
+type componentHandler struct {
+    level *slog.LevelVar
+    ops []func(slog.Handler) slog.Handler
+}
+
+func (handler *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+    return level >= handler.level.Level()
+}
+
+func (handler *componentHandler) Handle(ctx context.Context, record slog.Record) error {
+    out := output.Load().(outputHandler).handler
+    for _, op := range handler.ops {
+        out = op(out)
+    }
+    return out.Handle(ctx, record)
+}
+
+func (handler *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    return handler.withOp(withAttrs(attrs))
+}
+
+func (handler *componentHandler) WithGroup(name string) slog.Handler {
+    return handler.withOp(func(out slog.Handler) slog.Handler {
+        return out.WithGroup(name)
+    })
+}
+
+func (handler *componentHandler) withOp(op func(slog.Handler) slog.Handler) slog.Handler {
+    ops := make([]func(slog.Handler) slog.Handler, 0, len(handler.ops) + 1)
+    ops = append(ops, handler.ops...)
+    return &componentHandler{level: handler.level, ops: append(ops, op)}
+}
+
+func withAttrs(attrs []slog.Attr) func(slog.Handler) slog.Handler {
+    return func(out slog.Handler) slog.Handler {
+        return out.WithAttrs(attrs)
+    }
+}
+
+// redact replaces the values of credential keys, wherever they are in the
+// record
+func redact(groups []string, attr slog.Attr) slog.Attr {
+    if IsSensitiveKey(attr.Key) {
+        return slog.String(attr.Key, REDACTED)
+    }
+    return attr
+}
+
+// IsSensitiveKey is whether key (a log key, form field or header name)
+// names a credential
+func IsSensitiveKey(key string) bool {
+    parts := strings.FieldsFunc(strings.ToLower(key), func(r rune) bool {
+        return r == '_' || r == '.' || r == '-'
+    })
+    for _, part := range parts {
+        if sensitiveKeyParts[part] {
+            return true
+        }
+    }
+    return false
+}
+
+// Redact returns a copy of values (e.g. a parsed request body) with every
+// credential redacted, for logging
+func Redact(values map[string][]string) map[string][]string {
+    redacted := make(map[string][]string, len(values))
+    for key, value := range values {
+        if IsSensitiveKey(key) {
+            redacted[key] = []string{REDACTED}
+        } else {
+            redacted[key] = value
+        }
+    }
+    return redacted
+}
+
+// RedactURL returns rawURL with the values of any credential query
+// parameters (e.g. login_challenge) redacted, for logging
+func RedactURL(rawURL string) string {
+    parsed, err := url.Parse(rawURL)
+    if err != nil {
+        return REDACTED
+    }
+    if parsed.User != nil {
+        parsed.User = url.User(parsed.User.Username())
+    }
+    if parsed.RawQuery != "" {
+        parsed.RawQuery = url.Values(Redact(parsed.Query())).Encode()
+    }
+    return parsed.String()
+}
+
+// RedactURLError returns err with its URL redacted if it's a *url.Error,
+// which is what net/http returns, and which includes the whole request URL
+// in its message
+func RedactURLError(err error) error {
+    if urlErr, ok := err.(*url.Error); ok {
+        return &url.Error{Op: urlErr.Op, URL: RedactURL(urlErr.URL), Err: urlErr.Err}
+    }
+    return err
+}
@@ -0,0 +1,92 @@
+package logging
+
+import "bytes"
+import "errors"
+import "net/url"
+import "os"
+import "strings"
+import "testing"
+
+func TestComponentLevels(t *testing.T) {
+    var out bytes.Buffer
+    quiet := For("test-quiet")
+    chatty := For("test-chatty")
+    err := Configure(&out, FORMAT_TEXT, "warn", map[string]string{"test-chatty": "debug"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer Configure(os.Stderr, FORMAT_TEXT, "info", nil)
+
+    quiet.Info("quiet info")
+    quiet.Warn("quiet warn")
+    chatty.Debug("chatty debug")
+
+    logged := out.String()
+    if strings.Contains(logged, "quiet info") {
+        t.Errorf("Info logged below the default level: %s", logged)
+    }
+    for _, expected := range []string{"quiet warn", "component=test-quiet", "chatty debug"} {
+        if !strings.Contains(logged, expected) {
+            t.Errorf("Expected %q in %s", expected, logged)
+        }
+    }
+}
+
+func TestRedaction(t *testing.T) {
+    var out bytes.Buffer
+    err := Configure(&out, FORMAT_JSON, "info", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer Configure(os.Stderr, FORMAT_TEXT, "info", nil)
+
+    For("test").With("auth_token", "abc123", "conn_id", 7).Info("login",
+        "password", "hunter2", "new_tokens", 4)
+
+    logged := out.String()
+    for _, secret := range []string{"abc123", "hunter2"} {
+        if strings.Contains(logged, secret) {
+            t.Errorf("%q wasn't redacted: %s", secret, logged)
+        }
+    }
+    for _, expected := range []string{`"new_tokens":4`, `"conn_id":7`} {
+        if !strings.Contains(logged, expected) {
+            t.Errorf("Expected %s in %s", expected, logged)
+        }
+    }
+}
+
+func TestConfigureErrors(t *testing.T) {
+    var out bytes.Buffer
+    if Configure(&out, "xml", "info", nil) == nil {
+        t.Error("Expected an error for an unknown format")
+    }
+    if Configure(&out, FORMAT_TEXT, "loud", nil) == nil {
+        t.Error("Expected an error for an unknown level")
+    }
+    if Configure(&out, FORMAT_TEXT, "info", map[string]string{"carddb": "loud"}) == nil {
+        t.Error("Expected an error for an unknown component level")
+    }
+}
+
+func TestRedactURL(t *testing.T) {
+    redacted := RedactURL("http://hydra:4445/oauth2/auth/requests/login?login_challenge=abc123&x=1")
+    if strings.Contains(redacted, "abc123") || !strings.Contains(redacted, "x=1") {
+        t.Errorf("Unexpected redacted URL %s", redacted)
+    }
+}
+
+func TestRedactURLError(t *testing.T) {
+    err := RedactURLError(&url.Error{
+        Op: "Get",
+        URL: "http://hydra:4445/oauth2/auth/requests/consent?consent_challenge=abc123",
+        Err: errors.New("connection refused")})
+    if strings.Contains(err.Error(), "abc123") || !strings.Contains(err.Error(), "connection refused") {
+        t.Errorf("Unexpected redacted error %s", err)
+    }
+
+    other := errors.New("consent_challenge=abc123")
+    if RedactURLError(other) != other {
+        t.Errorf("Expected errors other than *url.Error to be left alone")
+    }
+}
@@ -0,0 +1,426 @@
+package metrics
+
+import "bufio"
+import "fmt"
+import "io"
+import "logging"
+import "math"
+import "net/http"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+
+var logger = logging.For("metrics")
+
+// The content type of the Prometheus text exposition format
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Histogram buckets (in seconds) suited to request latencies
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics, and writes them out in the Prometheus text format
+type Registry struct {
+    mutex sync.Mutex
+    families map[string]family
+    onScrape []func()
+}
+
+// A metric, along with all of its label combinations
+type family interface {
+    write(writer *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+    return &Registry{families: make(map[string]family)}
+}
+
+// DefaultRegistry is where the package level New* functions register
+// metrics, and what Handler serves
+var DefaultRegistry = NewRegistry()
+
+// Metrics are registered once, when a package is initialized, so a clash is
+// a programming error rather than something to handle
+func (registry *Registry) register(name string, metric family) {
+    registry.mutex.Lock()
+    defer registry.mutex.Unlock()
+    if _, exists := registry.families[name]; exists {
+        panic(fmt.Sprintf("Metric %s registered twice", name))
+    }
+    registry.families[name] = metric
+}
+
+// OnScrape adds fn to be called before every scrape, for metrics (e.g. gauges
+// of db pool stats) that are cheaper to read when asked for than to keep up
+// to date
+func (registry *Registry) OnScrape(fn func()) {
+    registry.mutex.Lock()
+    defer registry.mutex.Unlock()
+    registry.onScrape = append(registry.onScrape, fn)
+}
+
+// Write writes every metric, sorted by name
+func (registry *Registry) Write(out io.Writer) error {
+    registry.mutex.Lock()
+    onScrape := append([]func(){}, registry.onScrape...)
+    names := make([]string, 0, len(registry.families))
+    for name := range registry.families {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    families := make([]family, 0, len(names))
+    for _, name := range names {
+        families = append(families, registry.families[name])
+    }
+    registry.mutex.Unlock()
+
+    for _, fn := range onScrape {
+        fn()
+    }
+
+    writer := bufio.NewWriter(out)
+    for _, metric := range families {
+        metric.write(writer)
+    }
+    return writer.Flush()
+}
+
+// Handler serves the default registry, for mounting on /metrics
+func Handler() http.Handler {
+    return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+        if req.Method != http.MethodGet {
+            resp.WriteHeader(http.StatusMethodNotAllowed)
+            return
+        }
+        resp.Header().Set("Content-Type", CONTENT_TYPE)
+        err := DefaultRegistry.Write(resp)
+        if err != nil {
+            logger.Warn("Error writing metrics", "err", err)
+        }
+    })
+}
+
+func OnScrape(fn func()) {
+    DefaultRegistry.OnScrape(fn)
+}
+
+// What's common to every kind of metric: its name and help, and a child for
+// each combination of label values it's been used with
+type vec struct {
+    name string
+    help string
+    metricType string
+    labelNames []string
+
+    mutex sync.Mutex
+    children map[string]interface{}
+    labelValues map[string][]string
+}
+
+func newVec(name string, help string, metricType string, labelNames []string) vec {
+    return vec{
+        name: name,
+        help: help,
+        metricType: metricType,
+        labelNames: labelNames,
+        children: make(map[string]interface{}),
+        labelValues: make(map[string][]string)}
+}
+
+// child returns the metric for labelValues, creating it with create if it
+// hasn't been used yet
+func (v *vec) child(labelValues []string, create func() interface{}) interface{} {
+    if len(labelValues) != len(v.labelNames) {
+        panic(fmt.Sprintf("Metric %s has %d labels, got %d values",
+            v.name, len(v.labelNames), len(labelValues)))
+    }
+    key := strings.Join(labelValues, "\xff")
+
+    v.mutex.Lock()
+    defer v.mutex.Unlock()
+    if child, ok := v.children[key]; ok {
+        return child
+    }
+    child := create()
+    v.children[key] = child
+    v.labelValues[key] = append([]string(nil), labelValues...)
+    return child
+}
+
+// each calls fn with the labels of every child, in a stable order
+func (v *vec) each(fn func(labels string, child interface{})) {
+    v.mutex.Lock()
+    keys := make([]string, 0, len(v.children))
+    for key := range v.children {
+        keys = append(keys, key)
+    }
+    children := make(map[string]interface{}, len(v.children))
+    labels := make(map[string]string, len(v.children))
+    for _, key := range keys {
+        children[key] = v.children[key]
+        labels[key] = formatLabels(v.labelNames, v.labelValues[key])
+    }
+    v.mutex.Unlock()
+
+    sort.Strings(keys)
+    for _, key := range keys {
+        fn(labels[key], children[key])
+    }
+}
+
+func (v *vec) writeHeader(writer *bufio.Writer) {
+    fmt.Fprintf(writer, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+    fmt.Fprintf(writer, "# TYPE %s %s\n", v.name, v.metricType)
+}
+
+// A single float value, shared by counters and gauges
+type value struct {
+    mutex sync.Mutex
+    value float64
+}
+
+func (v *value) add(delta float64) {
+    v.mutex.Lock()
+    defer v.mutex.Unlock()
+    v.value += delta
+}
+
+func (v *value) set(newValue float64) {
+    v.mutex.Lock()
+    defer v.mutex.Unlock()
+    v.value = newValue
+}
+
+func (v *value) get() float64 {
+    v.mutex.Lock()
+    defer v.mutex.Unlock()
+    return v.value
+}
+
+// Counter only ever goes up, until the process restarts
+type Counter struct {
+    value
+}
+
+func (counter *Counter) Inc() {
+    counter.add(1)
+}
+
+// Add adds delta, which mustn't be negative
+func (counter *Counter) Add(delta float64) {
+    if delta < 0 {
+        panic("Counters can't go down")
+    }
+    counter.add(delta)
+}
+
+type CounterVec struct {
+    vec
+}
+
+func (registry *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+    counterVec := &CounterVec{newVec(name, help, "counter", labelNames)}
+    registry.register(name, counterVec)
+    return counterVec
+}
+
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+    return DefaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+func NewCounter(name string, help string) *Counter {
+    return NewCounterVec(name, help).With()
+}
+
+// With returns the counter for labelValues, in the order the label names
+// were given
+func (counterVec *CounterVec) With(labelValues ...string) *Counter {
+    return counterVec.child(labelValues, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+func (counterVec *CounterVec) write(writer *bufio.Writer) {
+    counterVec.writeHeader(writer)
+    counterVec.each(func(labels string, child interface{}) {
+        writeSample(writer, counterVec.name, labels, child.(*Counter).get())
+    })
+}
+
+// Gauge can go up and down
+type Gauge struct {
+    value
+}
+
+func (gauge *Gauge) Set(value float64) {
+    gauge.set(value)
+}
+
+func (gauge *Gauge) Add(delta float64) {
+    gauge.add(delta)
+}
+
+func (gauge *Gauge) Inc() {
+    gauge.add(1)
+}
+
+func (gauge *Gauge) Dec() {
+    gauge.add(-1)
+}
+
+type GaugeVec struct {
+    vec
+}
+
+func (registry *Registry) NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+    gaugeVec := &GaugeVec{newVec(name, help, "gauge", labelNames)}
+    registry.register(name, gaugeVec)
+    return gaugeVec
+}
+
+func NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+    return DefaultRegistry.NewGaugeVec(name, help, labelNames...)
+}
+
+func NewGauge(name string, help string) *Gauge {
+    return NewGaugeVec(name, help).With()
+}
+
+func (gaugeVec *GaugeVec) With(labelValues ...string) *Gauge {
+    return gaugeVec.child(labelValues, func() interface{} { return &Gauge{} }).(*Gauge)
+}
+
+func (gaugeVec *GaugeVec) write(writer *bufio.Writer) {
+    gaugeVec.writeHeader(writer)
+    gaugeVec.each(func(labels string, child interface{}) {
+        writeSample(writer, gaugeVec.name, labels, child.(*Gauge).get())
+    })
+}
+
+// Histogram counts observations into buckets, by their upper bounds
+type Histogram struct {
+    mutex sync.Mutex
+    buckets []float64
+    counts []uint64
+    count uint64
+    sum float64
+}
+
+func (histogram *Histogram) Observe(value float64) {
+    histogram.mutex.Lock()
+    defer histogram.mutex.Unlock()
+    for idx, upperBound := range histogram.buckets {
+        if value <= upperBound {
+            histogram.counts[idx] += 1
+            break
+        }
+    }
+    histogram.count += 1
+    histogram.sum += value
+}
+
+type HistogramVec struct {
+    vec
+    buckets []float64
+}
+
+// NewHistogramVec makes a histogram with the given bucket upper bounds,
+// which must be in increasing order.  The +Inf bucket is added implicitly.
+func (registry *Registry) NewHistogramVec(name string,
+        help string,
+        buckets []float64,
+        labelNames ...string) *HistogramVec {
+    if !sort.Float64sAreSorted(buckets) {
+        panic(fmt.Sprintf("Buckets for %s aren't in increasing order", name))
+    }
+    histogramVec := &HistogramVec{newVec(name, help, "histogram", labelNames), buckets}
+    registry.register(name, histogramVec)
+    return histogramVec
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+    return DefaultRegistry.NewHistogramVec(name, help, buckets, labelNames...)
+}
+
+func NewHistogram(name string, help string, buckets []float64) *Histogram {
+    return NewHistogramVec(name, help, buckets).With()
+}
+
+func (histogramVec *HistogramVec) With(labelValues ...string) *Histogram {
+    return histogramVec.child(labelValues, func() interface{} {
+        return &Histogram{
+            buckets: histogramVec.buckets,
+            counts: make([]uint64, len(histogramVec.buckets))}
+    }).(*Histogram)
+}
+
+func (histogramVec *HistogramVec) write(writer *bufio.Writer) {
+    histogramVec.writeHeader(writer)
+    histogramVec.each(func(labels string, child interface{}) {
+        histogram := child.(*Histogram)
+        histogram.mutex.Lock()
+        counts := append([]uint64(nil), histogram.counts...)
+        count := histogram.count
+        sum := histogram.sum
+        histogram.mutex.Unlock()
+
+        // Buckets are cumulative when they're written out
+        cumulative := uint64(0)
+        for idx, upperBound := range histogramVec.buckets {
+            cumulative += counts[idx]
+            writeSample(writer, histogramVec.name + "_bucket",
+                withLabel(labels, "le", formatFloat(upperBound)), float64(cumulative))
+        }
+        writeSample(writer, histogramVec.name + "_bucket",
+            withLabel(labels, "le", "+Inf"), float64(count))
+        writeSample(writer, histogramVec.name + "_sum", labels, sum)
+        writeSample(writer, histogramVec.name + "_count", labels, float64(count))
+    })
+}
+
+func writeSample(writer *bufio.Writer, name string, labels string, value float64) {
+    writer.WriteString(name)
+    if labels != "" {
+        writer.WriteString("{" + labels + "}")
+    }
+    writer.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// formatLabels renders the labels as they go between the braces, e.g.
+// `type="search",status="ok"`
+func formatLabels(names []string, values []string) string {
+    labels := ""
+    for idx, name := range names {
+        labels = withLabel(labels, name, values[idx])
+    }
+    return labels
+}
+
+func withLabel(labels string, name string, value string) string {
+    label := name + "=\"" + escapeLabelValue(value) + "\""
+    if labels == "" {
+        return label
+    }
+    return labels + "," + label
+}
+
+var labelValueEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+var helpEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+func escapeLabelValue(value string) string {
+    return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+    return helpEscaper.Replace(help)
+}
+
+func formatFloat(value float64) string {
+    switch {
+    case math.IsInf(value, 1):
+        return "+Inf"
+    case math.IsInf(value, -1):
+        return "-Inf"
+    case math.IsNaN(value):
+        return "NaN"
+    }
+    return strconv.FormatFloat(value, 'g', -1, 64)
+}
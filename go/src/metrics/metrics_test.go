@@ -0,0 +1,62 @@
+package metrics
+
+import "bytes"
+import "testing"
+
+func TestWrite(t *testing.T) {
+    registry := NewRegistry()
+    requests := registry.NewCounterVec("test_requests_total", "Requests by type", "type")
+    connections := registry.NewGaugeVec("test_connections", "Open connections")
+    latency := registry.NewHistogramVec("test_latency_seconds", "Request latency", []float64{0.1, 1}, "type")
+
+    requests.With("search").Inc()
+    requests.With("search").Add(2)
+    requests.With("say \"hi\"\n").Inc()
+    connections.With().Set(5)
+    connections.With().Dec()
+    latency.With("search").Observe(0.05)
+    latency.With("search").Observe(0.5)
+    latency.With("search").Observe(3)
+
+    scrapes := 0
+    registry.OnScrape(func() { scrapes += 1 })
+
+    var out bytes.Buffer
+    err := registry.Write(&out)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    expected := `# HELP test_connections Open connections
+# TYPE test_connections gauge
+test_connections 4
+# HELP test_latency_seconds Request latency
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{type="search",le="0.1"} 1
+test_latency_seconds_bucket{type="search",le="1"} 2
+test_latency_seconds_bucket{type="search",le="+Inf"} 3
+test_latency_seconds_sum{type="search"} 3.55
+test_latency_seconds_count{type="search"} 3
+# HELP test_requests_total Requests by type
+# TYPE test_requests_total counter
+test_requests_total{type="say \"hi\"\n"} 1
+test_requests_total{type="search"} 3
+`
+    if out.String() != expected {
+        t.Errorf("Unexpected metrics output:\n%s\nexpected:\n%s", out.String(), expected)
+    }
+    if scrapes != 1 {
+        t.Errorf("Expected the scrape hook to be called once, was called %d times", scrapes)
+    }
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+    registry := NewRegistry()
+    registry.NewCounterVec("test_total", "A counter")
+    defer func() {
+        if recover() == nil {
+            t.Errorf("Expected registering the same name twice to panic")
+        }
+    }()
+    registry.NewGaugeVec("test_total", "A gauge")
+}
@@ -0,0 +1,135 @@
+package migrations
+
+// Migrations for the mtg_cards database.  Version 1 is the schema created by
+// setup-scripts/db/CardDBSetupScripts.sql; every change after that has to be
+// added here rather than to the setup script, so that existing databases
+// pick it up too.
+var cardDBMigrations = []Migration{
+    {
+        Version: 1,
+        Name: "baseline schema from CardDBSetupScripts.sql"},
+    {
+        Version: 2,
+        Name: "fix flavor_name collation",
+        Up: []string{
+            `ALTER TABLE all_cards
+            MODIFY flavor_name VARCHAR(100) NULL COLLATE utf8mb4_general_ci`},
+        Down: []string{
+            `ALTER TABLE all_cards
+            MODIFY flavor_name VARCHAR(100) NULL COLLATE utf8mb4_bin`}},
+    {
+        Version: 3,
+        Name: "add price_points table for the sql price store",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS price_points (
+                price_point_id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                measurement VARCHAR(50) NOT NULL,
+                tags VARCHAR(200) NOT NULL,
+                field VARCHAR(50) NOT NULL,
+                value DOUBLE NOT NULL,
+                point_time DATETIME(6) NOT NULL,
+                UNIQUE INDEX series_time_index (measurement, tags, field, point_time)
+            ) DEFAULT COLLATE utf8mb4_bin`},
+        Down: []string{
+            `DROP TABLE price_points`}},
+    {
+        Version: 4,
+        Name: "add import_runs and import_run_checkpoints tables",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS import_runs (
+                import_run_id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                mtgjson_version VARCHAR(50) NOT NULL,
+                status VARCHAR(20) NOT NULL,
+                started_at DATETIME NOT NULL,
+                finished_at DATETIME NULL,
+                INDEX mtgjson_version_index (mtgjson_version)
+            ) DEFAULT COLLATE utf8mb4_bin`,
+            `CREATE TABLE IF NOT EXISTS import_run_checkpoints (
+                import_run_id INT NOT NULL,
+                set_code VARCHAR(20) NOT NULL,
+                completed_at DATETIME NOT NULL,
+                PRIMARY KEY (import_run_id, set_code)
+            ) DEFAULT COLLATE utf8mb4_bin`},
+        Down: []string{
+            `DROP TABLE import_run_checkpoints`,
+            `DROP TABLE import_runs`}},
+    {
+        Version: 5,
+        Name: "add removal tombstones to cards, tokens and sets",
+        Up: []string{
+            `ALTER TABLE all_cards
+            ADD COLUMN removed_at DATETIME NULL,
+            ADD COLUMN replaced_by_uuid CHAR(36) NULL`,
+            `ALTER TABLE all_tokens
+            ADD COLUMN removed_at DATETIME NULL,
+            ADD COLUMN replaced_by_uuid CHAR(36) NULL`,
+            `ALTER TABLE sets
+            ADD COLUMN removed_at DATETIME NULL`},
+        Down: []string{
+            `ALTER TABLE sets
+            DROP COLUMN removed_at`,
+            `ALTER TABLE all_tokens
+            DROP COLUMN replaced_by_uuid,
+            DROP COLUMN removed_at`,
+            `ALTER TABLE all_cards
+            DROP COLUMN replaced_by_uuid,
+            DROP COLUMN removed_at`}},
+    {
+        Version: 6,
+        Name: "add card_changes table for card change history",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS card_changes (
+                card_change_id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                card_id INT NOT NULL,
+                uuid CHAR(36) NOT NULL,
+                mtgjson_version VARCHAR(50) NULL,
+                old_hash CHAR(32) NOT NULL,
+                new_hash CHAR(32) NOT NULL,
+                changes MEDIUMTEXT NOT NULL,
+                changed_at DATETIME NOT NULL,
+                INDEX uuid_changed_at_index (uuid, changed_at)
+            ) DEFAULT COLLATE utf8mb4_bin`},
+        Down: []string{
+            `DROP TABLE card_changes`}},
+    {
+        Version: 7,
+        Name: "add legality_changes table for the per format change feed",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS legality_changes (
+                legality_change_id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                card_id INT NOT NULL,
+                uuid CHAR(36) NOT NULL,
+                game_format VARCHAR(50) NOT NULL,
+                old_legality VARCHAR(50) NULL,
+                new_legality VARCHAR(50) NULL,
+                mtgjson_version VARCHAR(50) NULL,
+                changed_at DATETIME NOT NULL,
+                INDEX game_format_changed_at_index (game_format, changed_at),
+                INDEX changed_at_index (changed_at)
+            ) DEFAULT COLLATE utf8mb4_bin`},
+        Down: []string{
+            `DROP TABLE legality_changes`}},
+    {
+        Version: 8,
+        Name: "add import_filters table for persisted selective imports",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS import_filters (
+                import_filter_id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                filter TEXT NOT NULL,
+                created_at DATETIME NOT NULL
+            ) DEFAULT COLLATE utf8mb4_bin`},
+        Down: []string{
+            `DROP TABLE import_filters`}},
+    {
+        Version: 9,
+        Name: "add import_schedule table for persisted scheduled run times",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS import_schedule (
+                job VARCHAR(20) NOT NULL PRIMARY KEY,
+                schedule VARCHAR(100) NOT NULL,
+                next_run_at DATETIME NOT NULL,
+                updated_at DATETIME NOT NULL
+            ) DEFAULT COLLATE utf8mb4_bin`},
+        Down: []string{
+            `DROP TABLE import_schedule`}},
+}
@@ -0,0 +1,203 @@
+package migrations
+
+import "database/sql"
+import "dbconn"
+import "fmt"
+import "logging"
+import "time"
+
+var logger = logging.For("migrations")
+
+// A Migration moves a schema from Version - 1 to Version (Up), or back
+// again (Down).  The go mysql driver only runs a single statement per Exec,
+// so each step is given as a list of statements.
+type Migration struct {
+    Version int
+    Name string
+    Up []string
+    Down []string
+}
+
+// SchemaOutOfDateError is returned by CheckSchema when the database isn't at
+// the version this build expects, so that callers can refuse to start
+type SchemaOutOfDateError struct {
+    Database string
+    CurrentVersion int
+    ExpectedVersion int
+}
+
+func (err *SchemaOutOfDateError) Error() string {
+    if err.CurrentVersion > err.ExpectedVersion {
+        return fmt.Sprintf("%s schema is at version %d, which is newer than the latest known version %d",
+            err.Database, err.CurrentVersion, err.ExpectedVersion)
+    }
+    return fmt.Sprintf("%s schema is at version %d but version %d is required, run db-migrate",
+        err.Database, err.CurrentVersion, err.ExpectedVersion)
+}
+
+// Validate makes sure a migration list is numbered 1, 2, 3, ... with no
+// gaps, since the versions are what get recorded in the version table
+func Validate(migrations []Migration) error {
+    for idx, migration := range migrations {
+        if migration.Version != idx + 1 {
+            return fmt.Errorf("Migration %q has version %d, expected %d",
+                migration.Name, migration.Version, idx + 1)
+        }
+        if migration.Name == "" {
+            return fmt.Errorf("Migration %d has no name", migration.Version)
+        }
+    }
+    return nil
+}
+
+func LatestVersion(migrations []Migration) int {
+    if len(migrations) == 0 {
+        return 0
+    }
+    return migrations[len(migrations) - 1].Version
+}
+
+func ensureVersionTable(db *sql.DB, schema *Schema) error {
+    _, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + schema.VersionTable + ` (
+        version INT NOT NULL PRIMARY KEY,
+        name VARCHAR(200) NOT NULL,
+        applied_at DATETIME NOT NULL)`)
+    return err
+}
+
+func readVersion(db *sql.DB, schema *Schema) (int, error) {
+    var version sql.NullInt64
+    err := db.QueryRow(`SELECT MAX(version) FROM ` + schema.VersionTable).Scan(&version)
+    if err != nil {
+        return 0, err
+    }
+
+    return int(version.Int64), nil
+}
+
+// CurrentVersion returns the newest migration of schema that's been applied
+// to db, or 0 if it has never been migrated
+func CurrentVersion(db *sql.DB, schema *Schema) (int, error) {
+    err := ensureVersionTable(db, schema)
+    if err != nil {
+        return 0, err
+    }
+
+    return readVersion(db, schema)
+}
+
+// CheckSchema returns a *SchemaOutOfDateError if db isn't exactly at the
+// latest version of schema.  It only reads the version table, it never
+// changes the database.  Any other error (e.g. the db being unreachable) is
+// returned as is.
+func CheckSchema(db *sql.DB, schema *Schema) error {
+    currentVersion, err := readVersion(db, schema)
+    if dbconn.IsMissingTableError(err) {
+        // The database has never been migrated
+        logger.Warn("No schema version table", "schema", schema.Name)
+    } else if err != nil {
+        return err
+    }
+
+    latestVersion := schema.LatestVersion()
+    if currentVersion != latestVersion {
+        return &SchemaOutOfDateError{
+            Database: schema.Name,
+            CurrentVersion: currentVersion,
+            ExpectedVersion: latestVersion}
+    }
+
+    return nil
+}
+
+// Migrate applies Up migrations until db is at targetVersion, or Down
+// migrations if it's currently past it.  Each migration is recorded in the
+// schema's version table as soon as it finishes, so a failure part way
+// through leaves the database at the last migration that succeeded.
+func Migrate(db *sql.DB, driver string, schema *Schema, targetVersion int) error {
+    migrations, err := schema.Migrations(driver)
+    if err != nil {
+        return err
+    }
+
+    err = Validate(migrations)
+    if err != nil {
+        return err
+    }
+
+    if targetVersion < 0 || targetVersion > LatestVersion(migrations) {
+        return fmt.Errorf("Unknown target version %d, latest is %d",
+            targetVersion, LatestVersion(migrations))
+    }
+
+    currentVersion, err := CurrentVersion(db, schema)
+    if err != nil {
+        return err
+    }
+    if currentVersion > LatestVersion(migrations) {
+        return fmt.Errorf("Database is at version %d, which is newer than the latest known version %d",
+            currentVersion, LatestVersion(migrations))
+    }
+
+    for currentVersion < targetVersion {
+        migration := migrations[currentVersion]
+        logger.Info("Applying migration", "schema", schema.Name, "version", migration.Version,
+            "name", migration.Name)
+        err = runMigrationStep(db, migration.Up)
+        if err != nil {
+            return fmt.Errorf("Error applying migration %d (%s): %s",
+                migration.Version, migration.Name, err)
+        }
+
+        _, err = db.Exec(`INSERT INTO ` + schema.VersionTable + `
+            (version, name, applied_at)
+            VALUES
+            (?, ?, ?)`,
+            migration.Version, migration.Name, time.Now().UTC())
+        if err != nil {
+            return err
+        }
+        currentVersion = migration.Version
+    }
+
+    for currentVersion > targetVersion {
+        migration := migrations[currentVersion - 1]
+        logger.Info("Reverting migration", "schema", schema.Name, "version", migration.Version,
+            "name", migration.Name)
+        err = runMigrationStep(db, migration.Down)
+        if err != nil {
+            return fmt.Errorf("Error reverting migration %d (%s): %s",
+                migration.Version, migration.Name, err)
+        }
+
+        _, err = db.Exec(`DELETE FROM ` + schema.VersionTable + ` WHERE version = ?`,
+            migration.Version)
+        if err != nil {
+            return err
+        }
+        currentVersion = migration.Version - 1
+    }
+
+    return nil
+}
+
+// MariaDB commits implicitly after most DDL statements, so the transaction
+// here only really protects data changes (SQLite does roll back DDL).
+// Migrations that mix DDL and data should be split up so that each one can
+// be re-run safely.
+func runMigrationStep(db *sql.DB, statements []string) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+
+    for _, statement := range statements {
+        _, err = tx.Exec(statement)
+        if err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+
+    return tx.Commit()
+}
@@ -0,0 +1,88 @@
+package migrations
+
+import "config"
+import "dbconn"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+
+func TestRegisteredMigrationsAreValid(t *testing.T) {
+    for _, schema := range []*Schema{&CardDBSchema, &UsersDBSchema} {
+        for _, driver := range []string{config.MYSQL_DRIVER, config.SQLITE_DRIVER} {
+            migrations, err := schema.Migrations(driver)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            err = Validate(migrations)
+            if err != nil {
+                t.Errorf("%s %s migrations: %s", driver, schema.Name, err)
+            }
+
+            // Every driver has to agree on the latest version, since that's
+            // what CheckSchema compares against
+            if LatestVersion(migrations) != schema.LatestVersion() {
+                t.Errorf("%s %s migrations end at version %d, expected %d",
+                    driver, schema.Name, LatestVersion(migrations), schema.LatestVersion())
+            }
+        }
+    }
+}
+
+func TestValidateRejectsGaps(t *testing.T) {
+    migrations := []Migration{
+        {Version: 1, Name: "first"},
+        {Version: 3, Name: "third"}}
+
+    if err := Validate(migrations); err == nil {
+        t.Errorf("Expected an error for a missing version 2")
+    }
+}
+
+func TestLatestVersion(t *testing.T) {
+    if version := LatestVersion(nil); version != 0 {
+        t.Errorf("Expected version 0 for no migrations, got %d", version)
+    }
+    if version := LatestVersion(cardDBMigrations); version != len(cardDBMigrations) {
+        t.Errorf("Expected version %d, got %d", len(cardDBMigrations), version)
+    }
+}
+
+func TestCheckSchema(t *testing.T) {
+    dir, err := ioutil.TempDir("", "migrations")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    dbConfig := config.DatabaseConfig{
+        Driver: config.SQLITE_DRIVER,
+        Path: filepath.Join(dir, "cards.db")}
+    db, err := dbconn.Open(dbConfig)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer db.Close()
+
+    // A db that's never been migrated is out of date, not broken
+    err = CheckSchema(db, &CardDBSchema)
+    if outOfDate, ok := err.(*SchemaOutOfDateError); !ok || outOfDate.CurrentVersion != 0 {
+        t.Errorf("Expected a SchemaOutOfDateError at version 0, got %v", err)
+    }
+
+    err = Migrate(db, dbConfig.Driver, &CardDBSchema, CardDBSchema.LatestVersion())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err = CheckSchema(db, &CardDBSchema); err != nil {
+        t.Errorf("Expected a migrated db to pass, got %v", err)
+    }
+
+    // Anything else, e.g. not being able to reach the db at all, mustn't be
+    // reported as needing a migration
+    db.Close()
+    err = CheckSchema(db, &CardDBSchema)
+    if _, ok := err.(*SchemaOutOfDateError); ok || err == nil {
+        t.Errorf("Expected the connection error, got %v", err)
+    }
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import "config"
+import "fmt"
+
+// A Schema is one logical database (cards or users), with a migration list
+// for each SQL dialect it can be stored in.  The two schemas keep their
+// versions in different tables, so that a single SQLite file can hold both.
+type Schema struct {
+    Name string
+    VersionTable string
+    mysqlMigrations []Migration
+    sqliteMigrations []Migration
+}
+
+var CardDBSchema = Schema{
+    Name: "cards",
+    VersionTable: "schema_version",
+    mysqlMigrations: cardDBMigrations,
+    sqliteMigrations: sqliteCardDBMigrations}
+
+var UsersDBSchema = Schema{
+    Name: "users",
+    VersionTable: "users_schema_version",
+    mysqlMigrations: usersDBMigrations,
+    sqliteMigrations: sqliteUsersDBMigrations}
+
+// Migrations returns the migration list for the given database driver
+func (schema *Schema) Migrations(driver string) ([]Migration, error) {
+    switch driver {
+    case config.MYSQL_DRIVER:
+        return schema.mysqlMigrations, nil
+    case config.SQLITE_DRIVER:
+        return schema.sqliteMigrations, nil
+    default:
+        return nil, fmt.Errorf("No %s migrations for database driver %q", schema.Name, driver)
+    }
+}
+
+// LatestVersion is the version every build of the tools expects the schema
+// to be at.  It's the same for every driver.
+func (schema *Schema) LatestVersion() int {
+    return LatestVersion(schema.mysqlMigrations)
+}
@@ -0,0 +1,563 @@
+package migrations
+
+// SQLite versions of the card and users migrations, used for single-machine
+// and test deployments.  There's no setup script for SQLite, so version 1
+// creates the whole baseline schema itself.  Each list must have the same
+// versions as its MySQL counterpart, so that a given build expects the same
+// schema version no matter which database it's pointed at.
+
+var sqliteCardDBMigrations = []Migration{
+    {
+        Version: 1,
+        Name: "baseline schema from CardDBSetupScripts.sql",
+        Up: []string{
+            `CREATE TABLE all_cards (
+                card_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                uuid CHAR(36) NOT NULL,
+                card_hash CHAR(32) NOT NULL,
+                artist VARCHAR(100) NOT NULL COLLATE NOCASE,
+                ascii_name VARCHAR(150) NULL COLLATE NOCASE,
+                border_color VARCHAR(30) NOT NULL COLLATE NOCASE,
+                card_number VARCHAR(20) NOT NULL COLLATE NOCASE,
+                card_power VARCHAR(10) NOT NULL COLLATE NOCASE,
+                card_type VARCHAR(100) NOT NULL COLLATE NOCASE,
+                color_identity TEXT NULL,
+                color_indicator TEXT NULL,
+                colors TEXT NULL,
+                converted_mana_cost FLOAT NOT NULL,
+                duel_deck CHAR(1) NULL COLLATE NOCASE,
+                edhrec_rank INTEGER NULL,
+                face_converted_mana_cost FLOAT NOT NULL,
+                flavor_name VARCHAR(100) NULL COLLATE NOCASE,
+                flavor_text VARCHAR(2000) NULL COLLATE NOCASE,
+                frame_version VARCHAR(20) NOT NULL COLLATE NOCASE,
+                hand VARCHAR(10) NULL COLLATE NOCASE,
+                has_foil BOOLEAN NOT NULL DEFAULT FALSE,
+                has_non_foil BOOLEAN NOT NULL DEFAULT FALSE,
+                is_alternative BOOLEAN NOT NULL DEFAULT FALSE,
+                is_arena BOOLEAN NOT NULL DEFAULT FALSE,
+                is_buy_a_box BOOLEAN NOT NULL DEFAULT FALSE,
+                is_date_stamped BOOLEAN NOT NULL DEFAULT FALSE,
+                is_full_art BOOLEAN NOT NULL DEFAULT FALSE,
+                is_mtgo BOOLEAN NOT NULL DEFAULT FALSE,
+                is_online_only BOOLEAN NOT NULL DEFAULT FALSE,
+                is_oversized BOOLEAN NOT NULL DEFAULT FALSE,
+                is_paper BOOLEAN NOT NULL DEFAULT FALSE,
+                is_promo BOOLEAN NOT NULL DEFAULT FALSE,
+                is_reprint BOOLEAN NOT NULL DEFAULT FALSE,
+                is_reserved BOOLEAN NOT NULL,
+                is_starter BOOLEAN NOT NULL DEFAULT FALSE,
+                is_story_spotlight BOOLEAN NOT NULL DEFAULT FALSE,
+                is_textless BOOLEAN NOT NULL DEFAULT FALSE,
+                is_timeshifted BOOLEAN NOT NULL DEFAULT FALSE,
+                layout VARCHAR(25) NOT NULL COLLATE NOCASE,
+                life VARCHAR(10) NULL COLLATE NOCASE,
+                loyalty VARCHAR(20) NULL COLLATE NOCASE,
+                mana_cost VARCHAR(100) NOT NULL COLLATE NOCASE,
+                mcm_id INT NOT NULL,
+                mcm_meta_id INT NOT NULL,
+                mtg_arena_id INT NULL,
+                mtgo_foil_id INT NULL,
+                mtgo_id INT NULL,
+                mtgstocks_id INTEGER NOT NULL,
+                multiverse_id INT NOT NULL,
+                name VARCHAR(500) NULL COLLATE NOCASE,
+                original_text VARCHAR(1500) NOT NULL COLLATE NOCASE,
+                original_type VARCHAR(100) NOT NULL COLLATE NOCASE,
+                rarity VARCHAR(20) NOT NULL COLLATE NOCASE,
+                scryfall_id CHAR(36) NOT NULL,
+                scryfall_illustration_id CHAR(36) NULL,
+                scryfall_oracle_id CHAR(36) NOT NULL,
+                set_id INT NOT NULL,
+                side CHAR(1) NULL,
+                tcgplayer_product_id INT NOT NULL,
+                text VARCHAR(1500) NOT NULL COLLATE NOCASE,
+                toughness VARCHAR(10) NOT NULL COLLATE NOCASE,
+                watermark VARCHAR(50) NOT NULL COLLATE NOCASE,
+                image_cached_locally BOOLEAN NOT NULL DEFAULT FALSE)`,
+            `CREATE UNIQUE INDEX all_cards_uuid_index ON all_cards (uuid)`,
+            `CREATE INDEX all_cards_card_hash_index ON all_cards (card_hash)`,
+            `CREATE INDEX all_cards_name_index ON all_cards (name)`,
+            `CREATE TABLE all_tokens (
+                token_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                uuid CHAR(36) NOT NULL,
+                token_hash CHAR(32) NOT NULL,
+                artist VARCHAR(100) NOT NULL COLLATE NOCASE,
+                border_color VARCHAR(30) NOT NULL COLLATE NOCASE,
+                card_number VARCHAR(20) NOT NULL COLLATE NOCASE,
+                card_power VARCHAR(10) NOT NULL COLLATE NOCASE,
+                card_type VARCHAR(100) NOT NULL COLLATE NOCASE,
+                color_identity TEXT NULL,
+                color_indicator TEXT NULL,
+                colors TEXT NULL,
+                is_online_only BOOLEAN NOT NULL DEFAULT FALSE,
+                layout VARCHAR(25) NOT NULL COLLATE NOCASE,
+                loyalty VARCHAR(20) NULL COLLATE NOCASE,
+                name VARCHAR(500) NULL COLLATE NOCASE,
+                scryfall_id CHAR(36) NOT NULL,
+                scryfall_illustration_id CHAR(36) NULL,
+                scryfall_oracle_id CHAR(36) NOT NULL,
+                set_id INT NOT NULL,
+                side CHAR(1) NULL,
+                text VARCHAR(1500) NOT NULL COLLATE NOCASE,
+                toughness VARCHAR(10) NOT NULL COLLATE NOCASE,
+                watermark VARCHAR(50) NOT NULL COLLATE NOCASE,
+                image_cached_locally BOOLEAN NOT NULL DEFAULT FALSE)`,
+            `CREATE UNIQUE INDEX all_tokens_uuid_index ON all_tokens (uuid)`,
+            `CREATE INDEX all_tokens_token_hash_index ON all_tokens (token_hash)`,
+            `CREATE INDEX all_tokens_name_index ON all_tokens (name)`,
+            `CREATE TABLE frame_effect_options (
+                frame_effect_option_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                frame_effect_option VARCHAR(50) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO frame_effect_options
+                (frame_effect_option)
+                VALUES
+                ('legendary'), ('nyxtouched'), ('sunmoondfc'), ('extendedart'), ('devoid'),
+                ('tombstone'), ('compasslanddfc'), ('showcase'), ('colorshifted'), ('originpwdfc'),
+                ('mooneldrazidfc'), ('inverted'), ('draft'), ('miracle'), ('nyxborn'),
+                ('waxingandwaningmoondfc')`,
+            `CREATE TABLE frame_effects (
+                frame_effect_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                frame_effect_option_id INT NOT NULL)`,
+            `CREATE TABLE variations (
+                variation_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                variation_uuid CHAR(36) NOT NULL)`,
+            `CREATE TABLE other_faces (
+                other_face_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                other_face_uuid CHAR(36) NOT NULL)`,
+            `CREATE TABLE alternate_language_data (
+                alt_lang_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                flavor_text VARCHAR(1000) NOT NULL COLLATE NOCASE,
+                language VARCHAR(50) NOT NULL COLLATE NOCASE,
+                multiverse_id INTEGER NOT NULL,
+                name VARCHAR(300) NOT NULL COLLATE NOCASE,
+                text VARCHAR(2000) NOT NULL COLLATE NOCASE,
+                card_type VARCHAR(200) NOT NULL COLLATE NOCASE)`,
+            `CREATE TABLE leadership_skills (
+                leadership_skill_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                leadership_format_id INT NOT NULL,
+                leader_legal BOOLEAN NOT NULL)`,
+            `CREATE TABLE leadership_formats (
+                leadership_format_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                leadership_format_name VARCHAR(30) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO leadership_formats
+                (leadership_format_name)
+                VALUES
+                ('brawl'), ('commander'), ('oathbreaker')`,
+            `CREATE TABLE legalities (
+                legality_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                game_format_id INT NOT NULL,
+                legality_option_id INT NOT NULL)`,
+            `CREATE TABLE game_formats (
+                game_format_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                game_format_name VARCHAR(30) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO game_formats
+                (game_format_name)
+                VALUES
+                ('brawl'), ('commander'), ('duel'), ('future'), ('frontier'), ('legacy'), ('modern'),
+                ('pauper'), ('penny'), ('pioneer'), ('standard'), ('vintage'), ('historic'),
+                ('oldschool')`,
+            `CREATE TABLE legality_options (
+                legality_option_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                legality_option_name VARCHAR(30) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO legality_options
+                (legality_option_name)
+                VALUES
+                ('Legal'), ('Not Legal'), ('Restricted'), ('Banned')`,
+            `CREATE TABLE base_type_options (
+                base_type_option_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                base_type_option VARCHAR(50) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO base_type_options
+                (base_type_option)
+                VALUES
+                ('Artifact'), ('Autobot'), ('Card'), ('Character'), ('Conspiracy'), ('Creature'),
+                ('Eaturecray'), ('Elemental'), ('Elite'), ('Emblem'), ('Enchantment'), ('Ever'),
+                ('Hero'), ('Instant'), ('Land'), ('Phenomenon'), ('Plane'), ('Planeswalker'),
+                ('Scariest'), ('Scheme'), ('See'), ('Sorcery'), ('Specter'), ('Summon'),
+                ('Token'), ('Tribal'), ('Vanguard'), ('Wolf'), ('You’ll'), ('instant')`,
+            `CREATE TABLE card_base_types (
+                base_type_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                base_type_option_id INT NOT NULL)`,
+            `CREATE TABLE token_base_types (
+                base_type_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                token_id INT NOT NULL,
+                base_type_option_id INT NOT NULL)`,
+            `CREATE TABLE sets (
+                set_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                set_hash CHAR(32) NOT NULL,
+                base_size INT NOT NULL,
+                block_name VARCHAR(50) NULL COLLATE NOCASE,
+                code VARCHAR(20) NOT NULL COLLATE NOCASE,
+                is_foreign_only BOOLEAN NOT NULL DEFAULT FALSE,
+                is_foil_only BOOLEAN NOT NULL DEFAULT FALSE,
+                is_online_only BOOLEAN NOT NULL DEFAULT FALSE,
+                is_partial_preview BOOLEAN NOT NULL DEFAULT FALSE,
+                keyrune_code VARCHAR(30) NOT NULL COLLATE NOCASE,
+                mcm_name VARCHAR(100) NOT NULL COLLATE NOCASE,
+                mcm_id INT NOT NULL,
+                mtgo_code VARCHAR(20) NOT NULL COLLATE NOCASE,
+                name VARCHAR(100) NOT NULL COLLATE NOCASE,
+                parent_code VARCHAR(20) NOT NULL COLLATE NOCASE,
+                release_date DATE NOT NULL,
+                tcgplayer_group_id INT NOT NULL,
+                total_set_size INT NOT NULL,
+                set_type VARCHAR(50) NOT NULL COLLATE NOCASE)`,
+            `CREATE INDEX sets_set_hash_index ON sets (set_hash)`,
+            `CREATE UNIQUE INDEX sets_code_index ON sets (code)`,
+            `CREATE TABLE card_printings (
+                card_printing_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                set_code VARCHAR(20) NOT NULL COLLATE NOCASE)`,
+            `CREATE TABLE purchase_sites (
+                purchase_site_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                purchase_site_name VARCHAR(30) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO purchase_sites
+                (purchase_site_name)
+                VALUES
+                ('cardmarket'), ('tcgplayer'), ('mtgstocks')`,
+            `CREATE TABLE purchase_urls (
+                purchase_url_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                purchase_site_id INT NOT NULL,
+                purchase_url VARCHAR(100) NOT NULL COLLATE NOCASE)`,
+            `CREATE TABLE rulings (
+                ruling_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                ruling_date DATE NOT NULL,
+                ruling_text VARCHAR(3000) NOT NULL COLLATE NOCASE)`,
+            `CREATE TABLE card_subtype_options (
+                subtype_option_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                subtype_option VARCHAR(50) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO card_subtype_options
+                (subtype_option)
+                VALUES
+                ('Abian'), ('Adventure'), ('Advisor'), ('Aetherborn'), ('Ajani'), ('Alara'),
+                ('Alicorn'), ('Alien'), ('Ally'), ('Aminatou'), ('Angel'), ('Angrath'),
+                ('Antelope'), ('Ape'), ('Arcane'), ('Archer'), ('Archon'), ('Arkhos'),
+                ('Arlinn'), ('Army'), ('Artificer'), ('Ashiok'), ('Assassin'), ('Assembly-Worker'),
+                ('Atog'), ('Aura'), ('Aurochs'), ('Autobot'), ('Avatar'), ('Azgol'),
+                ('Azra'), ('Baddest,'), ('Badger'), ('Barbarian'), ('Basilisk'), ('Bat'),
+                ('Bear'), ('Beast'), ('Beaver'), ('Beeble'), ('Beholder'), ('Belenon'),
+                ('Berserker'), ('Biggest,'), ('Bird'), ('Boar'), ('Bolas'), ('Bolas’s Meditation Realm'),
+                ('Bot'), ('Brainiac'), ('Bringer'), ('Brushwagg'), ('Bureaucrat'), ('Calix'),
+                ('Camel'), ('Carrier'), ('Cartouche'), ('Cat'), ('Centaur'), ('Cephalid'),
+                ('Chandra'), ('Chicken'), ('Child'), ('Chimera'), ('Citizen'), ('Clamfolk'),
+                ('Cleric'), ('Cloud'), ('Clue'), ('Cockatrice'), ('Construct'), ('Contraption'),
+                ('Cow'), ('Crab'), ('Crocodile'), ('Curse'), ('Cyborg'), ('Cyclops'),
+                ('Dack'), ('Daretti'), ('Dauthi'), ('Davriel'), ('Deer'), ('Demigod'),
+                ('Demon'), ('Desert'), ('Designer'), ('Devil'), ('Dinosaur'), ('Djinn'),
+                ('Dog'), ('Dominaria'), ('Domri'), ('Donkey'), ('Dovin'), ('Dragon'),
+                ('Drake'), ('Dreadnought'), ('Drone'), ('Druid'), ('Dryad'), ('Duck'),
+                ('Dungeon'), ('Dwarf'), ('Efreet'), ('Egg'), ('Elder'), ('Eldrazi'),
+                ('Elemental'), ('Elemental?'), ('Elephant'), ('Elf'), ('Elk'), ('Elspeth'),
+                ('Elves'), ('Equilor'), ('Equipment'), ('Ergamon'), ('Estrid'), ('Etiquette'),
+                ('Eye'), ('Fabacin'), ('Faerie'), ('Ferret'), ('Fire'), ('Fish'),
+                ('Flagbearer'), ('Food'), ('Forest'), ('Fortification'), ('Fox'), ('Freyalise'),
+                ('Frog'), ('Fungus'), ('Gamer'), ('Gargoyle'), ('Garruk'), ('Gate'),
+                ('Germ'), ('Giant'), ('Gideon'), ('Gnome'), ('Goat'), ('Goblin'),
+                ('God'), ('Golem'), ('Gorgon'), ('Gremlin'), ('Griffin'), ('Gus'),
+                ('Hag'), ('Harpy'), ('Hatificer'), ('Head'), ('Hellion'), ('Hero'),
+                ('Hippo'), ('Hippogriff'), ('Homarid'), ('Homunculus'), ('Horror'), ('Horse'),
+                ('Hound'), ('Huatli'), ('Human'), ('Hydra'), ('Hyena'), ('Igpay'),
+                ('Illusion'), ('Imp'), ('Incarnation'), ('Innistrad'), ('Insect'), ('Inzerva'),
+                ('Iquatana'), ('Ir'), ('Island'), ('Jace'), ('Jackal'), ('Jaya'),
+                ('Jellyfish'), ('Juggernaut'), ('Kaldheim'), ('Kamigawa'), ('Kangaroo'), ('Karn'),
+                ('Karsus'), ('Kasmina'), ('Kavu'), ('Kaya'), ('Kephalai'), ('Key'),
+                ('Killbot'), ('Kinshala'), ('Kiora'), ('Kirin'), ('Kithkin'), ('Knight'),
+                ('Kobold'), ('Kolbahan'), ('Kor'), ('Koth'), ('Kraken'), ('Kyneth'),
+                ('Lady'), ('Lair'), ('Lamia'), ('Lammasu'), ('Leech'), ('Legend'),
+                ('Leviathan'), ('Lhurgoyf'), ('Licid'), ('Liliana'), ('Lizard'), ('Lobster'),
+                ('Locus'), ('Lorwyn'), ('Luvion'), ('Mammoth'), ('Manticore'), ('Master'),
+                ('Masticore'), ('Mercadia'), ('Mercenary'), ('Merfolk'), ('Metathran'), ('Mime'),
+                ('Mine'), ('Minion'), ('Minotaur'), ('Mirrodin'), ('Moag'), ('Mode'),
+                ('Mole'), ('Monger'), ('Mongoose'), ('Mongseng'), ('Monk'), ('Monkey'),
+                ('Moonfolk'), ('Mountain'), ('Mouse'), ('Mummy'), ('Muraganda'), ('Mutant'),
+                ('Myr'), ('Mystic'), ('Naga'), ('Nahiri'), ('Narset'), ('Nastiest,'),
+                ('Nautilus'), ('Nephilim'), ('New Phyrexia'), ('Nightmare'), ('Nightstalker'), ('Ninja'),
+                ('Nissa'), ('Nixilis'), ('Noble'), ('Noggle'), ('Nomad'), ('Nymph'),
+                ('Octopus'), ('Ogre'), ('Oko'), ('Ooze'), ('Orc'), ('Orgg'),
+                ('Ouphe'), ('Ox'), ('Oyster'), ('Pangolin'), ('Paratrooper'), ('Peasant'),
+                ('Pegasus'), ('Penguin'), ('Pentavite'), ('Pest'), ('Phelddagrif'), ('Phoenix'),
+                ('Phyrexia'), ('Phyrexian'), ('Pilot'), ('Pirate'), ('Plains'), ('Plant'),
+                ('Power-Plant'), ('Praetor'), ('Processor'), ('Proper'), ('Pyrulea'), ('Rabbit'),
+                ('Rabiah'), ('Raccoon'), ('Ral'), ('Rat'), ('Rath'), ('Ravnica'),
+                ('Rebel'), ('Reflection'), ('Regatha'), ('Reveler'), ('Rhino'), ('Rigger'),
+                ('Rogue'), ('Rowan'), ('Rukh'), ('Sable'), ('Saga'), ('Saheeli'),
+                ('Salamander'), ('Samurai'), ('Samut'), ('Saproling'), ('Sarkhan'), ('Satyr'),
+                ('Scarecrow'), ('Scientist'), ('Scion'), ('Scorpion'), ('Scout'), ('Sculpture'),
+                ('Segovia'), ('Serf'), ('Serpent'), ('Serra'), ('Serra’s Realm'), ('Servo'),
+                ('Shade'), ('Shadowmoor'), ('Shaman'), ('Shandalar'), ('Shapeshifter'), ('Sheep'),
+                ('Ship'), ('Shrine'), ('Siren'), ('Skeleton'), ('Slith'), ('Sliver'),
+                ('Slug'), ('Snake'), ('Soldier'), ('Soltari'), ('Sorin'), ('Spawn'),
+                ('Specter'), ('Spellshaper'), ('Sphinx'), ('Spider'), ('Spike'), ('Spirit'),
+                ('Sponge'), ('Spy'), ('Squid'), ('Squirrel'), ('Starfish'), ('Surrakar'),
+                ('Survivor'), ('Swamp'), ('Tamiyo'), ('Teferi'), ('Tentacle'), ('Teyo'),
+                ('Tezzeret'), ('Thalakos'), ('The'), ('Thopter'), ('Thrull'), ('Tibalt'),
+                ('Tower'), ('Townsfolk'), ('Trap'), ('Treasure'), ('Treefolk'), ('Trilobite'),
+                ('Triskelavite'), ('Troll'), ('Turtle'), ('Ugin'), ('Ulgrotha'), ('Unicorn'),
+                ('Urza'), ('Urza’s'), ('Valla'), ('Vampire'), ('Vampyre'), ('Vedalken'),
+                ('Vehicle'), ('Venser'), ('Viashino'), ('Villain'), ('Vivien'), ('Volver'),
+                ('Vraska'), ('Vryn'), ('Waiter'), ('Wall'), ('Warlock'), ('Warrior'),
+                ('Weird'), ('Werewolf'), ('Whale'), ('Wildfire'), ('Will'), ('Windgrace'),
+                ('Wizard'), ('Wolf'), ('Wolverine'), ('Wombat'), ('Worm'), ('Wraith'),
+                ('Wrenn'), ('Wrestler'), ('Wurm'), ('Xenagos'), ('Xerex'), ('Yanggu'),
+                ('Yanling'), ('Yeti'), ('Zendikar'), ('Zombie'), ('Zubera'), ('and/or'),
+                ('of')`,
+            `CREATE TABLE card_subtypes (
+                subtype_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                subtype_option_id INT NOT NULL)`,
+            `CREATE TABLE token_subtypes (
+                subtype_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                token_id INT NOT NULL,
+                subtype_option_id INT NOT NULL)`,
+            `CREATE TABLE card_supertype_options (
+                supertype_option_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                supertype_option VARCHAR(50) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO card_supertype_options
+                (supertype_option)
+                VALUES
+                ('Basic'), ('Host'), ('Legendary'), ('Ongoing'), ('Snow'), ('World')`,
+            `CREATE TABLE card_supertypes (
+                supertype_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                supertype_option_id INT NOT NULL)`,
+            `CREATE TABLE token_supertypes (
+                supertype_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                token_id INT NOT NULL,
+                supertype_option_id INT NOT NULL)`,
+            `CREATE TABLE set_translation_languages (
+                set_translation_language_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                set_translation_language VARCHAR(50) NOT NULL COLLATE NOCASE)`,
+            `INSERT INTO set_translation_languages
+                (set_translation_language)
+                VALUES
+                ('Chinese Simplified'), ('Chinese Traditional'), ('French'), ('German'), ('Italian'),
+                ('Japanese'), ('Korean'), ('Portuguese (Brazil)'), ('Russian'), ('Spanish')`,
+            `CREATE TABLE set_translations (
+                set_translation_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                set_id INT NOT NULL,
+                set_translation_language_id INT NOT NULL,
+                set_translated_name VARCHAR(200) NOT NULL COLLATE NOCASE)`,
+            `CREATE TABLE token_reverse_related (
+                reverse_related_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                token_id INT NOT NULL,
+                reverse_related_card VARCHAR(200) NOT NULL COLLATE NOCASE)`,
+            `CREATE TABLE last_updates (
+                last_card_update DATE NOT NULL,
+                last_prices_update DATE NOT NULL,
+                last_mtgjson_version_major INT NOT NULL,
+                last_mtgjson_version_minor INT NOT NULL,
+                last_mtgjson_version_patch INT NOT NULL)`,
+            `INSERT INTO last_updates
+                (last_card_update, last_prices_update, last_mtgjson_version_major,
+                last_mtgjson_version_minor, last_mtgjson_version_patch)
+                VALUES
+                ('1000-01-01', '1000-01-01', 0, 0, 0)`},
+        Down: []string{
+            `DROP TABLE last_updates`,
+            `DROP TABLE token_reverse_related`,
+            `DROP TABLE set_translations`,
+            `DROP TABLE set_translation_languages`,
+            `DROP TABLE token_supertypes`,
+            `DROP TABLE card_supertypes`,
+            `DROP TABLE card_supertype_options`,
+            `DROP TABLE token_subtypes`,
+            `DROP TABLE card_subtypes`,
+            `DROP TABLE card_subtype_options`,
+            `DROP TABLE rulings`,
+            `DROP TABLE purchase_urls`,
+            `DROP TABLE purchase_sites`,
+            `DROP TABLE card_printings`,
+            `DROP TABLE sets`,
+            `DROP TABLE token_base_types`,
+            `DROP TABLE card_base_types`,
+            `DROP TABLE base_type_options`,
+            `DROP TABLE legality_options`,
+            `DROP TABLE game_formats`,
+            `DROP TABLE legalities`,
+            `DROP TABLE leadership_formats`,
+            `DROP TABLE leadership_skills`,
+            `DROP TABLE alternate_language_data`,
+            `DROP TABLE other_faces`,
+            `DROP TABLE variations`,
+            `DROP TABLE frame_effects`,
+            `DROP TABLE frame_effect_options`,
+            `DROP TABLE all_tokens`,
+            `DROP TABLE all_cards`}},
+    {
+        // SQLite never had the collation typo, so there's nothing to do here
+        Version: 2,
+        Name: "fix flavor_name collation"},
+    {
+        Version: 3,
+        Name: "add price_points table for the sql price store",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS price_points (
+                price_point_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                measurement VARCHAR(50) NOT NULL,
+                tags VARCHAR(200) NOT NULL,
+                field VARCHAR(50) NOT NULL,
+                value DOUBLE NOT NULL,
+                point_time DATETIME NOT NULL)`,
+            `CREATE UNIQUE INDEX IF NOT EXISTS price_points_series_time_index
+                ON price_points (measurement, tags, field, point_time)`},
+        Down: []string{
+            `DROP TABLE price_points`}},
+    {
+        Version: 4,
+        Name: "add import_runs and import_run_checkpoints tables",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS import_runs (
+                import_run_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                mtgjson_version VARCHAR(50) NOT NULL,
+                status VARCHAR(20) NOT NULL,
+                started_at DATETIME NOT NULL,
+                finished_at DATETIME NULL)`,
+            `CREATE INDEX IF NOT EXISTS import_runs_mtgjson_version_index
+                ON import_runs (mtgjson_version)`,
+            `CREATE TABLE IF NOT EXISTS import_run_checkpoints (
+                import_run_id INTEGER NOT NULL,
+                set_code VARCHAR(20) NOT NULL,
+                completed_at DATETIME NOT NULL,
+                PRIMARY KEY (import_run_id, set_code))`},
+        Down: []string{
+            `DROP TABLE import_run_checkpoints`,
+            `DROP TABLE import_runs`}},
+    {
+        Version: 5,
+        Name: "add removal tombstones to cards, tokens and sets",
+        // SQLite can only add or drop one column per statement
+        Up: []string{
+            `ALTER TABLE all_cards ADD COLUMN removed_at DATETIME NULL`,
+            `ALTER TABLE all_cards ADD COLUMN replaced_by_uuid CHAR(36) NULL`,
+            `ALTER TABLE all_tokens ADD COLUMN removed_at DATETIME NULL`,
+            `ALTER TABLE all_tokens ADD COLUMN replaced_by_uuid CHAR(36) NULL`,
+            `ALTER TABLE sets ADD COLUMN removed_at DATETIME NULL`},
+        Down: []string{
+            `ALTER TABLE sets DROP COLUMN removed_at`,
+            `ALTER TABLE all_tokens DROP COLUMN replaced_by_uuid`,
+            `ALTER TABLE all_tokens DROP COLUMN removed_at`,
+            `ALTER TABLE all_cards DROP COLUMN replaced_by_uuid`,
+            `ALTER TABLE all_cards DROP COLUMN removed_at`}},
+    {
+        Version: 6,
+        Name: "add card_changes table for card change history",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS card_changes (
+                card_change_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                uuid CHAR(36) NOT NULL,
+                mtgjson_version VARCHAR(50) NULL,
+                old_hash CHAR(32) NOT NULL,
+                new_hash CHAR(32) NOT NULL,
+                changes TEXT NOT NULL,
+                changed_at DATETIME NOT NULL)`,
+            `CREATE INDEX IF NOT EXISTS card_changes_uuid_changed_at_index
+                ON card_changes (uuid, changed_at)`},
+        Down: []string{
+            `DROP TABLE card_changes`}},
+    {
+        Version: 7,
+        Name: "add legality_changes table for the per format change feed",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS legality_changes (
+                legality_change_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                card_id INT NOT NULL,
+                uuid CHAR(36) NOT NULL,
+                game_format VARCHAR(50) NOT NULL,
+                old_legality VARCHAR(50) NULL,
+                new_legality VARCHAR(50) NULL,
+                mtgjson_version VARCHAR(50) NULL,
+                changed_at DATETIME NOT NULL)`,
+            `CREATE INDEX IF NOT EXISTS legality_changes_game_format_changed_at_index
+                ON legality_changes (game_format, changed_at)`,
+            `CREATE INDEX IF NOT EXISTS legality_changes_changed_at_index
+                ON legality_changes (changed_at)`},
+        Down: []string{
+            `DROP TABLE legality_changes`}},
+    {
+        Version: 8,
+        Name: "add import_filters table for persisted selective imports",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS import_filters (
+                import_filter_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                filter TEXT NOT NULL,
+                created_at DATETIME NOT NULL)`},
+        Down: []string{
+            `DROP TABLE import_filters`}},
+    {
+        Version: 9,
+        Name: "add import_schedule table for persisted scheduled run times",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS import_schedule (
+                job VARCHAR(20) NOT NULL PRIMARY KEY,
+                schedule VARCHAR(100) NOT NULL,
+                next_run_at DATETIME NOT NULL,
+                updated_at DATETIME NOT NULL)`},
+        Down: []string{
+            `DROP TABLE import_schedule`}},
+}
+
+var sqliteUsersDBMigrations = []Migration{
+    {
+        Version: 1,
+        Name: "baseline schema from UsersDBSetupScripts.sql",
+        Up: []string{
+            `CREATE TABLE user_info (
+                user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                pw_hash CHAR(60) NOT NULL,
+                user_name VARCHAR(100) NOT NULL COLLATE NOCASE,
+                email VARCHAR(100) NOT NULL COLLATE NOCASE,
+                first_name VARCHAR(100) NOT NULL COLLATE NOCASE,
+                last_name VARCHAR(100) NOT NULL COLLATE NOCASE)`,
+            `CREATE INDEX user_info_user_name_index ON user_info (user_name)`},
+        Down: []string{
+            `DROP TABLE user_info`}},
+    {
+        Version: 2,
+        Name: "add card lists and share links",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS card_lists (
+                list_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                user_id INT NOT NULL,
+                list_type TEXT NOT NULL CHECK (list_type IN ('deck', 'collection', 'trade')),
+                list_name VARCHAR(200) NOT NULL COLLATE NOCASE)`,
+            `CREATE INDEX IF NOT EXISTS card_lists_user_id_index ON card_lists (user_id)`,
+            `CREATE TABLE IF NOT EXISTS card_list_entries (
+                list_entry_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                list_id INT NOT NULL,
+                card_uuid CHAR(36) NOT NULL,
+                quantity INT NOT NULL DEFAULT 1,
+                is_foil BOOLEAN NOT NULL DEFAULT FALSE)`,
+            `CREATE INDEX IF NOT EXISTS card_list_entries_list_id_index ON card_list_entries (list_id)`,
+            `CREATE TABLE IF NOT EXISTS share_tokens (
+                share_token_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                share_token CHAR(43) NOT NULL,
+                list_id INT NOT NULL,
+                created_at DATETIME NOT NULL,
+                revoked_at DATETIME NULL)`,
+            `CREATE UNIQUE INDEX IF NOT EXISTS share_tokens_share_token_index ON share_tokens (share_token)`,
+            `CREATE INDEX IF NOT EXISTS share_tokens_list_id_index ON share_tokens (list_id)`},
+        Down: []string{
+            `DROP TABLE share_tokens`,
+            `DROP TABLE card_list_entries`,
+            `DROP TABLE card_lists`}},
+    {
+        Version: 3,
+        Name: "add user price preferences",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS user_price_preferences (
+                user_id INT NOT NULL PRIMARY KEY,
+                currency CHAR(3) NOT NULL,
+                vendor VARCHAR(30) NOT NULL)`},
+        Down: []string{
+            `DROP TABLE user_price_preferences`}},
+}
@@ -0,0 +1,52 @@
+package migrations
+
+// Migrations for the users database.  Version 1 is the schema created by
+// setup-scripts/db/UsersDBSetupScripts.sql.
+var usersDBMigrations = []Migration{
+    {
+        Version: 1,
+        Name: "baseline schema from UsersDBSetupScripts.sql"},
+    {
+        Version: 2,
+        Name: "add card lists and share links",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS card_lists (
+                list_id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                user_id INT NOT NULL,
+                list_type ENUM('deck', 'collection', 'trade') NOT NULL,
+                list_name VARCHAR(200) NOT NULL COLLATE utf8mb4_general_ci,
+                INDEX user_id_index (user_id)
+            ) DEFAULT COLLATE utf8mb4_bin`,
+            `CREATE TABLE IF NOT EXISTS card_list_entries (
+                list_entry_id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                list_id INT NOT NULL,
+                card_uuid CHAR(36) NOT NULL,
+                quantity INT NOT NULL DEFAULT 1,
+                is_foil BOOLEAN NOT NULL DEFAULT FALSE,
+                INDEX list_id_index (list_id)
+            ) DEFAULT COLLATE utf8mb4_bin`,
+            `CREATE TABLE IF NOT EXISTS share_tokens (
+                share_token_id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                share_token CHAR(43) NOT NULL,
+                list_id INT NOT NULL,
+                created_at DATETIME NOT NULL,
+                revoked_at DATETIME NULL,
+                UNIQUE INDEX share_token_index (share_token),
+                INDEX list_id_index (list_id)
+            ) DEFAULT COLLATE utf8mb4_bin`},
+        Down: []string{
+            `DROP TABLE share_tokens`,
+            `DROP TABLE card_list_entries`,
+            `DROP TABLE card_lists`}},
+    {
+        Version: 3,
+        Name: "add user price preferences",
+        Up: []string{
+            `CREATE TABLE IF NOT EXISTS user_price_preferences (
+                user_id INT NOT NULL PRIMARY KEY,
+                currency CHAR(3) NOT NULL,
+                vendor VARCHAR(30) NOT NULL
+            ) DEFAULT COLLATE utf8mb4_bin`},
+        Down: []string{
+            `DROP TABLE user_price_preferences`}},
+}
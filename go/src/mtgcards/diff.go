@@ -0,0 +1,255 @@
+package mtgcards
+
+import "fmt"
+import "reflect"
+import "sort"
+import "strings"
+
+type ChangeKind string
+
+const (
+    CHANGE_ADDED ChangeKind = "added"
+    CHANGE_REMOVED ChangeKind = "removed"
+    CHANGE_MODIFIED ChangeKind = "modified"
+)
+
+// FieldChange is a single difference between two objects.  Path names the
+// field the same way it would be written in Go, with slice elements and map
+// entries in brackets, e.g. "Rulings[2].Text" or "Legalities[modern]".
+// Cards and tokens in a set are identified by their uuid rather than their
+// position, e.g. "Cards[<uuid>].Name".
+type FieldChange struct {
+    Path string `json:"path"`
+    Kind ChangeKind `json:"kind"`
+    Old interface{} `json:"old,omitempty"`
+    New interface{} `json:"new,omitempty"`
+}
+
+func (change FieldChange) String() string {
+    switch change.Kind {
+    case CHANGE_ADDED:
+        return fmt.Sprintf("%s added (%s)", change.Path, describeValue(change.New))
+    case CHANGE_REMOVED:
+        return fmt.Sprintf("%s removed (%s)", change.Path, describeValue(change.Old))
+    default:
+        return fmt.Sprintf("%s (%s | %s)", change.Path,
+            describeValue(change.Old), describeValue(change.New))
+    }
+}
+
+// ChangeList is every difference found by a Diff, in field order
+type ChangeList []FieldChange
+
+// String renders the changes one per line
+func (changes ChangeList) String() string {
+    var b strings.Builder
+    for _, change := range changes {
+        fmt.Fprintf(&b, "%s\n", change)
+    }
+    return b.String()
+}
+
+// diffObjects returns every difference between old and new, which must be
+// the same type
+func diffObjects(old interface{}, new interface{}) ChangeList {
+    changes := make(ChangeList, 0)
+    diffValues("", reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+    return changes
+}
+
+func diffValues(path string, old reflect.Value, new reflect.Value, changes *ChangeList) {
+    switch old.Kind() {
+    case reflect.Ptr, reflect.Interface:
+        if old.IsNil() || new.IsNil() {
+            if old.IsNil() != new.IsNil() {
+                addModified(path, old, new, changes)
+            }
+            return
+        }
+        diffValues(path, old.Elem(), new.Elem(), changes)
+
+    case reflect.Struct:
+        diffStructs(path, old, new, changes)
+
+    case reflect.Slice, reflect.Array:
+        diffSlices(path, old, new, changes)
+
+    case reflect.Map:
+        diffMaps(path, old, new, changes)
+
+    default:
+        if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+            addModified(path, old, new, changes)
+        }
+    }
+}
+
+func diffStructs(path string, old reflect.Value, new reflect.Value, changes *ChangeList) {
+    structType := old.Type()
+    for i := 0; i < structType.NumField(); i++ {
+        field := structType.Field(i)
+        if field.PkgPath != "" {
+            // Unexported
+            continue
+        }
+
+        // Embedded structs (e.g. MTGCardCommon) are flattened, the same way
+        // their fields are accessed
+        fieldPath := joinPath(path, field.Name)
+        if field.Anonymous {
+            fieldPath = path
+        }
+        diffValues(fieldPath, old.Field(i), new.Field(i), changes)
+    }
+}
+
+func diffSlices(path string, old reflect.Value, new reflect.Value, changes *ChangeList) {
+    elemType := old.Type().Elem()
+
+    // Strings (printings, subtypes etc.) are treated as a set of values,
+    // since adding one in the middle of a sorted list would otherwise show up
+    // as a change to every value after it
+    if elemType.Kind() == reflect.String {
+        oldValues := make(map[string]bool)
+        newValues := make(map[string]bool)
+        for i := 0; i < old.Len(); i++ {
+            oldValues[old.Index(i).String()] = true
+        }
+        for i := 0; i < new.Len(); i++ {
+            newValues[new.Index(i).String()] = true
+        }
+        for i := 0; i < old.Len(); i++ {
+            if value := old.Index(i).String(); !newValues[value] {
+                *changes = append(*changes, FieldChange{Path: path, Kind: CHANGE_REMOVED, Old: value})
+            }
+        }
+        for i := 0; i < new.Len(); i++ {
+            if value := new.Index(i).String(); !oldValues[value] {
+                *changes = append(*changes, FieldChange{Path: path, Kind: CHANGE_ADDED, New: value})
+            }
+        }
+        return
+    }
+
+    // Cards and tokens are matched up by their identity, since a set may gain
+    // or lose cards anywhere
+    if hasIdentity(elemType) {
+        oldByKey := make(map[string]reflect.Value)
+        newByKey := make(map[string]reflect.Value)
+        keys := make([]string, 0)
+        for i := 0; i < old.Len(); i++ {
+            key := identityKey(old.Index(i))
+            oldByKey[key] = old.Index(i)
+            keys = append(keys, key)
+        }
+        for i := 0; i < new.Len(); i++ {
+            key := identityKey(new.Index(i))
+            newByKey[key] = new.Index(i)
+            if _, inOld := oldByKey[key]; !inOld {
+                keys = append(keys, key)
+            }
+        }
+        sort.Strings(keys)
+
+        for _, key := range keys {
+            elemPath := fmt.Sprintf("%s[%s]", path, key)
+            oldElem, inOld := oldByKey[key]
+            newElem, inNew := newByKey[key]
+            if !inNew {
+                *changes = append(*changes,
+                    FieldChange{Path: elemPath, Kind: CHANGE_REMOVED, Old: oldElem.Interface()})
+            } else if !inOld {
+                *changes = append(*changes,
+                    FieldChange{Path: elemPath, Kind: CHANGE_ADDED, New: newElem.Interface()})
+            } else {
+                diffValues(elemPath, oldElem, newElem, changes)
+            }
+        }
+        return
+    }
+
+    // Anything else (rulings, foreign data) is compared position by position
+    for i := 0; i < old.Len() || i < new.Len(); i++ {
+        elemPath := fmt.Sprintf("%s[%d]", path, i)
+        if i >= new.Len() {
+            *changes = append(*changes,
+                FieldChange{Path: elemPath, Kind: CHANGE_REMOVED, Old: old.Index(i).Interface()})
+        } else if i >= old.Len() {
+            *changes = append(*changes,
+                FieldChange{Path: elemPath, Kind: CHANGE_ADDED, New: new.Index(i).Interface()})
+        } else {
+            diffValues(elemPath, old.Index(i), new.Index(i), changes)
+        }
+    }
+}
+
+func diffMaps(path string, old reflect.Value, new reflect.Value, changes *ChangeList) {
+    keys := make([]reflect.Value, 0, old.Len() + new.Len())
+    keys = append(keys, old.MapKeys()...)
+    for _, key := range new.MapKeys() {
+        if !old.MapIndex(key).IsValid() {
+            keys = append(keys, key)
+        }
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+    })
+
+    for _, key := range keys {
+        entryPath := fmt.Sprintf("%s[%v]", path, key.Interface())
+        oldEntry := old.MapIndex(key)
+        newEntry := new.MapIndex(key)
+        if !newEntry.IsValid() {
+            *changes = append(*changes,
+                FieldChange{Path: entryPath, Kind: CHANGE_REMOVED, Old: oldEntry.Interface()})
+        } else if !oldEntry.IsValid() {
+            *changes = append(*changes,
+                FieldChange{Path: entryPath, Kind: CHANGE_ADDED, New: newEntry.Interface()})
+        } else {
+            diffValues(entryPath, oldEntry, newEntry, changes)
+        }
+    }
+}
+
+func addModified(path string, old reflect.Value, new reflect.Value, changes *ChangeList) {
+    *changes = append(*changes, FieldChange{
+        Path: path,
+        Kind: CHANGE_MODIFIED,
+        Old: old.Interface(),
+        New: new.Interface()})
+}
+
+// Cards and tokens have a UUID, and tokens are further split by side
+func hasIdentity(elemType reflect.Type) bool {
+    if elemType.Kind() != reflect.Struct {
+        return false
+    }
+    uuidField, ok := elemType.FieldByName("UUID")
+    return ok && uuidField.Type.Kind() == reflect.String
+}
+
+func identityKey(elem reflect.Value) string {
+    key := elem.FieldByName("UUID").String()
+    if side := elem.FieldByName("Side"); side.IsValid() && side.String() != "" {
+        key += "/" + side.String()
+    }
+    return key
+}
+
+func joinPath(path string, field string) string {
+    if path == "" {
+        return field
+    }
+    return path + "." + field
+}
+
+// Whole cards and tokens are described by name rather than printed in full
+func describeValue(value interface{}) string {
+    reflected := reflect.ValueOf(value)
+    if reflected.Kind() == reflect.Struct {
+        if name := reflected.FieldByName("Name"); name.IsValid() && name.Kind() == reflect.String {
+            return name.String()
+        }
+    }
+    return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,83 @@
+package mtgcards
+
+import "testing"
+
+func TestCardDiffCoversMapsAndSlices(t *testing.T) {
+    oldCard := MTGCard{
+        Legalities: map[string]string{"modern": "Legal", "legacy": "Legal"},
+        Printings: []string{"AAA", "BBB"},
+        Rulings: []MTGCardRuling{{Date: "2020-01-01", Text: "Old ruling"}}}
+    oldCard.Name = "Card A"
+    newCard := MTGCard{
+        Legalities: map[string]string{"modern": "Banned", "vintage": "Legal"},
+        Printings: []string{"BBB", "AAA", "CCC"},
+        Rulings: []MTGCardRuling{
+            {Date: "2020-01-01", Text: "New ruling"},
+            {Date: "2021-01-01", Text: "Another ruling"}}}
+    newCard.Name = "Card B"
+
+    expected := ChangeList{
+        {Path: "Name", Kind: CHANGE_MODIFIED, Old: "Card A", New: "Card B"},
+        {Path: "Legalities[legacy]", Kind: CHANGE_REMOVED, Old: "Legal"},
+        {Path: "Legalities[modern]", Kind: CHANGE_MODIFIED, Old: "Legal", New: "Banned"},
+        {Path: "Legalities[vintage]", Kind: CHANGE_ADDED, New: "Legal"},
+        {Path: "Printings", Kind: CHANGE_ADDED, New: "CCC"},
+        {Path: "Rulings[0].Text", Kind: CHANGE_MODIFIED, Old: "Old ruling", New: "New ruling"},
+        {Path: "Rulings[1]", Kind: CHANGE_ADDED,
+            New: MTGCardRuling{Date: "2021-01-01", Text: "Another ruling"}},
+    }
+
+    changes := oldCard.Diff(&newCard)
+    if len(changes) != len(expected) {
+        t.Fatalf("Expected %d changes, got %d:\n%s", len(expected), len(changes), changes)
+    }
+    for i := range expected {
+        if changes[i] != expected[i] {
+            t.Errorf("Expected change %d to be %s, got %s", i, expected[i], changes[i])
+        }
+    }
+}
+
+func TestSetDiffMatchesCardsByUUID(t *testing.T) {
+    kept := MTGCard{}
+    kept.UUID = "uuid-kept"
+    kept.Name = "Kept"
+    removed := MTGCard{}
+    removed.UUID = "uuid-removed"
+    removed.Name = "Removed"
+    added := MTGCard{}
+    added.UUID = "uuid-added"
+    added.Name = "Added"
+
+    changedKept := kept
+    changedKept.Text = "New text"
+
+    oldSet := MTGSet{Cards: []MTGCard{removed, kept}}
+    newSet := MTGSet{Cards: []MTGCard{changedKept, added}}
+
+    expected := []string{
+        "Cards[uuid-added] added (Added)",
+        "Cards[uuid-kept].Text ( | New text)",
+        "Cards[uuid-removed] removed (Removed)",
+    }
+
+    changes := oldSet.Diff(&newSet)
+    if len(changes) != len(expected) {
+        t.Fatalf("Expected %d changes, got %d:\n%s", len(expected), len(changes), changes)
+    }
+    for i := range expected {
+        if changes[i].String() != expected[i] {
+            t.Errorf("Expected change %d to be %q, got %q", i, expected[i], changes[i])
+        }
+    }
+}
+
+func TestDiffOfIdenticalCardsIsEmpty(t *testing.T) {
+    card := MTGCard{
+        Legalities: map[string]string{"modern": "Legal"},
+        Printings: []string{"AAA"}}
+    other := card
+    if changes := card.Diff(&other); len(changes) != 0 {
+        t.Errorf("Expected no changes, got:\n%s", changes)
+    }
+}
@@ -1,7 +1,6 @@
 package mtgcards
 
 import "fmt"
-import "reflect"
 import "sort"
 import "strings"
 
@@ -63,49 +62,10 @@ func (card MTGCardCommon) String() string {
     return b.String()
 }
 
-func (card *MTGCardCommon) Diff(other *MTGCardCommon) string {
-    var b strings.Builder
-
-    cardType := reflect.TypeOf(*card)
-
-    selfValue := reflect.ValueOf(*card)
-    otherValue := reflect.ValueOf(*other)
-
-    for i := 0; i < cardType.NumField(); i++ {
-        cardField := cardType.Field(i)
-        selfField := selfValue.FieldByName(cardField.Name)
-        otherField := otherValue.FieldByName(cardField.Name)
-        switch selfField.Kind() {
-        case reflect.Bool:
-            if selfField.Bool() != otherField.Bool() {
-                fmt.Fprintf(&b, "%s (%t | %t)\n", cardField.Name,
-                    selfField.Bool(), otherField.Bool())
-            }
-        case reflect.Int:
-            if selfField.Int() != otherField.Int() {
-                fmt.Fprintf(&b, "%s (%d | %d)\n", cardField.Name,
-                    selfField.Int(), otherField.Int())
-            }
-        case reflect.String:
-            if selfField.String() != otherField.String() {
-                fmt.Fprintf(&b, "%s (%s | %s)\n", cardField.Name,
-                    selfField.String(), otherField.String())
-            }
-
-        case reflect.Slice:
-            if selfField.Len() != otherField.Len() {
-                fmt.Fprintf(&b, "%s length (%d | %d)\n", cardField.Name,
-                    selfField.Len(), otherField.Len())
-            }
-            // TODO: Diff values
-
-        case reflect.Map:
-            // TODO: Diff maps
-
-        }
-    }
-
-    return b.String()
+// Diff returns every difference between card and other, with card's values
+// as the old ones
+func (card *MTGCardCommon) Diff(other *MTGCardCommon) ChangeList {
+    return diffObjects(*card, *other)
 }
 
 func (card *MTGCardCommon) Canonicalize() {
@@ -210,51 +170,10 @@ func (card MTGCard) String() string {
     return b.String()
 }
 
-func (card *MTGCard) Diff(other *MTGCard) string {
-    var b strings.Builder
-
-    b.WriteString(card.MTGCardCommon.Diff(&other.MTGCardCommon))
-
-    cardType := reflect.TypeOf(*card)
-
-    selfValue := reflect.ValueOf(*card)
-    otherValue := reflect.ValueOf(*other)
-
-    for i := 0; i < cardType.NumField(); i++ {
-        cardField := cardType.Field(i)
-        selfField := selfValue.FieldByName(cardField.Name)
-        otherField := otherValue.FieldByName(cardField.Name)
-        switch selfField.Kind() {
-        case reflect.Bool:
-            if selfField.Bool() != otherField.Bool() {
-                fmt.Fprintf(&b, "%s (%t | %t)\n", cardField.Name,
-                    selfField.Bool(), otherField.Bool())
-            }
-        case reflect.Int:
-            if selfField.Int() != otherField.Int() {
-                fmt.Fprintf(&b, "%s (%d | %d)\n", cardField.Name,
-                    selfField.Int(), otherField.Int())
-            }
-        case reflect.String:
-            if selfField.String() != otherField.String() {
-                fmt.Fprintf(&b, "%s (%s | %s)\n", cardField.Name,
-                    selfField.String(), otherField.String())
-            }
-
-        case reflect.Slice:
-            if selfField.Len() != otherField.Len() {
-                fmt.Fprintf(&b, "%s length (%d | %d)\n", cardField.Name,
-                    selfField.Len(), otherField.Len())
-            }
-            // TODO: Diff values
-
-        case reflect.Map:
-            // TODO: Diff maps
-
-        }
-    }
-
-    return b.String()
+// Diff returns every difference between card and other, including the
+// common fields, with card's values as the old ones
+func (card *MTGCard) Diff(other *MTGCard) ChangeList {
+    return diffObjects(*card, *other)
 }
 
 func (card *MTGCard) Hash() string {
@@ -18,11 +18,53 @@ func (priceRecords MTGCardPriceRecords) Swap(i, j int) {
 	priceRecords[i], priceRecords[j] = priceRecords[j], priceRecords[i]
 }
 
+// The vendors MTGJSON has prices from, and the currency each one's prices
+// are in
+const (
+    VENDOR_CARDHOARDER = "cardhoarder"
+    VENDOR_CARDKINGDOM = "cardkingdom"
+    VENDOR_CARDMARKET = "cardmarket"
+    VENDOR_TCGPLAYER = "tcgplayer"
+)
+
+var VendorCurrencies = map[string]string{
+    VENDOR_CARDHOARDER: "USD",
+    VENDOR_CARDKINGDOM: "USD",
+    VENDOR_CARDMARKET: "EUR",
+    VENDOR_TCGPLAYER: "USD",
+}
+
+const (
+    PLATFORM_MTGO = "mtgo"
+    PLATFORM_PAPER = "paper"
+)
+
+// MTGCardPrices is a card's price history, with every vendor's prices in
+// Vendors.  MTGJSON v4 only has the four retail price lists, which came from
+// Cardhoarder (MTGO) and TCGplayer (paper), so those are all Vendors has for
+// v4.  For v5, the four lists are filled in from those same two vendors.
 type MTGCardPrices struct {
     MTGO MTGCardPriceRecords `json:"mtgo"`
     MTGOFoil MTGCardPriceRecords `json:"mtgoFoil"`
     Paper MTGCardPriceRecords `json:"paper"`
     PaperFoil MTGCardPriceRecords `json:"paperFoil"`
+
+    Vendors []MTGCardVendorPrices `json:"-"`
+}
+
+// MTGCardVendorPrices is one vendor's prices for a card on one platform
+// ("mtgo" or "paper"), in the vendor's currency
+type MTGCardVendorPrices struct {
+    Platform string `json:"-"`
+    Vendor string `json:"-"`
+    Currency string `json:"currency"`
+    Retail MTGCardFinishPrices `json:"retail"`
+    Buylist MTGCardFinishPrices `json:"buylist"`
+}
+
+type MTGCardFinishPrices struct {
+    Normal MTGCardPriceRecords `json:"normal"`
+    Foil MTGCardPriceRecords `json:"foil"`
 }
 
 type MTGCardPricesDummy MTGCardPrices
@@ -59,6 +101,18 @@ func (cardPrices *MTGCardPrices) UnmarshalJSON(data []byte) error {
     // but we know it's compatible since it's just a type alias
     *cardPrices = MTGCardPrices(pricesDummy.Prices)
 
+    cardPrices.Vendors = []MTGCardVendorPrices{
+        {
+            Platform: PLATFORM_MTGO,
+            Vendor: VENDOR_CARDHOARDER,
+            Currency: VendorCurrencies[VENDOR_CARDHOARDER],
+            Retail: MTGCardFinishPrices{Normal: cardPrices.MTGO, Foil: cardPrices.MTGOFoil}},
+        {
+            Platform: PLATFORM_PAPER,
+            Vendor: VENDOR_TCGPLAYER,
+            Currency: VendorCurrencies[VENDOR_TCGPLAYER],
+            Retail: MTGCardFinishPrices{Normal: cardPrices.Paper, Foil: cardPrices.PaperFoil}}}
+
     return nil
 }
 
@@ -0,0 +1,27 @@
+package mtgcards
+
+// MTGSealedProduct is a booster box, bundle, deck etc. sold for a set.  Only
+// MTGJSON v5 lists these.
+type MTGSealedProduct struct {
+	Category string `json:"category"`
+	Identifiers map[string]string `json:"identifiers"`
+	Name string `json:"name"`
+	PurchaseURLs map[string]string `json:"purchaseUrls"`
+	ReleaseDate string `json:"releaseDate"`
+	Subtype string `json:"subtype"`
+	UUID string `json:"uuid"`
+}
+
+type SealedProductByUUID []MTGSealedProduct
+
+func (products SealedProductByUUID) Len() int {
+	return len(products)
+}
+
+func (products SealedProductByUUID) Less(i, j int) bool {
+	return products[i].UUID < products[j].UUID
+}
+
+func (products SealedProductByUUID) Swap(i, j int) {
+	products[i], products[j] = products[j], products[i]
+}
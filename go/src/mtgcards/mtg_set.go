@@ -1,7 +1,6 @@
 package mtgcards
 
 import "fmt"
-import "reflect"
 import "sort"
 import "strings"
 
@@ -21,6 +20,8 @@ type MTGSet struct {
 	Name string `json:"name"`
 	ParentCode string `json:"parentCode"`
 	ReleaseDate string `json:"releaseDate"`
+	// Sealed product isn't stored in the card db, so it isn't in the hash
+	SealedProduct []MTGSealedProduct `json:"sealedProduct" hash:"-"`
 	TCGPlayerGroupId int `json:"tcgplayerGroupId"`
 	TotalSetSize int `json:"totalSetSize"`
     Tokens []MTGToken `json:"tokens"`
@@ -28,49 +29,11 @@ type MTGSet struct {
 	Type string `json:"type"`
 }
 
-func (set *MTGSet) Diff(other *MTGSet) string {
-    var b strings.Builder
-
-    setType := reflect.TypeOf(*set)
-
-    selfValue := reflect.ValueOf(*set)
-    otherValue := reflect.ValueOf(*set)
-
-    for i := 0; i < setType.NumField(); i++ {
-        setField := setType.Field(i)
-        selfField := selfValue.FieldByName(setField.Name)
-        otherField := otherValue.FieldByName(setField.Name)
-        switch selfField.Kind() {
-        case reflect.Bool:
-            if selfField.Bool() != otherField.Bool() {
-                fmt.Fprintf(&b, "%s (%t | %t)\n", setField.Name,
-                    selfField.Bool(), otherField.Bool())
-            }
-        case reflect.Int:
-            if selfField.Int() != otherField.Int() {
-                fmt.Fprintf(&b, "%s (%d | %d)\n", setField.Name,
-                    selfField.Int(), otherField.Int())
-            }
-        case reflect.String:
-            if selfField.String() != otherField.String() {
-                fmt.Fprintf(&b, "%s (%s | %s)\n", setField.Name,
-                    selfField.String(), otherField.String())
-            }
-
-        case reflect.Slice:
-            if selfField.Len() != otherField.Len() {
-                fmt.Fprintf(&b, "%s length (%d | %d)\n", setField.Name,
-                    selfField.Len(), otherField.Len())
-            }
-            // TODO: Diff values
-
-        case reflect.Map:
-            // TODO: Diff maps
-
-        }
-    }
-
-    return b.String()
+// Diff returns every difference between set and other, including the
+// differences in each of their cards and tokens, with set's values as the
+// old ones
+func (set *MTGSet) Diff(other *MTGSet) ChangeList {
+    return diffObjects(*set, *other)
 }
 
 func (set MTGSet) String() string {
@@ -105,6 +68,9 @@ func (set *MTGSet) Canonicalize() {
         // Same as above
         set.Tokens[idx].Canonicalize()
     }
+
+    // Sealed product
+    sort.Sort(SealedProductByUUID(set.SealedProduct))
 }
 
 type CardByUUID []MTGCard
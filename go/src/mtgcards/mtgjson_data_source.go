@@ -0,0 +1,89 @@
+package mtgcards
+
+import "bytes"
+import "context"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+
+// The kinds of DataSource NewDataSource can build
+const (
+    DATA_SOURCE_HTTP = "http"
+    DATA_SOURCE_LOCAL = "local"
+)
+
+// FetchOptions only matter to sources that fetch files from elsewhere and
+// keep a local copy, i.e. HTTPDataSource
+type FetchOptions struct {
+    // Use the local copy from an earlier fetch instead of fetching the file
+    // again, if there is one
+    UseCachedIfAvailable bool
+    // Keep the local copy in the working directory rather than the data dir
+    UseDebugDownloadLocation bool
+}
+
+// A DataSource provides the MTGJSON files by name, e.g.
+// "AllPrintings.json.gz".  A file the source doesn't have is an error, so
+// that the next format can be tried.
+type DataSource interface {
+    Open(ctx context.Context, filename string, options FetchOptions) (io.ReadCloser, error)
+}
+
+// NewDataSource builds the DataSource for kind.  The HTTP source fetches
+// from baseUrl and caches in dataDir; the local source reads a mirror of
+// the MTGJSON files in dataDir and never touches the network.
+func NewDataSource(kind string, baseUrl string, dataDir string) (DataSource, error) {
+    switch kind {
+    case DATA_SOURCE_HTTP:
+        return NewHTTPDataSource(baseUrl, dataDir), nil
+    case DATA_SOURCE_LOCAL:
+        return NewLocalDataSource(dataDir), nil
+    default:
+        return nil, fmt.Errorf("Unknown data source %q", kind)
+    }
+}
+
+// LocalDataSource reads files from a directory mirroring MTGJSON's, so that
+// imports can run without network access.  The mirror is only ever read.
+type LocalDataSource struct {
+    dir string
+}
+
+func NewLocalDataSource(dir string) *LocalDataSource {
+    return &LocalDataSource{dir: dir}
+}
+
+func (source *LocalDataSource) Open(ctx context.Context,
+        filename string,
+        options FetchOptions) (io.ReadCloser, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    return os.Open(filepath.Join(source.dir, filename))
+}
+
+// MemoryDataSource serves files held in memory, for tests
+type MemoryDataSource struct {
+    files map[string][]byte
+}
+
+// NewMemoryDataSource serves files, which maps filenames (e.g.
+// "version.json") to their contents
+func NewMemoryDataSource(files map[string][]byte) *MemoryDataSource {
+    return &MemoryDataSource{files: files}
+}
+
+func (source *MemoryDataSource) Open(ctx context.Context,
+        filename string,
+        options FetchOptions) (io.ReadCloser, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    contents, ok := source.files[filename]
+    if !ok {
+        return nil, fmt.Errorf("No file %s in memory", filename)
+    }
+    return ioutil.NopCloser(bytes.NewReader(contents)), nil
+}
@@ -0,0 +1,96 @@
+package mtgcards
+
+import "context"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+
+func TestDownloadsReadFromConfiguredSource(t *testing.T) {
+    defer ConfigureDownloads(dataSource, schemaVersion)
+
+    ConfigureDownloads(NewMemoryDataSource(map[string][]byte{
+        "version.json": []byte(`{"date": "2020-06-01", "pricesDate": "2020-06-02", "version": "4.6.3+20200601"}`),
+        "AllPrices.json": []byte(`{"uuid-a": {"prices": {"paper": {"2020-06-02": 1.5}}}}`),
+    }), MTGJSON_V4)
+
+    version, err := DownloadVersion(context.Background())
+    if err != nil {
+        t.Fatalf("Unexpected error downloading the version: %s", err)
+    }
+    if version.VersionMajor != 4 || version.VersionMinor != 6 {
+        t.Errorf("Unexpected version %v", version)
+    }
+
+    // There's no gz or bz2 copy, so this falls back to the plain json
+    prices, err := DownloadAllPrices(context.Background(), false)
+    if err != nil {
+        t.Fatalf("Unexpected error downloading prices: %s", err)
+    }
+    if len(prices["uuid-a"].Paper) != 1 || prices["uuid-a"].Paper[0].Price != 1.5 {
+        t.Errorf("Unexpected prices %v", prices)
+    }
+}
+
+func TestLocalSourceReadsMirror(t *testing.T) {
+    dir, err := ioutil.TempDir("", "mtgjson-mirror")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    err = ioutil.WriteFile(filepath.Join(dir, "version.json"), []byte(`{}`), 0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    source := NewLocalDataSource(dir)
+    file, err := source.Open(context.Background(), "version.json", FetchOptions{})
+    if err != nil {
+        t.Fatalf("Unexpected error opening a mirrored file: %s", err)
+    }
+    file.Close()
+
+    if _, err := source.Open(context.Background(), "AllPrices.json", FetchOptions{}); err == nil {
+        t.Errorf("Expected an error opening a file missing from the mirror")
+    }
+}
+
+func TestDownloadSetListAndSingleSet(t *testing.T) {
+    defer ConfigureDownloads(dataSource, schemaVersion)
+
+    ConfigureDownloads(NewMemoryDataSource(map[string][]byte{
+        "SetList.json": []byte(`{"meta": {}, "data": [{"code": "AAA", "totalSetSize": 1}, {"code": "BBB"}]}`),
+        "AAA.json": []byte(`{"meta": {}, "data": {"code": "AAA", "cards": [{"name": "Card A", "uuid": "uuid-a"}]}}`),
+        "CON_.json": []byte(`{"meta": {}, "data": {"code": "CON", "cards": []}}`),
+    }), MTGJSON_V5)
+
+    setList, err := DownloadSetList(context.Background())
+    if err != nil {
+        t.Fatalf("Unexpected error downloading the set list: %s", err)
+    }
+    if len(setList) != 2 || setList[0].Code != "AAA" || setList[0].TotalSetSize != 1 {
+        t.Errorf("Unexpected set list %+v", setList)
+    }
+
+    set, err := DownloadSet(context.Background(), "AAA")
+    if err != nil {
+        t.Fatalf("Unexpected error downloading a set: %s", err)
+    }
+    if set.Code != "AAA" || len(set.Cards) != 1 || set.Cards[0].UUID != "uuid-a" {
+        t.Errorf("Unexpected set %+v", set)
+    }
+
+    // Conflux's code is a reserved file name on Windows, so MTGJSON renames it
+    set, err = DownloadSet(context.Background(), "CON")
+    if err != nil {
+        t.Fatalf("Unexpected error downloading a set with a reserved code: %s", err)
+    }
+    if set.Code != "CON" {
+        t.Errorf("Unexpected set %+v", set)
+    }
+
+    if _, err := DownloadSet(context.Background(), "BBB"); err == nil {
+        t.Errorf("Expected an error downloading a set with no file")
+    }
+}
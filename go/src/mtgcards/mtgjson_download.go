@@ -2,18 +2,30 @@ package mtgcards
 
 import "compress/bzip2"
 import "compress/gzip"
+import "context"
 import "encoding/json"
 import "fmt"
 import "io"
-import "log"
-import "net/http"
+import "logging"
 import "os"
+import "strings"
+
+var logger = logging.For("mtgcards")
+
+// The MTGJSON schema versions that can be read.  v5 wraps every file in a
+// data/meta envelope, moves cards' ids into identifiers, breaks prices down
+// by vendor, and renames the version file to Meta.
+const (
+    MTGJSON_V4 = 4
+    MTGJSON_V5 = 5
+)
 
 const (
-	mtgjsonBaseUrl = "https://www.mtgjson.com/files/"
     allPrintingsUrl = "AllPrintings"
     allPricesUrl = "AllPrices"
+    setListUrl = "SetList"
     versionUrl = "version"
+    metaUrl = "Meta"
 )
 
 const (
@@ -23,13 +35,51 @@ const (
 )
 
 const (
-    downloadLocation = "/var/card-importer/card-data/"
     debugDownloadLocation = "./"
 )
 
-func DownloadAllPrintings(useCachedIfAvailable bool,
+// Set codes that are reserved file names on Windows, which MTGJSON publishes
+// with an underscore on the end (e.g. Conflux is in CON_.json)
+var reservedSetFileNames = map[string]bool{
+    "CON": true, "PRN": true, "AUX": true, "NUL": true,
+    "COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+    "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+    "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+    "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// setFileName is the name of the file MTGJSON publishes the set with code in,
+// without the extension
+func setFileName(code string) string {
+    if reservedSetFileNames[strings.ToUpper(code)] {
+        return code + "_"
+    }
+    return code
+}
+
+var dataSource DataSource = NewHTTPDataSource("https://www.mtgjson.com/files/",
+    "/var/card-importer/card-data/")
+var schemaVersion = MTGJSON_V4
+
+// ConfigureDownloads sets where MTGJSON files come from and which schema
+// version (MTGJSON_V4 or MTGJSON_V5) they're read as
+func ConfigureDownloads(source DataSource, schema int) {
+    dataSource = source
+    schemaVersion = schema
+}
+
+func ensureTrailingSlash(path string) string {
+    if strings.HasSuffix(path, "/") {
+        return path
+    }
+    return path + "/"
+}
+
+func DownloadAllPrintings(ctx context.Context,
+        useCachedIfAvailable bool,
         useDebugDownloadLocation bool) (map[string]MTGSet, error) {
     result, err := downloadData(
+        ctx,
         useCachedIfAvailable,
         useDebugDownloadLocation,
         allPrintingsUrl,
@@ -69,8 +119,10 @@ func DebugParseAllPrintingsGz(filename string) (map[string]MTGSet, error) {
     }
 }
 
-func DownloadAllPrices(useCachedIfAvailable bool) (map[string]MTGCardPrices, error) {
+func DownloadAllPrices(ctx context.Context,
+        useCachedIfAvailable bool) (map[string]MTGCardPrices, error) {
     result, err := downloadData(
+        ctx,
         useCachedIfAvailable,
         false,
         allPricesUrl,
@@ -86,11 +138,57 @@ func DownloadAllPrices(useCachedIfAvailable bool) (map[string]MTGCardPrices, err
     }
 }
 
-func DownloadVersion() (MTGJSONVersion, error) {
+// DownloadSetList returns every set MTGJSON has, without their cards or
+// tokens
+func DownloadSetList(ctx context.Context) ([]MTGSet, error) {
+    result, err := downloadData(
+        ctx,
+        false,
+        false,
+        setListUrl,
+        decodeSetList)
+    if err != nil {
+        return nil, err
+    }
+
+    if resultCast, ok := result.([]MTGSet); !ok {
+        return nil, fmt.Errorf("Unable to convert set list to correct type")
+    } else {
+        return resultCast, nil
+    }
+}
+
+// DownloadSet downloads the set with code from its own file, which has the
+// same contents as its entry in AllPrintings
+func DownloadSet(ctx context.Context, code string) (MTGSet, error) {
+    result, err := downloadData(
+        ctx,
+        false,
+        false,
+        setFileName(code),
+        decodeSet)
+    if err != nil {
+        return MTGSet{}, err
+    }
+
+    if resultCast, ok := result.(MTGSet); !ok {
+        return MTGSet{}, fmt.Errorf("Unable to convert set %s to correct type", code)
+    } else {
+        return resultCast, nil
+    }
+}
+
+func DownloadVersion(ctx context.Context) (MTGJSONVersion, error) {
+    fileUrl := versionUrl
+    if schemaVersion == MTGJSON_V5 {
+        fileUrl = metaUrl
+    }
+
     result, err := downloadData(
+        ctx,
         false,
         false,
-        versionUrl,
+        fileUrl,
         decodeVersion)
     if err != nil {
         return MTGJSONVersion{}, err
@@ -104,8 +202,50 @@ func DownloadVersion() (MTGJSONVersion, error) {
 }
 
 func decodeSets(input io.Reader) (interface{}, error) {
+    reader := NewSetReader(input)
+    result := make(map[string]MTGSet)
+    for {
+        set, err := reader.Next()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, err
+        }
+        result[set.Code] = set
+    }
+    return result, nil
+}
+
+func decodeSetList(input io.Reader) (interface{}, error) {
     decoder := json.NewDecoder(input)
-    var result map[string]MTGSet
+    var result []MTGSet
+    if schemaVersion == MTGJSON_V5 {
+        var envelope struct {
+            Data []MTGSet `json:"data"`
+        }
+        if err := decoder.Decode(&envelope); err != nil {
+            return nil, err
+        }
+        result = envelope.Data
+    } else if err := decoder.Decode(&result); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+func decodeSet(input io.Reader) (interface{}, error) {
+    decoder := json.NewDecoder(input)
+    if schemaVersion == MTGJSON_V5 {
+        var envelope struct {
+            Data mtgjsonV5Set `json:"data"`
+        }
+        if err := decoder.Decode(&envelope); err != nil {
+            return nil, err
+        }
+        return envelope.Data.toSet()
+    }
+
+    var result MTGSet
     if err := decoder.Decode(&result); err != nil {
         return nil, err
     }
@@ -113,6 +253,10 @@ func decodeSets(input io.Reader) (interface{}, error) {
 }
 
 func decodePrices(input io.Reader) (interface{}, error) {
+    if schemaVersion == MTGJSON_V5 {
+        return decodeV5Prices(input)
+    }
+
     decoder := json.NewDecoder(input)
     var result map[string]MTGCardPrices
     if err := decoder.Decode(&result); err != nil {
@@ -122,6 +266,10 @@ func decodePrices(input io.Reader) (interface{}, error) {
 }
 
 func decodeVersion(input io.Reader) (interface{}, error) {
+    if schemaVersion == MTGJSON_V5 {
+        return decodeV5Version(input)
+    }
+
     decoder := json.NewDecoder(input)
     var result MTGJSONVersion
     if err := decoder.Decode(&result); err != nil {
@@ -131,37 +279,47 @@ func decodeVersion(input io.Reader) (interface{}, error) {
 }
 
 func downloadData(
+        ctx context.Context,
         useCachedIfAvailable bool,
         useDebugDownloadLocation bool,
         fileUrl string,
         decoderFn func(io.Reader) (interface{}, error)) (interface{}, error) {
-    result, err := tryGz(useCachedIfAvailable, useDebugDownloadLocation, fileUrl, decoderFn)
+    result, err := tryGz(ctx, useCachedIfAvailable, useDebugDownloadLocation, fileUrl, decoderFn)
     if err == nil {
         return result, nil
     }
-    log.Print(err)
+    logger.Warn("Couldn't get the gzipped file", "url", fileUrl, "err", err)
 
-    result, err = tryBz2(useCachedIfAvailable, useDebugDownloadLocation, fileUrl, decoderFn)
+    if ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
+
+    result, err = tryBz2(ctx, useCachedIfAvailable, useDebugDownloadLocation, fileUrl, decoderFn)
     if err == nil {
         return result, nil
     }
-    log.Print(err)
+    logger.Warn("Couldn't get the bzipped file", "url", fileUrl, "err", err)
+
+    if ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
 
-    result, err = tryRaw(useCachedIfAvailable, useDebugDownloadLocation, fileUrl, decoderFn)
+    result, err = tryRaw(ctx, useCachedIfAvailable, useDebugDownloadLocation, fileUrl, decoderFn)
     if err == nil {
         return result, nil
     }
-    log.Print(err)
+    logger.Warn("Couldn't get the uncompressed file", "url", fileUrl, "err", err)
 
     return nil, fmt.Errorf("Unable to get %s from any sources", fileUrl)
 }
 
 func tryGz(
+        ctx context.Context,
         useCachedIfAvailable bool,
         useDebugDownloadLocation bool,
         fileUrl string,
         decoderFn func(io.Reader) (interface{}, error)) (interface{}, error) {
-    reader, err := tryDownload(fileUrl + jsonExt + gzExt, useCachedIfAvailable, useDebugDownloadLocation)
+    reader, err := tryDownload(ctx, fileUrl + jsonExt + gzExt, useCachedIfAvailable, useDebugDownloadLocation)
     if err != nil {
         return nil, err
     }
@@ -177,11 +335,12 @@ func tryGz(
 }
 
 func tryBz2(
+        ctx context.Context,
         useCachedIfAvailable bool,
         useDebugDownloadLocation bool,
         fileUrl string,
         decoderFn func(io.Reader) (interface{}, error)) (interface{}, error) {
-    reader, err := tryDownload(fileUrl + jsonExt + bz2Ext, useCachedIfAvailable, useDebugDownloadLocation)
+    reader, err := tryDownload(ctx, fileUrl + jsonExt + bz2Ext, useCachedIfAvailable, useDebugDownloadLocation)
     if err != nil {
         return nil, err
     }
@@ -193,11 +352,12 @@ func tryBz2(
 }
 
 func tryRaw(
+        ctx context.Context,
         useCachedIfAvailable bool,
         useDebugDownloadLocation bool,
         fileUrl string,
         decoderFn func(io.Reader) (interface{}, error)) (interface{}, error) {
-    reader, err := tryDownload(fileUrl + jsonExt, useCachedIfAvailable, useDebugDownloadLocation)
+    reader, err := tryDownload(ctx, fileUrl + jsonExt, useCachedIfAvailable, useDebugDownloadLocation)
     if err != nil {
         return nil, err
     }
@@ -206,47 +366,11 @@ func tryRaw(
     return decoderFn(reader)
 }
 
-func tryDownload(filename string, useCachedIfAvailable bool, useDebugDownloadLocation bool) (io.ReadCloser, error) {
-	// If we've either been asked to not use a local cached file, or
-	// we have, but the file hasn't been downloaded, download the file
-    var fileLocation string
-    if useDebugDownloadLocation {
-        fileLocation = debugDownloadLocation + filename
-    } else {
-        fileLocation = downloadLocation + filename
-    }
-	_, err := os.Stat(fileLocation)
-	if !useCachedIfAvailable || os.IsNotExist(err) {
-		fullUrl := mtgjsonBaseUrl + filename
-		resp, err := http.Get(fullUrl)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("Error %s while fetching %s", resp.Status, fullUrl)
-		}
-
-		file, err := os.Create(fileLocation)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-
-		_, err = io.Copy(file, resp.Body)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// If we're here, we've either freshly downloaded the file, or have determined
-	// there's an existing cached version we can use
-	file, err := os.Open(fileLocation)
-	if err != nil {
-		return nil, err
-	}
-	return file, err
+func tryDownload(ctx context.Context,
+        filename string,
+        useCachedIfAvailable bool,
+        useDebugDownloadLocation bool) (io.ReadCloser, error) {
+    return dataSource.Open(ctx, filename, FetchOptions{
+        UseCachedIfAvailable: useCachedIfAvailable,
+        UseDebugDownloadLocation: useDebugDownloadLocation})
 }
-
-
@@ -0,0 +1,283 @@
+package mtgcards
+
+import "context"
+import "crypto/sha256"
+import "encoding/hex"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "net/http"
+import "os"
+import "path/filepath"
+import "strings"
+import "sync"
+import "time"
+
+// How many times a download is attempted before giving up on it
+const downloadAttempts = 3
+
+// How long to wait before the first retry, doubling for each one after
+var downloadRetryBackoff = 2 * time.Second
+
+const (
+    checksumExt = ".sha256"
+    etagExt = ".etag"
+)
+
+// DownloadStats counts what happened to the files downloaded by
+// HTTPDataSource since the stats were last taken
+type DownloadStats struct {
+    Downloads int
+    NotModified int
+    Retries int
+    VerificationFailures int
+    // Including the bytes of downloads that failed part way, or didn't verify
+    Bytes int64
+}
+
+var downloadStatsMutex sync.Mutex
+var downloadStats DownloadStats
+
+// TakeDownloadStats returns the download stats since the last time they
+// were taken, and starts counting again from zero
+func TakeDownloadStats() DownloadStats {
+    downloadStatsMutex.Lock()
+    defer downloadStatsMutex.Unlock()
+    stats := downloadStats
+    downloadStats = DownloadStats{}
+    return stats
+}
+
+func addToDownloadStats(update func(stats *DownloadStats)) {
+    downloadStatsMutex.Lock()
+    defer downloadStatsMutex.Unlock()
+    update(&downloadStats)
+}
+
+// A download that failed in a way that's worth trying again, as opposed to
+// e.g. a 404 for a format MTGJSON doesn't publish
+type retryableError struct {
+    err error
+}
+
+func (err *retryableError) Error() string {
+    return err.err.Error()
+}
+
+// A download that didn't match its published checksum
+type verificationError struct {
+    filename string
+    expected string
+    actual string
+}
+
+func (err *verificationError) Error() string {
+    return fmt.Sprintf("%s has sha256 %s, expected %s", err.filename, err.actual, err.expected)
+}
+
+// HTTPDataSource downloads files from MTGJSON (or anything serving the same
+// layout), keeping a copy of each in cacheDir.  Downloads go to a temporary
+// file that's checked against the published .sha256 before it replaces the
+// cached copy, so an interrupted or corrupt download is never reused.  The
+// cached copy's ETag and modification time are sent with each request, so
+// that unchanged files aren't downloaded again.
+type HTTPDataSource struct {
+    baseUrl string
+    cacheDir string
+    client *http.Client
+}
+
+func NewHTTPDataSource(baseUrl string, cacheDir string) *HTTPDataSource {
+    return &HTTPDataSource{
+        baseUrl: ensureTrailingSlash(baseUrl),
+        cacheDir: ensureTrailingSlash(cacheDir),
+        client: http.DefaultClient}
+}
+
+func (source *HTTPDataSource) Open(ctx context.Context,
+        filename string,
+        options FetchOptions) (io.ReadCloser, error) {
+    var fileLocation string
+    if options.UseDebugDownloadLocation {
+        fileLocation = debugDownloadLocation + filename
+    } else {
+        fileLocation = source.cacheDir + filename
+    }
+
+    // If we've either been asked to not use a local cached file, or we have,
+    // but the file hasn't been downloaded, download the file
+    _, err := os.Stat(fileLocation)
+    if !options.UseCachedIfAvailable || os.IsNotExist(err) {
+        err = source.downloadWithRetries(ctx, filename, fileLocation)
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    // If we're here, we've either freshly downloaded the file, or have
+    // determined there's an existing cached version we can use
+    return os.Open(fileLocation)
+}
+
+func (source *HTTPDataSource) downloadWithRetries(ctx context.Context,
+        filename string,
+        fileLocation string) error {
+    backoff := downloadRetryBackoff
+    for attempt := 1; ; attempt++ {
+        err := source.download(ctx, filename, fileLocation)
+        if err == nil {
+            return nil
+        }
+
+        if verifyErr, ok := err.(*verificationError); ok {
+            addToDownloadStats(func(stats *DownloadStats) { stats.VerificationFailures += 1 })
+            err = &retryableError{verifyErr}
+        }
+        if _, ok := err.(*retryableError); !ok || attempt == downloadAttempts || ctx.Err() != nil {
+            return err
+        }
+
+        logger.Warn("Download failed, retrying", "file", filename, "attempt", attempt,
+            "attempts", downloadAttempts, "backoff", backoff, "err", err)
+        addToDownloadStats(func(stats *DownloadStats) { stats.Retries += 1 })
+
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-time.After(backoff):
+        }
+        backoff *= 2
+    }
+}
+
+// download fetches filename into fileLocation, unless the copy already
+// there is still current
+func (source *HTTPDataSource) download(ctx context.Context,
+        filename string,
+        fileLocation string) error {
+    fullUrl := source.baseUrl + filename
+    request, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
+    if err != nil {
+        return err
+    }
+
+    // Only make the request conditional if the copy being compared against
+    // is actually there
+    cachedInfo, err := os.Stat(fileLocation)
+    if err == nil {
+        if etag, err := ioutil.ReadFile(fileLocation + etagExt); err == nil && len(etag) > 0 {
+            request.Header.Set("If-None-Match", string(etag))
+        }
+        request.Header.Set("If-Modified-Since", cachedInfo.ModTime().UTC().Format(http.TimeFormat))
+    }
+
+    resp, err := source.client.Do(request)
+    if err != nil {
+        return &retryableError{err}
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode == http.StatusNotModified {
+        logger.Info("File hasn't changed, using the cached copy", "file", filename)
+        addToDownloadStats(func(stats *DownloadStats) { stats.NotModified += 1 })
+        return nil
+    }
+    if resp.StatusCode != http.StatusOK {
+        err = fmt.Errorf("Error %s while fetching %s", resp.Status, fullUrl)
+        if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+            return &retryableError{err}
+        }
+        return err
+    }
+
+    // Download next to the cached copy, so that the rename replacing it is
+    // atomic
+    tempFile, err := ioutil.TempFile(filepath.Dir(fileLocation), filepath.Base(fileLocation) + ".*.tmp")
+    if err != nil {
+        return err
+    }
+    tempLocation := tempFile.Name()
+    defer os.Remove(tempLocation)
+
+    hash := sha256.New()
+    written, err := io.Copy(io.MultiWriter(tempFile, hash), resp.Body)
+    addToDownloadStats(func(stats *DownloadStats) { stats.Bytes += written })
+    closeErr := tempFile.Close()
+    if err != nil {
+        return &retryableError{err}
+    }
+    if closeErr != nil {
+        return closeErr
+    }
+
+    expected, err := source.fetchChecksum(ctx, filename)
+    if err != nil {
+        return err
+    }
+    if expected != "" {
+        actual := hex.EncodeToString(hash.Sum(nil))
+        if actual != expected {
+            return &verificationError{filename, expected, actual}
+        }
+    } else {
+        logger.Warn("No checksum published, not verifying the file", "file", filename)
+    }
+
+    if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+        os.Chtimes(tempLocation, lastModified, lastModified)
+    }
+
+    err = os.Rename(tempLocation, fileLocation)
+    if err != nil {
+        return err
+    }
+
+    // The ETag is only a hint for next time, so failing to keep it isn't
+    // a failed download
+    if etag := resp.Header.Get("ETag"); etag != "" {
+        err = ioutil.WriteFile(fileLocation + etagExt, []byte(etag), 0644)
+    } else {
+        err = os.Remove(fileLocation + etagExt)
+    }
+    if err != nil && !os.IsNotExist(err) {
+        logger.Warn("Couldn't save the ETag", "file", filename, "err", err)
+    }
+
+    addToDownloadStats(func(stats *DownloadStats) { stats.Downloads += 1 })
+    return nil
+}
+
+// fetchChecksum returns the published sha256 of filename in hex, or "" if
+// none is published
+func (source *HTTPDataSource) fetchChecksum(ctx context.Context, filename string) (string, error) {
+    fullUrl := source.baseUrl + filename + checksumExt
+    request, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
+    if err != nil {
+        return "", err
+    }
+
+    resp, err := source.client.Do(request)
+    if err != nil {
+        return "", &retryableError{err}
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode == http.StatusNotFound {
+        return "", nil
+    }
+    if resp.StatusCode != http.StatusOK {
+        return "", &retryableError{fmt.Errorf("Error %s while fetching %s", resp.Status, fullUrl)}
+    }
+
+    // The file is the hex digest, possibly followed by the filename as
+    // sha256sum writes it
+    body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+    if err != nil {
+        return "", &retryableError{err}
+    }
+    fields := strings.Fields(string(body))
+    if len(fields) == 0 {
+        return "", fmt.Errorf("Empty checksum in %s", fullUrl)
+    }
+    return strings.ToLower(fields[0]), nil
+}
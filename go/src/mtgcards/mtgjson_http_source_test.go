@@ -0,0 +1,93 @@
+package mtgcards
+
+import "context"
+import "crypto/sha256"
+import "encoding/hex"
+import "io/ioutil"
+import "net/http"
+import "net/http/httptest"
+import "os"
+import "testing"
+import "time"
+
+func newTestHTTPSource(t *testing.T, handler http.HandlerFunc) (*HTTPDataSource, string, func()) {
+    server := httptest.NewServer(handler)
+    dir, err := ioutil.TempDir("", "mtgjson-cache")
+    if err != nil {
+        t.Fatal(err)
+    }
+    oldBackoff := downloadRetryBackoff
+    downloadRetryBackoff = time.Millisecond
+    TakeDownloadStats()
+
+    cleanup := func() {
+        downloadRetryBackoff = oldBackoff
+        os.RemoveAll(dir)
+        server.Close()
+    }
+    return NewHTTPDataSource(server.URL, dir), dir, cleanup
+}
+
+func TestHTTPSourceRejectsCorruptDownloads(t *testing.T) {
+    source, dir, cleanup := newTestHTTPSource(t, func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/version.json.sha256" {
+            w.Write([]byte("0000000000000000000000000000000000000000000000000000000000000000  version.json\n"))
+            return
+        }
+        w.Write([]byte(`{"version": "4.6.3"}`))
+    })
+    defer cleanup()
+
+    if _, err := source.Open(context.Background(), "version.json", FetchOptions{}); err == nil {
+        t.Fatalf("Expected a checksum mismatch to fail the download")
+    }
+    if _, err := os.Stat(dir + "/version.json"); !os.IsNotExist(err) {
+        t.Errorf("Expected no cached copy of a corrupt download, got %v", err)
+    }
+    files, _ := ioutil.ReadDir(dir)
+    if len(files) != 0 {
+        t.Errorf("Expected temporary files to be cleaned up, found %d files", len(files))
+    }
+
+    stats := TakeDownloadStats()
+    if stats.VerificationFailures != downloadAttempts || stats.Retries != downloadAttempts - 1 {
+        t.Errorf("Unexpected download stats %+v", stats)
+    }
+}
+
+func TestHTTPSourceUsesCacheWhenNotModified(t *testing.T) {
+    contents := []byte(`{"version": "4.6.3"}`)
+    sum := sha256.Sum256(contents)
+    requests := 0
+    source, _, cleanup := newTestHTTPSource(t, func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/version.json.sha256" {
+            w.Write([]byte(hex.EncodeToString(sum[:])))
+            return
+        }
+        requests += 1
+        if r.Header.Get("If-None-Match") == `"v1"` {
+            w.WriteHeader(http.StatusNotModified)
+            return
+        }
+        w.Header().Set("ETag", `"v1"`)
+        w.Write(contents)
+    })
+    defer cleanup()
+
+    for i := 0; i < 2; i++ {
+        file, err := source.Open(context.Background(), "version.json", FetchOptions{})
+        if err != nil {
+            t.Fatalf("Unexpected error on fetch %d: %s", i, err)
+        }
+        data, _ := ioutil.ReadAll(file)
+        file.Close()
+        if string(data) != string(contents) {
+            t.Errorf("Unexpected contents on fetch %d: %s", i, data)
+        }
+    }
+
+    stats := TakeDownloadStats()
+    if requests != 2 || stats.Downloads != 1 || stats.NotModified != 1 {
+        t.Errorf("Unexpected requests %d and download stats %+v", requests, stats)
+    }
+}
@@ -0,0 +1,265 @@
+package mtgcards
+
+import "compress/bzip2"
+import "compress/gzip"
+import "context"
+import "encoding/json"
+import "fmt"
+import "io"
+
+// SetReader decodes an AllPrintings file one set at a time, so that only
+// the set currently being handled needs to be in memory rather than the
+// whole file (which runs to gigabytes once decoded)
+type SetReader struct {
+    decoder *json.Decoder
+    closers []io.Closer
+    schema int
+    started bool
+    finished bool
+}
+
+// NewSetReader reads input with the schema version set by
+// ConfigureDownloads
+func NewSetReader(input io.Reader) *SetReader {
+    return NewSetReaderForSchema(input, schemaVersion)
+}
+
+// NewSetReaderForSchema reads input as the given schema version,
+// MTGJSON_V4 or MTGJSON_V5
+func NewSetReaderForSchema(input io.Reader, schema int) *SetReader {
+    return &SetReader{decoder: json.NewDecoder(input), schema: schema}
+}
+
+// OpenAllPrintings downloads AllPrintings (or uses the cached copy), trying
+// the same formats in the same order as DownloadAllPrintings, and returns a
+// reader over its sets.  The reader must be closed once it's no longer
+// needed.
+func OpenAllPrintings(ctx context.Context,
+        useCachedIfAvailable bool,
+        useDebugDownloadLocation bool) (*SetReader, error) {
+    reader, err := openGzSetReader(ctx, useCachedIfAvailable, useDebugDownloadLocation)
+    if err == nil {
+        return reader, nil
+    }
+    logger.Warn("Couldn't open the gzipped file", "url", allPrintingsUrl, "err", err)
+
+    if ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
+
+    reader, err = openBz2SetReader(ctx, useCachedIfAvailable, useDebugDownloadLocation)
+    if err == nil {
+        return reader, nil
+    }
+    logger.Warn("Couldn't open the bzipped file", "url", allPrintingsUrl, "err", err)
+
+    if ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
+
+    file, err := tryDownload(ctx, allPrintingsUrl + jsonExt, useCachedIfAvailable,
+        useDebugDownloadLocation)
+    if err == nil {
+        reader = NewSetReader(file)
+        reader.closers = []io.Closer{file}
+        return reader, nil
+    }
+    logger.Warn("Couldn't open the uncompressed file", "url", allPrintingsUrl, "err", err)
+
+    return nil, fmt.Errorf("Unable to get %s from any sources", allPrintingsUrl)
+}
+
+func openGzSetReader(ctx context.Context,
+        useCachedIfAvailable bool,
+        useDebugDownloadLocation bool) (*SetReader, error) {
+    file, err := tryDownload(ctx, allPrintingsUrl + jsonExt + gzExt, useCachedIfAvailable,
+        useDebugDownloadLocation)
+    if err != nil {
+        return nil, err
+    }
+
+    decompressor, err := gzip.NewReader(file)
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+
+    reader := NewSetReader(decompressor)
+    // Close the decompressor before the file underneath it
+    reader.closers = []io.Closer{decompressor, file}
+    return reader, nil
+}
+
+func openBz2SetReader(ctx context.Context,
+        useCachedIfAvailable bool,
+        useDebugDownloadLocation bool) (*SetReader, error) {
+    file, err := tryDownload(ctx, allPrintingsUrl + jsonExt + bz2Ext, useCachedIfAvailable,
+        useDebugDownloadLocation)
+    if err != nil {
+        return nil, err
+    }
+
+    reader := NewSetReader(bzip2.NewReader(file))
+    reader.closers = []io.Closer{file}
+    return reader, nil
+}
+
+// Next returns the next set in the file, or io.EOF once every set has been
+// read
+func (reader *SetReader) Next() (MTGSet, error) {
+    var set MTGSet
+
+    if reader.finished {
+        return set, io.EOF
+    }
+
+    // AllPrintings is a single object mapping set codes to sets, which in v5
+    // is the data of the envelope
+    if !reader.started {
+        err := reader.expectDelim('{')
+        if err != nil {
+            return set, err
+        }
+        if reader.schema == MTGJSON_V5 {
+            err = reader.skipToData()
+            if err != nil {
+                return set, err
+            }
+        }
+        reader.started = true
+    }
+
+    if !reader.decoder.More() {
+        err := reader.expectDelim('}')
+        if err != nil {
+            return set, err
+        }
+        if reader.schema == MTGJSON_V5 {
+            err = reader.skipToEnd()
+            if err != nil {
+                return set, err
+            }
+        }
+        reader.finished = true
+        return set, io.EOF
+    }
+
+    // Skip over the set code key, it's repeated inside the set itself
+    token, err := reader.decoder.Token()
+    if err != nil {
+        return set, err
+    }
+    if _, ok := token.(string); !ok {
+        return set, fmt.Errorf("Expected a set code, got %v", token)
+    }
+
+    if reader.schema == MTGJSON_V5 {
+        var v5Set mtgjsonV5Set
+        err = reader.decoder.Decode(&v5Set)
+        if err != nil {
+            return set, err
+        }
+        return v5Set.toSet()
+    }
+
+    err = reader.decoder.Decode(&set)
+    if err != nil {
+        return set, err
+    }
+
+    return set, nil
+}
+
+// SendAll reads every remaining set and sends it on sets, closing sets once
+// it's done (whether or not there was an error).  It stops early, without an
+// error, if ctx is cancelled.
+func (reader *SetReader) SendAll(ctx context.Context, sets chan<- MTGSet) error {
+    defer close(sets)
+
+    for {
+        set, err := reader.Next()
+        if err == io.EOF {
+            return nil
+        } else if err != nil {
+            return err
+        }
+
+        select {
+        case <-ctx.Done():
+            return nil
+        case sets <- set:
+        }
+    }
+}
+
+func (reader *SetReader) Close() error {
+    var firstErr error
+    for _, closer := range reader.closers {
+        err := closer.Close()
+        if err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    return firstErr
+}
+
+// skipToData skips over everything in the v5 envelope up to the start of
+// the data object
+func (reader *SetReader) skipToData() error {
+    for reader.decoder.More() {
+        key, err := reader.nextKey()
+        if err != nil {
+            return err
+        }
+        if key == "data" {
+            return reader.expectDelim('{')
+        }
+
+        var skipped json.RawMessage
+        err = reader.decoder.Decode(&skipped)
+        if err != nil {
+            return err
+        }
+    }
+    return fmt.Errorf("No data in AllPrintings")
+}
+
+// skipToEnd skips over anything in the v5 envelope after the data object
+func (reader *SetReader) skipToEnd() error {
+    for reader.decoder.More() {
+        _, err := reader.nextKey()
+        if err != nil {
+            return err
+        }
+
+        var skipped json.RawMessage
+        err = reader.decoder.Decode(&skipped)
+        if err != nil {
+            return err
+        }
+    }
+    return reader.expectDelim('}')
+}
+
+func (reader *SetReader) nextKey() (string, error) {
+    token, err := reader.decoder.Token()
+    if err != nil {
+        return "", err
+    }
+    key, ok := token.(string)
+    if !ok {
+        return "", fmt.Errorf("Expected a key in AllPrintings, got %v", token)
+    }
+    return key, nil
+}
+
+func (reader *SetReader) expectDelim(expected json.Delim) error {
+    token, err := reader.decoder.Token()
+    if err != nil {
+        return err
+    }
+    if delim, ok := token.(json.Delim); !ok || delim != expected {
+        return fmt.Errorf("Expected %v in AllPrintings, got %v", expected, token)
+    }
+    return nil
+}
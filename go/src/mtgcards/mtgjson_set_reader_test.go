@@ -0,0 +1,61 @@
+package mtgcards
+
+import "context"
+import "io"
+import "strings"
+import "testing"
+
+func TestSetReaderYieldsSetsInOrder(t *testing.T) {
+    input := `{
+        "AAA": {"code": "AAA", "name": "First", "cards": [{"name": "Card A"}]},
+        "BBB": {"code": "BBB", "name": "Second", "cards": []}
+    }`
+    reader := NewSetReader(strings.NewReader(input))
+
+    expectedCodes := []string{"AAA", "BBB"}
+    for _, expectedCode := range expectedCodes {
+        set, err := reader.Next()
+        if err != nil {
+            t.Fatalf("Unexpected error reading set %s: %s", expectedCode, err)
+        }
+        if set.Code != expectedCode {
+            t.Errorf("Expected set %s, got %s", expectedCode, set.Code)
+        }
+    }
+
+    if _, err := reader.Next(); err != io.EOF {
+        t.Errorf("Expected io.EOF after the last set, got %v", err)
+    }
+    if _, err := reader.Next(); err != io.EOF {
+        t.Errorf("Expected io.EOF to be sticky, got %v", err)
+    }
+}
+
+func TestSetReaderRejectsNonObject(t *testing.T) {
+    reader := NewSetReader(strings.NewReader(`[{"code": "AAA"}]`))
+    if _, err := reader.Next(); err == nil || err == io.EOF {
+        t.Errorf("Expected an error for a top level array, got %v", err)
+    }
+}
+
+func TestSetReaderSendAll(t *testing.T) {
+    reader := NewSetReader(strings.NewReader(`{"AAA": {"code": "AAA"}, "BBB": {"code": "BBB"}}`))
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    sets := make(chan MTGSet)
+    readErr := make(chan error, 1)
+    go func() {
+        readErr <- reader.SendAll(ctx, sets)
+    }()
+
+    count := 0
+    for range sets {
+        count += 1
+    }
+    if count != 2 {
+        t.Errorf("Expected 2 sets, got %d", count)
+    }
+    if err := <-readErr; err != nil {
+        t.Errorf("Unexpected error: %s", err)
+    }
+}
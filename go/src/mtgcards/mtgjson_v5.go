@@ -0,0 +1,195 @@
+package mtgcards
+
+import "encoding/json"
+import "fmt"
+import "io"
+import "strconv"
+
+// Every MTGJSON v5 file is wrapped in the same envelope, with the build's
+// version in meta and the contents of the file in data
+type mtgjsonV5Envelope struct {
+    Meta json.RawMessage `json:"meta"`
+    Data json.RawMessage `json:"data"`
+}
+
+// In v5, a card's ids from other sites all move into identifiers, as
+// strings
+type mtgjsonV5Identifiers struct {
+    MCMId string `json:"mcmId"`
+    MCMMetaId string `json:"mcmMetaId"`
+    MTGArenaId string `json:"mtgArenaId"`
+    MTGOFoilId string `json:"mtgoFoilId"`
+    MTGOId string `json:"mtgoId"`
+    MultiverseId string `json:"multiverseId"`
+    ScryfallId string `json:"scryfallId"`
+    ScryfallIllustrationId string `json:"scryfallIllustrationId"`
+    ScryfallOracleId string `json:"scryfallOracleId"`
+    TCGPlayerProductId string `json:"tcgplayerProductId"`
+}
+
+// The v5 set, card and token layouts are the v4 ones plus identifiers (and
+// availability, which replaces isArena, isMtgo and isPaper), so they're
+// decoded over the top of the v4 types and then folded back into them
+type mtgjsonV5Set struct {
+    MTGSet
+    Cards []mtgjsonV5Card `json:"cards"`
+    Tokens []mtgjsonV5Token `json:"tokens"`
+}
+
+type mtgjsonV5Card struct {
+    MTGCard
+    Identifiers mtgjsonV5Identifiers `json:"identifiers"`
+    Availability []string `json:"availability"`
+}
+
+type mtgjsonV5Token struct {
+    MTGToken
+    Identifiers mtgjsonV5Identifiers `json:"identifiers"`
+}
+
+// By platform, and then vendor
+type mtgjsonV5CardPrices map[string]map[string]MTGCardVendorPrices
+
+func (v5Set *mtgjsonV5Set) toSet() (MTGSet, error) {
+    set := v5Set.MTGSet
+
+    set.Cards = make([]MTGCard, 0, len(v5Set.Cards))
+    for idx := range v5Set.Cards {
+        card, err := v5Set.Cards[idx].toCard()
+        if err != nil {
+            return set, fmt.Errorf("Card %s in set %s: %s", v5Set.Cards[idx].UUID, set.Code, err)
+        }
+        set.Cards = append(set.Cards, card)
+    }
+
+    set.Tokens = make([]MTGToken, 0, len(v5Set.Tokens))
+    for idx := range v5Set.Tokens {
+        token := v5Set.Tokens[idx].MTGToken
+        v5Set.Tokens[idx].Identifiers.applyCommon(&token.MTGCardCommon)
+        set.Tokens = append(set.Tokens, token)
+    }
+
+    return set, nil
+}
+
+func (v5Card *mtgjsonV5Card) toCard() (MTGCard, error) {
+    card := v5Card.MTGCard
+    identifiers := &v5Card.Identifiers
+    identifiers.applyCommon(&card.MTGCardCommon)
+
+    var err error
+    if card.MCMId, err = parseIdentifier("mcmId", identifiers.MCMId); err != nil {
+        return card, err
+    }
+    if card.MCMMetaId, err = parseIdentifier("mcmMetaId", identifiers.MCMMetaId); err != nil {
+        return card, err
+    }
+    if card.MTGArenaId, err = parseIdentifier("mtgArenaId", identifiers.MTGArenaId); err != nil {
+        return card, err
+    }
+    if card.MTGOFoilId, err = parseIdentifier("mtgoFoilId", identifiers.MTGOFoilId); err != nil {
+        return card, err
+    }
+    if card.MTGOId, err = parseIdentifier("mtgoId", identifiers.MTGOId); err != nil {
+        return card, err
+    }
+    if card.MultiverseId, err = parseIdentifier("multiverseId", identifiers.MultiverseId); err != nil {
+        return card, err
+    }
+    card.TCGPlayerProductId, err = parseIdentifier("tcgplayerProductId",
+        identifiers.TCGPlayerProductId)
+    if err != nil {
+        return card, err
+    }
+
+    if v5Card.Availability != nil {
+        card.IsArena = containsString(v5Card.Availability, "arena")
+        card.IsMTGO = containsString(v5Card.Availability, PLATFORM_MTGO)
+        card.IsPaper = containsString(v5Card.Availability, PLATFORM_PAPER)
+    }
+
+    return card, nil
+}
+
+func (identifiers *mtgjsonV5Identifiers) applyCommon(card *MTGCardCommon) {
+    card.ScryfallId = identifiers.ScryfallId
+    card.ScryfallIllustrationId = identifiers.ScryfallIllustrationId
+    card.ScryfallOracleId = identifiers.ScryfallOracleId
+}
+
+// A missing identifier is 0, the same as a missing id in v4
+func parseIdentifier(name string, value string) (int, error) {
+    if value == "" {
+        return 0, nil
+    }
+    id, err := strconv.Atoi(value)
+    if err != nil {
+        return 0, fmt.Errorf("Unexpected %s %q", name, value)
+    }
+    return id, nil
+}
+
+func containsString(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+func (v5Prices mtgjsonV5CardPrices) toPrices() MTGCardPrices {
+    var prices MTGCardPrices
+    for platform, vendors := range v5Prices {
+        for vendor, vendorPrices := range vendors {
+            vendorPrices.Platform = platform
+            vendorPrices.Vendor = vendor
+            prices.Vendors = append(prices.Vendors, vendorPrices)
+
+            // The same vendors v4's prices came from
+            if platform == PLATFORM_MTGO && vendor == VENDOR_CARDHOARDER {
+                prices.MTGO = vendorPrices.Retail.Normal
+                prices.MTGOFoil = vendorPrices.Retail.Foil
+            } else if platform == PLATFORM_PAPER && vendor == VENDOR_TCGPLAYER {
+                prices.Paper = vendorPrices.Retail.Normal
+                prices.PaperFoil = vendorPrices.Retail.Foil
+            }
+        }
+    }
+    return prices
+}
+
+func decodeV5Prices(input io.Reader) (interface{}, error) {
+    decoder := json.NewDecoder(input)
+    var envelope struct {
+        Data map[string]mtgjsonV5CardPrices `json:"data"`
+    }
+    if err := decoder.Decode(&envelope); err != nil {
+        return nil, err
+    }
+
+    result := make(map[string]MTGCardPrices, len(envelope.Data))
+    for uuid, v5Prices := range envelope.Data {
+        result[uuid] = v5Prices.toPrices()
+    }
+    return result, nil
+}
+
+// Meta.json has the same date and version as v4's version.json, but inside
+// the envelope
+func decodeV5Version(input io.Reader) (interface{}, error) {
+    decoder := json.NewDecoder(input)
+    var envelope mtgjsonV5Envelope
+    if err := decoder.Decode(&envelope); err != nil {
+        return nil, err
+    }
+    if envelope.Data == nil {
+        return nil, fmt.Errorf("No data in Meta")
+    }
+
+    var result MTGJSONVersion
+    if err := json.Unmarshal(envelope.Data, &result); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
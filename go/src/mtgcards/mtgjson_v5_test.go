@@ -0,0 +1,102 @@
+package mtgcards
+
+import "io"
+import "strings"
+import "testing"
+
+func TestV5SetReaderUnwrapsEnvelope(t *testing.T) {
+    input := `{
+        "meta": {"date": "2020-06-01", "version": "5.0.0+20200601"},
+        "data": {
+            "AAA": {
+                "code": "AAA",
+                "cards": [{
+                    "name": "Card A",
+                    "uuid": "uuid-a",
+                    "availability": ["mtgo", "paper"],
+                    "identifiers": {"scryfallId": "scryfall-a", "mtgoId": "123", "multiverseId": "456"},
+                    "purchaseUrls": {"tcgplayer": "https://example.com/a"}
+                }],
+                "tokens": [{"name": "Token A", "uuid": "uuid-t", "identifiers": {"scryfallId": "scryfall-t"}}],
+                "sealedProduct": [{"name": "Booster Box", "uuid": "uuid-s", "category": "booster_box"}]
+            }
+        },
+        "trailing": {}
+    }`
+    reader := NewSetReaderForSchema(strings.NewReader(input), MTGJSON_V5)
+
+    set, err := reader.Next()
+    if err != nil {
+        t.Fatalf("Unexpected error reading set: %s", err)
+    }
+    if set.Code != "AAA" || len(set.Cards) != 1 || len(set.Tokens) != 1 || len(set.SealedProduct) != 1 {
+        t.Fatalf("Unexpected set %+v", set)
+    }
+
+    card := set.Cards[0]
+    if card.ScryfallId != "scryfall-a" || card.MTGOId != 123 || card.MultiverseId != 456 {
+        t.Errorf("Identifiers weren't applied to the card: %+v", card)
+    }
+    if !card.IsMTGO || !card.IsPaper || card.IsArena {
+        t.Errorf("Availability wasn't applied to the card: %+v", card)
+    }
+    if card.PurchaseURLs["tcgplayer"] != "https://example.com/a" {
+        t.Errorf("Unexpected purchase urls %v", card.PurchaseURLs)
+    }
+    if set.Tokens[0].ScryfallId != "scryfall-t" {
+        t.Errorf("Identifiers weren't applied to the token: %+v", set.Tokens[0])
+    }
+
+    if _, err := reader.Next(); err != io.EOF {
+        t.Errorf("Expected io.EOF after the last set, got %v", err)
+    }
+}
+
+func TestV5PricesFillRetailFromDefaultVendors(t *testing.T) {
+    input := `{"meta": {}, "data": {"uuid-a": {
+        "mtgo": {"cardhoarder": {"currency": "USD", "retail": {"normal": {"2020-06-01": 0.5}}}},
+        "paper": {
+            "tcgplayer": {"currency": "USD", "retail": {"foil": {"2020-06-01": 4.0}}},
+            "cardmarket": {"currency": "EUR", "buylist": {"normal": {"2020-06-01": 1.0}}}
+        }
+    }}}`
+
+    result, err := decodeV5Prices(strings.NewReader(input))
+    if err != nil {
+        t.Fatalf("Unexpected error decoding prices: %s", err)
+    }
+    prices := result.(map[string]MTGCardPrices)["uuid-a"]
+
+    if len(prices.MTGO) != 1 || prices.MTGO[0].Price != 0.5 {
+        t.Errorf("Unexpected MTGO prices %v", prices.MTGO)
+    }
+    if len(prices.PaperFoil) != 1 || prices.PaperFoil[0].Price != 4.0 {
+        t.Errorf("Unexpected paper foil prices %v", prices.PaperFoil)
+    }
+    if len(prices.Vendors) != 3 {
+        t.Fatalf("Expected 3 vendors, got %d", len(prices.Vendors))
+    }
+    for _, vendor := range prices.Vendors {
+        if vendor.Vendor == "cardmarket" &&
+                (vendor.Platform != "paper" || vendor.Currency != "EUR" || len(vendor.Buylist.Normal) != 1) {
+            t.Errorf("Unexpected cardmarket prices %+v", vendor)
+        }
+    }
+}
+
+func TestV5VersionHasNoSeparatePricesDate(t *testing.T) {
+    input := `{"meta": {"date": "2020-06-01", "version": "5.0.1+20200601"},
+        "data": {"date": "2020-06-01", "version": "5.0.1+20200601"}}`
+
+    result, err := decodeV5Version(strings.NewReader(input))
+    if err != nil {
+        t.Fatalf("Unexpected error decoding version: %s", err)
+    }
+    version := result.(MTGJSONVersion)
+    if version.VersionMajor != 5 || version.VersionPatch != 1 {
+        t.Errorf("Unexpected version %v", version)
+    }
+    if !version.PricesDate.Equal(version.BuildDate) {
+        t.Errorf("Expected the prices date to be the build date, got %v", version)
+    }
+}
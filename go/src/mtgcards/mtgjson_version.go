@@ -44,9 +44,15 @@ func (version *MTGJSONVersion) UnmarshalJSON(data []byte) error {
     if err != nil {
         return err
     }
-    version.PricesDate, err = time.Parse("2006-01-02", dummyVersion.PricesDate)
-    if err != nil {
-        return err
+    // MTGJSON v5 rebuilds prices along with everything else, so there's no
+    // separate date for them
+    if dummyVersion.PricesDate == "" {
+        version.PricesDate = version.BuildDate
+    } else {
+        version.PricesDate, err = time.Parse("2006-01-02", dummyVersion.PricesDate)
+        if err != nil {
+            return err
+        }
     }
 
     // Version can either be just a semver version number,
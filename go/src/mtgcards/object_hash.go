@@ -63,6 +63,12 @@ func objectHash(object interface{}) string {
             if unicode.IsLower(firstRune) {
                 continue
             }
+            // And fields tagged with hash:"-", which are left out so that
+            // adding them doesn't change every hash that's already stored
+            structField, _ := objectType.FieldByName(fieldName)
+            if structField.Tag.Get("hash") == "-" {
+                continue
+            }
 
             field := objectValue.FieldByName(fieldName).Interface()
             hash.Write([]byte(objectHash(field)))
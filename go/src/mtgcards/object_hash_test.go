@@ -1,5 +1,6 @@
 package mtgcards
 
+import "context"
 import "fmt"
 import "testing"
 import "math/rand"
@@ -8,7 +9,7 @@ import "unicode"
 import "unicode/utf8"
 
 func loadTestCardData() (map[string]MTGSet, error) {
-    testCardDataGz, err := tryGz(true, true, "TestCardData", decodeSets)
+    testCardDataGz, err := tryGz(context.Background(), true, true, "TestCardData", decodeSets)
     if err != nil {
         return nil, err
     }
@@ -204,3 +205,23 @@ func TestObjectHashStability(t *testing.T) {
         }
     }
 }
+
+func TestObjectHashSkipsTaggedFields(t *testing.T) {
+    type before struct {
+        Code string
+    }
+    type after struct {
+        Code string
+        Added []string `hash:"-"`
+    }
+
+    if objectHash(before{Code: "AAA"}) != objectHash(after{Code: "AAA", Added: []string{"a"}}) {
+        t.Error("Expected a field tagged hash:\"-\" not to change the hash")
+    }
+
+    set := MTGSet{Code: "AAA"}
+    withProduct := MTGSet{Code: "AAA", SealedProduct: []MTGSealedProduct{{Name: "Booster"}}}
+    if set.Hash() != withProduct.Hash() {
+        t.Error("Expected sealed product not to change a set's hash")
+    }
+}
@@ -1,17 +1,64 @@
 package main
 
 import "backend"
+import "config"
+import "dbconn"
+import "flag"
 import "net/http"
-import "log"
+import "logging"
+import "metrics"
+import "migrations"
+
+var logger = logging.For("organizer-backend")
 
 func main() {
+    configPath := flag.String("config", "", "Path to the JSON config file")
+    flag.Parse()
+
+    backendConfig, err := config.Load(*configPath)
+    if err != nil {
+        logging.Fatal(logger, "Error loading config", "err", err)
+    }
+    err = backendConfig.ConfigureLogging()
+    if err != nil {
+        logging.Fatal(logger, "Error configuring logging", "err", err)
+    }
+    // net/http logs things like TLS handshake errors through the standard
+    // logger
+    logging.RedirectStdLog(logger)
+    backend.Configure(backendConfig)
+
+    err = checkSchema(backendConfig.CardDB, &migrations.CardDBSchema)
+    if err != nil {
+        logging.Fatal(logger, "Card db schema check failed", "err", err)
+    }
+    err = checkSchema(backendConfig.UserDB, &migrations.UsersDBSchema)
+    if err != nil {
+        logging.Fatal(logger, "Users db schema check failed", "err", err)
+    }
+
     http.HandleFunc("/backend/api", backend.HandleApi)
     http.HandleFunc("/backend/login/challenge", backend.HandleLoginChallenge)
     http.HandleFunc("/backend/login/creds", backend.HandleLoginCredentials)
     http.HandleFunc("/backend/consent/challenge", backend.HandleConsentChallenge)
     http.HandleFunc("/backend/logout/challenge", backend.HandleLogoutChallenge)
-    log.Printf("Starting listener...\n")
-    http.ListenAndServe(":8085", nil)
-    log.Printf("Exiting backend...\n")
+    http.HandleFunc(backend.SHARE_JSON_PATH, backend.HandleSharedListJson)
+    http.HandleFunc(backend.SHARE_VIEW_PATH, backend.HandleSharedListPage)
+    http.Handle(backend.METRICS_PATH, metrics.Handler())
+    logger.Info("Starting listener", "address", backendConfig.Backend.ListenAddress)
+    err = http.ListenAndServe(backendConfig.Backend.ListenAddress, nil)
+    if err != nil {
+        logger.Error("Listener stopped", "err", err)
+    }
+    logger.Info("Exiting backend")
 }
 
+func checkSchema(dbConfig config.DatabaseConfig, schema *migrations.Schema) error {
+    db, err := dbconn.Open(dbConfig)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    return migrations.CheckSchema(db, schema)
+}
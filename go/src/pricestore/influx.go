@@ -0,0 +1,165 @@
+package pricestore
+
+import "config"
+import "encoding/json"
+import "fmt"
+import influx "github.com/influxdata/influxdb1-client/v2"
+import "sort"
+import "strings"
+import "time"
+
+type InfluxPriceStore struct {
+    client influx.Client
+    database string
+}
+
+func NewInfluxPriceStore(pricesConfig config.PricesDBConfig) (*InfluxPriceStore, error) {
+    influxClientConfig := influx.HTTPConfig{
+        Addr: pricesConfig.Address,
+        Username: pricesConfig.User,
+        Password: pricesConfig.Password}
+
+    client, err := influx.NewHTTPClient(influxClientConfig)
+    if err != nil {
+        return nil, err
+    }
+
+    return &InfluxPriceStore{client: client, database: pricesConfig.Database}, nil
+}
+
+func (store *InfluxPriceStore) WritePoints(points []Point) error {
+    bpConfig := influx.BatchPointsConfig{Database: store.database}
+    bp, err := influx.NewBatchPoints(bpConfig)
+    if err != nil {
+        return err
+    }
+
+    for _, point := range points {
+        influxPoint, err := influx.NewPoint(
+            point.Measurement,
+            point.Tags,
+            point.Fields,
+            point.Time)
+        if err != nil {
+            return err
+        }
+        bp.AddPoint(influxPoint)
+    }
+
+    return store.client.Write(bp)
+}
+
+func (store *InfluxPriceStore) QueryRange(measurement string,
+        tags map[string]string,
+        field string,
+        start time.Time,
+        end time.Time) ([]Point, error) {
+    whereClause, parameters := influxWhereClause(tags)
+    parameters["start"] = start.UTC().Format(time.RFC3339Nano)
+    parameters["end"] = end.UTC().Format(time.RFC3339Nano)
+
+    command := fmt.Sprintf(`SELECT %s FROM %s WHERE %s time >= $start AND time <= $end ORDER BY time ASC`,
+        quoteInfluxIdentifier(field), quoteInfluxIdentifier(measurement), whereClause)
+
+    return store.query(command, parameters, measurement, tags, field)
+}
+
+func (store *InfluxPriceStore) QueryLatest(measurement string,
+        tags map[string]string,
+        field string) (Point, bool, error) {
+    whereClause, parameters := influxWhereClause(tags)
+    // An empty where clause isn't valid, and every point has a time
+    whereClause += " time > 0"
+
+    command := fmt.Sprintf(`SELECT %s FROM %s WHERE %s ORDER BY time DESC LIMIT 1`,
+        quoteInfluxIdentifier(field), quoteInfluxIdentifier(measurement), whereClause)
+
+    points, err := store.query(command, parameters, measurement, tags, field)
+    if err != nil || len(points) == 0 {
+        return Point{}, false, err
+    }
+
+    return points[0], true, nil
+}
+
+func (store *InfluxPriceStore) Close() error {
+    return store.client.Close()
+}
+
+func (store *InfluxPriceStore) query(command string,
+        parameters map[string]interface{},
+        measurement string,
+        tags map[string]string,
+        field string) ([]Point, error) {
+    query := influx.NewQueryWithParameters(command, store.database, "", parameters)
+    response, err := store.client.Query(query)
+    if err != nil {
+        return nil, err
+    }
+    if response.Error() != nil {
+        return nil, response.Error()
+    }
+
+    points := make([]Point, 0)
+    for _, result := range response.Results {
+        for _, series := range result.Series {
+            for _, row := range series.Values {
+                // Rows come back as [time, field]
+                if len(row) != 2 {
+                    return nil, fmt.Errorf("Unexpected influx row %v", row)
+                }
+
+                timeString, ok := row[0].(string)
+                if !ok {
+                    return nil, fmt.Errorf("Unexpected influx time %v", row[0])
+                }
+                pointTime, err := time.Parse(time.RFC3339Nano, timeString)
+                if err != nil {
+                    return nil, err
+                }
+
+                value := row[1]
+                if number, isNumber := value.(json.Number); isNumber {
+                    value, err = number.Float64()
+                    if err != nil {
+                        return nil, err
+                    }
+                }
+
+                points = append(points, Point{
+                    Measurement: measurement,
+                    Tags: tags,
+                    Fields: map[string]interface{}{field: value},
+                    Time: pointTime})
+            }
+        }
+    }
+
+    return points, nil
+}
+
+// Tag values are passed as bound parameters, but the tag keys have to be
+// written into the query itself, so they're sorted to keep the query stable
+func influxWhereClause(tags map[string]string) (string, map[string]interface{}) {
+    tagKeys := make([]string, 0, len(tags))
+    for tagKey := range tags {
+        tagKeys = append(tagKeys, tagKey)
+    }
+    sort.Strings(tagKeys)
+
+    var clause strings.Builder
+    parameters := make(map[string]interface{})
+    for idx, tagKey := range tagKeys {
+        parameterName := fmt.Sprintf("tag%d", idx)
+        fmt.Fprintf(&clause, "%s = $%s AND ", quoteInfluxIdentifier(tagKey), parameterName)
+        parameters[parameterName] = tags[tagKey]
+    }
+
+    return clause.String(), parameters
+}
+
+func quoteInfluxIdentifier(identifier string) string {
+    escaped := strings.ReplaceAll(identifier, `\`, `\\`)
+    escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+    return `"` + escaped + `"`
+}
@@ -0,0 +1,61 @@
+package pricestore
+
+import "config"
+import "dbconn"
+import "fmt"
+import "time"
+
+// A Point is a single timestamped set of values in a series.  A series is
+// identified by its measurement (e.g. "paper" or "card_updates") and its
+// tags (e.g. the card uuid for prices).
+type Point struct {
+    Measurement string
+    Tags map[string]string
+    Fields map[string]interface{}
+    Time time.Time
+}
+
+// PriceStore is where card prices, and the stats from each update run, are
+// kept.  Writing a point with the same series and time as an existing one
+// replaces its values.
+type PriceStore interface {
+    // WritePoints stores all of points, as a single batch where possible
+    WritePoints(points []Point) error
+
+    // QueryRange returns the value of field for the series identified by
+    // measurement and tags, for every point between start and end
+    // (inclusive), oldest first.  tags should be the full set of tags the
+    // points were written with.
+    QueryRange(measurement string,
+        tags map[string]string,
+        field string,
+        start time.Time,
+        end time.Time) ([]Point, error)
+
+    // QueryLatest returns the most recent value of field for the series,
+    // and false if the series has no points at all
+    QueryLatest(measurement string,
+        tags map[string]string,
+        field string) (Point, bool, error)
+
+    Close() error
+}
+
+// Open returns the price store described by storeConfig.Store.  The SQL
+// store keeps its points in the card database.
+func Open(storeConfig *config.Config) (PriceStore, error) {
+    switch storeConfig.PricesDB.Store {
+    case config.INFLUX_PRICE_STORE:
+        return NewInfluxPriceStore(storeConfig.PricesDB)
+
+    case config.SQL_PRICE_STORE:
+        db, err := dbconn.Open(storeConfig.CardDB)
+        if err != nil {
+            return nil, err
+        }
+        return NewSQLPriceStore(db), nil
+
+    default:
+        return nil, fmt.Errorf("Unknown price store %q", storeConfig.PricesDB.Store)
+    }
+}
@@ -0,0 +1,165 @@
+package pricestore
+
+import "database/sql"
+import "dbconn"
+import "fmt"
+import "sort"
+import "strings"
+import "time"
+
+// SQLPriceStore keeps points in the price_points table of the card
+// database, one row per field.  Every value is stored as a double, so
+// integer and bool fields come back as float64.
+type SQLPriceStore struct {
+    db *sql.DB
+}
+
+func NewSQLPriceStore(db *sql.DB) *SQLPriceStore {
+    return &SQLPriceStore{db: db}
+}
+
+func (store *SQLPriceStore) WritePoints(points []Point) error {
+    tx, err := store.db.Begin()
+    if err != nil {
+        return err
+    }
+
+    // Rewriting a point replaces it, the same as it does in influx
+    insertQuery, err := tx.Prepare(`INSERT INTO price_points
+        (measurement, tags, field, value, point_time)
+        VALUES
+        (?, ?, ?, ?, ?)
+        ` + dbconn.OnDuplicateUpdate(store.db,
+            "measurement, tags, field, point_time", "value"))
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+    defer insertQuery.Close()
+
+    for _, point := range points {
+        encodedTags := encodeTags(point.Tags)
+        for field, fieldValue := range point.Fields {
+            value, err := fieldValueToFloat(fieldValue)
+            if err != nil {
+                tx.Rollback()
+                return fmt.Errorf("Field %s of %s: %s", field, point.Measurement, err)
+            }
+
+            _, err = insertQuery.Exec(point.Measurement, encodedTags, field, value,
+                point.Time.UTC())
+            if err != nil {
+                tx.Rollback()
+                return err
+            }
+        }
+    }
+
+    return tx.Commit()
+}
+
+func (store *SQLPriceStore) QueryRange(measurement string,
+        tags map[string]string,
+        field string,
+        start time.Time,
+        end time.Time) ([]Point, error) {
+    rows, err := store.db.Query(`SELECT value, point_time
+        FROM price_points
+        WHERE measurement = ? AND tags = ? AND field = ?
+        AND point_time >= ? AND point_time <= ?
+        ORDER BY point_time ASC`,
+        measurement, encodeTags(tags), field, start.UTC(), end.UTC())
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    points := make([]Point, 0)
+    for rows.Next() {
+        var value float64
+        var pointTime time.Time
+        err = rows.Scan(&value, &pointTime)
+        if err != nil {
+            return nil, err
+        }
+
+        points = append(points, Point{
+            Measurement: measurement,
+            Tags: tags,
+            Fields: map[string]interface{}{field: value},
+            Time: pointTime})
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return points, nil
+}
+
+func (store *SQLPriceStore) QueryLatest(measurement string,
+        tags map[string]string,
+        field string) (Point, bool, error) {
+    var value float64
+    var pointTime time.Time
+    res := store.db.QueryRow(`SELECT value, point_time
+        FROM price_points
+        WHERE measurement = ? AND tags = ? AND field = ?
+        ORDER BY point_time DESC
+        LIMIT 1`,
+        measurement, encodeTags(tags), field)
+    err := res.Scan(&value, &pointTime)
+    if err == sql.ErrNoRows {
+        return Point{}, false, nil
+    } else if err != nil {
+        return Point{}, false, err
+    }
+
+    return Point{
+        Measurement: measurement,
+        Tags: tags,
+        Fields: map[string]interface{}{field: value},
+        Time: pointTime}, true, nil
+}
+
+func (store *SQLPriceStore) Close() error {
+    return store.db.Close()
+}
+
+// Tags are stored as a single canonical "key=value,key=value" string, sorted
+// by key, so that a series can be matched with a plain equality check
+func encodeTags(tags map[string]string) string {
+    tagKeys := make([]string, 0, len(tags))
+    for tagKey := range tags {
+        tagKeys = append(tagKeys, tagKey)
+    }
+    sort.Strings(tagKeys)
+
+    escaper := strings.NewReplacer(`\`, `\\`, `,`, `\,`, `=`, `\=`)
+    encodedTags := make([]string, 0, len(tags))
+    for _, tagKey := range tagKeys {
+        encodedTags = append(encodedTags,
+            escaper.Replace(tagKey) + "=" + escaper.Replace(tags[tagKey]))
+    }
+
+    return strings.Join(encodedTags, ",")
+}
+
+func fieldValueToFloat(fieldValue interface{}) (float64, error) {
+    switch value := fieldValue.(type) {
+    case float64:
+        return value, nil
+    case float32:
+        return float64(value), nil
+    case int:
+        return float64(value), nil
+    case int64:
+        return float64(value), nil
+    case bool:
+        if value {
+            return 1, nil
+        }
+        return 0, nil
+    default:
+        return 0, fmt.Errorf("Unsupported field type %T", fieldValue)
+    }
+}
@@ -0,0 +1,158 @@
+package pricestore
+
+import "config"
+import "dbconn"
+import "io/ioutil"
+import "migrations"
+import "os"
+import "path/filepath"
+import "testing"
+import "time"
+
+func openTestPriceStore(tb testing.TB) (*SQLPriceStore, func()) {
+    dir, err := ioutil.TempDir("", "pricestore")
+    if err != nil {
+        tb.Fatal(err)
+    }
+    dbConfig := config.DatabaseConfig{
+        Driver: config.SQLITE_DRIVER,
+        Path: filepath.Join(dir, "cards.db")}
+
+    db, err := dbconn.Open(dbConfig)
+    if err != nil {
+        os.RemoveAll(dir)
+        tb.Fatal(err)
+    }
+    cleanup := func() {
+        db.Close()
+        os.RemoveAll(dir)
+    }
+
+    err = migrations.Migrate(db, dbConfig.Driver, &migrations.CardDBSchema,
+        migrations.CardDBSchema.LatestVersion())
+    if err != nil {
+        cleanup()
+        tb.Fatal(err)
+    }
+    return NewSQLPriceStore(db), cleanup
+}
+
+func TestEncodeTagsIsCanonical(t *testing.T) {
+    first := encodeTags(map[string]string{"card": "abc", "vendor": "tcgplayer"})
+    second := encodeTags(map[string]string{"vendor": "tcgplayer", "card": "abc"})
+    if first != second {
+        t.Errorf("Tag encoding depends on map order: %q vs %q", first, second)
+    }
+    if first != "card=abc,vendor=tcgplayer" {
+        t.Errorf("Unexpected tag encoding %q", first)
+    }
+}
+
+func TestEncodeTagsEscapesSeparators(t *testing.T) {
+    ambiguous := encodeTags(map[string]string{"a": "b,c=d"})
+    split := encodeTags(map[string]string{"a": "b", "c": "d"})
+    if ambiguous == split {
+        t.Errorf("Tags with separators in them collide with other tag sets: %q", ambiguous)
+    }
+}
+
+func TestFieldValueToFloat(t *testing.T) {
+    values := map[interface{}]float64{
+        1.5: 1.5,
+        3: 3,
+        int64(4): 4,
+        true: 1,
+        false: 0}
+
+    for fieldValue, expected := range values {
+        value, err := fieldValueToFloat(fieldValue)
+        if err != nil {
+            t.Errorf("Unexpected error for %v: %s", fieldValue, err)
+        } else if value != expected {
+            t.Errorf("Expected %v for %v, got %v", expected, fieldValue, value)
+        }
+    }
+
+    if _, err := fieldValueToFloat("text"); err == nil {
+        t.Errorf("Expected an error for a string field")
+    }
+}
+
+func TestSQLPriceStoreWriteAndQuery(t *testing.T) {
+    store, cleanup := openTestPriceStore(t)
+    defer cleanup()
+
+    tags := map[string]string{"uuid": "abc", "vendor": "tcgplayer"}
+    otherTags := map[string]string{"uuid": "def", "vendor": "tcgplayer"}
+    start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+    var points []Point
+    for day := 0; day < 3; day++ {
+        points = append(points, Point{
+            Measurement: "card_prices",
+            Tags: tags,
+            Fields: map[string]interface{}{"normal": float64(day + 1), "foil": 10},
+            Time: start.AddDate(0, 0, day)})
+    }
+    points = append(points, Point{
+        Measurement: "card_prices",
+        Tags: otherTags,
+        Fields: map[string]interface{}{"normal": 100.0},
+        Time: start.AddDate(0, 0, 5)})
+
+    if err := store.WritePoints(points); err != nil {
+        t.Fatal(err)
+    }
+
+    // Rewriting a point replaces its value rather than adding another row
+    rewrite := Point{
+        Measurement: "card_prices",
+        Tags: map[string]string{"vendor": "tcgplayer", "uuid": "abc"},
+        Fields: map[string]interface{}{"normal": 2.5},
+        Time: start.AddDate(0, 0, 1)}
+    if err := store.WritePoints([]Point{rewrite}); err != nil {
+        t.Fatal(err)
+    }
+
+    rangePoints, err := store.QueryRange("card_prices", tags, "normal",
+        start, start.AddDate(0, 0, 1))
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []float64{1, 2.5}
+    if len(rangePoints) != len(expected) {
+        t.Fatalf("Expected %d points, got %v", len(expected), rangePoints)
+    }
+    for idx, point := range rangePoints {
+        if point.Fields["normal"] != expected[idx] {
+            t.Errorf("Expected point %d to be %v, got %v", idx, expected[idx], point.Fields["normal"])
+        }
+        if !point.Time.Equal(start.AddDate(0, 0, idx)) {
+            t.Errorf("Expected point %d at %s, got %s", idx, start.AddDate(0, 0, idx), point.Time)
+        }
+    }
+
+    latest, found, err := store.QueryLatest("card_prices", tags, "normal")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !found || latest.Fields["normal"] != 3.0 || !latest.Time.Equal(start.AddDate(0, 0, 2)) {
+        t.Errorf("Expected the latest point to be 3 on %s, got %v", start.AddDate(0, 0, 2), latest)
+    }
+
+    // Integer fields come back as float64
+    latest, found, err = store.QueryLatest("card_prices", tags, "foil")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !found || latest.Fields["foil"] != 10.0 {
+        t.Errorf("Expected the latest foil price to be 10, got %v", latest)
+    }
+
+    _, found, err = store.QueryLatest("card_prices", map[string]string{"uuid": "abc"}, "normal")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if found {
+        t.Error("Expected nothing for a partial tag set")
+    }
+}
@@ -17,7 +17,7 @@ CREATE TABLE mtg_cards.all_cards (
 	duel_deck CHAR(1) NULL COLLATE utf8mb4_general_ci,
 	edhrec_rank INTEGER NULL,
 	face_converted_mana_cost FLOAT NOT NULL,
-	flavor_name VARCHAR(100) NULL COLLAGE utf8mb4_general_ci, #Max existing len: 30
+	flavor_name VARCHAR(100) NULL COLLATE utf8mb4_general_ci, #Max existing len: 30
 	flavor_text VARCHAR(2000) NULL COLLATE utf8mb4_general_ci, #Max existing len: 1000
 	frame_version VARCHAR(20) NOT NULL COLLATE utf8mb4_general_ci, #Max existing len: 6
 	hand VARCHAR(10) NULL COLLATE utf8mb4_general_ci, #Max existing len: 2